@@ -15,6 +15,16 @@ import (
 
 func main() {
 	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "admin" {
+		if err := runner.RunAdmin(args[1:], os.Stdin, os.Stdout); err != nil {
+			fmt.Println("Fatal error:", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if len(args) > 0 && args[0] == "import" {
+		os.Exit(runImport(args[1:]))
+	}
 	if shouldProceed := runner.ParseArgs(args); !shouldProceed {
 		os.Exit(0)
 	}
@@ -37,3 +47,20 @@ func main() {
 	}
 	os.Exit(0)
 }
+
+func runImport(args []string) int {
+	cfg, err := runner.ParseImportFlags(args, os.Stdout)
+	if err != nil {
+		fmt.Println("Fatal error: cannot parse flags: ", err)
+		return 1
+	}
+	if err = log.Init(cfg.LogCfg); err != nil {
+		fmt.Println("Fatal error: cannot start logger: ", err)
+		return 1
+	}
+	if err = runner.RunImport(cfg); err != nil {
+		log.Error("msg", "Import failed", "err", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,254 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/route"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/admin"
+)
+
+const (
+	retentionPeriodSetting = "retention_period"
+	chunkIntervalSetting   = "chunk_interval"
+	compressionSetting     = "compression"
+)
+
+// MetricAdmin manages the storage settings of metrics.
+type MetricAdmin interface {
+	MetricSettings(metric string) (admin.MetricSettings, error)
+	AllMetricSettings() ([]admin.MetricSettings, error)
+	SetRetentionPeriod(metric string, retentionPeriod time.Duration) error
+	ResetRetentionPeriod(metric string) error
+	SetChunkInterval(metric string, chunkInterval time.Duration) error
+	ResetChunkInterval(metric string) error
+	SetCompression(metric string, compression bool) error
+	ResetCompression(metric string) error
+}
+
+// MetricSettings returns the effective storage settings of the metric given by the
+// name route parameter, or of all metrics if there is no such parameter.
+func MetricSettings(conf *Config, metricAdmin MetricAdmin) http.Handler {
+	hf := corsWrapper(conf, adminWrapper(conf, false, metricSettingsHandler(metricAdmin)))
+	return gziphandler.GzipHandler(hf)
+}
+
+// UpdateMetricSetting sets the given storage setting of the metric given by the
+// name route parameter to the value form parameter. DELETE requests reset the
+// setting to the default.
+func UpdateMetricSetting(conf *Config, metricAdmin MetricAdmin, setting string) http.Handler {
+	hf := corsWrapper(conf, adminWrapper(conf, true, updateMetricSettingHandler(metricAdmin, setting)))
+	return gziphandler.GzipHandler(hf)
+}
+
+// DropMetrics drops all metrics whose name matches the match form parameter,
+// which is a fully anchored regex. With the dry_run form parameter set, the
+// metrics are only listed.
+func DropMetrics(conf *Config, dropper admin.MetricDropper) http.Handler {
+	hf := corsWrapper(conf, adminWrapper(conf, true, dropMetricsHandler(dropper)))
+	return gziphandler.GzipHandler(hf)
+}
+
+// adminWrapper only lets requests through if the admin API is enabled and, for
+// requests that modify the database, if the connector is not read-only.
+func adminWrapper(conf *Config, modifies bool, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if modifies && conf.ReadOnly {
+			respondError(w, http.StatusForbidden, fmt.Errorf("read-only connector cannot perform admin operations"), "operation_not_permitted")
+			return
+		}
+		if !conf.AdminAPIEnabled {
+			respondError(w, http.StatusForbidden, fmt.Errorf("admin operations require admin permissions. Use -web-enable-admin-api flag to allow admin operations"), "operation_not_permitted")
+			return
+		}
+		h(w, r)
+	}
+}
+
+func metricSettingsHandler(metricAdmin MetricAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := route.Param(r.Context(), "name")
+		if name == "" {
+			settings, err := metricAdmin.AllMetricSettings()
+			if err != nil {
+				log.Error("msg", "Fetching metric settings failed", "err", err)
+				respondError(w, http.StatusInternalServerError, err, "fetching_settings")
+				return
+			}
+			respond(w, http.StatusOK, settings)
+			return
+		}
+		if !model.IsValidMetricName(model.LabelValue(name)) {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("invalid metric name: %s", name), "bad_data")
+			return
+		}
+		respondMetricSettings(w, metricAdmin, name)
+	}
+}
+
+func updateMetricSettingHandler(metricAdmin MetricAdmin, setting string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := route.Param(r.Context(), "name")
+		if !model.IsValidMetricName(model.LabelValue(name)) {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("invalid metric name: %s", name), "bad_data")
+			return
+		}
+
+		var err error
+		if r.Method == http.MethodDelete {
+			err = resetMetricSetting(metricAdmin, name, setting)
+		} else {
+			if err = r.ParseForm(); err != nil {
+				respondError(w, http.StatusBadRequest, err, "bad_data")
+				return
+			}
+			value := r.FormValue("value")
+			if value == "" {
+				respondError(w, http.StatusBadRequest, fmt.Errorf("no value parameter provided"), "bad_data")
+				return
+			}
+			err = setMetricSetting(metricAdmin, name, setting, value)
+		}
+		if _, ok := err.(invalidSettingError); ok {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		if err != nil {
+			log.Error("msg", "Updating metric setting failed", "metric", name, "setting", setting, "err", err)
+			respondError(w, http.StatusInternalServerError, err, "updating_setting")
+			return
+		}
+		respondMetricSettings(w, metricAdmin, name)
+	}
+}
+
+type invalidSettingError struct {
+	error
+}
+
+func setMetricSetting(metricAdmin MetricAdmin, metric, setting, value string) error {
+	switch setting {
+	case retentionPeriodSetting, chunkIntervalSetting:
+		d, err := model.ParseDuration(value)
+		if err != nil {
+			return invalidSettingError{fmt.Errorf("invalid value for '%s': %w", setting, err)}
+		}
+		if d <= 0 {
+			return invalidSettingError{fmt.Errorf("'%s' must be greater than 0", setting)}
+		}
+		if setting == retentionPeriodSetting {
+			return metricAdmin.SetRetentionPeriod(metric, time.Duration(d))
+		}
+		return metricAdmin.SetChunkInterval(metric, time.Duration(d))
+	case compressionSetting:
+		compression, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalidSettingError{fmt.Errorf("invalid value for '%s': %w", setting, err)}
+		}
+		return metricAdmin.SetCompression(metric, compression)
+	}
+	return fmt.Errorf("unknown setting '%s'", setting)
+}
+
+func resetMetricSetting(metricAdmin MetricAdmin, metric, setting string) error {
+	switch setting {
+	case retentionPeriodSetting:
+		return metricAdmin.ResetRetentionPeriod(metric)
+	case chunkIntervalSetting:
+		return metricAdmin.ResetChunkInterval(metric)
+	case compressionSetting:
+		return metricAdmin.ResetCompression(metric)
+	}
+	return fmt.Errorf("unknown setting '%s'", setting)
+}
+
+func respondMetricSettings(w http.ResponseWriter, metricAdmin MetricAdmin, metric string) {
+	settings, err := metricAdmin.MetricSettings(metric)
+	if err == admin.ErrMetricNotFound {
+		respondError(w, http.StatusNotFound, fmt.Errorf("metric '%s' not found", metric), "not_found")
+		return
+	}
+	if err != nil {
+		log.Error("msg", "Fetching metric settings failed", "metric", metric, "err", err)
+		respondError(w, http.StatusInternalServerError, err, "fetching_settings")
+		return
+	}
+	respond(w, http.StatusOK, settings)
+}
+
+type dropMetricsResult struct {
+	DryRun  bool                  `json:"dry_run"`
+	Metrics []admin.DroppedMetric `json:"metrics"`
+}
+
+func dropMetricsHandler(dropper admin.MetricDropper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		expr := r.FormValue("match")
+		if expr == "" {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("no match parameter provided"), "bad_data")
+			return
+		}
+		matcher, err := admin.CompileMetricMatcher(expr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("invalid match regex: %w", err), "bad_data")
+			return
+		}
+		dryRun := false
+		if s := r.FormValue("dry_run"); s != "" {
+			if dryRun, err = strconv.ParseBool(s); err != nil {
+				respondError(w, http.StatusBadRequest, fmt.Errorf("invalid dry_run parameter: %w", err), "bad_data")
+				return
+			}
+		}
+
+		metrics, err := dropper.MatchMetrics(matcher)
+		if err != nil {
+			log.Error("msg", "Matching metrics failed", "match", expr, "err", err)
+			respondError(w, http.StatusInternalServerError, err, "fetching_metrics")
+			return
+		}
+		if dryRun {
+			respond(w, http.StatusOK, dropMetricsResult{DryRun: true, Metrics: metrics})
+			return
+		}
+		if err = admin.DropMetrics(dropper, metrics); err != nil {
+			status, errType := http.StatusInternalServerError, "dropping_metric"
+			if err == admin.ErrConnectorsAttached {
+				status, errType = http.StatusConflict, "connectors_attached"
+			}
+			if dropped := droppedMetricNames(metrics); dropped != "" {
+				err = fmt.Errorf("dropped %s before failing: %w", dropped, err)
+			}
+			log.Error("msg", "Dropping metrics failed", "match", expr, "err", err)
+			respondError(w, status, err, errType)
+			return
+		}
+		log.Info("msg", "Dropped metrics", "match", expr, "metrics", len(metrics))
+		respond(w, http.StatusOK, dropMetricsResult{Metrics: metrics})
+	}
+}
+
+// droppedMetricNames returns the comma separated names of the dropped metrics.
+func droppedMetricNames(metrics []admin.DroppedMetric) string {
+	names := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		if m.Dropped {
+			names = append(names, m.Metric)
+		}
+	}
+	return strings.Join(names, ", ")
+}
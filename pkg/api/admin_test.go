@@ -0,0 +1,261 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/route"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/admin"
+)
+
+type mockMetricAdmin struct {
+	settings map[string]admin.MetricSettings
+}
+
+func newMockMetricAdmin() *mockMetricAdmin {
+	chunkInterval := model.Duration(8 * time.Hour)
+	return &mockMetricAdmin{settings: map[string]admin.MetricSettings{
+		"up": {
+			Metric:                   "up",
+			RetentionPeriod:          model.Duration(90 * 24 * time.Hour),
+			RetentionPeriodIsDefault: true,
+			ChunkInterval:            &chunkInterval,
+			ChunkIntervalIsDefault:   true,
+			Compression:              true,
+			CompressionIsDefault:     true,
+		},
+	}}
+}
+
+func (m *mockMetricAdmin) MetricSettings(metric string) (admin.MetricSettings, error) {
+	s, ok := m.settings[metric]
+	if !ok {
+		return admin.MetricSettings{}, admin.ErrMetricNotFound
+	}
+	return s, nil
+}
+
+func (m *mockMetricAdmin) AllMetricSettings() ([]admin.MetricSettings, error) {
+	res := make([]admin.MetricSettings, 0, len(m.settings))
+	for _, s := range m.settings {
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func (m *mockMetricAdmin) update(metric string, f func(s *admin.MetricSettings)) error {
+	s := m.settings[metric]
+	s.Metric = metric
+	f(&s)
+	m.settings[metric] = s
+	return nil
+}
+
+func (m *mockMetricAdmin) SetRetentionPeriod(metric string, retentionPeriod time.Duration) error {
+	return m.update(metric, func(s *admin.MetricSettings) {
+		s.RetentionPeriod, s.RetentionPeriodIsDefault = model.Duration(retentionPeriod), false
+	})
+}
+
+func (m *mockMetricAdmin) ResetRetentionPeriod(metric string) error {
+	return m.update(metric, func(s *admin.MetricSettings) {
+		s.RetentionPeriod, s.RetentionPeriodIsDefault = model.Duration(90*24*time.Hour), true
+	})
+}
+
+func (m *mockMetricAdmin) SetChunkInterval(metric string, chunkInterval time.Duration) error {
+	return m.update(metric, func(s *admin.MetricSettings) {
+		d := model.Duration(chunkInterval)
+		s.ChunkInterval, s.ChunkIntervalIsDefault = &d, false
+	})
+}
+
+func (m *mockMetricAdmin) ResetChunkInterval(metric string) error {
+	return m.update(metric, func(s *admin.MetricSettings) {
+		d := model.Duration(8 * time.Hour)
+		s.ChunkInterval, s.ChunkIntervalIsDefault = &d, true
+	})
+}
+
+func (m *mockMetricAdmin) SetCompression(metric string, compression bool) error {
+	return m.update(metric, func(s *admin.MetricSettings) {
+		s.Compression, s.CompressionIsDefault = compression, false
+	})
+}
+
+func (m *mockMetricAdmin) ResetCompression(metric string) error {
+	return m.update(metric, func(s *admin.MetricSettings) {
+		s.Compression, s.CompressionIsDefault = true, true
+	})
+}
+
+func doAdminRequest(handler http.Handler, method, metric string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/v1/admin/metrics", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if metric != "" {
+		req = req.WithContext(route.WithParam(req.Context(), "name", metric))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestMetricSettings(t *testing.T) {
+	metricAdmin := newMockMetricAdmin()
+	handler := metricSettingsHandler(metricAdmin)
+
+	w := doAdminRequest(handler, http.MethodGet, "up", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"OK","data":{
+		"metric":"up",
+		"retention_period":"90d","retention_period_is_default":true,
+		"chunk_interval":"8h","chunk_interval_is_default":true,
+		"compression":true,"compression_is_default":true}}`, w.Body.String())
+
+	w = doAdminRequest(handler, http.MethodGet, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"metric":"up"`)
+
+	w = doAdminRequest(handler, http.MethodGet, "missing", nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doAdminRequest(handler, http.MethodGet, "invalid-name", nil)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateMetricSetting(t *testing.T) {
+	metricAdmin := newMockMetricAdmin()
+
+	w := doAdminRequest(updateMetricSettingHandler(metricAdmin, retentionPeriodSetting), http.MethodPut, "up", url.Values{"value": {"30d"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, model.Duration(30*24*time.Hour), metricAdmin.settings["up"].RetentionPeriod)
+	require.False(t, metricAdmin.settings["up"].RetentionPeriodIsDefault)
+	require.Contains(t, w.Body.String(), `"retention_period":"30d"`)
+
+	w = doAdminRequest(updateMetricSettingHandler(metricAdmin, retentionPeriodSetting), http.MethodDelete, "up", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, metricAdmin.settings["up"].RetentionPeriodIsDefault)
+
+	w = doAdminRequest(updateMetricSettingHandler(metricAdmin, chunkIntervalSetting), http.MethodPost, "up", url.Values{"value": {"2h"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, model.Duration(2*time.Hour), *metricAdmin.settings["up"].ChunkInterval)
+
+	w = doAdminRequest(updateMetricSettingHandler(metricAdmin, compressionSetting), http.MethodPost, "up", url.Values{"value": {"false"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.False(t, metricAdmin.settings["up"].Compression)
+
+	// Settings can be set before the metric is ingested.
+	w = doAdminRequest(updateMetricSettingHandler(metricAdmin, compressionSetting), http.MethodPost, "new_metric", url.Values{"value": {"false"}})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	for _, tc := range []struct {
+		setting string
+		value   string
+	}{
+		{retentionPeriodSetting, ""},
+		{retentionPeriodSetting, "ten days"},
+		{retentionPeriodSetting, "0s"},
+		{chunkIntervalSetting, "-1h"},
+		{compressionSetting, "maybe"},
+	} {
+		w = doAdminRequest(updateMetricSettingHandler(metricAdmin, tc.setting), http.MethodPut, "up", url.Values{"value": {tc.value}})
+		require.Equal(t, http.StatusBadRequest, w.Code, "setting %s to '%s'", tc.setting, tc.value)
+	}
+}
+
+func TestAdminWrapper(t *testing.T) {
+	metricAdmin := newMockMetricAdmin()
+
+	w := doAdminRequest(MetricSettings(&Config{}, metricAdmin), http.MethodGet, "up", nil)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doAdminRequest(MetricSettings(&Config{AdminAPIEnabled: true, ReadOnly: true}, metricAdmin), http.MethodGet, "up", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doAdminRequest(UpdateMetricSetting(&Config{AdminAPIEnabled: true, ReadOnly: true}, metricAdmin, compressionSetting), http.MethodPut, "up", url.Values{"value": {"false"}})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.True(t, metricAdmin.settings["up"].Compression)
+}
+
+type mockMetricDropper struct {
+	metrics []admin.DroppedMetric
+	dropped []string
+	dropErr map[string]error
+}
+
+func (m *mockMetricDropper) MatchMetrics(matcher *regexp.Regexp) ([]admin.DroppedMetric, error) {
+	res := make([]admin.DroppedMetric, 0)
+	for _, metric := range m.metrics {
+		if matcher.MatchString(metric.Metric) {
+			res = append(res, metric)
+		}
+	}
+	return res, nil
+}
+
+func (m *mockMetricDropper) DropMetric(metric string) error {
+	if err := m.dropErr[metric]; err != nil {
+		return err
+	}
+	m.dropped = append(m.dropped, metric)
+	return nil
+}
+
+func TestDropMetrics(t *testing.T) {
+	newDropper := func() *mockMetricDropper {
+		return &mockMetricDropper{metrics: []admin.DroppedMetric{
+			{Metric: "container_cpu", Series: 10},
+			{Metric: "container_memory", Series: 20},
+			{Metric: "up", Series: 1},
+		}}
+	}
+
+	dropper := newDropper()
+	w := doAdminRequest(dropMetricsHandler(dropper), http.MethodPost, "", url.Values{"match": {"container_.*"}, "dry_run": {"true"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"OK","data":{"dry_run":true,"metrics":[
+		{"metric":"container_cpu","series":10,"dropped":false},{"metric":"container_memory","series":20,"dropped":false}]}}`, w.Body.String())
+	require.Empty(t, dropper.dropped)
+
+	// The regex is fully anchored.
+	w = doAdminRequest(dropMetricsHandler(dropper), http.MethodPost, "", url.Values{"match": {"container"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"OK","data":{"dry_run":false,"metrics":[]}}`, w.Body.String())
+	require.Empty(t, dropper.dropped)
+
+	w = doAdminRequest(dropMetricsHandler(dropper), http.MethodPost, "", url.Values{"match": {"container_.*"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"OK","data":{"dry_run":false,"metrics":[
+		{"metric":"container_cpu","series":10,"dropped":true},{"metric":"container_memory","series":20,"dropped":true}]}}`, w.Body.String())
+	require.Equal(t, []string{"container_cpu", "container_memory"}, dropper.dropped)
+
+	dropper = newDropper()
+	dropper.dropErr = map[string]error{"container_memory": admin.ErrConnectorsAttached}
+	w = doAdminRequest(dropMetricsHandler(dropper), http.MethodPost, "", url.Values{"match": {".*"}})
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Contains(t, w.Body.String(), "dropped container_cpu before failing")
+	require.Equal(t, []string{"container_cpu"}, dropper.dropped)
+
+	for _, form := range []url.Values{
+		{},
+		{"match": {"("}},
+		{"match": {"up"}, "dry_run": {"maybe"}},
+	} {
+		w = doAdminRequest(dropMetricsHandler(newDropper()), http.MethodPost, "", form)
+		require.Equal(t, http.StatusBadRequest, w.Code, "form %v", form)
+	}
+
+	w = doAdminRequest(DropMetrics(&Config{AdminAPIEnabled: true, ReadOnly: true}, dropper), http.MethodPost, "", url.Values{"match": {"up"}, "dry_run": {"true"}})
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
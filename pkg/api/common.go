@@ -91,6 +91,7 @@ type Config struct {
 
 	Auth         *Auth
 	MultiTenancy tenancy.Authorizer
+	Rules        RulesRetriever
 
 	// PromQL configuration.
 	EnableFeatures       string
@@ -100,6 +101,9 @@ type Config struct {
 	LookBackDelta        time.Duration
 	MaxSamples           int64
 	MaxPointsPerTs       int64
+
+	// Remote-read configuration.
+	ReadMaxBytesInFrame int
 }
 
 func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
@@ -107,7 +111,7 @@ func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 
 	fs.BoolVar(&cfg.ReadOnly, "read-only", false, "Read-only mode for the connector. Operations related to writing or updating the database are disallowed. It is used when pointing the connector to a TimescaleDB read replica.")
 	fs.BoolVar(&cfg.HighAvailability, "high-availability", false, "Enable external_labels based HA.")
-	fs.BoolVar(&cfg.AdminAPIEnabled, "web-enable-admin-api", false, "Allow operations via API that are for advanced users. Currently, these operations are limited to deletion of series.")
+	fs.BoolVar(&cfg.AdminAPIEnabled, "web-enable-admin-api", false, "Allow operations via API that are for advanced users. These operations are deletion of series, dropping of metrics and managing the storage settings of metrics.")
 	fs.StringVar(&cfg.TelemetryPath, "web-telemetry-path", "/metrics", "Web endpoint for exposing Promscale's Prometheus metrics.")
 
 	fs.StringVar(&cfg.Auth.BasicAuthUsername, "auth-username", "", "Authentication username used for web endpoint authentication. Disabled by default.")
@@ -129,6 +133,8 @@ func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 		"so this also limits the number of samples a query can return.")
 	fs.Int64Var(&cfg.MaxPointsPerTs, "promql-max-points-per-ts", 11000, "Maximum number of points per time-series in a query-range request. "+
 		"This calculation is an estimation, that happens as (start - end)/step where start and end are the 'start' and 'end' timestamps of the query_range.")
+	fs.IntVar(&cfg.ReadMaxBytesInFrame, "read-max-bytes-in-frame", 1048576, "Maximum number of bytes in a single frame for streaming remote read responses (STREAMED_XOR_CHUNKS). "+
+		"Note that the client might have a limit on frame size as well. 1MB as recommended by protobuf by default.")
 	return cfg
 }
 
@@ -7,12 +7,16 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/NYTimes/gziphandler"
+	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgclient"
-	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
 	deletePkg "github.com/timescale/promscale/pkg/pgmodel/delete"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 )
@@ -33,9 +37,9 @@ func deleteHandler(config *Config, client *pgclient.Client) http.HandlerFunc {
 			return
 		}
 		var (
-			totalRowsDeleted int
-			metricsTouched   []string
-			seriesDeleted    []model.SeriesID
+			metricsTouched []string
+			seriesDeleted  []model.SeriesID
+			rowsDeleted    = make(map[string]int)
 		)
 		if err := r.ParseForm(); err != nil {
 			respondError(w, http.StatusBadRequest, err, "bad_data")
@@ -57,9 +61,19 @@ func deleteHandler(config *Config, client *pgclient.Client) http.HandlerFunc {
 			respondError(w, http.StatusBadRequest, err, "bad_data")
 			return
 		}
-		if start != model.MinTime || end != model.MaxTime {
-			log.Warn("msg", "Time based series deletion is unsupported.")
-			respondError(w, http.StatusBadRequest, errors.ErrTimeBasedDeletion, "bad_data")
+		if end.Before(start) {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("end timestamp must not be before start timestamp"), "bad_data")
+			return
+		}
+		dryRun := false
+		if v := r.FormValue("dry_run"); v != "" {
+			if dryRun, err = strconv.ParseBool(v); err != nil {
+				respondError(w, http.StatusBadRequest, fmt.Errorf("invalid value for 'dry_run': %w", err), "bad_data")
+				return
+			}
+		}
+		if dryRun {
+			deleteDryRun(w, r, client, start, end)
 			return
 		}
 		for _, s := range r.Form["match[]"] {
@@ -72,29 +86,92 @@ func deleteHandler(config *Config, client *pgclient.Client) http.HandlerFunc {
 				continue
 			}
 			pgDelete := deletePkg.PgDelete{Conn: client.Connection}
-			touchedMetrics, deletedSeriesIDs, rowsDeleted, err := pgDelete.DeleteSeries(matchers, start, end)
+			touchedMetrics, deletedSeriesIDs, metricRowsDeleted, err := pgDelete.DeleteSeries(matchers, start, end)
+			metricsTouched = append(metricsTouched, touchedMetrics...)
+			seriesDeleted = append(seriesDeleted, deletedSeriesIDs...)
+			for metric, rows := range metricRowsDeleted {
+				rowsDeleted[metric] += rows
+			}
 			if err != nil {
 				respondErrorWithMessage(w, http.StatusInternalServerError, err, "deleting_series",
-					fmt.Sprintf("partial delete: deleted %v series IDs from %v metrics, affecting %d rows in total.",
-						distinctValues(seriesDeleted),
-						distinctValues(metricsTouched),
-						totalRowsDeleted,
-					),
-				)
+					"partial delete: "+deletionSummary(start, end, seriesDeleted, metricsTouched, rowsDeleted))
 				return
 			}
-			metricsTouched = append(metricsTouched, touchedMetrics...)
-			seriesDeleted = append(seriesDeleted, deletedSeriesIDs...)
-			totalRowsDeleted += rowsDeleted
 		}
-		respond(w, http.StatusOK,
-			fmt.Sprintf("deleted %v series IDs from %v metrics, affecting %d rows in total.",
-				distinctValues(seriesDeleted),
-				distinctValues(metricsTouched),
-				totalRowsDeleted,
-			),
+		respond(w, http.StatusOK, deletionSummary(start, end, seriesDeleted, metricsTouched, rowsDeleted))
+	}
+}
+
+type deleteDryRunResult struct {
+	Metrics       []deletePkg.MetricDryRun `json:"metrics"`
+	Series        int                      `json:"series"`
+	EstimatedRows int64                    `json:"estimated_rows"`
+}
+
+// deleteDryRun responds with the metrics, series and estimated rows that would be
+// deleted by the request, without deleting anything.
+func deleteDryRun(w http.ResponseWriter, r *http.Request, client *pgclient.Client, start, end time.Time) {
+	matcherSets := make([][]*labels.Matcher, 0, len(r.Form["match[]"]))
+	for _, s := range r.Form["match[]"] {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		matcherSets = append(matcherSets, matchers)
+	}
+	result := deleteDryRunResult{Metrics: []deletePkg.MetricDryRun{}}
+	if client != nil {
+		pgDelete := deletePkg.PgDelete{Conn: client.Connection}
+		metrics, err := pgDelete.DryRun(matcherSets, start, end)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err, "deleting_series")
+			return
+		}
+		result.Metrics = metrics
+	}
+	for _, m := range result.Metrics {
+		result.Series += m.Series
+		result.EstimatedRows += m.EstimatedRows
+	}
+	respond(w, http.StatusOK, result)
+}
+
+// deletionSummary describes the outcome of a deletion request, including the
+// number of rows deleted from each metric.
+func deletionSummary(start, end time.Time, seriesDeleted []model.SeriesID, metricsTouched []string, rowsDeleted map[string]int) string {
+	var (
+		totalRowsDeleted int
+		metrics          = make([]string, 0, len(rowsDeleted))
+	)
+	for metric, rows := range rowsDeleted {
+		totalRowsDeleted += rows
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	perMetric := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		perMetric = append(perMetric, fmt.Sprintf("%s: %d", metric, rowsDeleted[metric]))
+	}
+
+	summary := fmt.Sprintf("deleted %v series IDs from %v metrics, affecting %d rows in total.",
+		distinctValues(seriesDeleted),
+		distinctValues(metricsTouched),
+		totalRowsDeleted,
+	)
+	if !start.Equal(model.MinTime) || !end.Equal(model.MaxTime) {
+		summary = fmt.Sprintf("deleted samples between %s and %s of %v series IDs from %v metrics, affecting %d rows in total.",
+			start.Format(time.RFC3339Nano),
+			end.Format(time.RFC3339Nano),
+			distinctValues(seriesDeleted),
+			distinctValues(metricsTouched),
+			totalRowsDeleted,
 		)
 	}
+	if len(perMetric) > 0 {
+		summary += fmt.Sprintf(" Rows deleted per metric: %s.", strings.Join(perMetric, ", "))
+	}
+	return summary
 }
 
 func distinctValues(slice interface{}) []string {
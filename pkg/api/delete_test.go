@@ -11,6 +11,10 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 )
 
 func TestDelete(t *testing.T) {
@@ -44,26 +48,29 @@ func TestDelete(t *testing.T) {
 			name:         "normal_with_start",
 			matchers:     []string{`{__name__=~".*"}`},
 			start:        "1604311719000",
-			expectedCode: http.StatusBadRequest,
-			fails:        true,
-			message:      "time based series deletion is unsupported",
+			expectedCode: http.StatusOK,
 		},
 		{
 			name:         "normal_with_end",
 			matchers:     []string{`{__name__=~".*"}`},
 			end:          "1604311719000",
-			expectedCode: http.StatusBadRequest,
-			fails:        true,
-			message:      "time based series deletion is unsupported",
+			expectedCode: http.StatusOK,
 		},
 		{
 			name:         "normal_with_start_end",
 			matchers:     []string{`{__name__=~".*"}`},
 			start:        "1604311711000",
 			end:          "1604311719000",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "end_before_start",
+			matchers:     []string{`{__name__=~".*"}`},
+			start:        "1604311719000",
+			end:          "1604311711000",
 			expectedCode: http.StatusBadRequest,
 			fails:        true,
-			message:      "time based series deletion is unsupported",
+			message:      "end timestamp must not be before start timestamp",
 		},
 		{
 			name:         "normal_with_start_end_without_matchers",
@@ -130,6 +137,47 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDeleteDryRun(t *testing.T) {
+	config := &Config{
+		ReadOnly:        false,
+		AdminAPIEnabled: true,
+	}
+	handler := deleteHandler(config, nil)
+
+	vals := constructRequestValues("1604311711000", "1604311719000", []string{`{__name__=~".*"}`})
+	vals.Add("dry_run", "true")
+	resp := doPostDeleteRequest(t, handler, vals)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	bstream, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"status":"OK","data":{"metrics":[],"series":0,"estimated_rows":0}}`, string(bstream))
+
+	vals = constructRequestValues("", "", []string{`{__name__=~".*"}`})
+	vals.Add("dry_run", "maybe")
+	resp = doPostDeleteRequest(t, handler, vals)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	vals = constructRequestValues("", "", []string{`{__name__=~".*"`})
+	vals.Add("dry_run", "true")
+	resp = doPostDeleteRequest(t, handler, vals)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestDeletionSummary(t *testing.T) {
+	start := time.Unix(1604311711, 0).UTC()
+	end := time.Unix(1604311719, 0).UTC()
+	rowsDeleted := map[string]int{"metric_b": 2, "metric_a": 10}
+
+	require.Equal(t,
+		"deleted [1] series IDs from [metric_a] metrics, affecting 12 rows in total. Rows deleted per metric: metric_a: 10, metric_b: 2.",
+		deletionSummary(model.MinTime, model.MaxTime, []model.SeriesID{1, 1}, []string{"metric_a"}, rowsDeleted),
+	)
+	require.Equal(t,
+		"deleted samples between 2020-11-02T10:08:31Z and 2020-11-02T10:08:39Z of [] series IDs from [] metrics, affecting 0 rows in total.",
+		deletionSummary(start, end, nil, nil, map[string]int{}),
+	)
+}
+
 func constructRequestValues(start, end string, matchers []string) url.Values {
 	values := make(url.Values)
 	if start != "" {
@@ -0,0 +1,164 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/exemplar"
+	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+type exemplarTracesResult struct {
+	SeriesLabels labels.Labels   `json:"seriesLabels"`
+	Exemplars    []exemplarTrace `json:"exemplars"`
+}
+
+type exemplarTrace struct {
+	Labels    labels.Labels          `json:"labels"`
+	Value     string                 `json:"value"`
+	Timestamp float64                `json:"timestamp"`
+	Trace     *exemplar.TraceSummary `json:"trace,omitempty"`
+}
+
+// QueryExemplarTraces returns the exemplars of a PromQL query, each with a summary of the trace it points to.
+func QueryExemplarTraces(conf *Config, queryable promql.Queryable, conn pgxconn.PgxConn, metrics *Metrics) http.Handler {
+	hf := corsWrapper(conf, queryExemplarTraces(queryable, conn, metrics))
+	return gziphandler.GzipHandler(hf)
+}
+
+func queryExemplarTraces(queryable promql.Queryable, conn pgxconn.PgxConn, metrics *Metrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parseTimeRange(r)
+		if err != nil {
+			log.Info("msg", "Exemplar traces query bad request:", "error", err)
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			metrics.InvalidQueryReqs.Add(1)
+			return
+		}
+
+		begin := time.Now()
+		results, err := exemplar.QueryExemplar(r.Context(), r.FormValue("query"), queryable, start, end)
+		if err != nil {
+			log.Error("msg", err, "endpoint", "query_exemplars_traces")
+			respondError(w, http.StatusInternalServerError, err, "bad_data")
+			return
+		}
+		var traceIDs []string
+		for _, result := range results {
+			for _, e := range result.Exemplars {
+				if id, ok := exemplar.TraceID(e.Labels); ok {
+					traceIDs = append(traceIDs, id)
+				}
+			}
+		}
+		summaries, err := exemplar.TraceSummaries(r.Context(), conn, traceIDs)
+		if err != nil {
+			log.Error("msg", err, "endpoint", "query_exemplars_traces")
+			respondError(w, http.StatusInternalServerError, err, "internal")
+			return
+		}
+		metrics.QueryDuration.Observe(time.Since(begin).Seconds())
+		respondExemplarTraces(w, results, summaries)
+	}
+}
+
+// TraceExemplars returns the exemplars that point to a trace, restricted to the
+// series of the tenants allowed to be read with multi-tenancy.
+func TraceExemplars(conf *Config, conn pgxconn.PgxConn, metrics *Metrics) http.Handler {
+	var rAuth tenancy.ReadAuthorizer
+	if conf.MultiTenancy != nil {
+		rAuth = conf.MultiTenancy.ReadAuthorizer()
+	}
+	hf := corsWrapper(conf, traceExemplars(conn, rAuth, metrics))
+	return gziphandler.GzipHandler(hf)
+}
+
+func traceExemplars(conn pgxconn.PgxConn, rAuth tenancy.ReadAuthorizer, metrics *Metrics) http.HandlerFunc {
+	var seriesMatchers []*labels.Matcher
+	if rAuth != nil {
+		seriesMatchers = rAuth.AppendTenantMatcher(nil)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parseTimeRange(r)
+		if err != nil {
+			log.Info("msg", "Trace exemplars query bad request:", "error", err)
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			metrics.InvalidQueryReqs.Add(1)
+			return
+		}
+		traceID := r.FormValue("trace_id")
+		if _, err = exemplar.ParseTraceID(traceID); err != nil {
+			log.Info("msg", "Trace exemplars query bad request:", "error", err)
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			metrics.InvalidQueryReqs.Add(1)
+			return
+		}
+
+		begin := time.Now()
+		results, err := exemplar.TraceExemplars(r.Context(), conn, traceID, start, end, seriesMatchers)
+		if err != nil {
+			log.Error("msg", err, "endpoint", "trace_exemplars")
+			respondError(w, http.StatusInternalServerError, err, "internal")
+			return
+		}
+		metrics.QueryDuration.Observe(time.Since(begin).Seconds())
+		respondExemplar(w, results)
+	}
+}
+
+func parseTimeRange(r *http.Request) (start, end time.Time, err error) {
+	start, err = parseTime(r.FormValue("start"))
+	if err != nil {
+		return
+	}
+	end, err = parseTime(r.FormValue("end"))
+	if err != nil {
+		return
+	}
+	if end.Before(start) {
+		err = errors.New("end timestamp must not be before start time")
+	}
+	return
+}
+
+func respondExemplarTraces(w http.ResponseWriter, data []pgmodel.ExemplarQueryResult, summaries map[string]exemplar.TraceSummary) {
+	results := make([]exemplarTracesResult, 0, len(data))
+	for _, d := range data {
+		result := exemplarTracesResult{
+			SeriesLabels: d.SeriesLabels,
+			Exemplars:    make([]exemplarTrace, 0, len(d.Exemplars)),
+		}
+		for _, e := range d.Exemplars {
+			et := exemplarTrace{
+				Labels:    e.Labels,
+				Value:     strconv.FormatFloat(e.Value, 'f', -1, 64),
+				Timestamp: float64(e.Ts) / 1000,
+			}
+			if id, ok := exemplar.TraceID(e.Labels); ok {
+				if summary, ok := summaries[id]; ok {
+					et.Trace = &summary
+				}
+			}
+			result.Exemplars = append(result.Exemplars, et)
+		}
+		results = append(results, result)
+	}
+	setResponseHeaders(w, nil, true, nil)
+	_ = json.NewEncoder(w).Encode(&response{
+		Status: "success",
+		Data:   results,
+	})
+}
@@ -0,0 +1,124 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/exemplar"
+	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+func TestTraceExemplarsBadRequest(t *testing.T) {
+	tcs := []struct {
+		name    string
+		traceID string
+		start   string
+		end     string
+		err     string
+	}{
+		{
+			name:    "start_greater_than_end",
+			traceID: "0123456789abcdef0123456789abcdef",
+			start:   "1625557347",
+			end:     "1617694947",
+			err:     `{"status":"error","errorType":"bad_data","error":"end timestamp must not be before start time"}`,
+		},
+		{
+			name:  "empty_trace_id",
+			start: "1617694947",
+			end:   "1625557347",
+			err:   `{"status":"error","errorType":"bad_data","error":"invalid trace ID: \"\""}`,
+		},
+		{
+			name:    "invalid_trace_id",
+			traceID: "xyz",
+			start:   "1617694947",
+			end:     "1625557347",
+			err:     `{"status":"error","errorType":"bad_data","error":"invalid trace ID: \"xyz\""}`,
+		},
+		{
+			name:    "trace_id_too_long",
+			traceID: "0123456789abcdef0123456789abcdef00",
+			start:   "1617694947",
+			end:     "1625557347",
+			err:     `{"status":"error","errorType":"bad_data","error":"invalid trace ID: \"0123456789abcdef0123456789abcdef00\""}`,
+		},
+	}
+
+	invalidQueryReqs := &mockMetric{}
+	metrics := &Metrics{
+		InvalidQueryReqs: invalidQueryReqs,
+		QueryDuration:    &mockMetric{},
+	}
+
+	for _, tc := range tcs {
+		handler := traceExemplars(nil, nil, metrics)
+		url := fmt.Sprintf("http://localhost:9090/api/v1/trace_exemplars?trace_id=%s&start=%s&end=%s", tc.traceID, tc.start, tc.end)
+		r := doExemplarQuery(t, "GET", url, handler)
+		require.Equal(t, http.StatusBadRequest, r.Code, tc.name)
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, tc.err, string(b), tc.name)
+	}
+	require.Equal(t, float64(len(tcs)), invalidQueryReqs.value)
+}
+
+func TestRespondExemplarTraces(t *testing.T) {
+	data := []pgmodel.ExemplarQueryResult{
+		{
+			SeriesLabels: labels.FromStrings("__name__", "http_request_duration_seconds_bucket", "le", "0.5"),
+			Exemplars: []pgmodel.ExemplarData{
+				{Labels: labels.FromStrings("trace_id", "00000000000000000000000000000abc"), Value: 0.25, Ts: 1600000000500},
+				{Labels: labels.FromStrings("trace_id", "def"), Value: 0.5, Ts: 1600000001000},
+				{Labels: labels.FromStrings("user", "a"), Value: 0.1, Ts: 1600000002000},
+			},
+		},
+	}
+	summaries := map[string]exemplar.TraceSummary{
+		"00000000000000000000000000000abc": {
+			TraceID:      "00000000000000000000000000000abc",
+			RootService:  "frontend",
+			RootSpanName: "GET /",
+			StartTime:    time.Unix(1600000000, 0).UTC(),
+			DurationMs:   250,
+			SpanCount:    3,
+		},
+	}
+
+	w := httptest.NewRecorder()
+	respondExemplarTraces(w, data, summaries)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{
+		"status": "success",
+		"data": [{
+			"seriesLabels": {"__name__": "http_request_duration_seconds_bucket", "le": "0.5"},
+			"exemplars": [
+				{
+					"labels": {"trace_id": "00000000000000000000000000000abc"},
+					"value": "0.25",
+					"timestamp": 1600000000.5,
+					"trace": {
+						"traceID": "00000000000000000000000000000abc",
+						"rootServiceName": "frontend",
+						"rootSpanName": "GET /",
+						"startTime": "2020-09-13T12:26:40Z",
+						"durationMs": 250,
+						"spanCount": 3
+					}
+				},
+				{"labels": {"trace_id": "def"}, "value": "0.5", "timestamp": 1600000001},
+				{"labels": {"user": "a"}, "value": "0.1", "timestamp": 1600000002}
+			]
+		}]
+	}`, w.Body.String())
+}
@@ -0,0 +1,161 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/prometheus/prometheus/pkg/value"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/promql"
+)
+
+func Federate(conf *Config, queryable promql.Queryable) http.Handler {
+	hf := corsWrapper(conf, federate(conf, queryable))
+	return gziphandler.GzipHandler(hf)
+}
+
+// federate returns the latest sample within the look-back delta of each series
+// that matches any of the match[] selectors, in the text exposition format or
+// OpenMetrics, depending on the Accept header. Like for the other read endpoints,
+// the safety matcher of the tenancy ReadAuthorizer is applied by the querier.
+func federate(conf *Config, queryable promql.Queryable) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, errors.Wrap(err, "error parsing form values").Error(), http.StatusBadRequest)
+			return
+		}
+		if len(r.Form["match[]"]) == 0 {
+			http.Error(w, "no match[] parameter provided", http.StatusBadRequest)
+			return
+		}
+
+		var matcherSets [][]*labels.Matcher
+		for _, s := range r.Form["match[]"] {
+			matchers, err := parser.ParseMetricSelector(s)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			matcherSets = append(matcherSets, matchers)
+		}
+
+		var (
+			now  = time.Now()
+			mint = timestamp.FromTime(now.Add(-conf.LookBackDelta))
+			maxt = timestamp.FromTime(now)
+		)
+		q, err := queryable.SamplesQuerier(r.Context(), mint, maxt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer q.Close()
+
+		hints := &storage.SelectHints{Start: mint, End: maxt}
+		var sets []storage.SeriesSet
+		for _, mset := range matcherSets {
+			s, _ := q.Select(true, hints, nil, nil, mset...)
+			sets = append(sets, s)
+		}
+		set := storage.NewMergeSeriesSet(sets, storage.ChainedSeriesMerge)
+
+		families, err := federationFamilies(set)
+		if err != nil {
+			log.Error("msg", "Federation failed", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, warning := range set.Warnings() {
+			log.Warn("msg", "Federation select returned warning", "err", warning)
+		}
+
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		w.Header().Set("Content-Type", string(format))
+		enc := expfmt.NewEncoder(w, format)
+		for _, mf := range families {
+			if err := enc.Encode(mf); err != nil {
+				log.Error("msg", "Federation failed", "err", err)
+				return
+			}
+		}
+		if closer, ok := enc.(expfmt.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Error("msg", "Federation failed", "err", err)
+			}
+		}
+	}
+}
+
+// federationFamilies reads the latest non-stale sample of each series and
+// groups the series into untyped metric families, sorted by metric name.
+func federationFamilies(set storage.SeriesSet) ([]*dto.MetricFamily, error) {
+	var (
+		byName   = make(map[string]*dto.MetricFamily)
+		untyped  = dto.MetricType_UNTYPED
+		families []*dto.MetricFamily
+	)
+	for set.Next() {
+		series := set.At()
+		var (
+			t  int64
+			v  float64
+			ok bool
+		)
+		it := series.Iterator()
+		for it.Next() {
+			t, v = it.At()
+			ok = true
+		}
+		if it.Err() != nil {
+			return nil, it.Err()
+		}
+		if !ok || value.IsStaleNaN(v) {
+			continue
+		}
+
+		lbls := series.Labels()
+		name := lbls.Get(labels.MetricName)
+		mf, exists := byName[name]
+		if !exists {
+			familyName := name
+			mf = &dto.MetricFamily{Name: &familyName, Type: &untyped}
+			byName[name] = mf
+			families = append(families, mf)
+		}
+
+		m := &dto.Metric{
+			Label:       make([]*dto.LabelPair, 0, len(lbls)-1),
+			Untyped:     &dto.Untyped{Value: &v},
+			TimestampMs: &t,
+		}
+		for _, l := range lbls {
+			if l.Name == labels.MetricName {
+				continue
+			}
+			l := l
+			m.Label = append(m.Label, &dto.LabelPair{Name: &l.Name, Value: &l.Value})
+		}
+		mf.Metric = append(mf.Metric, m)
+	}
+	if set.Err() != nil {
+		return nil, set.Err()
+	}
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+	return families, nil
+}
@@ -0,0 +1,170 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/value"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/tsdbutil"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/promql"
+)
+
+type mockFederateQueryable struct {
+	series     []storage.Series
+	mint, maxt int64
+	matchers   [][]*labels.Matcher
+}
+
+func (m *mockFederateQueryable) SamplesQuerier(_ context.Context, mint, maxt int64) (promql.SamplesQuerier, error) {
+	m.mint, m.maxt = mint, maxt
+	return m, nil
+}
+
+func (m *mockFederateQueryable) ExemplarsQuerier(_ context.Context) querier.ExemplarQuerier {
+	return nil
+}
+
+func (m *mockFederateQueryable) LabelValues(string, ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	return nil, nil, nil
+}
+
+func (m *mockFederateQueryable) LabelNames(...*labels.Matcher) ([]string, storage.Warnings, error) {
+	return nil, nil, nil
+}
+
+func (m *mockFederateQueryable) Close() error {
+	return nil
+}
+
+func (m *mockFederateQueryable) Select(_ bool, _ *storage.SelectHints, _ *querier.QueryHints, _ []parser.Node, ms ...*labels.Matcher) (storage.SeriesSet, parser.Node) {
+	m.matchers = append(m.matchers, ms)
+	var matched []storage.Series
+outer:
+	for _, s := range m.series {
+		for _, matcher := range ms {
+			if !matcher.Matches(s.Labels().Get(matcher.Name)) {
+				continue outer
+			}
+		}
+		matched = append(matched, s)
+	}
+	return &mockListSeriesSet{series: matched}, nil
+}
+
+func TestFederate(t *testing.T) {
+	queryable := &mockFederateQueryable{
+		series: []storage.Series{
+			storage.NewListSeries(
+				labels.FromStrings("__name__", "job:up:sum", "job", "a"),
+				[]tsdbutil.Sample{sample{1000, 1}, sample{2000, 2}},
+			),
+			storage.NewListSeries(
+				labels.FromStrings("__name__", "job:up:sum", "job", "b"),
+				[]tsdbutil.Sample{sample{1000, 1}, sample{2000, math.Float64frombits(value.StaleNaN)}},
+			),
+			storage.NewListSeries(
+				labels.FromStrings("__name__", "go_goroutines", "job", "a"),
+				[]tsdbutil.Sample{sample{3000, 30}},
+			),
+			storage.NewListSeries(
+				labels.FromStrings("__name__", "process_open_fds", "job", "a"),
+				[]tsdbutil.Sample{sample{3000, 5}},
+			),
+		},
+	}
+	conf := &Config{LookBackDelta: 5 * time.Minute}
+	handler := federate(conf, queryable)
+
+	testCases := []struct {
+		name        string
+		matchers    []string
+		accept      string
+		code        int
+		contentType expfmt.Format
+		body        string
+	}{
+		{
+			name: "no match[]",
+			code: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid match[]",
+			matchers: []string{"job:up:sum{"},
+			code:     http.StatusBadRequest,
+		},
+		{
+			name:        "text format",
+			matchers:    []string{"job:up:sum", `{__name__="go_goroutines"}`},
+			code:        http.StatusOK,
+			contentType: expfmt.FmtText,
+			body: `# TYPE go_goroutines untyped
+go_goroutines{job="a"} 30 3000
+# TYPE job:up:sum untyped
+job:up:sum{job="a"} 2 2000
+`,
+		},
+		{
+			name:        "OpenMetrics format",
+			matchers:    []string{"job:up:sum"},
+			accept:      "application/openmetrics-text; version=0.0.1",
+			code:        http.StatusOK,
+			contentType: expfmt.FmtOpenMetrics,
+			body: `# TYPE job:up:sum unknown
+job:up:sum{job="a"} 2.0 2.0
+# EOF
+`,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			queryable.matchers = nil
+			params := url.Values{"match[]": c.matchers}
+			req, err := http.NewRequest("GET", "/federate?"+params.Encode(), nil)
+			require.NoError(t, err)
+			if c.accept != "" {
+				req.Header.Set("Accept", c.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, c.code, w.Code)
+			if c.code != http.StatusOK {
+				return
+			}
+			require.Equal(t, string(c.contentType), w.Header().Get("Content-Type"))
+			require.Equal(t, c.body, w.Body.String())
+			require.Len(t, queryable.matchers, len(c.matchers))
+			require.Equal(t, conf.LookBackDelta.Milliseconds(), queryable.maxt-queryable.mint)
+		})
+	}
+}
+
+func TestFederateResponseIsSortedByName(t *testing.T) {
+	set := &mockListSeriesSet{series: []storage.Series{
+		storage.NewListSeries(labels.FromStrings("__name__", "b"), []tsdbutil.Sample{sample{1, 1}}),
+		storage.NewListSeries(labels.FromStrings("__name__", "a", "x", "1"), []tsdbutil.Sample{sample{1, 1}}),
+		storage.NewListSeries(labels.FromStrings("__name__", "a", "x", "2"), []tsdbutil.Sample{sample{1, 1}}),
+	}}
+	families, err := federationFamilies(set)
+	require.NoError(t, err)
+	require.Len(t, families, 2)
+	require.Equal(t, "a", families[0].GetName())
+	require.Len(t, families[0].Metric, 2)
+	require.Equal(t, "b", families[1].GetName())
+}
@@ -0,0 +1,64 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/timescale/promscale/pkg/api/parser"
+	"github.com/timescale/promscale/pkg/api/parser/influx"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+// InfluxWrite returns an http.Handler that ingests data in the InfluxDB line protocol,
+// as sent to the write endpoints of InfluxDB 1.x and 2.x. Like InfluxDB, it responds
+// with 204 No Content on success.
+func InfluxWrite(inserter ingestor.DBInserter, dataParser *parser.DefaultParser, elector *util.Elector) http.Handler {
+	wh := writeHandler{}
+	wh.addStages(
+		validateInfluxWriteHeaders,
+		checkLegacyHA(elector),
+		decodeGzip,
+		ingest(inserter, dataParser),
+		respondNoContent,
+	)
+	return wh.handler()
+}
+
+// validateInfluxWriteHeaders checks the request method and marks the body as line
+// protocol, since InfluxDB clients send it as text/plain or without a content type.
+func validateInfluxWriteHeaders(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "POST" {
+		validateError(w, fmt.Sprintf("HTTP Method %s instead of POST", r.Method), metrics)
+		return false
+	}
+	r.Header.Set("Content-Type", influx.ContentType)
+	return true
+}
+
+func decodeGzip(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
+		return true
+	}
+	reader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		invalidRequestError(w, "gzip decode error", err.Error(), metrics)
+		return false
+	}
+	r.Body = &readCloser{
+		reader: reader,
+		closer: r.Body,
+	}
+	return true
+}
+
+func respondNoContent(w http.ResponseWriter, _ *http.Request) bool {
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
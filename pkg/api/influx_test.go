@@ -0,0 +1,136 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/api/parser"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+type labelingPreprocessor struct {
+	err error
+}
+
+func (p labelingPreprocessor) Process(_ *http.Request, wr *prompb.WriteRequest) error {
+	if p.err != nil {
+		return p.err
+	}
+	for i := range wr.Timeseries {
+		wr.Timeseries[i].Labels = append(wr.Timeseries[i].Labels, prompb.Label{Name: "__tenant__", Value: "iot"})
+	}
+	return nil
+}
+
+func TestInfluxWrite(t *testing.T) {
+	metrics = &Metrics{
+		LeaderGauge:       &mockMetric{},
+		ReceivedSamples:   &mockMetric{},
+		ReceivedMetadata:  &mockMetric{},
+		FailedSamples:     &mockMetric{},
+		FailedMetadata:    &mockMetric{},
+		SentSamples:       &mockMetric{},
+		SentMetadata:      &mockMetric{},
+		SentBatchDuration: &mockMetric{},
+		InvalidWriteReqs:  &mockMetric{},
+	}
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write([]byte("cpu,host=a usage=1.5 1600000000000000000\n"))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	testCases := []struct {
+		name         string
+		method       string
+		body         string
+		headers      map[string]string
+		preprocessor parser.Preprocessor
+		responseCode int
+		result       []prompb.TimeSeries
+	}{
+		{
+			name:         "plain text",
+			method:       http.MethodPost,
+			body:         "cpu,host=a usage=1.5 1600000000000000000\n",
+			headers:      map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+			responseCode: http.StatusNoContent,
+			result: []prompb.TimeSeries{{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "cpu_usage"}, {Name: "host", Value: "a"}},
+				Samples: []prompb.Sample{{Timestamp: 1600000000000, Value: 1.5}},
+			}},
+		},
+		{
+			name:         "gzip and preprocessors",
+			method:       http.MethodPost,
+			body:         gzipped.String(),
+			headers:      map[string]string{"Content-Encoding": "gzip"},
+			preprocessor: labelingPreprocessor{},
+			responseCode: http.StatusNoContent,
+			result: []prompb.TimeSeries{{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "cpu_usage"}, {Name: "host", Value: "a"}, {Name: "__tenant__", Value: "iot"}},
+				Samples: []prompb.Sample{{Timestamp: 1600000000000, Value: 1.5}},
+			}},
+		},
+		{
+			name:         "no samples",
+			method:       http.MethodPost,
+			body:         "# nothing to see\n",
+			responseCode: http.StatusNoContent,
+		},
+		{
+			name:         "preprocessor error",
+			method:       http.MethodPost,
+			body:         "cpu usage=1",
+			preprocessor: labelingPreprocessor{err: fmt.Errorf("unauthorized tenant")},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "parse error",
+			method:       http.MethodPost,
+			body:         "cpu usage=abc",
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid gzip",
+			method:       http.MethodPost,
+			body:         "cpu usage=1",
+			headers:      map[string]string{"Content-Encoding": "gzip"},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "wrong method",
+			method:       http.MethodGet,
+			responseCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockInserter{}
+			dataParser := parser.NewParser()
+			dataParser.AddPreprocessor(c.preprocessor)
+
+			req := httptest.NewRequest(c.method, "/api/v2/write", strings.NewReader(c.body))
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			InfluxWrite(mock, dataParser, nil).ServeHTTP(w, req)
+
+			require.Equal(t, c.responseCode, w.Code, w.Body.String())
+			require.Equal(t, c.result, mock.ts)
+		})
+	}
+}
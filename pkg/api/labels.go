@@ -5,15 +5,19 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
-	"math"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/NYTimes/gziphandler"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
+	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/promql"
 )
 
@@ -34,13 +38,20 @@ func Labels(conf *Config, queryable promql.Queryable) http.Handler {
 
 func labelsHandler(queryable promql.Queryable) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		querier, err := queryable.SamplesQuerier(context.Background(), math.MinInt64, math.MaxInt64)
+		start, end, matcherSets, err := parseLabelsParams(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		querier, err := queryable.SamplesQuerier(r.Context(), timestamp.FromTime(start), timestamp.FromTime(end))
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, err, "internal")
 			return
 		}
+		defer querier.Close()
+
 		var names labelsValue
-		names, warnings, err := querier.LabelNames()
+		names, warnings, err := mergeLabelSets(matcherSets, querier.LabelNames)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, err, "internal")
 			return
@@ -51,6 +62,64 @@ func labelsHandler(queryable promql.Queryable) http.HandlerFunc {
 	}
 }
 
+// parseLabelsParams parses the optional start, end and match[] parameters of
+// the label names and label values endpoints.
+func parseLabelsParams(r *http.Request) (start, end time.Time, matcherSets [][]*labels.Matcher, err error) {
+	if err = r.ParseForm(); err != nil {
+		return start, end, nil, errors.Wrap(err, "error parsing form values")
+	}
+	start, err = parseTimeParam(r, "start", pgmodel.MinTime)
+	if err != nil {
+		return start, end, nil, err
+	}
+	end, err = parseTimeParam(r, "end", pgmodel.MaxTime)
+	if err != nil {
+		return start, end, nil, err
+	}
+	if end.Before(start) {
+		return start, end, nil, errors.New("end timestamp must not be before start time")
+	}
+
+	for _, s := range r.Form["match[]"] {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return start, end, nil, err
+		}
+		matcherSets = append(matcherSets, matchers)
+	}
+	return start, end, matcherSets, nil
+}
+
+// mergeLabelSets calls fetch once per matcher set and returns the sorted union
+// of the results. Without matcher sets, fetch is called without matchers.
+func mergeLabelSets(matcherSets [][]*labels.Matcher, fetch func(...*labels.Matcher) ([]string, storage.Warnings, error)) ([]string, storage.Warnings, error) {
+	if len(matcherSets) == 0 {
+		return fetch()
+	}
+
+	var (
+		warnings storage.Warnings
+		unique   = make(map[string]struct{})
+	)
+	for _, matchers := range matcherSets {
+		vals, w, err := fetch(matchers...)
+		if err != nil {
+			return nil, w, err
+		}
+		warnings = append(warnings, w...)
+		for _, v := range vals {
+			unique[v] = struct{}{}
+		}
+	}
+
+	result := make([]string, 0, len(unique))
+	for v := range unique {
+		result = append(result, v)
+	}
+	sort.Strings(result)
+	return result, warnings, nil
+}
+
 func respondLabels(w http.ResponseWriter, res *promql.Result, warnings storage.Warnings) {
 	setResponseHeaders(w, res, false, warnings)
 	resp := &response{
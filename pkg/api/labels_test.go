@@ -24,10 +24,12 @@ func TestLabels(t *testing.T) {
 	})
 	testCases := []struct {
 		name         string
+		params       string
 		querier      *mockQuerier
 		labelsReader *mockLabelsReader
 		expectCode   int
 		expectError  string
+		expectNames  []string
 	}{
 		{
 			name:         "Error on get label names",
@@ -40,12 +42,41 @@ func TestLabels(t *testing.T) {
 			expectCode:   http.StatusOK,
 			querier:      &mockQuerier{},
 			labelsReader: &mockLabelsReader{labelNames: []string{"a"}},
+			expectNames:  []string{"a"},
+		}, {
+			name:         "End before start",
+			params:       "?start=10&end=5",
+			expectCode:   http.StatusBadRequest,
+			expectError:  "bad_data",
+			querier:      &mockQuerier{},
+			labelsReader: &mockLabelsReader{},
+		}, {
+			name:         "Invalid matcher",
+			params:       "?match[]=up{",
+			expectCode:   http.StatusBadRequest,
+			expectError:  "bad_data",
+			querier:      &mockQuerier{},
+			labelsReader: &mockLabelsReader{},
+		}, {
+			name:         "Error on get label names with matchers",
+			params:       "?match[]=up",
+			expectCode:   http.StatusInternalServerError,
+			expectError:  "internal",
+			querier:      &mockQuerier{},
+			labelsReader: &mockLabelsReader{labelNamesErr: fmt.Errorf("error on label names")},
+		}, {
+			name:         "With matchers and time range",
+			params:       "?match[]=up&match[]={job=%22a%22}&start=1&end=10",
+			expectCode:   http.StatusOK,
+			querier:      &mockQuerier{},
+			labelsReader: &mockLabelsReader{labelNames: []string{"__name__", "job"}},
+			expectNames:  []string{"__name__", "job"},
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			handler := labelsHandler(query.NewQueryable(nil, tc.labelsReader))
-			w := doLabels(t, handler)
+			handler := labelsHandler(query.NewQueryable(tc.querier, tc.labelsReader))
+			w := doLabels(t, handler, tc.params)
 
 			if w.Code != tc.expectCode {
 				t.Errorf("Unexpected HTTP status code received: got %d wanted %d", w.Code, tc.expectCode)
@@ -68,8 +99,8 @@ func TestLabels(t *testing.T) {
 			for _, s := range res.Data.([]interface{}) {
 				resStr = append(resStr, s.(string))
 			}
-			if !reflect.DeepEqual(resStr, tc.labelsReader.labelNames) {
-				t.Errorf("expected: %v, got: %v", tc.labelsReader.labelNames, res.Data)
+			if !reflect.DeepEqual(resStr, tc.expectNames) {
+				t.Errorf("expected: %v, got: %v", tc.expectNames, res.Data)
 			}
 		})
 
@@ -77,8 +108,8 @@ func TestLabels(t *testing.T) {
 
 }
 
-func doLabels(t *testing.T, queryHandler http.Handler) *httptest.ResponseRecorder {
-	req, err := http.NewRequestWithContext(context.Background(), "GET", "http://localhost:9090/labels", nil)
+func doLabels(t *testing.T, queryHandler http.Handler, params string) *httptest.ResponseRecorder {
+	req, err := http.NewRequestWithContext(context.Background(), "GET", "http://localhost:9090/labels"+params, nil)
 	if err != nil {
 		t.Errorf("%v", err)
 	}
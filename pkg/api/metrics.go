@@ -5,9 +5,11 @@
 package api
 
 import (
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/rules"
 	"github.com/timescale/promscale/pkg/util"
 )
 
@@ -34,6 +36,7 @@ type Metrics struct {
 	InvalidWriteReqs      prometheus.Counter
 	InvalidQueryReqs      prometheus.Counter
 	HTTPRequestDuration   *prometheus.HistogramVec
+	RuleGroups            *RuleGroupsCollector
 }
 
 // InitMetrics sets up and returns the Prometheus metrics which Promscale exposes.
@@ -60,6 +63,7 @@ func InitMetrics() *Metrics {
 		metrics.QueryDuration,
 		metrics.ExemplarQueryDuration,
 		metrics.HTTPRequestDuration,
+		metrics.RuleGroups,
 	)
 
 	return metrics
@@ -193,5 +197,93 @@ func createMetrics() *Metrics {
 			},
 			[]string{"path"},
 		),
+		RuleGroups: newRuleGroupsCollector(),
+	}
+}
+
+// RuleGroupsCollector exposes the evaluation state of the rule groups
+// evaluated by Promscale. The rule groups are read at collection time,
+// from the retriever set with SetRetriever.
+type RuleGroupsCollector struct {
+	mux       sync.RWMutex
+	retriever RulesRetriever
+
+	lastEvaluation *prometheus.Desc
+	lastDuration   *prometheus.Desc
+	interval       *prometheus.Desc
+	rules          *prometheus.Desc
+	unhealthyRules *prometheus.Desc
+}
+
+func newRuleGroupsCollector() *RuleGroupsCollector {
+	groupLabels := []string{"rule_group"}
+	return &RuleGroupsCollector{
+		lastEvaluation: prometheus.NewDesc(
+			prometheus.BuildFQName(util.PromNamespace, "", "rule_group_last_evaluation_timestamp_seconds"),
+			"The timestamp of the last rule group evaluation in seconds.",
+			groupLabels, nil,
+		),
+		lastDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(util.PromNamespace, "", "rule_group_last_duration_seconds"),
+			"The duration of the last rule group evaluation in seconds.",
+			groupLabels, nil,
+		),
+		interval: prometheus.NewDesc(
+			prometheus.BuildFQName(util.PromNamespace, "", "rule_group_interval_seconds"),
+			"The interval of a rule group in seconds.",
+			groupLabels, nil,
+		),
+		rules: prometheus.NewDesc(
+			prometheus.BuildFQName(util.PromNamespace, "", "rule_group_rules"),
+			"The number of rules in a rule group.",
+			groupLabels, nil,
+		),
+		unhealthyRules: prometheus.NewDesc(
+			prometheus.BuildFQName(util.PromNamespace, "", "rule_group_unhealthy_rules"),
+			"The number of rules in a rule group whose last evaluation failed.",
+			groupLabels, nil,
+		),
+	}
+}
+
+// SetRetriever sets the source of the rule groups to collect.
+func (c *RuleGroupsCollector) SetRetriever(retriever RulesRetriever) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.retriever = retriever
+}
+
+func (c *RuleGroupsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lastEvaluation
+	ch <- c.lastDuration
+	ch <- c.interval
+	ch <- c.rules
+	ch <- c.unhealthyRules
+}
+
+func (c *RuleGroupsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mux.RLock()
+	retriever := c.retriever
+	c.mux.RUnlock()
+	if retriever == nil {
+		return
+	}
+	for _, g := range retriever.RuleGroups() {
+		key := rules.GroupKey(g.File(), g.Name())
+		unhealthy := 0
+		for _, r := range g.Rules() {
+			if r.Health() == rules.HealthBad {
+				unhealthy++
+			}
+		}
+		lastEvaluation := 0.0
+		if ts := g.GetLastEvaluation(); !ts.IsZero() {
+			lastEvaluation = float64(ts.UnixNano()) / 1e9
+		}
+		ch <- prometheus.MustNewConstMetric(c.lastEvaluation, prometheus.GaugeValue, lastEvaluation, key)
+		ch <- prometheus.MustNewConstMetric(c.lastDuration, prometheus.GaugeValue, g.GetEvaluationTime().Seconds(), key)
+		ch <- prometheus.MustNewConstMetric(c.interval, prometheus.GaugeValue, g.Interval().Seconds(), key)
+		ch <- prometheus.MustNewConstMetric(c.rules, prometheus.GaugeValue, float64(len(g.Rules())), key)
+		ch <- prometheus.MustNewConstMetric(c.unhealthyRules, prometheus.GaugeValue, float64(unhealthy), key)
 	}
 }
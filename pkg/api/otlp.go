@@ -6,21 +6,257 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
 
+	"github.com/timescale/promscale/pkg/api/parser"
+	"github.com/timescale/promscale/pkg/api/parser/otlp"
+	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/tenancy"
+	"github.com/timescale/promscale/pkg/util"
+	otlpencoding "go.opentelemetry.io/collector/model/otlp"
 	"go.opentelemetry.io/collector/model/otlpgrpc"
+	"go.opentelemetry.io/collector/model/pdata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-func NewTraceServer(i ingestor.DBInserter) otlpgrpc.TracesServer {
+// NewTraceServer returns an OTLP gRPC traces server that ingests the received
+// traces. If wAuth is not nil, the traces are authorized for the tenant of the
+// `tenant` metadata of the request.
+func NewTraceServer(i ingestor.DBInserter, wAuth tenancy.WriteAuthorizer) otlpgrpc.TracesServer {
 	return &tracesServer{
 		ingestor: i,
+		wAuth:    wAuth,
 	}
 }
 
 type tracesServer struct {
 	ingestor ingestor.DBInserter
+	wAuth    tenancy.WriteAuthorizer
 }
 
 func (t *tracesServer) Export(ctx context.Context, tr otlpgrpc.TracesRequest) (otlpgrpc.TracesResponse, error) {
-	return otlpgrpc.NewTracesResponse(), t.ingestor.IngestTraces(ctx, tr.Traces())
+	traces := tr.Traces()
+	if t.wAuth != nil {
+		if err := t.wAuth.ProcessTraces(tenancy.TenantFromMetadata(ctx), traces); err != nil {
+			return otlpgrpc.NewTracesResponse(), preprocessingStatus(err)
+		}
+	}
+	return otlpgrpc.NewTracesResponse(), t.ingestor.IngestTraces(ctx, traces)
+}
+
+// preprocessingStatus returns the gRPC status of a write rejected by the HA or
+// multi-tenancy rules.
+func preprocessingStatus(err error) error {
+	code := codes.InvalidArgument
+	if errors.Is(err, tenancy.ErrUnauthorizedTenant) {
+		code = codes.PermissionDenied
+	}
+	return status.Error(code, err.Error())
+}
+
+// grpcRequest returns an HTTP request with the context of a gRPC request, with
+// the tenant of its metadata as tenant header, for the preprocessors of writes.
+func grpcRequest(ctx context.Context) *http.Request {
+	r := (&http.Request{Header: http.Header{}}).WithContext(ctx)
+	if tenant := tenancy.TenantFromMetadata(ctx); tenant != "" {
+		r.Header.Set(tenancy.TenantHeaderKey, tenant)
+	}
+	return r
+}
+
+// NewMetricsServer returns an OTLP gRPC metrics server that converts the received
+// metrics to Prometheus series and ingests them, after running the preprocessors
+// of dataParser like for HTTP writes. The tenant of a request is taken from its
+// `tenant` metadata. With a non-nil elector, only the leader ingests metrics.
+func NewMetricsServer(i ingestor.DBInserter, dataParser *parser.DefaultParser, elector *util.Elector) otlpgrpc.MetricsServer {
+	return &metricsServer{
+		ingestor:   i,
+		dataParser: dataParser,
+		elector:    elector,
+	}
+}
+
+type metricsServer struct {
+	ingestor   ingestor.DBInserter
+	dataParser *parser.DefaultParser
+	elector    *util.Elector
+}
+
+func (m *metricsServer) Export(ctx context.Context, mr otlpgrpc.MetricsRequest) (otlpgrpc.MetricsResponse, error) {
+	if m.elector != nil && !isLegacyHALeader(m.elector) {
+		return otlpgrpc.NewMetricsResponse(), nil
+	}
+	wr := ingestor.NewWriteRequest()
+	otlp.ConvertMetrics(mr.Metrics(), wr)
+	if err := m.dataParser.Preprocess(grpcRequest(ctx), wr); err != nil {
+		ingestor.FinishWriteRequest(wr)
+		return otlpgrpc.NewMetricsResponse(), preprocessingStatus(err)
+	}
+	if len(wr.Timeseries) == 0 && len(wr.Metadata) == 0 {
+		ingestor.FinishWriteRequest(wr)
+		return otlpgrpc.NewMetricsResponse(), nil
+	}
+	_, _, err := m.ingestor.Ingest(wr)
+	return otlpgrpc.NewMetricsResponse(), err
+}
+
+// OTLPMetricsWrite returns an http.Handler that ingests OTLP/HTTP metrics export
+// requests in the protobuf or JSON encoding.
+func OTLPMetricsWrite(conf *Config, inserter ingestor.DBInserter, dataParser *parser.DefaultParser, elector *util.Elector) http.Handler {
+	wh := writeHandler{}
+	wh.addStages(
+		validateOTLPMetricsWriteHeaders,
+		checkLegacyHA(elector),
+		decodeGzip,
+		ingest(inserter, dataParser),
+		respondOTLP,
+	)
+	return corsWrapper(conf, wh.handler().ServeHTTP)
+}
+
+// OTLPTracesWrite returns an http.Handler that ingests OTLP/HTTP traces export
+// requests in the protobuf or JSON encoding.
+func OTLPTracesWrite(conf *Config, inserter ingestor.DBInserter) http.Handler {
+	wh := writeHandler{}
+	wh.addStages(
+		validateOTLPTracesWriteHeaders,
+		decodeGzip,
+		ingestTraces(inserter, otlpTracesUnmarshalers, traceWriteAuthorizer(conf)),
+		respondOTLP,
+	)
+	return corsWrapper(conf, wh.handler().ServeHTTP)
+}
+
+// Preflight returns an http.Handler that answers the CORS preflight requests
+// browsers send before exporting data to the trace and OTLP endpoints.
+func Preflight(conf *Config) http.Handler {
+	return corsWrapper(conf, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+const (
+	otlpProtobufContentType = "application/x-protobuf"
+	otlpJSONContentType     = "application/json"
+)
+
+var (
+	otlpMetricsContentTypes = map[string]string{
+		otlpProtobufContentType: otlp.ProtobufContentType,
+		otlpJSONContentType:     otlp.JSONContentType,
+	}
+	otlpTracesUnmarshalers = map[string]tracesUnmarshaler{
+		otlpProtobufContentType: otlpencoding.NewProtobufTracesUnmarshaler().UnmarshalTraces,
+		otlpJSONContentType:     otlpencoding.NewJSONTracesUnmarshaler().UnmarshalTraces,
+	}
+)
+
+// validateOTLPWriteHeaders checks the request method and returns the media type of
+// an OTLP/HTTP request.
+func validateOTLPWriteHeaders(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Method != "POST" {
+		validateError(w, fmt.Sprintf("HTTP Method %s instead of POST", r.Method), metrics)
+		return "", false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		validateError(w, "Error parsing media type from Content-Type header", metrics)
+		return "", false
+	}
+	if mediaType != otlpProtobufContentType && mediaType != otlpJSONContentType {
+		validateError(w, "unsupported data format (not protobuf or JSON)", metrics)
+		return "", false
+	}
+	return mediaType, true
+}
+
+// validateOTLPMetricsWriteHeaders validates the headers of an OTLP/HTTP metrics
+// request and marks the body as OTLP metrics of that encoding.
+func validateOTLPMetricsWriteHeaders(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, ok := validateOTLPWriteHeaders(w, r)
+	if !ok {
+		return false
+	}
+	r.Header.Set("Content-Type", otlpMetricsContentTypes[mediaType])
+	return true
+}
+
+func validateOTLPTracesWriteHeaders(w http.ResponseWriter, r *http.Request) bool {
+	_, ok := validateOTLPWriteHeaders(w, r)
+	return ok
+}
+
+// traceWriteAuthorizer returns the authorizer of trace writes, which is nil
+// without multi-tenancy.
+func traceWriteAuthorizer(conf *Config) tenancy.WriteAuthorizer {
+	if conf.MultiTenancy == nil {
+		return nil
+	}
+	return conf.MultiTenancy.WriteAuthorizer()
+}
+
+// tracesUnmarshaler decodes a request body into OpenTelemetry traces.
+type tracesUnmarshaler func([]byte) (pdata.Traces, error)
+
+// ingestTraces decodes the request body with the unmarshaler of its media type
+// and ingests the traces, after authorizing them for the tenant of the request
+// if wAuth is not nil.
+func ingestTraces(inserter ingestor.DBInserter, unmarshalers map[string]tracesUnmarshaler, wAuth tenancy.WriteAuthorizer) writeStage {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		unmarshal, ok := unmarshalers[mediaType]
+		if !ok {
+			validateError(w, fmt.Sprintf("unsupported media type %s", mediaType), metrics)
+			return false
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			invalidRequestError(w, "request body read error", err.Error(), metrics)
+			return false
+		}
+		traces, err := unmarshal(b)
+		if err != nil {
+			invalidRequestError(w, "traces decode error", err.Error(), metrics)
+			return false
+		}
+		if traces.SpanCount() == 0 {
+			return true
+		}
+		if wAuth != nil {
+			if err = wAuth.ProcessTraces(tenancy.TenantFromHeader(r), traces); err != nil {
+				invalidRequestError(w, "tenancy error", err.Error(), metrics)
+				return false
+			}
+		}
+
+		if err = inserter.IngestTraces(r.Context(), traces); err != nil {
+			log.Warn("msg", "Error sending spans to remote storage", "err", err, "num_spans", traces.SpanCount())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return false
+		}
+		return true
+	}
+}
+
+// respondOTLP responds with an empty export response in the encoding of the request,
+// as the OTLP/HTTP specification requires.
+func respondOTLP(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == otlpJSONContentType || mediaType == otlp.JSONContentType {
+		w.Header().Set("Content-Type", otlpJSONContentType)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+		return true
+	}
+	// An empty protobuf message is encoded as zero bytes.
+	w.Header().Set("Content-Type", otlpProtobufContentType)
+	w.WriteHeader(http.StatusOK)
+	return true
 }
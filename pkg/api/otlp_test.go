@@ -0,0 +1,338 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/api/parser"
+	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/tenancy"
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/otlpgrpc"
+	"go.opentelemetry.io/collector/model/pdata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func testOTLPMetrics() pdata.Metrics {
+	md := pdata.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().InsertString("service.name", "api")
+	m := rm.InstrumentationLibraryMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("up")
+	m.SetDataType(pdata.MetricDataTypeGauge)
+	p := m.Gauge().DataPoints().AppendEmpty()
+	p.SetTimestamp(pdata.NewTimestampFromTime(time.Unix(1600000000, 0)))
+	p.SetDoubleVal(1)
+	return md
+}
+
+var testOTLPSeries = []prompb.TimeSeries{{
+	Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "api"}},
+	Samples: []prompb.Sample{{Timestamp: 1600000000000, Value: 1}},
+}}
+
+func setMockWriteMetrics() {
+	metrics = &Metrics{
+		LeaderGauge:       &mockMetric{},
+		ReceivedSamples:   &mockMetric{},
+		ReceivedMetadata:  &mockMetric{},
+		FailedSamples:     &mockMetric{},
+		FailedMetadata:    &mockMetric{},
+		SentSamples:       &mockMetric{},
+		SentMetadata:      &mockMetric{},
+		SentBatchDuration: &mockMetric{},
+		InvalidWriteReqs:  &mockMetric{},
+	}
+}
+
+func TestOTLPMetricsWrite(t *testing.T) {
+	setMockWriteMetrics()
+
+	pb, err := otlp.NewProtobufMetricsMarshaler().MarshalMetrics(testOTLPMetrics())
+	require.NoError(t, err)
+	js, err := otlp.NewJSONMetricsMarshaler().MarshalMetrics(testOTLPMetrics())
+	require.NoError(t, err)
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err = gw.Write(pb)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	testCases := []struct {
+		name         string
+		method       string
+		body         []byte
+		headers      map[string]string
+		responseCode int
+		responseBody string
+		result       []prompb.TimeSeries
+	}{
+		{
+			name:         "protobuf",
+			method:       http.MethodPost,
+			body:         pb,
+			headers:      map[string]string{"Content-Type": "application/x-protobuf"},
+			responseCode: http.StatusOK,
+			result:       testOTLPSeries,
+		},
+		{
+			name:         "json",
+			method:       http.MethodPost,
+			body:         js,
+			headers:      map[string]string{"Content-Type": "application/json"},
+			responseCode: http.StatusOK,
+			responseBody: "{}",
+			result:       testOTLPSeries,
+		},
+		{
+			name:         "gzip",
+			method:       http.MethodPost,
+			body:         gzipped.Bytes(),
+			headers:      map[string]string{"Content-Type": "application/x-protobuf", "Content-Encoding": "gzip"},
+			responseCode: http.StatusOK,
+			result:       testOTLPSeries,
+		},
+		{
+			name:         "invalid payload",
+			method:       http.MethodPost,
+			body:         []byte("{"),
+			headers:      map[string]string{"Content-Type": "application/json"},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unsupported content type",
+			method:       http.MethodPost,
+			body:         pb,
+			headers:      map[string]string{"Content-Type": "text/plain"},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "wrong method",
+			method:       http.MethodGet,
+			headers:      map[string]string{"Content-Type": "application/x-protobuf"},
+			responseCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockInserter{}
+			req := httptest.NewRequest(c.method, "/v1/metrics", bytes.NewReader(c.body))
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			OTLPMetricsWrite(&Config{}, mock, parser.NewParser(), nil).ServeHTTP(w, req)
+
+			require.Equal(t, c.responseCode, w.Code, w.Body.String())
+			require.Equal(t, c.result, mock.ts)
+			if c.responseCode == http.StatusOK {
+				require.Equal(t, c.responseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMetricsServer(t *testing.T) {
+	mock := &mockInserter{}
+	req := otlpgrpc.NewMetricsRequest()
+	req.SetMetrics(testOTLPMetrics())
+
+	_, err := NewMetricsServer(mock, parser.NewParser(), nil).Export(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, testOTLPSeries, mock.ts)
+
+	mock = &mockInserter{}
+	_, err = NewMetricsServer(mock, parser.NewParser(), nil).Export(context.Background(), otlpgrpc.NewMetricsRequest())
+	require.NoError(t, err)
+	require.Nil(t, mock.ts)
+}
+
+func TestMetricsServerTenancy(t *testing.T) {
+	authr, err := tenancy.NewAuthorizer(tenancy.NewSelectiveTenancyConfig([]string{"tenant-a"}, false))
+	require.NoError(t, err)
+	dataParser := parser.NewParser()
+	dataParser.AddPreprocessor(authr.WriteAuthorizer())
+	req := otlpgrpc.NewMetricsRequest()
+	req.SetMetrics(testOTLPMetrics())
+
+	mock := &mockInserter{}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", "tenant-a"))
+	_, err = NewMetricsServer(mock, dataParser, nil).Export(ctx, req)
+	require.NoError(t, err)
+	require.NotEmpty(t, mock.ts)
+	for _, ts := range mock.ts {
+		require.Contains(t, ts.Labels, prompb.Label{Name: tenancy.TenantLabelKey, Value: "tenant-a"})
+	}
+
+	mock = &mockInserter{}
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", "tenant-b"))
+	_, err = NewMetricsServer(mock, dataParser, nil).Export(ctx, req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	require.Nil(t, mock.ts)
+
+	_, err = NewMetricsServer(mock, dataParser, nil).Export(context.Background(), req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err), "series without a tenant are rejected")
+	require.Nil(t, mock.ts)
+}
+
+func testOTLPTraces() pdata.Traces {
+	traces := pdata.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().InsertString("service.name", "frontend")
+	span := rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetName("GET /")
+	span.SetTraceID(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+	span.SetSpanID(pdata.NewSpanID([8]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	return traces
+}
+
+func TestOTLPTracesWrite(t *testing.T) {
+	setMockWriteMetrics()
+
+	pb, err := otlp.NewProtobufTracesMarshaler().MarshalTraces(testOTLPTraces())
+	require.NoError(t, err)
+	js, err := otlp.NewJSONTracesMarshaler().MarshalTraces(testOTLPTraces())
+	require.NoError(t, err)
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err = gw.Write(js)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	testCases := []struct {
+		name         string
+		method       string
+		body         []byte
+		headers      map[string]string
+		ingestErr    error
+		responseCode int
+		responseBody string
+		spans        int
+	}{
+		{
+			name:         "protobuf",
+			method:       http.MethodPost,
+			body:         pb,
+			headers:      map[string]string{"Content-Type": "application/x-protobuf"},
+			responseCode: http.StatusOK,
+			spans:        1,
+		},
+		{
+			name:         "json with CORS",
+			method:       http.MethodPost,
+			body:         js,
+			headers:      map[string]string{"Content-Type": "application/json", "Origin": "https://shop.example.com"},
+			responseCode: http.StatusOK,
+			responseBody: "{}",
+			spans:        1,
+		},
+		{
+			name:         "gzip",
+			method:       http.MethodPost,
+			body:         gzipped.Bytes(),
+			headers:      map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+			responseCode: http.StatusOK,
+			responseBody: "{}",
+			spans:        1,
+		},
+		{
+			name:         "no spans",
+			method:       http.MethodPost,
+			headers:      map[string]string{"Content-Type": "application/x-protobuf"},
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "invalid payload",
+			method:       http.MethodPost,
+			body:         []byte("{"),
+			headers:      map[string]string{"Content-Type": "application/json"},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unsupported content type",
+			method:       http.MethodPost,
+			body:         js,
+			headers:      map[string]string{"Content-Type": "text/plain"},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "ingest error",
+			method:       http.MethodPost,
+			body:         pb,
+			headers:      map[string]string{"Content-Type": "application/x-protobuf"},
+			ingestErr:    fmt.Errorf("connection refused"),
+			responseCode: http.StatusInternalServerError,
+			spans:        1,
+		},
+	}
+
+	conf := &Config{AllowedOrigin: regexp.MustCompile("^(?:https://shop\\.example\\.com)$")}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockInserter{err: c.ingestErr}
+			req := httptest.NewRequest(c.method, "/v1/traces", bytes.NewReader(c.body))
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			OTLPTracesWrite(conf, mock).ServeHTTP(w, req)
+
+			require.Equal(t, c.responseCode, w.Code, w.Body.String())
+			require.Len(t, mock.traces, c.spans)
+			for _, traces := range mock.traces {
+				require.Equal(t, "GET /", traces.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans().At(0).Name())
+			}
+			if c.responseCode == http.StatusOK {
+				require.Equal(t, c.responseBody, w.Body.String())
+			}
+			if origin := c.headers["Origin"]; origin != "" {
+				require.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+}
+
+func TestTraceServerTenancy(t *testing.T) {
+	authr, err := tenancy.NewAuthorizer(tenancy.NewSelectiveTenancyConfig([]string{"tenant-a"}, false))
+	require.NoError(t, err)
+	tenantCtx := func(tenant string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", tenant))
+	}
+
+	mock := &mockInserter{}
+	server := NewTraceServer(mock, authr.WriteAuthorizer())
+	req := otlpgrpc.NewTracesRequest()
+	req.SetTraces(testOTLPTraces())
+	_, err = server.Export(tenantCtx("tenant-a"), req)
+	require.NoError(t, err)
+	require.Len(t, mock.traces, 1)
+	tenant, found := mock.traces[0].ResourceSpans().At(0).Resource().Attributes().Get(tenancy.TenantAttributeKey)
+	require.True(t, found)
+	require.Equal(t, "tenant-a", tenant.StringVal())
+
+	mock = &mockInserter{}
+	server = NewTraceServer(mock, authr.WriteAuthorizer())
+	req.SetTraces(testOTLPTraces())
+	_, err = server.Export(tenantCtx("tenant-b"), req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	require.Empty(t, mock.traces)
+
+	req.SetTraces(testOTLPTraces())
+	_, err = server.Export(context.Background(), req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err), "traces without a tenant are rejected")
+}
@@ -0,0 +1,239 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package influx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+// ContentType is the media type under which the InfluxDB line protocol parser is
+// registered. InfluxDB clients send the line protocol as text/plain, so the write
+// endpoints of the protocol set this media type before parsing.
+const ContentType = "application/x-influxdb-line-protocol"
+
+// valueField is the field name that Telegraf and other tools use for single value
+// measurements. Its metric name is the plain measurement name.
+const valueField = "value"
+
+var timeProvider = time.Now
+
+// precisions maps the precision URL parameter of the InfluxDB 1.x and 2.x write
+// APIs to the duration of a timestamp unit.
+var precisions = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"n":  time.Nanosecond,
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// ParseRequest parses an incoming HTTP request in the InfluxDB line protocol. Every
+// numeric or boolean field of a line becomes a sample of the metric named
+// <measurement>_<field>, labeled by the tags of the line. String fields are skipped,
+// since they cannot be stored as sample values.
+func ParseRequest(r *http.Request, wr *prompb.WriteRequest) error {
+	precision, ok := precisions[r.URL.Query().Get("precision")]
+	if !ok {
+		return fmt.Errorf("invalid precision: %s", r.URL.Query().Get("precision"))
+	}
+
+	var (
+		defTime = model.TimeFromUnixNano(timeProvider().UnixNano())
+		reader  = bufio.NewReader(r.Body)
+		lineNum = 0
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("error reading request body: %w", err)
+		}
+		lineNum++
+		if perr := parseLine(bytes.TrimSpace(line), precision, defTime, wr); perr != nil {
+			return fmt.Errorf("line %d: %w", lineNum, perr)
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func parseLine(line []byte, precision time.Duration, defTime model.Time, wr *prompb.WriteRequest) error {
+	if len(line) == 0 || line[0] == '#' {
+		return nil
+	}
+
+	seriesEnd := indexUnescaped(line, ' ', false)
+	if seriesEnd < 0 {
+		return fmt.Errorf("missing fields")
+	}
+	series, rest := line[:seriesEnd], bytes.TrimLeft(line[seriesEnd:], " ")
+
+	fieldsEnd := indexUnescaped(rest, ' ', true)
+	if fieldsEnd < 0 {
+		fieldsEnd = len(rest)
+	}
+	fields, ts := rest[:fieldsEnd], bytes.TrimSpace(rest[fieldsEnd:])
+
+	t := defTime
+	if len(ts) > 0 {
+		n, err := strconv.ParseInt(string(ts), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %s: %w", ts, err)
+		}
+		if p := int64(precision); n > math.MaxInt64/p || n < math.MinInt64/p {
+			return fmt.Errorf("timestamp %s out of range", ts)
+		}
+		t = model.TimeFromUnixNano(n * int64(precision))
+	}
+
+	parts := splitUnescaped(series, ',', false)
+	measurement := unescape(parts[0])
+	if measurement == "" {
+		return fmt.Errorf("missing measurement")
+	}
+	tags := make([]prompb.Label, 0, len(parts))
+	// Distinct tag keys can be sanitized to the same label name. The line is
+	// rejected in that case, since a series cannot have duplicate labels.
+	tagKeys := make(map[string]string, len(parts)-1)
+	for _, tag := range parts[1:] {
+		kv := splitUnescaped(tag, '=', false)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			return fmt.Errorf("invalid tag %s", tag)
+		}
+		name := util.SanitizeLabelName(unescape(kv[0]))
+		if name == model.MetricNameLabel {
+			return fmt.Errorf("tag key %s is reserved", model.MetricNameLabel)
+		}
+		if key, ok := tagKeys[name]; ok {
+			return fmt.Errorf("tag keys %s and %s both map to label %s", key, kv[0], name)
+		}
+		tagKeys[name] = string(kv[0])
+		if value := unescape(kv[1]); value != "" {
+			tags = append(tags, prompb.Label{Name: name, Value: value})
+		}
+	}
+
+	for _, field := range splitUnescaped(fields, ',', true) {
+		kv := splitUnescaped(field, '=', true)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			return fmt.Errorf("invalid field %s", field)
+		}
+		key := unescape(kv[0])
+		value, ok, err := parseFieldValue(kv[1])
+		if err != nil {
+			return fmt.Errorf("invalid value of field %s: %w", key, err)
+		}
+		if !ok {
+			continue
+		}
+
+		name := measurement
+		if key != valueField {
+			name += "_" + key
+		}
+		labels := make([]prompb.Label, 0, len(tags)+1)
+		labels = append(labels, prompb.Label{Name: model.MetricNameLabel, Value: util.SanitizeMetricName(name)})
+		labels = append(labels, tags...)
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+		wr.Timeseries = append(wr.Timeseries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: []prompb.Sample{{Timestamp: int64(t), Value: value}},
+		})
+	}
+	return nil
+}
+
+// parseFieldValue parses a float, integer, unsigned integer or boolean field value.
+// It returns false for string values.
+func parseFieldValue(v []byte) (float64, bool, error) {
+	if len(v) == 0 {
+		return 0, false, fmt.Errorf("missing value")
+	}
+	switch s := string(v); {
+	case v[0] == '"':
+		if len(v) < 2 || v[len(v)-1] != '"' {
+			return 0, false, fmt.Errorf("unterminated string %s", v)
+		}
+		return 0, false, nil
+	case s == "t" || s == "T" || s == "true" || s == "True" || s == "TRUE":
+		return 1, true, nil
+	case s == "f" || s == "F" || s == "false" || s == "False" || s == "FALSE":
+		return 0, true, nil
+	case v[len(v)-1] == 'i':
+		n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		return float64(n), err == nil, err
+	case v[len(v)-1] == 'u':
+		n, err := strconv.ParseUint(s[:len(s)-1], 10, 64)
+		return float64(n), err == nil, err
+	default:
+		f, err := strconv.ParseFloat(s, 64)
+		if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			return 0, false, fmt.Errorf("%s is not a valid float", s)
+		}
+		return f, err == nil, err
+	}
+}
+
+// indexUnescaped returns the index of the first occurrence of sep that is neither
+// escaped by a backslash nor, if quoted is set, inside a double-quoted string.
+func indexUnescaped(b []byte, sep byte, quoted bool) int {
+	inString := false
+	for i := 0; i < len(b); i++ {
+		switch {
+		case b[i] == '\\':
+			i++
+		case quoted && b[i] == '"':
+			inString = !inString
+		case b[i] == sep && !inString:
+			return i
+		}
+	}
+	return -1
+}
+
+func splitUnescaped(b []byte, sep byte, quoted bool) [][]byte {
+	var parts [][]byte
+	for {
+		i := indexUnescaped(b, sep, quoted)
+		if i < 0 {
+			return append(parts, b)
+		}
+		parts = append(parts, b[:i])
+		b = b[i+1:]
+	}
+}
+
+func unescape(b []byte) string {
+	if bytes.IndexByte(b, '\\') < 0 {
+		return string(b)
+	}
+	res := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] == '\\' && i+1 < len(b) {
+			switch b[i+1] {
+			case ',', '=', ' ', '"', '\\':
+				i++
+			}
+		}
+		res = append(res, b[i])
+	}
+	return string(res)
+}
@@ -0,0 +1,155 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package influx
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+func TestParseRequest(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	timeProvider = func() time.Time { return now }
+
+	series := func(name string, t int64, v float64, labels ...prompb.Label) prompb.TimeSeries {
+		return prompb.TimeSeries{
+			Labels:  append([]prompb.Label{{Name: "__name__", Value: name}}, labels...),
+			Samples: []prompb.Sample{{Timestamp: t, Value: v}},
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		input     string
+		precision string
+		result    []prompb.TimeSeries
+		err       bool
+	}{
+		{
+			name:  "fields and tags",
+			input: "cpu,host=server01,region=us-west usage_idle=98.5,usage_user=1.5 1600000001000000000\n",
+			result: []prompb.TimeSeries{
+				series("cpu_usage_idle", 1600000001000, 98.5, prompb.Label{Name: "host", Value: "server01"}, prompb.Label{Name: "region", Value: "us-west"}),
+				series("cpu_usage_user", 1600000001000, 1.5, prompb.Label{Name: "host", Value: "server01"}, prompb.Label{Name: "region", Value: "us-west"}),
+			},
+		},
+		{
+			name:  "value field, no timestamp, comments and empty lines",
+			input: "# comment\n\ntemperature value=21.5\n",
+			result: []prompb.TimeSeries{
+				series("temperature", 1600000000000, 21.5),
+			},
+		},
+		{
+			name:  "field types",
+			input: `mem used=10i,free=20u,ok=true,down=F,status="running, fine" 1600000000000000000`,
+			result: []prompb.TimeSeries{
+				series("mem_used", 1600000000000, 10),
+				series("mem_free", 1600000000000, 20),
+				series("mem_ok", 1600000000000, 1),
+				series("mem_down", 1600000000000, 0),
+			},
+		},
+		{
+			name:  "escaping and sanitizing",
+			input: `disk\ io,dev\,ice=sda\ 1,0path=/ read.bytes=5 1600000000000000000`,
+			result: []prompb.TimeSeries{
+				{
+					Labels: []prompb.Label{
+						{Name: "_0path", Value: "/"},
+						{Name: "__name__", Value: "disk_io_read_bytes"},
+						{Name: "dev_ice", Value: "sda 1"},
+					},
+					Samples: []prompb.Sample{{Timestamp: 1600000000000, Value: 5}},
+				},
+			},
+		},
+		{
+			name:      "precision",
+			input:     "load value=1 1600000002",
+			precision: "s",
+			result: []prompb.TimeSeries{
+				series("load", 1600000002000, 1),
+			},
+		},
+		{
+			name:  "labels sorted by name",
+			input: "cpu,zone=b,Host=a,region=c value=1 1600000000000000000",
+			result: []prompb.TimeSeries{
+				{
+					Labels: []prompb.Label{
+						{Name: "Host", Value: "a"},
+						{Name: "__name__", Value: "cpu"},
+						{Name: "region", Value: "c"},
+						{Name: "zone", Value: "b"},
+					},
+					Samples: []prompb.Sample{{Timestamp: 1600000000000, Value: 1}},
+				},
+			},
+		},
+		{
+			name:  "missing fields",
+			input: "cpu,host=a",
+			err:   true,
+		},
+		{
+			name:  "invalid field value",
+			input: "cpu value=abc",
+			err:   true,
+		},
+		{
+			name:  "invalid timestamp",
+			input: "cpu value=1 yesterday",
+			err:   true,
+		},
+		{
+			name:      "timestamp out of range",
+			input:     "cpu value=1 9223372036854775",
+			precision: "s",
+			err:       true,
+		},
+		{
+			name:  "tag keys colliding after sanitizing",
+			input: "cpu,a-b=x,a.b=y value=1",
+			err:   true,
+		},
+		{
+			name:  "duplicate tag key",
+			input: "cpu,host=x,host=y value=1",
+			err:   true,
+		},
+		{
+			name:  "reserved tag",
+			input: "cpu,__name__=x value=1",
+			err:   true,
+		},
+		{
+			name:      "invalid precision",
+			input:     "cpu value=1",
+			precision: "d",
+			err:       true,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodPost, "/api/v2/write?precision="+c.precision, strings.NewReader(c.input))
+			require.NoError(t, err)
+			wr := &prompb.WriteRequest{}
+			err = ParseRequest(r, wr)
+			if c.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.result, wr.Timeseries)
+		})
+	}
+}
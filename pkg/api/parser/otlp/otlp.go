@@ -0,0 +1,339 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package otlp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/util"
+	otlpencoding "go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// ProtobufContentType and JSONContentType are the media types under which the OTLP
+// metrics parsers are registered. OTLP/HTTP shares the application/x-protobuf and
+// application/json media types with the other write formats, so the OTLP endpoint
+// sets these media types before parsing.
+const (
+	ProtobufContentType = "application/x-otlp-metrics+protobuf"
+	JSONContentType     = "application/x-otlp-metrics+json"
+)
+
+const (
+	serviceNameKey       = "service.name"
+	serviceNamespaceKey  = "service.namespace"
+	serviceInstanceIDKey = "service.instance.id"
+
+	jobLabel      = "job"
+	instanceLabel = "instance"
+	traceIDLabel  = "trace_id"
+	spanIDLabel   = "span_id"
+
+	totalSuffix  = "_total"
+	bucketSuffix = "_bucket"
+	sumSuffix    = "_sum"
+	countSuffix  = "_count"
+)
+
+var (
+	protobufUnmarshaler = otlpencoding.NewProtobufMetricsUnmarshaler()
+	jsonUnmarshaler     = otlpencoding.NewJSONMetricsUnmarshaler()
+)
+
+// ParseProtobufRequest parses an incoming OTLP/HTTP metrics export request in the
+// binary protobuf encoding.
+func ParseProtobufRequest(r *http.Request, wr *prompb.WriteRequest) error {
+	return parseRequest(r, wr, protobufUnmarshaler)
+}
+
+// ParseJSONRequest parses an incoming OTLP/HTTP metrics export request in the
+// JSON encoding.
+func ParseJSONRequest(r *http.Request, wr *prompb.WriteRequest) error {
+	return parseRequest(r, wr, jsonUnmarshaler)
+}
+
+func parseRequest(r *http.Request, wr *prompb.WriteRequest, unmarshaler pdata.MetricsUnmarshaler) error {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("error reading request body: %w", err)
+	}
+	md, err := unmarshaler.UnmarshalMetrics(b)
+	if err != nil {
+		return fmt.Errorf("error decoding OTLP metrics: %w", err)
+	}
+	ConvertMetrics(md, wr)
+	return nil
+}
+
+// ConvertMetrics appends the data points of OTLP metrics to a write request, in the
+// same way the Prometheus exporters of the OpenTelemetry Collector translate them:
+//   - gauges and non-monotonic sums become gauges,
+//   - monotonic sums become counters with a _total suffix,
+//   - histograms become _bucket series with an le label and _sum and _count series,
+//   - summaries become series with a quantile label and _sum and _count series.
+//
+// The job and instance labels are derived from the service.name, service.namespace
+// and service.instance.id resource attributes. Metrics with delta temporality have
+// no Prometheus representation and are skipped.
+func ConvertMetrics(md pdata.Metrics, wr *prompb.WriteRequest) {
+	metadata := make(map[string]struct{})
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		resLabels := resourceLabels(rm.Resource().Attributes())
+		ilms := rm.InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			metrics := ilms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				name, typ, ok := convertMetric(metrics.At(k), resLabels, wr)
+				if !ok {
+					continue
+				}
+				if _, seen := metadata[name]; seen {
+					continue
+				}
+				metadata[name] = struct{}{}
+				wr.Metadata = append(wr.Metadata, prompb.MetricMetadata{
+					Type:             typ,
+					MetricFamilyName: name,
+					Help:             metrics.At(k).Description(),
+					Unit:             metrics.At(k).Unit(),
+				})
+			}
+		}
+	}
+}
+
+// convertMetric appends the series of a metric to the write request. It returns the
+// metric family name and type, or false if the metric was skipped.
+func convertMetric(metric pdata.Metric, resLabels []prompb.Label, wr *prompb.WriteRequest) (string, prompb.MetricMetadata_MetricType, bool) {
+	name := util.SanitizeMetricName(metric.Name())
+	if name == "" {
+		return "", prompb.MetricMetadata_UNKNOWN, false
+	}
+
+	switch metric.DataType() {
+	case pdata.MetricDataTypeGauge:
+		convertNumberDataPoints(metric.Gauge().DataPoints(), name, resLabels, wr)
+		return name, prompb.MetricMetadata_GAUGE, true
+	case pdata.MetricDataTypeSum:
+		sum := metric.Sum()
+		if sum.AggregationTemporality() != pdata.AggregationTemporalityCumulative {
+			skipMetric(metric)
+			return "", prompb.MetricMetadata_UNKNOWN, false
+		}
+		if !sum.IsMonotonic() {
+			convertNumberDataPoints(sum.DataPoints(), name, resLabels, wr)
+			return name, prompb.MetricMetadata_GAUGE, true
+		}
+		if !strings.HasSuffix(name, totalSuffix) {
+			name += totalSuffix
+		}
+		convertNumberDataPoints(sum.DataPoints(), name, resLabels, wr)
+		return name, prompb.MetricMetadata_COUNTER, true
+	case pdata.MetricDataTypeHistogram:
+		histogram := metric.Histogram()
+		if histogram.AggregationTemporality() != pdata.AggregationTemporalityCumulative {
+			skipMetric(metric)
+			return "", prompb.MetricMetadata_UNKNOWN, false
+		}
+		convertHistogramDataPoints(histogram.DataPoints(), name, resLabels, wr)
+		return name, prompb.MetricMetadata_HISTOGRAM, true
+	case pdata.MetricDataTypeSummary:
+		convertSummaryDataPoints(metric.Summary().DataPoints(), name, resLabels, wr)
+		return name, prompb.MetricMetadata_SUMMARY, true
+	default:
+		skipMetric(metric)
+		return "", prompb.MetricMetadata_UNKNOWN, false
+	}
+}
+
+func skipMetric(metric pdata.Metric) {
+	log.DebugRateLimited("msg", "Skipping OTLP metric without Prometheus representation", "metric", metric.Name(), "type", metric.DataType().String())
+}
+
+func convertNumberDataPoints(points pdata.NumberDataPointSlice, name string, resLabels []prompb.Label, wr *prompb.WriteRequest) {
+	for i := 0; i < points.Len(); i++ {
+		p := points.At(i)
+		var value float64
+		switch p.Type() {
+		case pdata.MetricValueTypeInt:
+			value = float64(p.IntVal())
+		case pdata.MetricValueTypeDouble:
+			value = p.DoubleVal()
+		default:
+			continue
+		}
+		lbls := seriesLabels(resLabels, p.Attributes())
+		appendSeries(wr, name, lbls, p.Timestamp(), value, convertExemplars(p.Exemplars()))
+	}
+}
+
+func convertHistogramDataPoints(points pdata.HistogramDataPointSlice, name string, resLabels []prompb.Label, wr *prompb.WriteRequest) {
+	for i := 0; i < points.Len(); i++ {
+		p := points.At(i)
+		lbls := withoutLabel(seriesLabels(resLabels, p.Attributes()), model.BucketLabel)
+		bounds, counts := p.ExplicitBounds(), p.BucketCounts()
+
+		// Exemplars are attached to the bucket their value falls into.
+		exemplars := make([][]prompb.Exemplar, len(bounds)+1)
+		for _, e := range convertExemplars(p.Exemplars()) {
+			b := sort.SearchFloat64s(bounds, e.Value)
+			exemplars[b] = append(exemplars[b], e)
+		}
+
+		// OTLP bucket counts are not cumulative, Prometheus buckets are.
+		var cumulative uint64
+		for b, bound := range bounds {
+			if b < len(counts) {
+				cumulative += counts[b]
+			}
+			le := prompb.Label{Name: model.BucketLabel, Value: formatFloat(bound)}
+			appendSeries(wr, name+bucketSuffix, lbls, p.Timestamp(), float64(cumulative), exemplars[b], le)
+		}
+		inf := prompb.Label{Name: model.BucketLabel, Value: formatFloat(math.Inf(1))}
+		appendSeries(wr, name+bucketSuffix, lbls, p.Timestamp(), float64(p.Count()), exemplars[len(bounds)], inf)
+		appendSeries(wr, name+sumSuffix, lbls, p.Timestamp(), p.Sum(), nil)
+		appendSeries(wr, name+countSuffix, lbls, p.Timestamp(), float64(p.Count()), nil)
+	}
+}
+
+func convertSummaryDataPoints(points pdata.SummaryDataPointSlice, name string, resLabels []prompb.Label, wr *prompb.WriteRequest) {
+	for i := 0; i < points.Len(); i++ {
+		p := points.At(i)
+		lbls := withoutLabel(seriesLabels(resLabels, p.Attributes()), model.QuantileLabel)
+		quantiles := p.QuantileValues()
+		for q := 0; q < quantiles.Len(); q++ {
+			quantile := prompb.Label{Name: model.QuantileLabel, Value: formatFloat(quantiles.At(q).Quantile())}
+			appendSeries(wr, name, lbls, p.Timestamp(), quantiles.At(q).Value(), nil, quantile)
+		}
+		appendSeries(wr, name+sumSuffix, lbls, p.Timestamp(), p.Sum(), nil)
+		appendSeries(wr, name+countSuffix, lbls, p.Timestamp(), float64(p.Count()), nil)
+	}
+}
+
+// convertExemplars converts OTLP exemplars to Prometheus exemplars, labeled by
+// their trace and span IDs and their filtered attributes.
+func convertExemplars(exemplars pdata.ExemplarSlice) []prompb.Exemplar {
+	if exemplars.Len() == 0 {
+		return nil
+	}
+	res := make([]prompb.Exemplar, 0, exemplars.Len())
+	for i := 0; i < exemplars.Len(); i++ {
+		e := exemplars.At(i)
+		var value float64
+		switch e.Type() {
+		case pdata.MetricValueTypeInt:
+			value = float64(e.IntVal())
+		case pdata.MetricValueTypeDouble:
+			value = e.DoubleVal()
+		default:
+			continue
+		}
+		var lbls []prompb.Label
+		if !e.TraceID().IsEmpty() {
+			lbls = append(lbls, prompb.Label{Name: traceIDLabel, Value: e.TraceID().HexString()})
+		}
+		if !e.SpanID().IsEmpty() {
+			lbls = append(lbls, prompb.Label{Name: spanIDLabel, Value: e.SpanID().HexString()})
+		}
+		lbls = appendAttributes(lbls, e.FilteredAttributes())
+		res = append(res, prompb.Exemplar{
+			Labels:    lbls,
+			Value:     value,
+			Timestamp: int64(model.TimeFromUnixNano(int64(e.Timestamp()))),
+		})
+	}
+	return res
+}
+
+// resourceLabels returns the job and instance labels of a resource.
+func resourceLabels(attrs pdata.AttributeMap) []prompb.Label {
+	var lbls []prompb.Label
+	if service, ok := attrs.Get(serviceNameKey); ok && service.AsString() != "" {
+		job := service.AsString()
+		if namespace, ok := attrs.Get(serviceNamespaceKey); ok && namespace.AsString() != "" {
+			job = namespace.AsString() + "/" + job
+		}
+		lbls = append(lbls, prompb.Label{Name: jobLabel, Value: job})
+	}
+	if instance, ok := attrs.Get(serviceInstanceIDKey); ok && instance.AsString() != "" {
+		lbls = append(lbls, prompb.Label{Name: instanceLabel, Value: instance.AsString()})
+	}
+	return lbls
+}
+
+// seriesLabels merges the resource labels with the attributes of a data point.
+// Data point attributes take precedence over resource labels.
+func seriesLabels(resLabels []prompb.Label, attrs pdata.AttributeMap) []prompb.Label {
+	lbls := make([]prompb.Label, 0, len(resLabels)+attrs.Len())
+	lbls = appendAttributes(lbls, attrs)
+	for _, l := range resLabels {
+		if !hasLabel(lbls, l.Name) {
+			lbls = append(lbls, l)
+		}
+	}
+	return lbls
+}
+
+// appendAttributes appends attributes with non-empty values as labels with
+// sanitized names. The attributes are visited in an unspecified order, so of
+// attributes that map to the same label name an arbitrary one wins.
+func appendAttributes(lbls []prompb.Label, attrs pdata.AttributeMap) []prompb.Label {
+	attrs.Range(func(k string, v pdata.AttributeValue) bool {
+		name, value := util.SanitizeLabelName(k), v.AsString()
+		if name == "" || name == model.MetricNameLabel || value == "" || hasLabel(lbls, name) {
+			return true
+		}
+		lbls = append(lbls, prompb.Label{Name: name, Value: value})
+		return true
+	})
+	return lbls
+}
+
+func hasLabel(lbls []prompb.Label, name string) bool {
+	for _, l := range lbls {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutLabel removes a label that is reserved for the series of a metric type.
+func withoutLabel(lbls []prompb.Label, name string) []prompb.Label {
+	for i := range lbls {
+		if lbls[i].Name == name {
+			return append(lbls[:i], lbls[i+1:]...)
+		}
+	}
+	return lbls
+}
+
+func appendSeries(wr *prompb.WriteRequest, name string, lbls []prompb.Label, ts pdata.Timestamp, value float64, exemplars []prompb.Exemplar, extra ...prompb.Label) {
+	seriesLabels := make([]prompb.Label, 0, len(lbls)+len(extra)+1)
+	seriesLabels = append(seriesLabels, prompb.Label{Name: model.MetricNameLabel, Value: name})
+	seriesLabels = append(seriesLabels, lbls...)
+	seriesLabels = append(seriesLabels, extra...)
+	sort.Slice(seriesLabels, func(i, j int) bool { return seriesLabels[i].Name < seriesLabels[j].Name })
+	wr.Timeseries = append(wr.Timeseries, prompb.TimeSeries{
+		Labels:    seriesLabels,
+		Samples:   []prompb.Sample{{Timestamp: int64(model.TimeFromUnixNano(int64(ts))), Value: value}},
+		Exemplars: exemplars,
+	})
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,213 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package otlp
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/prompb"
+	otlpencoding "go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+var (
+	testTime = time.Unix(1600000000, 0)
+	testTS   = testTime.UnixNano() / int64(time.Millisecond)
+)
+
+func lbl(name, value string) prompb.Label {
+	return prompb.Label{Name: name, Value: value}
+}
+
+func series(value float64, labels ...prompb.Label) prompb.TimeSeries {
+	return prompb.TimeSeries{
+		Labels:  labels,
+		Samples: []prompb.Sample{{Timestamp: testTS, Value: value}},
+	}
+}
+
+// newMetric returns a new metric of a resource of the api service.
+func newMetric(name string, typ pdata.MetricDataType) (pdata.Metrics, pdata.Metric) {
+	md := pdata.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().InsertString("service.namespace", "shop")
+	rm.Resource().Attributes().InsertString("service.name", "api")
+	rm.Resource().Attributes().InsertString("service.instance.id", "api-0")
+	rm.Resource().Attributes().InsertString("host.name", "node-1")
+	m := rm.InstrumentationLibraryMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName(name)
+	m.SetDescription("A test metric.")
+	m.SetDataType(typ)
+	return md, m
+}
+
+func TestConvertGaugesAndSums(t *testing.T) {
+	traceID := pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	spanID := pdata.NewSpanID([8]byte{1, 2, 3, 4, 5, 6, 7, 8})
+
+	md, gauge := newMetric("memory.usage", pdata.MetricDataTypeGauge)
+	gauge.SetUnit("By")
+	p := gauge.Gauge().DataPoints().AppendEmpty()
+	p.SetTimestamp(pdata.NewTimestampFromTime(testTime))
+	p.SetIntVal(1024)
+	p.Attributes().InsertString("state", "used")
+	p.Attributes().InsertString("empty", "")
+
+	metrics := md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+	counter := metrics.AppendEmpty()
+	counter.SetName("http.requests")
+	counter.SetDataType(pdata.MetricDataTypeSum)
+	counter.Sum().SetIsMonotonic(true)
+	counter.Sum().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+	p = counter.Sum().DataPoints().AppendEmpty()
+	p.SetTimestamp(pdata.NewTimestampFromTime(testTime))
+	p.SetDoubleVal(10)
+	p.Attributes().InsertString("job", "override")
+	e := p.Exemplars().AppendEmpty()
+	e.SetTimestamp(pdata.NewTimestampFromTime(testTime))
+	e.SetDoubleVal(1)
+	e.SetTraceID(traceID)
+	e.SetSpanID(spanID)
+	e.FilteredAttributes().InsertString("user.id", "42")
+
+	upDown := metrics.AppendEmpty()
+	upDown.SetName("queue_length")
+	upDown.SetDataType(pdata.MetricDataTypeSum)
+	upDown.Sum().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+	p = upDown.Sum().DataPoints().AppendEmpty()
+	p.SetTimestamp(pdata.NewTimestampFromTime(testTime))
+	p.SetIntVal(3)
+
+	delta := metrics.AppendEmpty()
+	delta.SetName("delta")
+	delta.SetDataType(pdata.MetricDataTypeSum)
+	delta.Sum().SetIsMonotonic(true)
+	delta.Sum().SetAggregationTemporality(pdata.AggregationTemporalityDelta)
+	p = delta.Sum().DataPoints().AppendEmpty()
+	p.SetTimestamp(pdata.NewTimestampFromTime(testTime))
+	p.SetIntVal(3)
+
+	wr := &prompb.WriteRequest{}
+	ConvertMetrics(md, wr)
+
+	counterSeries := series(10, lbl("__name__", "http_requests_total"), lbl("instance", "api-0"), lbl("job", "override"))
+	counterSeries.Exemplars = []prompb.Exemplar{{
+		Labels:    []prompb.Label{lbl("trace_id", traceID.HexString()), lbl("span_id", spanID.HexString()), lbl("user_id", "42")},
+		Value:     1,
+		Timestamp: testTS,
+	}}
+	require.Equal(t, []prompb.TimeSeries{
+		series(1024, lbl("__name__", "memory_usage"), lbl("instance", "api-0"), lbl("job", "shop/api"), lbl("state", "used")),
+		counterSeries,
+		series(3, lbl("__name__", "queue_length"), lbl("instance", "api-0"), lbl("job", "shop/api")),
+	}, wr.Timeseries)
+	require.Equal(t, []prompb.MetricMetadata{
+		{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: "memory_usage", Help: "A test metric.", Unit: "By"},
+		{Type: prompb.MetricMetadata_COUNTER, MetricFamilyName: "http_requests_total"},
+		{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: "queue_length"},
+	}, wr.Metadata)
+}
+
+func TestConvertHistogram(t *testing.T) {
+	md, m := newMetric("request.duration", pdata.MetricDataTypeHistogram)
+	m.Histogram().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+	p := m.Histogram().DataPoints().AppendEmpty()
+	p.SetTimestamp(pdata.NewTimestampFromTime(testTime))
+	p.SetExplicitBounds([]float64{0.1, 1})
+	p.SetBucketCounts([]uint64{2, 3, 1})
+	p.SetCount(6)
+	p.SetSum(4.5)
+	p.Attributes().InsertString("le", "ignored")
+	for _, v := range []float64{0.5, 2} {
+		e := p.Exemplars().AppendEmpty()
+		e.SetTimestamp(pdata.NewTimestampFromTime(testTime))
+		e.SetDoubleVal(v)
+	}
+
+	wr := &prompb.WriteRequest{}
+	ConvertMetrics(md, wr)
+
+	bucket := func(le string, v float64, exemplarValue float64) prompb.TimeSeries {
+		s := series(v, lbl("__name__", "request_duration_bucket"), lbl("instance", "api-0"), lbl("job", "shop/api"), lbl("le", le))
+		if exemplarValue > 0 {
+			s.Exemplars = []prompb.Exemplar{{Value: exemplarValue, Timestamp: testTS}}
+		}
+		return s
+	}
+	require.Equal(t, []prompb.TimeSeries{
+		bucket("0.1", 2, 0),
+		bucket("1", 5, 0.5),
+		bucket("+Inf", 6, 2),
+		series(4.5, lbl("__name__", "request_duration_sum"), lbl("instance", "api-0"), lbl("job", "shop/api")),
+		series(6, lbl("__name__", "request_duration_count"), lbl("instance", "api-0"), lbl("job", "shop/api")),
+	}, wr.Timeseries)
+	require.Equal(t, []prompb.MetricMetadata{
+		{Type: prompb.MetricMetadata_HISTOGRAM, MetricFamilyName: "request_duration", Help: "A test metric."},
+	}, wr.Metadata)
+}
+
+func TestConvertSummary(t *testing.T) {
+	md, m := newMetric("rpc.latency", pdata.MetricDataTypeSummary)
+	p := m.Summary().DataPoints().AppendEmpty()
+	p.SetTimestamp(pdata.NewTimestampFromTime(testTime))
+	p.SetCount(10)
+	p.SetSum(20)
+	q := p.QuantileValues().AppendEmpty()
+	q.SetQuantile(0.99)
+	q.SetValue(5)
+
+	wr := &prompb.WriteRequest{}
+	ConvertMetrics(md, wr)
+
+	require.Equal(t, []prompb.TimeSeries{
+		series(5, lbl("__name__", "rpc_latency"), lbl("instance", "api-0"), lbl("job", "shop/api"), lbl("quantile", "0.99")),
+		series(20, lbl("__name__", "rpc_latency_sum"), lbl("instance", "api-0"), lbl("job", "shop/api")),
+		series(10, lbl("__name__", "rpc_latency_count"), lbl("instance", "api-0"), lbl("job", "shop/api")),
+	}, wr.Timeseries)
+	require.Equal(t, prompb.MetricMetadata_SUMMARY, wr.Metadata[0].Type)
+}
+
+func TestParseRequest(t *testing.T) {
+	md, m := newMetric("up", pdata.MetricDataTypeGauge)
+	p := m.Gauge().DataPoints().AppendEmpty()
+	p.SetTimestamp(pdata.NewTimestampFromTime(testTime))
+	p.SetDoubleVal(1)
+	expected := []prompb.TimeSeries{series(1, lbl("__name__", "up"), lbl("instance", "api-0"), lbl("job", "shop/api"))}
+
+	pb, err := otlpencoding.NewProtobufMetricsMarshaler().MarshalMetrics(md)
+	require.NoError(t, err)
+	js, err := otlpencoding.NewJSONMetricsMarshaler().MarshalMetrics(md)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name  string
+		body  []byte
+		parse func(*http.Request, *prompb.WriteRequest) error
+		err   bool
+	}{
+		{name: "protobuf", body: pb, parse: ParseProtobufRequest},
+		{name: "json", body: js, parse: ParseJSONRequest},
+		{name: "invalid protobuf", body: []byte("not protobuf"), parse: ParseProtobufRequest, err: true},
+		{name: "invalid json", body: []byte("{"), parse: ParseJSONRequest, err: true},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewReader(c.body))
+			require.NoError(t, err)
+			wr := &prompb.WriteRequest{}
+			err = c.parse(req, wr)
+			if c.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, expected, wr.Timeseries)
+		})
+	}
+}
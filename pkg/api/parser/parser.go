@@ -5,7 +5,9 @@ import (
 	"mime"
 	"net/http"
 
+	"github.com/timescale/promscale/pkg/api/parser/influx"
 	"github.com/timescale/promscale/pkg/api/parser/json"
+	"github.com/timescale/promscale/pkg/api/parser/otlp"
 	"github.com/timescale/promscale/pkg/api/parser/protobuf"
 	"github.com/timescale/promscale/pkg/api/parser/text"
 	"github.com/timescale/promscale/pkg/prompb"
@@ -34,6 +36,9 @@ func NewParser() *DefaultParser {
 			"application/json":             json.ParseRequest,
 			"text/plain":                   text.ParseRequest,
 			"application/openmetrics-text": text.ParseRequest,
+			influx.ContentType:             influx.ParseRequest,
+			otlp.ProtobufContentType:       otlp.ParseProtobufRequest,
+			otlp.JSONContentType:           otlp.ParseJSONRequest,
 		},
 	}
 }
@@ -62,6 +67,12 @@ func (d DefaultParser) ParseRequest(r *http.Request, req *prompb.WriteRequest) e
 		return fmt.Errorf("parser error: %w", err)
 	}
 
+	return d.Preprocess(r, req)
+}
+
+// Preprocess runs the preprocessors on a write request that was already parsed,
+// e.g. one received over gRPC, with r carrying the request headers.
+func (d DefaultParser) Preprocess(r *http.Request, req *prompb.WriteRequest) error {
 	if len(req.Timeseries) == 0 {
 		return nil
 	}
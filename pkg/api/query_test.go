@@ -52,6 +52,8 @@ func (m mockSeriesSet) Close() {}
 type mockQuerier struct {
 	timeToSleepOnSelect time.Duration
 	selectErr           error
+	labelNames          []string
+	labelNamesErr       error
 }
 
 var _ querier.Querier = (*mockQuerier)(nil)
@@ -60,6 +62,10 @@ func (m mockQuerier) Query(*prompb.Query) ([]*prompb.TimeSeries, error) {
 	panic("implement me")
 }
 
+func (m mockQuerier) QuerySeriesSet(*prompb.Query) (querier.SeriesSet, error) {
+	panic("implement me")
+}
+
 func (m mockQuerier) SamplesQuerier() querier.SamplesQuerier {
 	return m
 }
@@ -74,6 +80,20 @@ func (m mockQuerier) ExemplarsQuerier(_ context.Context) querier.ExemplarQuerier
 	return mockExemplarQuerier{}
 }
 
+func (m mockQuerier) LabelsQuerier() querier.LabelsQuerier {
+	return m
+}
+
+// LabelNames implements the querier.LabelsQuerier interface.
+func (m mockQuerier) LabelNames(int64, int64, ...*labels.Matcher) ([]string, error) {
+	return m.labelNames, m.labelNamesErr
+}
+
+// LabelValues implements the querier.LabelsQuerier interface.
+func (m mockQuerier) LabelValues(string, int64, int64, ...*labels.Matcher) ([]string, error) {
+	return nil, nil
+}
+
 type mockExemplarQuerier struct{}
 
 // Select implements the querier.ExemplarQuerier interface.
@@ -86,11 +106,11 @@ type mockLabelsReader struct {
 	labelNamesErr error
 }
 
-func (m mockLabelsReader) LabelNames() ([]string, error) {
+func (m mockLabelsReader) LabelNames(int64, int64, ...*labels.Matcher) ([]string, error) {
 	return m.labelNames, m.labelNamesErr
 }
 
-func (m mockLabelsReader) LabelValues(string) ([]string, error) {
+func (m mockLabelsReader) LabelValues(string, int64, int64, ...*labels.Matcher) ([]string, error) {
 	return nil, nil
 }
 
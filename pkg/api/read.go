@@ -5,7 +5,11 @@
 package api
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -13,12 +17,20 @@ import (
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	"github.com/timescale/promscale/pkg/ha"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/prompb"
 )
 
+// maxSamplesInChunk is the maximum number of samples encoded into a single
+// XOR chunk of a streamed response. It matches the TSDB head chunk size.
+const maxSamplesInChunk = 120
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
 func Read(config *Config, reader querier.Reader, metrics *Metrics) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !validateReadHeaders(w, r) {
@@ -64,35 +76,279 @@ func Read(config *Config, reader querier.Reader, metrics *Metrics) http.Handler
 			}
 		}
 
-		var resp *prompb.ReadResponse
-		resp, err = reader.Read(&req)
+		responseType, err := negotiateReadResponseType(req.AcceptedResponseTypes)
 		if err != nil {
-			log.Warn("msg", "Error executing query", "query", req, "storage", "PostgreSQL", "err", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			metrics.FailedQueries.Add(queryCount)
+			log.Error("msg", "Response type negotiation error", "err", err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		duration := time.Since(begin).Seconds()
-		metrics.QueryBatchDuration.Observe(duration)
+		switch responseType {
+		case prompb.ReadRequest_STREAMED_XOR_CHUNKS:
+			readStreamedChunks(config, reader, metrics, &req, w, begin)
+		default:
+			readSamples(reader, metrics, &req, w, begin)
+		}
+	})
+}
 
-		data, err := proto.Marshal(resp)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			metrics.FailedQueries.Add(queryCount)
-			return
+// negotiateReadResponseType picks the first response type accepted by the
+// client that we support. Clients that do not state their accepted types
+// get the sampled response, as described in the remote-read protocol.
+func negotiateReadResponseType(accepted []prompb.ReadRequest_ResponseType) (prompb.ReadRequest_ResponseType, error) {
+	if len(accepted) == 0 {
+		return prompb.ReadRequest_SAMPLES, nil
+	}
+
+	for _, rt := range accepted {
+		switch rt {
+		case prompb.ReadRequest_SAMPLES, prompb.ReadRequest_STREAMED_XOR_CHUNKS:
+			return rt, nil
 		}
+	}
+	return 0, fmt.Errorf("server does not support any of the requested response types: %v", accepted)
+}
+
+func readSamples(reader querier.Reader, metrics *Metrics, req *prompb.ReadRequest, w http.ResponseWriter, begin time.Time) {
+	queryCount := float64(len(req.Queries))
+
+	resp, err := reader.Read(req)
+	if err != nil {
+		log.Warn("msg", "Error executing query", "query", req, "storage", "PostgreSQL", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		metrics.FailedQueries.Add(queryCount)
+		return
+	}
+
+	duration := time.Since(begin).Seconds()
+	metrics.QueryBatchDuration.Observe(duration)
 
-		w.Header().Set("Content-Type", "application/x-protobuf")
-		w.Header().Set("Content-Encoding", "snappy")
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		metrics.FailedQueries.Add(queryCount)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+
+	compressed := snappy.Encode(nil, data)
+	if _, err := w.Write(compressed); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		metrics.FailedQueries.Add(queryCount)
+		return
+	}
+}
 
-		compressed = snappy.Encode(nil, data)
-		if _, err := w.Write(compressed); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			metrics.FailedQueries.Add(queryCount)
+func readStreamedChunks(config *Config, reader querier.Reader, metrics *Metrics, req *prompb.ReadRequest, w http.ResponseWriter, begin time.Time) {
+	queryCount := float64(len(req.Queries))
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "internal http.ResponseWriter does not implement http.Flusher interface", http.StatusInternalServerError)
+		metrics.FailedQueries.Add(queryCount)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse")
+
+	stream := newChunkedWriter(w, f)
+	for i, q := range req.Queries {
+		if err := streamQueryChunks(reader, q, int64(i), stream, config.ReadMaxBytesInFrame); err != nil {
+			log.Warn("msg", "Error executing query", "query", q, "storage", "PostgreSQL", "err", err)
+			metrics.FailedQueries.Add(queryCount - float64(i))
+			// Once the first frame is flushed the status code can not be
+			// changed anymore and an error message would corrupt the
+			// stream. The client still gets an error since the stream
+			// ends abruptly.
+			if !stream.flushed {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
 			return
 		}
-	})
+	}
+
+	duration := time.Since(begin).Seconds()
+	metrics.QueryBatchDuration.Observe(duration)
+}
+
+// streamQueryChunks encodes the series matching a single query into XOR chunks
+// and writes them as ChunkedReadResponse frames. A frame is flushed as soon as
+// it grows over maxBytesInFrame, so only a single frame is ever kept in memory.
+func streamQueryChunks(reader querier.Reader, q *prompb.Query, queryIndex int64, stream io.Writer, maxBytesInFrame int) error {
+	ss, err := reader.ReadSeriesSet(q)
+	if err != nil {
+		return err
+	}
+	defer ss.Close()
+
+	var (
+		frame      []*prompb.ChunkedSeries
+		frameBytes int
+	)
+	flush := func() error {
+		if len(frame) == 0 {
+			return nil
+		}
+		b, err := proto.Marshal(&prompb.ChunkedReadResponse{
+			ChunkedSeries: frame,
+			QueryIndex:    queryIndex,
+		})
+		if err != nil {
+			return fmt.Errorf("marshal ChunkedReadResponse: %w", err)
+		}
+		if _, err := stream.Write(b); err != nil {
+			return fmt.Errorf("write to stream: %w", err)
+		}
+		frame = frame[:0]
+		frameBytes = 0
+		return nil
+	}
+
+	for ss.Next() {
+		series := ss.At()
+		if series == nil {
+			break
+		}
+		lbls := labelsToProm(series.Labels())
+		newSeries := true
+
+		// A series that does not fit into a single frame is split across
+		// frames, in which case every frame repeats the labels of the series.
+		appendChunk := func(chk prompb.Chunk) error {
+			if newSeries || len(frame) == 0 {
+				frame = append(frame, &prompb.ChunkedSeries{Labels: lbls})
+				for _, l := range lbls {
+					frameBytes += l.Size()
+				}
+				newSeries = false
+			}
+			cs := frame[len(frame)-1]
+			cs.Chunks = append(cs.Chunks, chk)
+			frameBytes += chk.Size()
+			if frameBytes >= maxBytesInFrame {
+				return flush()
+			}
+			return nil
+		}
+
+		err := encodeXORChunks(series.Iterator(), appendChunk)
+		if err != nil {
+			return err
+		}
+	}
+	if err := ss.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// encodeXORChunks encodes the samples of the iterator into XOR chunks of at
+// most maxSamplesInChunk samples each, passing every complete chunk to emit.
+func encodeXORChunks(iter chunkenc.Iterator, emit func(prompb.Chunk) error) error {
+	var (
+		chk          *chunkenc.XORChunk
+		app          chunkenc.Appender
+		minTs, maxTs int64
+		err          error
+	)
+	emitChunk := func() error {
+		c := prompb.Chunk{
+			MinTimeMs: minTs,
+			MaxTimeMs: maxTs,
+			Type:      prompb.Chunk_Encoding(chk.Encoding()),
+			Data:      chk.Bytes(),
+		}
+		chk = nil
+		return emit(c)
+	}
+
+	for iter.Next() {
+		if chk == nil {
+			chk = chunkenc.NewXORChunk()
+			if app, err = chk.Appender(); err != nil {
+				return err
+			}
+		}
+		t, v := iter.At()
+		if chk.NumSamples() == 0 {
+			minTs = t
+		}
+		maxTs = t
+		app.Append(t, v)
+
+		if chk.NumSamples() >= maxSamplesInChunk {
+			if err = emitChunk(); err != nil {
+				return err
+			}
+		}
+	}
+	if err = iter.Err(); err != nil {
+		return err
+	}
+	if chk != nil && chk.NumSamples() > 0 {
+		return emitChunk()
+	}
+	return nil
+}
+
+// chunkedWriter writes each buffer as a frame of a streamed remote-read
+// response and flushes it to the client. Every frame is prefixed with the
+// uvarint size of the data and the big-endian CRC-32 (Castagnoli) checksum
+// of the data, as described in the remote-read protocol.
+//
+// It mirrors remote.ChunkedWriter from Prometheus, which we can not import
+// since its package registers the upstream prompb types.
+type chunkedWriter struct {
+	writer  io.Writer
+	flusher http.Flusher
+	crc32   hash.Hash32
+	// flushed is set once the first frame was flushed to the client.
+	flushed bool
+}
+
+func newChunkedWriter(w io.Writer, f http.Flusher) *chunkedWriter {
+	return &chunkedWriter{writer: w, flusher: f, crc32: crc32.New(castagnoliTable)}
+}
+
+// Write writes a single frame and flushes it. The returned number of bytes
+// does not include the delimiter and checksum bytes.
+func (w *chunkedWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+	v := binary.PutUvarint(buf[:], uint64(len(b)))
+	if _, err := w.writer.Write(buf[:v]); err != nil {
+		return 0, err
+	}
+
+	w.crc32.Reset()
+	if _, err := w.crc32.Write(b); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(w.writer, binary.BigEndian, w.crc32.Sum32()); err != nil {
+		return 0, err
+	}
+
+	n, err := w.writer.Write(b)
+	if err != nil {
+		return n, err
+	}
+
+	w.flusher.Flush()
+	w.flushed = true
+	return n, nil
+}
+
+func labelsToProm(lls labels.Labels) []prompb.Label {
+	result := make([]prompb.Label, 0, len(lls))
+	for _, l := range lls {
+		result = append(result, prompb.Label{Name: l.Name, Value: l.Value})
+	}
+	return result
 }
 
 func validateReadHeaders(w http.ResponseWriter, r *http.Request) bool {
@@ -5,7 +5,10 @@
 package api
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -13,6 +16,12 @@ import (
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/tsdbutil"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/prompb"
 )
 
@@ -53,6 +62,17 @@ func TestRead(t *testing.T) {
 			),
 			expReceivedQueries: 1,
 		},
+		{
+			name:         "unsupported response type",
+			responseCode: http.StatusBadRequest,
+			requestBody: readRequestToString(
+				&prompb.ReadRequest{
+					Queries:               []*prompb.Query{{}},
+					AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{5},
+				},
+			),
+			expReceivedQueries: 1,
+		},
 		{
 			name:           "happy path",
 			responseCode:   http.StatusOK,
@@ -116,10 +136,229 @@ func readRequestToString(r *prompb.ReadRequest) string {
 	return string(snappy.Encode(nil, data))
 }
 
+func TestReadStreamedChunks(t *testing.T) {
+	seriesA := labels.FromStrings("__name__", "a", "job", "x")
+	seriesB := labels.FromStrings("__name__", "b", "job", "y")
+	samplesA := make([]tsdbutil.Sample, 0, 250)
+	for i := 0; i < 250; i++ {
+		samplesA = append(samplesA, sample{t: int64(i * 1000), v: float64(i)})
+	}
+	samplesB := []tsdbutil.Sample{sample{t: 1000, v: 1}, sample{t: 2000, v: 2}}
+
+	testCases := []struct {
+		name            string
+		maxBytesInFrame int
+		expFrames       int
+	}{
+		{
+			name:            "single frame",
+			maxBytesInFrame: 1048576,
+			expFrames:       1,
+		},
+		{
+			name:            "frame per chunk",
+			maxBytesInFrame: 1,
+			expFrames:       4,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mockReader := &mockReader{
+				seriesSet: &mockListSeriesSet{series: []storage.Series{
+					storage.NewListSeries(seriesA, samplesA),
+					storage.NewListSeries(seriesB, samplesB),
+				}},
+			}
+			metrics := &Metrics{
+				QueryBatchDuration: &mockMetric{},
+				FailedQueries:      &mockMetric{},
+				ReceivedQueries:    &mockMetric{},
+				InvalidReadReqs:    &mockMetric{},
+			}
+			handler := Read(&Config{ReadMaxBytesInFrame: c.maxBytesInFrame}, mockReader, metrics)
+
+			test := GenerateReadHandleTester(t, handler, false)
+			w := test("POST", getReader(readRequestToString(&prompb.ReadRequest{
+				Queries: []*prompb.Query{{}},
+				AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{
+					prompb.ReadRequest_STREAMED_XOR_CHUNKS,
+					prompb.ReadRequest_SAMPLES,
+				},
+			})))
+
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse", w.Header().Get("Content-Type"))
+
+			got := map[string][]tsdbutil.Sample{}
+			var order []string
+			frames := 0
+			for {
+				data, err := readChunkedFrame(w.Body)
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				res := &prompb.ChunkedReadResponse{}
+				require.NoError(t, proto.Unmarshal(data, res))
+				require.Equal(t, int64(0), res.QueryIndex)
+				frames++
+
+				for _, cs := range res.ChunkedSeries {
+					key := labelProtosToLabels(cs.Labels).String()
+					if len(order) == 0 || order[len(order)-1] != key {
+						order = append(order, key)
+					}
+					for _, chk := range cs.Chunks {
+						require.Equal(t, prompb.Chunk_XOR, chk.Type)
+						xc, err := chunkenc.FromData(chunkenc.EncXOR, chk.Data)
+						require.NoError(t, err)
+						require.LessOrEqual(t, xc.NumSamples(), maxSamplesInChunk)
+						it := xc.Iterator(nil)
+						for it.Next() {
+							ts, v := it.At()
+							got[key] = append(got[key], sample{t: ts, v: v})
+						}
+						require.NoError(t, it.Err())
+						require.Equal(t, got[key][len(got[key])-xc.NumSamples()].T(), chk.MinTimeMs)
+						require.Equal(t, got[key][len(got[key])-1].T(), chk.MaxTimeMs)
+					}
+				}
+			}
+
+			require.Equal(t, c.expFrames, frames)
+			require.Equal(t, []string{seriesA.String(), seriesB.String()}, order)
+			require.Equal(t, samplesA, got[seriesA.String()])
+			require.Equal(t, samplesB, got[seriesB.String()])
+		})
+	}
+}
+
+func TestReadStreamedChunksError(t *testing.T) {
+	series := labels.FromStrings("__name__", "a", "job", "x")
+	samples := []tsdbutil.Sample{sample{t: 1000, v: 1}, sample{t: 2000, v: 2}}
+
+	testCases := []struct {
+		name            string
+		maxBytesInFrame int
+		expCode         int
+		expFrames       int
+	}{
+		{
+			name:            "error before first frame",
+			maxBytesInFrame: 1048576,
+			expCode:         http.StatusInternalServerError,
+		},
+		{
+			name:            "error after first frame",
+			maxBytesInFrame: 1,
+			expCode:         http.StatusOK,
+			expFrames:       1,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mockReader := &mockReader{
+				seriesSet: &mockListSeriesSet{
+					series: []storage.Series{storage.NewListSeries(series, samples)},
+					err:    fmt.Errorf("some error"),
+				},
+			}
+			failedQueries := &mockMetric{}
+			metrics := &Metrics{
+				QueryBatchDuration: &mockMetric{},
+				FailedQueries:      failedQueries,
+				ReceivedQueries:    &mockMetric{},
+				InvalidReadReqs:    &mockMetric{},
+			}
+			handler := Read(&Config{ReadMaxBytesInFrame: c.maxBytesInFrame}, mockReader, metrics)
+
+			test := GenerateReadHandleTester(t, handler, false)
+			w := test("POST", getReader(readRequestToString(&prompb.ReadRequest{
+				Queries: []*prompb.Query{{}},
+				AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{
+					prompb.ReadRequest_STREAMED_XOR_CHUNKS,
+				},
+			})))
+
+			require.Equal(t, c.expCode, w.Code)
+			require.Equal(t, float64(1), failedQueries.value)
+			if c.expFrames == 0 {
+				return
+			}
+			frames := 0
+			for {
+				data, err := readChunkedFrame(w.Body)
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err, "the stream must only contain frames")
+				require.NoError(t, proto.Unmarshal(data, &prompb.ChunkedReadResponse{}))
+				frames++
+			}
+			require.Equal(t, c.expFrames, frames)
+		})
+	}
+}
+
+// readChunkedFrame reads a single frame written by chunkedWriter and verifies its checksum.
+func readChunkedFrame(r *bytes.Buffer) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	var checksum uint32
+	if err := binary.Read(r, binary.BigEndian, &checksum); err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if crc32.Checksum(data, castagnoliTable) != checksum {
+		return nil, fmt.Errorf("frame checksum mismatch")
+	}
+	return data, nil
+}
+
+func labelProtosToLabels(lps []prompb.Label) labels.Labels {
+	result := make(labels.Labels, 0, len(lps))
+	for _, l := range lps {
+		result = append(result, labels.Label{Name: l.Name, Value: l.Value})
+	}
+	return result
+}
+
+type sample struct {
+	t int64
+	v float64
+}
+
+func (s sample) T() int64   { return s.t }
+func (s sample) V() float64 { return s.v }
+
+type mockListSeriesSet struct {
+	idx    int
+	series []storage.Series
+	err    error
+}
+
+func (m *mockListSeriesSet) Next() bool {
+	m.idx++
+	return m.idx <= len(m.series)
+}
+
+func (m *mockListSeriesSet) At() storage.Series         { return m.series[m.idx-1] }
+func (m *mockListSeriesSet) Err() error                 { return m.err }
+func (m *mockListSeriesSet) Warnings() storage.Warnings { return nil }
+func (m *mockListSeriesSet) Close()                     {}
+
 type mockReader struct {
-	request  *prompb.ReadRequest
-	response *prompb.ReadResponse
-	err      error
+	request   *prompb.ReadRequest
+	response  *prompb.ReadResponse
+	seriesSet querier.SeriesSet
+	err       error
 }
 
 func (m *mockReader) Read(r *prompb.ReadRequest) (*prompb.ReadResponse, error) {
@@ -127,6 +366,10 @@ func (m *mockReader) Read(r *prompb.ReadRequest) (*prompb.ReadResponse, error) {
 	return m.response, m.err
 }
 
+func (m *mockReader) ReadSeriesSet(*prompb.Query) (querier.SeriesSet, error) {
+	return m.seriesSet, m.err
+}
+
 func GenerateReadHandleTester(t *testing.T, handleFunc http.Handler, badHeader bool) HandleTester {
 	return func(method string, body io.Reader) *httptest.ResponseRecorder {
 		req, err := http.NewRequest(method, "", body)
@@ -20,11 +20,14 @@ import (
 	haClient "github.com/timescale/promscale/pkg/ha/client"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgclient"
+	"github.com/timescale/promscale/pkg/pgmodel/admin"
 	"github.com/timescale/promscale/pkg/query"
 	"github.com/timescale/promscale/pkg/util"
 )
 
-func GenerateRouter(apiConf *Config, client *pgclient.Client, elector *util.Elector) (http.Handler, error) {
+// NewWriteParser returns the parser of metric writes, with the high-availability
+// and multi-tenancy preprocessors enabled by apiConf.
+func NewWriteParser(apiConf *Config, client *pgclient.Client) *parser.DefaultParser {
 	var writePreprocessors []parser.Preprocessor
 	if apiConf.HighAvailability {
 		service := ha.NewService(haClient.NewLeaseClient(client.Connection))
@@ -38,12 +41,23 @@ func GenerateRouter(apiConf *Config, client *pgclient.Client, elector *util.Elec
 	for _, preproc := range writePreprocessors {
 		dataParser.AddPreprocessor(preproc)
 	}
+	return dataParser
+}
 
+func GenerateRouter(apiConf *Config, client *pgclient.Client, dataParser *parser.DefaultParser, elector *util.Elector) (http.Handler, error) {
 	writeHandler := timeHandler(metrics.HTTPRequestDuration, "write", Write(client, dataParser, elector))
+	influxWriteHandler := timeHandler(metrics.HTTPRequestDuration, "influx/write", InfluxWrite(client, dataParser, elector))
+	otlpMetricsWriteHandler := timeHandler(metrics.HTTPRequestDuration, "v1/metrics", OTLPMetricsWrite(apiConf, client, dataParser, elector))
+	otlpTracesWriteHandler := timeHandler(metrics.HTTPRequestDuration, "v1/traces", OTLPTracesWrite(apiConf, client))
+	zipkinWriteHandler := timeHandler(metrics.HTTPRequestDuration, "api/v2/spans", ZipkinWrite(apiConf, client))
 
 	// If we are running in read-only mode, log and send NotFound status.
 	if apiConf.ReadOnly {
 		writeHandler = withWarnLog("trying to send metrics to write API while connector is in read-only mode", http.NotFoundHandler())
+		influxWriteHandler = writeHandler
+		otlpMetricsWriteHandler = writeHandler
+		otlpTracesWriteHandler = withWarnLog("trying to send traces to write API while connector is in read-only mode", http.NotFoundHandler())
+		zipkinWriteHandler = otlpTracesWriteHandler
 	}
 
 	authWrapper := func(name string, h http.HandlerFunc) http.HandlerFunc {
@@ -53,6 +67,11 @@ func GenerateRouter(apiConf *Config, client *pgclient.Client, elector *util.Elec
 	router := route.New().WithInstrumentation(authWrapper)
 
 	router.Post("/write", writeHandler)
+	router.Post("/influx/write", influxWriteHandler)
+	router.Post("/api/v2/write", influxWriteHandler)
+	router.Post("/v1/metrics", otlpMetricsWriteHandler)
+	router.Post("/v1/traces", otlpTracesWriteHandler)
+	router.Post("/api/v2/spans", zipkinWriteHandler)
 
 	readHandler := timeHandler(metrics.HTTPRequestDuration, "read", Read(apiConf, client, metrics))
 	router.Get("/read", readHandler)
@@ -62,6 +81,21 @@ func GenerateRouter(apiConf *Config, client *pgclient.Client, elector *util.Elec
 	router.Put("/delete_series", deleteHandler)
 	router.Post("/delete_series", deleteHandler)
 
+	metricAdmin := &admin.PgAdmin{Conn: client.Connection}
+	metricSettingsHandler := timeHandler(metrics.HTTPRequestDuration, "admin/metrics", MetricSettings(apiConf, metricAdmin))
+	router.Get("/api/v1/admin/metrics", metricSettingsHandler)
+	router.Get("/api/v1/admin/metrics/:name", metricSettingsHandler)
+	for _, setting := range []string{retentionPeriodSetting, chunkIntervalSetting, compressionSetting} {
+		path := "/api/v1/admin/metrics/:name/" + setting
+		settingHandler := timeHandler(metrics.HTTPRequestDuration, "admin/metrics/:name/"+setting, UpdateMetricSetting(apiConf, metricAdmin, setting))
+		router.Put(path, settingHandler)
+		router.Post(path, settingHandler)
+		router.Del(path, settingHandler)
+	}
+	dropMetricsHandler := timeHandler(metrics.HTTPRequestDuration, "admin/drop_metric", DropMetrics(apiConf, metricAdmin))
+	router.Put("/api/v1/admin/drop_metric", dropMetricsHandler)
+	router.Post("/api/v1/admin/drop_metric", dropMetricsHandler)
+
 	queryable := client.Queryable()
 	queryEngine, err := query.NewEngine(log.GetLogger(), apiConf.MaxQueryTimeout, apiConf.LookBackDelta, apiConf.SubQueryStepInterval, apiConf.MaxSamples, apiConf.EnabledFeaturesList)
 	if err != nil {
@@ -79,6 +113,14 @@ func GenerateRouter(apiConf *Config, client *pgclient.Client, elector *util.Elec
 	router.Get("/api/v1/query_exemplars", exemplarQueryHandler)
 	router.Post("/api/v1/query_exemplars", exemplarQueryHandler)
 
+	exemplarTracesHandler := timeHandler(metrics.HTTPRequestDuration, "query_exemplars_traces", QueryExemplarTraces(apiConf, queryable, client.QuerierConnection, metrics))
+	router.Get("/api/v1/query_exemplars_traces", exemplarTracesHandler)
+	router.Post("/api/v1/query_exemplars_traces", exemplarTracesHandler)
+
+	traceExemplarsHandler := timeHandler(metrics.HTTPRequestDuration, "trace_exemplars", TraceExemplars(apiConf, client.QuerierConnection, metrics))
+	router.Get("/api/v1/trace_exemplars", traceExemplarsHandler)
+	router.Post("/api/v1/trace_exemplars", traceExemplarsHandler)
+
 	seriesHandler := timeHandler(metrics.HTTPRequestDuration, "series", Series(apiConf, queryable))
 	router.Get("/api/v1/series", seriesHandler)
 	router.Post("/api/v1/series", seriesHandler)
@@ -91,9 +133,21 @@ func GenerateRouter(apiConf *Config, client *pgclient.Client, elector *util.Elec
 	router.Get("/api/v1/metadata", metadataHandler)
 	router.Post("/api/v1/metadata", metadataHandler)
 
+	federateHandler := timeHandler(metrics.HTTPRequestDuration, "federate", Federate(apiConf, queryable))
+	router.Get("/federate", federateHandler)
+
 	labelValuesHandler := timeHandler(metrics.HTTPRequestDuration, "label/:name/values", LabelValues(apiConf, queryable))
 	router.Get("/api/v1/label/:name/values", labelValuesHandler)
 
+	if apiConf.Rules != nil {
+		metrics.RuleGroups.SetRetriever(apiConf.Rules)
+	}
+	rulesHandler := timeHandler(metrics.HTTPRequestDuration, "rules", Rules(apiConf, apiConf.Rules))
+	router.Get("/api/v1/rules", rulesHandler)
+
+	alertsHandler := timeHandler(metrics.HTTPRequestDuration, "alerts", Alerts(apiConf, apiConf.Rules))
+	router.Get("/api/v1/alerts", alertsHandler)
+
 	healthChecker := func() error { return client.HealthCheck() }
 	router.Get("/healthz", Health(healthChecker))
 
@@ -112,7 +166,26 @@ func GenerateRouter(apiConf *Config, client *pgclient.Client, elector *util.Elec
 
 	router.Get("/debug/fgprof", fgprof.Handler().ServeHTTP)
 
-	return router, nil
+	return preflightWrapper(apiConf, router, "/v1/metrics", "/v1/traces", "/api/v2/spans"), nil
+}
+
+// preflightWrapper answers CORS preflight requests to the given paths before
+// they reach the router, as browsers never send credentials with them. All
+// other requests, including preflight requests to other paths, go through
+// the router and its authentication.
+func preflightWrapper(cfg *Config, router http.Handler, paths ...string) http.Handler {
+	preflight := Preflight(cfg)
+	preflightPaths := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		preflightPaths[path] = struct{}{}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := preflightPaths[r.URL.Path]; ok && r.Method == http.MethodOptions {
+			preflight.ServeHTTP(w, r)
+			return
+		}
+		router.ServeHTTP(w, r)
+	})
 }
 
 func authHandler(cfg *Config, handler http.HandlerFunc) http.HandlerFunc {
@@ -13,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/route"
 )
 
 type mockHTTPHandler struct {
@@ -212,3 +213,34 @@ func TestAuthHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestPreflightWrapper(t *testing.T) {
+	cfg := &Config{Auth: &Auth{BearerToken: "foo"}}
+	router := route.New().WithInstrumentation(func(_ string, h http.HandlerFunc) http.HandlerFunc {
+		return authHandler(cfg, h)
+	})
+	router.Post("/v1/traces", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	router.Options("/api/v1/query", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := preflightWrapper(cfg, router, "/v1/traces")
+
+	testCases := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{method: http.MethodOptions, path: "/v1/traces", status: http.StatusNoContent},
+		{method: http.MethodPost, path: "/v1/traces", status: http.StatusUnauthorized},
+		{method: http.MethodOptions, path: "/api/v1/query", status: http.StatusUnauthorized},
+	}
+	for _, c := range testCases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.status {
+			t.Errorf("%s %s: unexpected status code: got %d, wanted %d", c.method, c.path, w.Code, c.status)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/rules"
+)
+
+// RulesRetriever provides the rule groups and alerting rules evaluated by Promscale.
+type RulesRetriever interface {
+	RuleGroups() []*rules.Group
+	AlertingRules() []*rules.AlertingRule
+}
+
+// alert has the same format as alerts in the Prometheus API.
+type alert struct {
+	Labels      labels.Labels `json:"labels"`
+	Annotations labels.Labels `json:"annotations"`
+	State       string        `json:"state"`
+	ActiveAt    *time.Time    `json:"activeAt,omitempty"`
+	Value       string        `json:"value"`
+}
+
+type alertDiscovery struct {
+	Alerts []*alert `json:"alerts"`
+}
+
+type ruleDiscovery struct {
+	RuleGroups []*ruleGroup `json:"groups"`
+}
+
+type ruleGroup struct {
+	Name           string        `json:"name"`
+	File           string        `json:"file"`
+	Rules          []interface{} `json:"rules"`
+	Interval       float64       `json:"interval"`
+	EvaluationTime float64       `json:"evaluationTime"`
+	LastEvaluation time.Time     `json:"lastEvaluation"`
+}
+
+type alertingRule struct {
+	// State can be "pending", "firing", "inactive".
+	State          string           `json:"state"`
+	Name           string           `json:"name"`
+	Query          string           `json:"query"`
+	Duration       float64          `json:"duration"`
+	Labels         labels.Labels    `json:"labels"`
+	Annotations    labels.Labels    `json:"annotations"`
+	Alerts         []*alert         `json:"alerts"`
+	Health         rules.RuleHealth `json:"health"`
+	LastError      string           `json:"lastError,omitempty"`
+	EvaluationTime float64          `json:"evaluationTime"`
+	LastEvaluation time.Time        `json:"lastEvaluation"`
+	// Type of an alertingRule is always "alerting".
+	Type string `json:"type"`
+}
+
+type recordingRule struct {
+	Name           string           `json:"name"`
+	Query          string           `json:"query"`
+	Labels         labels.Labels    `json:"labels,omitempty"`
+	Health         rules.RuleHealth `json:"health"`
+	LastError      string           `json:"lastError,omitempty"`
+	EvaluationTime float64          `json:"evaluationTime"`
+	LastEvaluation time.Time        `json:"lastEvaluation"`
+	// Type of a recordingRule is always "recording".
+	Type string `json:"type"`
+}
+
+func Rules(conf *Config, retriever RulesRetriever) http.Handler {
+	hf := corsWrapper(conf, rulesHandler(retriever))
+	return gziphandler.GzipHandler(hf)
+}
+
+func rulesHandler(retriever RulesRetriever) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		typ := r.FormValue("type")
+		if typ != "" && typ != "alert" && typ != "record" {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("invalid query parameter type='%v'", typ), "bad_data")
+			return
+		}
+		returnAlerts := typ == "" || typ == "alert"
+		returnRecording := typ == "" || typ == "record"
+
+		res := &ruleDiscovery{RuleGroups: []*ruleGroup{}}
+		if retriever == nil {
+			respondRules(w, res)
+			return
+		}
+		for _, grp := range retriever.RuleGroups() {
+			apiGroup := &ruleGroup{
+				Name:           grp.Name(),
+				File:           grp.File(),
+				Interval:       grp.Interval().Seconds(),
+				Rules:          []interface{}{},
+				EvaluationTime: grp.GetEvaluationTime().Seconds(),
+				LastEvaluation: grp.GetLastEvaluation(),
+			}
+			for _, rule := range grp.Rules() {
+				var lastError string
+				if rule.LastError() != nil {
+					lastError = rule.LastError().Error()
+				}
+				switch rule := rule.(type) {
+				case *rules.AlertingRule:
+					if !returnAlerts {
+						break
+					}
+					apiGroup.Rules = append(apiGroup.Rules, alertingRule{
+						State:          rule.State().String(),
+						Name:           rule.Name(),
+						Query:          rule.Query().String(),
+						Duration:       rule.HoldDuration().Seconds(),
+						Labels:         rule.Labels(),
+						Annotations:    rule.Annotations(),
+						Alerts:         rulesAlertsToAPIAlerts(rule.ActiveAlerts()),
+						Health:         rule.Health(),
+						LastError:      lastError,
+						EvaluationTime: rule.GetEvaluationDuration().Seconds(),
+						LastEvaluation: rule.GetEvaluationTimestamp(),
+						Type:           "alerting",
+					})
+				case *rules.RecordingRule:
+					if !returnRecording {
+						break
+					}
+					apiGroup.Rules = append(apiGroup.Rules, recordingRule{
+						Name:           rule.Name(),
+						Query:          rule.Query().String(),
+						Labels:         rule.Labels(),
+						Health:         rule.Health(),
+						LastError:      lastError,
+						EvaluationTime: rule.GetEvaluationDuration().Seconds(),
+						LastEvaluation: rule.GetEvaluationTimestamp(),
+						Type:           "recording",
+					})
+				}
+			}
+			if len(apiGroup.Rules) > 0 || typ == "" {
+				res.RuleGroups = append(res.RuleGroups, apiGroup)
+			}
+		}
+		respondRules(w, res)
+	}
+}
+
+func Alerts(conf *Config, retriever RulesRetriever) http.Handler {
+	hf := corsWrapper(conf, alertsHandler(retriever))
+	return gziphandler.GzipHandler(hf)
+}
+
+func alertsHandler(retriever RulesRetriever) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res := &alertDiscovery{Alerts: []*alert{}}
+		if retriever == nil {
+			respondRules(w, res)
+			return
+		}
+		for _, rule := range retriever.AlertingRules() {
+			res.Alerts = append(res.Alerts, rulesAlertsToAPIAlerts(rule.ActiveAlerts())...)
+		}
+		respondRules(w, res)
+	}
+}
+
+func rulesAlertsToAPIAlerts(rulesAlerts []*rules.Alert) []*alert {
+	apiAlerts := make([]*alert, len(rulesAlerts))
+	for i, ruleAlert := range rulesAlerts {
+		activeAt := ruleAlert.ActiveAt
+		apiAlerts[i] = &alert{
+			Labels:      ruleAlert.Labels,
+			Annotations: ruleAlert.Annotations,
+			State:       ruleAlert.State.String(),
+			ActiveAt:    &activeAt,
+			Value:       strconv.FormatFloat(ruleAlert.Value, 'e', -1, 64),
+		}
+	}
+	return apiAlerts
+}
+
+func respondRules(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(&response{
+		Status: "success",
+		Data:   data,
+	})
+}
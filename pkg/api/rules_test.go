@@ -0,0 +1,219 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRulesRetriever struct {
+	groups []*rules.Group
+}
+
+func (m mockRulesRetriever) RuleGroups() []*rules.Group {
+	return m.groups
+}
+
+func (m mockRulesRetriever) AlertingRules() []*rules.AlertingRule {
+	var res []*rules.AlertingRule
+	for _, g := range m.groups {
+		res = append(res, g.AlertingRules()...)
+	}
+	return res
+}
+
+// newMockRulesRetriever returns a retriever with a group that has a recording
+// rule and an alerting rule with one pending alert.
+func newMockRulesRetriever(t *testing.T) RulesRetriever {
+	recordExpr, err := parser.ParseExpr("sum by (job) (up)")
+	require.NoError(t, err)
+	alertExpr, err := parser.ParseExpr("up == 0")
+	require.NoError(t, err)
+
+	alertingRule := rules.NewAlertingRule(
+		"InstanceDown", alertExpr, 5*time.Minute,
+		labels.FromStrings("severity", "page"), labels.FromStrings("summary", "instance is down"),
+		nil, "", true, nil,
+	)
+	queryFunc := func(_ context.Context, _ string, ts time.Time) (promql.Vector, error) {
+		return promql.Vector{{
+			Point:  promql.Point{T: timestamp.FromTime(ts), V: 0},
+			Metric: labels.FromStrings("__name__", "up", "instance", "a"),
+		}}, nil
+	}
+	_, err = alertingRule.Eval(context.Background(), time.Unix(1000, 0), queryFunc, nil, 0)
+	require.NoError(t, err)
+
+	group := rules.NewGroup(rules.GroupOptions{
+		Name:     "example",
+		File:     "rules.yml",
+		Interval: time.Minute,
+		Rules: []rules.Rule{
+			rules.NewRecordingRule("job:up:sum", recordExpr, labels.FromStrings("team", "a")),
+			alertingRule,
+		},
+		Opts: &rules.ManagerOptions{},
+	})
+	return mockRulesRetriever{groups: []*rules.Group{group}}
+}
+
+func TestRules(t *testing.T) {
+	retriever := newMockRulesRetriever(t)
+	testCases := []struct {
+		name       string
+		retriever  RulesRetriever
+		params     url.Values
+		code       int
+		groups     int
+		groupRules int
+	}{
+		{
+			name:      "no rules configured",
+			retriever: nil,
+			code:      http.StatusOK,
+		},
+		{
+			name:       "all rules",
+			retriever:  retriever,
+			code:       http.StatusOK,
+			groups:     1,
+			groupRules: 2,
+		},
+		{
+			name:       "recording rules",
+			retriever:  retriever,
+			params:     url.Values{"type": []string{"record"}},
+			code:       http.StatusOK,
+			groups:     1,
+			groupRules: 1,
+		},
+		{
+			name:       "alerting rules",
+			retriever:  retriever,
+			params:     url.Values{"type": []string{"alert"}},
+			code:       http.StatusOK,
+			groups:     1,
+			groupRules: 1,
+		},
+		{
+			name:      "invalid type",
+			retriever: retriever,
+			params:    url.Values{"type": []string{"foo"}},
+			code:      http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			handler := rulesHandler(c.retriever)
+			req, err := http.NewRequest("GET", "/api/v1/rules?"+c.params.Encode(), nil)
+			require.NoError(t, err)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			require.Equal(t, c.code, w.Code)
+			if c.code != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Status string `json:"status"`
+				Data   struct {
+					Groups []struct {
+						Name     string                   `json:"name"`
+						File     string                   `json:"file"`
+						Interval float64                  `json:"interval"`
+						Rules    []map[string]interface{} `json:"rules"`
+					} `json:"groups"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			require.Equal(t, "success", resp.Status)
+			require.Len(t, resp.Data.Groups, c.groups)
+			if c.groups == 0 {
+				return
+			}
+			group := resp.Data.Groups[0]
+			require.Equal(t, "example", group.Name)
+			require.Equal(t, "rules.yml", group.File)
+			require.Equal(t, 60.0, group.Interval)
+			require.Len(t, group.Rules, c.groupRules)
+			for _, rule := range group.Rules {
+				switch rule["type"] {
+				case "recording":
+					require.Equal(t, "job:up:sum", rule["name"])
+					require.Equal(t, "sum by(job) (up)", rule["query"])
+					require.Equal(t, "unknown", rule["health"])
+				case "alerting":
+					require.Equal(t, "InstanceDown", rule["name"])
+					require.Equal(t, "up == 0", rule["query"])
+					require.Equal(t, "pending", rule["state"])
+					require.Equal(t, 300.0, rule["duration"])
+					require.Len(t, rule["alerts"], 1)
+				default:
+					t.Fatalf("unexpected rule type %v", rule["type"])
+				}
+			}
+		})
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	for _, retriever := range []RulesRetriever{nil, newMockRulesRetriever(t)} {
+		handler := alertsHandler(retriever)
+		req, err := http.NewRequest("GET", "/api/v1/alerts", nil)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Status string `json:"status"`
+			Data   struct {
+				Alerts []struct {
+					Labels      map[string]string `json:"labels"`
+					Annotations map[string]string `json:"annotations"`
+					State       string            `json:"state"`
+					ActiveAt    time.Time         `json:"activeAt"`
+					Value       string            `json:"value"`
+				} `json:"alerts"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		require.Equal(t, "success", resp.Status)
+		if retriever == nil {
+			require.Empty(t, resp.Data.Alerts)
+			continue
+		}
+		require.Len(t, resp.Data.Alerts, 1)
+		a := resp.Data.Alerts[0]
+		require.Equal(t, map[string]string{"alertname": "InstanceDown", "instance": "a", "severity": "page"}, a.Labels)
+		require.Equal(t, map[string]string{"summary": "instance is down"}, a.Annotations)
+		require.Equal(t, "pending", a.State)
+		require.True(t, a.ActiveAt.Equal(time.Unix(1000, 0)))
+		require.Equal(t, "0e+00", a.Value)
+	}
+}
+
+func TestRuleGroupsCollector(t *testing.T) {
+	collector := newRuleGroupsCollector()
+	require.Equal(t, 0, testutil.CollectAndCount(collector))
+
+	collector.SetRetriever(newMockRulesRetriever(t))
+	require.Equal(t, 5, testutil.CollectAndCount(collector))
+}
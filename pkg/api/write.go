@@ -17,6 +17,7 @@ import (
 
 	"github.com/golang/snappy"
 	"github.com/timescale/promscale/pkg/api/parser"
+	"github.com/timescale/promscale/pkg/api/parser/influx"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
 	"github.com/timescale/promscale/pkg/util"
@@ -92,8 +93,10 @@ func validateWriteHeaders(w http.ResponseWriter, r *http.Request) bool {
 		// Don't need any other header checks for JSON content type.
 	case "text/plain", "application/openmetrics":
 		// Don't need any other header checks for text content type.
+	case influx.ContentType:
+		// Don't need any other header checks for InfluxDB line protocol.
 	default:
-		validateError(w, "unsupported data format (not protobuf, JSON, or text format)", metrics)
+		validateError(w, "unsupported data format (not protobuf, JSON, text format or InfluxDB line protocol)", metrics)
 		return false
 	}
 
@@ -106,25 +109,31 @@ func checkLegacyHA(elector *util.Elector) func(http.ResponseWriter, *http.Reques
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) bool {
-		// We need to record this time even if we're not the leader as it's
-		// used to determine if we're eligible to become the leader.
-		atomic.StoreInt64(&metrics.LastRequestUnixNano, time.Now().UnixNano())
+		return isLegacyHALeader(elector)
+	}
+}
 
-		shouldWrite, err := elector.IsLeader()
-		if err != nil {
-			metrics.LeaderGauge.Set(0)
-			log.Error("msg", "IsLeader check failed", "err", err)
-			return false
-		}
-		if !shouldWrite {
-			metrics.LeaderGauge.Set(0)
-			log.DebugRateLimited("msg", fmt.Sprintf("Election id %v: Instance is not a leader. Can't write data", elector.ID()))
-			return false
-		}
+// isLegacyHALeader tells if the instance is the leader that writes data in
+// the legacy HA setup.
+func isLegacyHALeader(elector *util.Elector) bool {
+	// We need to record this time even if we're not the leader as it's
+	// used to determine if we're eligible to become the leader.
+	atomic.StoreInt64(&metrics.LastRequestUnixNano, time.Now().UnixNano())
 
-		metrics.LeaderGauge.Set(1)
-		return true
+	shouldWrite, err := elector.IsLeader()
+	if err != nil {
+		metrics.LeaderGauge.Set(0)
+		log.Error("msg", "IsLeader check failed", "err", err)
+		return false
+	}
+	if !shouldWrite {
+		metrics.LeaderGauge.Set(0)
+		log.DebugRateLimited("msg", fmt.Sprintf("Election id %v: Instance is not a leader. Can't write data", elector.ID()))
+		return false
 	}
+
+	metrics.LeaderGauge.Set(1)
+	return true
 }
 
 type readCloser struct {
@@ -230,10 +239,10 @@ func ingest(inserter ingestor.DBInserter, dataParser *parser.DefaultParser) func
 		}
 
 		// if samples in write request are empty the we do not need to
-		// proceed further
+		// ingest anything
 		if len(req.Timeseries) == 0 && len(req.Metadata) == 0 {
 			ingestor.FinishWriteRequest(req)
-			return false
+			return true
 		}
 
 		var receivedSamplesCount, receivedMetadataCount int64
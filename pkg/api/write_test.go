@@ -353,12 +353,14 @@ func (m *mockElection) Resign() error {
 
 type mockInserter struct {
 	ts     []prompb.TimeSeries
+	traces []pdata.Traces
 	result int64
 	err    error
 }
 
-func (m *mockInserter) IngestTraces(_ context.Context, _ pdata.Traces) error {
-	panic("not implemented") // TODO: Implement
+func (m *mockInserter) IngestTraces(_ context.Context, traces pdata.Traces) error {
+	m.traces = append(m.traces, traces)
+	return m.err
 }
 
 func (m *mockInserter) Ingest(r *prompb.WriteRequest) (uint64, uint64, error) {
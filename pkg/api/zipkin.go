@@ -0,0 +1,50 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/zipkin"
+)
+
+var zipkinUnmarshalers = map[string]tracesUnmarshaler{
+	"application/json":       zipkin.UnmarshalJSON,
+	"application/x-protobuf": zipkin.UnmarshalProtobuf,
+}
+
+// ZipkinWrite returns an http.Handler that ingests spans in the Zipkin v2 JSON or
+// protobuf format, as sent to the /api/v2/spans endpoint of a Zipkin server. Like
+// Zipkin, it responds with 202 Accepted on success.
+func ZipkinWrite(conf *Config, inserter ingestor.DBInserter) http.Handler {
+	wh := writeHandler{}
+	wh.addStages(
+		validateZipkinWriteHeaders,
+		decodeGzip,
+		ingestTraces(inserter, zipkinUnmarshalers, traceWriteAuthorizer(conf)),
+		respondAccepted,
+	)
+	return corsWrapper(conf, wh.handler().ServeHTTP)
+}
+
+// validateZipkinWriteHeaders checks the request method. Zipkin reporters may omit
+// the content type of JSON spans.
+func validateZipkinWriteHeaders(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "POST" {
+		validateError(w, fmt.Sprintf("HTTP Method %s instead of POST", r.Method), metrics)
+		return false
+	}
+	if r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return true
+}
+
+func respondAccepted(w http.ResponseWriter, _ *http.Request) bool {
+	w.WriteHeader(http.StatusAccepted)
+	return true
+}
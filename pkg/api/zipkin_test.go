@@ -0,0 +1,103 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestZipkinWrite(t *testing.T) {
+	setMockWriteMetrics()
+
+	spans := `[{"traceId": "5af7183fb1d4cf5f", "id": "352bff9a74ca9ad2", "name": "get", "timestamp": 1556604172355737, "duration": 1431, "localEndpoint": {"serviceName": "frontend"}}]`
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write([]byte(spans))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	testCases := []struct {
+		name         string
+		method       string
+		body         string
+		headers      map[string]string
+		responseCode int
+		spans        int
+	}{
+		{
+			name:         "json",
+			method:       http.MethodPost,
+			body:         spans,
+			headers:      map[string]string{"Content-Type": "application/json"},
+			responseCode: http.StatusAccepted,
+			spans:        1,
+		},
+		{
+			name:         "no content type",
+			method:       http.MethodPost,
+			body:         spans,
+			responseCode: http.StatusAccepted,
+			spans:        1,
+		},
+		{
+			name:         "gzip",
+			method:       http.MethodPost,
+			body:         gzipped.String(),
+			headers:      map[string]string{"Content-Encoding": "gzip"},
+			responseCode: http.StatusAccepted,
+			spans:        1,
+		},
+		{
+			name:         "empty list",
+			method:       http.MethodPost,
+			body:         "[]",
+			responseCode: http.StatusAccepted,
+		},
+		{
+			name:         "invalid protobuf",
+			method:       http.MethodPost,
+			body:         "\x0a\xff",
+			headers:      map[string]string{"Content-Type": "application/x-protobuf"},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unsupported content type",
+			method:       http.MethodPost,
+			body:         spans,
+			headers:      map[string]string{"Content-Type": "application/x-thrift"},
+			responseCode: http.StatusBadRequest,
+		},
+		{
+			name:         "wrong method",
+			method:       http.MethodGet,
+			responseCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockInserter{}
+			req := httptest.NewRequest(c.method, "/api/v2/spans", strings.NewReader(c.body))
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			ZipkinWrite(&Config{}, mock).ServeHTTP(w, req)
+
+			require.Equal(t, c.responseCode, w.Code, w.Body.String())
+			require.Len(t, mock.traces, c.spans)
+			for _, traces := range mock.traces {
+				require.Equal(t, 1, traces.SpanCount())
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package importer
+
+import (
+	"fmt"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/timescale/promscale/pkg/log"
+)
+
+// importBlock imports all series of a Prometheus TSDB block. The series are read
+// sorted by their labels, so that a checkpoint position refers to the same series
+// when the import is resumed.
+func (imp *Importer) importBlock(dir string, pos Position) error {
+	block, err := tsdb.OpenBlock(log.GetLogger(), dir, chunkenc.NewPool())
+	if err != nil {
+		return fmt.Errorf("opening block: %w", err)
+	}
+	defer block.Close()
+
+	meta := block.Meta()
+	imp.inputTotal = int64(meta.Stats.NumSamples)
+	q, err := tsdb.NewBlockQuerier(block, meta.MinTime, meta.MaxTime)
+	if err != nil {
+		return fmt.Errorf("querying block: %w", err)
+	}
+	defer q.Close()
+
+	var (
+		ss      = q.Select(true, nil, labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, ".+"))
+		series  int64
+		current = newBatch()
+	)
+	for ; ss.Next(); series++ {
+		s := ss.At()
+		if series < pos.Series {
+			imp.inputSamples += countSamples(s.Iterator())
+			continue
+		}
+
+		var (
+			it      = s.Iterator()
+			samples int64
+			index   = -1
+		)
+		for it.Next() {
+			samples++
+			if series == pos.Series && samples <= pos.Samples {
+				imp.inputSamples++
+				continue
+			}
+			if index < 0 {
+				index = current.seriesIndex(s.Labels())
+			}
+			t, v := it.At()
+			current.addSample(index, t, v)
+
+			if current.samples >= imp.cfg.BatchSize {
+				if current, err = imp.flush(current, Position{Series: series, Samples: samples}); err != nil {
+					return err
+				}
+				index = -1
+			}
+		}
+		if err = it.Err(); err != nil {
+			return fmt.Errorf("reading samples of series %s: %w", s.Labels(), err)
+		}
+	}
+	if err = ss.Err(); err != nil {
+		return fmt.Errorf("reading series: %w", err)
+	}
+	_, err = imp.flush(current, Position{Series: series})
+	return err
+}
+
+func countSamples(it chunkenc.Iterator) int64 {
+	var n int64
+	for it.Next() {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Position is the position up to which an input has been imported.
+type Position struct {
+	// Done is set once the whole input has been imported.
+	Done bool `json:"done"`
+	// Series is the number of series of a TSDB block that have been imported completely.
+	Series int64 `json:"series,omitempty"`
+	// Samples is the number of imported samples of the TSDB block series after the
+	// completely imported ones.
+	Samples int64 `json:"samples,omitempty"`
+	// Offset is the number of bytes of a text file that have been imported.
+	Offset int64 `json:"offset,omitempty"`
+}
+
+// checkpoints keeps the import positions of all inputs, keyed by their absolute path,
+// in a JSON file. Without a file, positions are only kept in memory.
+type checkpoints struct {
+	file      string
+	positions map[string]Position
+}
+
+func loadCheckpoints(file string) (*checkpoints, error) {
+	c := &checkpoints{file: file, positions: make(map[string]Position)}
+	if file == "" {
+		return c, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint file: %w", err)
+	}
+	if err = json.Unmarshal(b, &c.positions); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint file %s: %w", file, err)
+	}
+	return c, nil
+}
+
+func (c *checkpoints) get(input string) Position {
+	return c.positions[input]
+}
+
+// set records the position of the input and persists all positions. The file is
+// replaced atomically so that a crash never leaves a truncated checkpoint behind.
+func (c *checkpoints) set(input string, pos Position) error {
+	c.positions[input] = pos
+	if c.file == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(c.positions, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.file + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("writing checkpoint file: %w", err)
+	}
+	if err = os.Rename(tmp, c.file); err != nil {
+		return fmt.Errorf("writing checkpoint file: %w", err)
+	}
+	return nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
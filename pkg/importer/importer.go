@@ -0,0 +1,252 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package importer backfills historical data from OpenMetrics / Prometheus text
+// files and Prometheus TSDB blocks by writing it through the ingestor.
+package importer
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+const (
+	defaultBatchSize        = 50000
+	defaultCheckpointFile   = "promscale-import-checkpoint.json"
+	defaultProgressInterval = 10 * time.Second
+
+	blockMetaFile = "meta.json"
+)
+
+// Config for the importer.
+type Config struct {
+	BatchSize        int
+	CheckpointFile   string
+	ProgressInterval time.Duration
+}
+
+// ParseFlags parses the configuration flags specific to importing.
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	fs.IntVar(&cfg.BatchSize, "import-batch-size", defaultBatchSize, "Maximum number of samples written to the database in one batch.")
+	fs.StringVar(&cfg.CheckpointFile, "import-checkpoint-file", defaultCheckpointFile, "File that keeps track of the import progress, so that an interrupted import "+
+		"resumes where it stopped when run again. Set to empty to disable checkpoints.")
+	fs.DurationVar(&cfg.ProgressInterval, "import-progress-interval", defaultProgressInterval, "Interval at which the import progress is logged.")
+	return cfg
+}
+
+// Validate the importer configuration.
+func Validate(cfg *Config) error {
+	if cfg.BatchSize < 1 {
+		return fmt.Errorf("invalid import batch size %d, must be at least 1", cfg.BatchSize)
+	}
+	return nil
+}
+
+// Importer writes the data of its inputs through the ingestor in large batches.
+type Importer struct {
+	cfg         Config
+	inserter    ingestor.DBInserter
+	checkpoints *checkpoints
+
+	// Progress of the current input.
+	input        string
+	inputTotal   int64
+	inputSamples int64
+	lastReport   time.Time
+
+	start        time.Time
+	totalSamples int64
+}
+
+// New returns an Importer writing to inserter. Imports resume from the checkpoint
+// file of the configuration if it exists.
+func New(cfg Config, inserter ingestor.DBInserter) (*Importer, error) {
+	if err := Validate(&cfg); err != nil {
+		return nil, err
+	}
+	c, err := loadCheckpoints(cfg.CheckpointFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Importer{cfg: cfg, inserter: inserter, checkpoints: c}, nil
+}
+
+// Import imports the data of the given paths, which are either text files in the
+// OpenMetrics or Prometheus exposition format, TSDB block directories or TSDB data
+// directories containing blocks. It returns the number of imported samples.
+func (imp *Importer) Import(paths ...string) (int64, error) {
+	imp.start, imp.totalSamples = time.Now(), 0
+	for _, path := range paths {
+		inputs, err := expandInput(path)
+		if err != nil {
+			return imp.totalSamples, err
+		}
+		for _, in := range inputs {
+			if err = imp.importInput(in); err != nil {
+				return imp.totalSamples, fmt.Errorf("importing %s: %w", in.path, err)
+			}
+		}
+	}
+	log.Info("msg", "Import finished", "samples", imp.totalSamples, "duration", time.Since(imp.start).Round(time.Millisecond))
+	return imp.totalSamples, nil
+}
+
+type input struct {
+	path    string
+	isBlock bool
+}
+
+// expandInput returns the inputs of path. The blocks of a TSDB data directory are
+// imported in the order of their ULIDs, i.e. in the order they were created.
+func expandInput(path string) ([]input, error) {
+	path = absPath(path)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []input{{path: path}}, nil
+	}
+	if isBlockDir(path) {
+		return []input{{path: path, isBlock: true}}, nil
+	}
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var inputs []input
+	for _, f := range files {
+		dir := filepath.Join(path, f.Name())
+		if f.IsDir() && isBlockDir(dir) {
+			inputs = append(inputs, input{path: dir, isBlock: true})
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("%s is neither a TSDB block nor contains any TSDB blocks", path)
+	}
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].path < inputs[j].path })
+	return inputs, nil
+}
+
+func isBlockDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, blockMetaFile))
+	return err == nil
+}
+
+func (imp *Importer) importInput(in input) error {
+	pos := imp.checkpoints.get(in.path)
+	if pos.Done {
+		log.Info("msg", "Skipping input, it was imported already according to the checkpoint file", "input", in.path)
+		return nil
+	}
+	if pos != (Position{}) {
+		log.Info("msg", "Resuming import from checkpoint", "input", in.path, "series", pos.Series, "samples", pos.Samples, "offset", pos.Offset)
+	} else {
+		log.Info("msg", "Importing", "input", in.path)
+	}
+	imp.input, imp.inputTotal, imp.inputSamples, imp.lastReport = in.path, 0, 0, time.Now()
+
+	var err error
+	if in.isBlock {
+		err = imp.importBlock(in.path, pos)
+	} else {
+		err = imp.importTextFile(in.path, pos)
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("msg", "Imported", "input", in.path, "samples", imp.inputSamples)
+	return imp.checkpoints.set(in.path, Position{Done: true})
+}
+
+// batch collects the samples of many series into one write request.
+type batch struct {
+	wr      *prompb.WriteRequest
+	series  map[string]int
+	samples int
+}
+
+func newBatch() *batch {
+	return &batch{wr: ingestor.NewWriteRequest(), series: make(map[string]int)}
+}
+
+// seriesIndex returns the index of the time series with the given labels in the
+// write request, adding the time series if needed.
+func (b *batch) seriesIndex(lset labels.Labels) int {
+	key := lset.String()
+	if i, ok := b.series[key]; ok {
+		return i
+	}
+	lbls := make([]prompb.Label, len(lset))
+	for i := range lset {
+		lbls[i] = prompb.Label{Name: lset[i].Name, Value: lset[i].Value}
+	}
+	b.wr.Timeseries = append(b.wr.Timeseries, prompb.TimeSeries{Labels: lbls})
+	b.series[key] = len(b.wr.Timeseries) - 1
+	return len(b.wr.Timeseries) - 1
+}
+
+func (b *batch) addSample(seriesIndex int, t int64, v float64) {
+	ts := &b.wr.Timeseries[seriesIndex]
+	ts.Samples = append(ts.Samples, prompb.Sample{Timestamp: t, Value: v})
+	b.samples++
+}
+
+func (b *batch) addExemplar(seriesIndex int, e prompb.Exemplar) {
+	ts := &b.wr.Timeseries[seriesIndex]
+	ts.Exemplars = append(ts.Exemplars, e)
+}
+
+func (b *batch) isEmpty() bool {
+	return len(b.wr.Timeseries) == 0 && len(b.wr.Metadata) == 0
+}
+
+// flush writes the batch, records pos as the checkpoint of the current input and
+// returns a new batch. The checkpoint is only correct if the inserter returns once
+// the batch is written, i.e. if it doesn't acknowledge inserts asynchronously. The
+// write request of the batch must not be used afterwards, as the ingestor recycles it.
+func (imp *Importer) flush(b *batch, pos Position) (*batch, error) {
+	if b.isEmpty() {
+		return b, nil
+	}
+	samples := b.samples
+	if _, _, err := imp.inserter.Ingest(b.wr); err != nil {
+		return nil, err
+	}
+	if err := imp.checkpoints.set(imp.input, pos); err != nil {
+		return nil, err
+	}
+	imp.inputSamples += int64(samples)
+	imp.totalSamples += int64(samples)
+	imp.reportProgress()
+	return newBatch(), nil
+}
+
+func (imp *Importer) reportProgress() {
+	if time.Since(imp.lastReport) < imp.cfg.ProgressInterval {
+		return
+	}
+	imp.lastReport = time.Now()
+	keyvals := []interface{}{
+		"msg", "Import progress",
+		"input", imp.input,
+		"input_samples", imp.inputSamples,
+		"total_samples", imp.totalSamples,
+		"samples_per_sec", int64(float64(imp.totalSamples) / time.Since(imp.start).Seconds()),
+	}
+	if imp.inputTotal > 0 {
+		keyvals = append(keyvals, "input_percent", fmt.Sprintf("%.1f", 100*float64(imp.inputSamples)/float64(imp.inputTotal)))
+	}
+	log.Info(keyvals...)
+}
@@ -0,0 +1,234 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/tsdbutil"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/prompb"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// mockInserter records the ingested samples per series. It fails once failAfter
+// write requests have been ingested, if set.
+type mockInserter struct {
+	samples   map[string][]prompb.Sample
+	exemplars map[string][]prompb.Exemplar
+	metadata  []prompb.MetricMetadata
+	requests  int
+	failAfter int
+}
+
+func newMockInserter() *mockInserter {
+	return &mockInserter{samples: make(map[string][]prompb.Sample), exemplars: make(map[string][]prompb.Exemplar)}
+}
+
+func (m *mockInserter) Ingest(wr *prompb.WriteRequest) (uint64, uint64, error) {
+	if m.failAfter > 0 && m.requests == m.failAfter {
+		return 0, 0, fmt.Errorf("connection lost")
+	}
+	m.requests++
+	var n uint64
+	for _, ts := range wr.Timeseries {
+		key := labelsString(ts.Labels)
+		m.samples[key] = append(m.samples[key], ts.Samples...)
+		m.exemplars[key] = append(m.exemplars[key], ts.Exemplars...)
+		n += uint64(len(ts.Samples) + len(ts.Exemplars))
+	}
+	m.metadata = append(m.metadata, wr.Metadata...)
+	return n, uint64(len(wr.Metadata)), nil
+}
+
+func (m *mockInserter) IngestTraces(_ context.Context, _ pdata.Traces) error {
+	return nil
+}
+
+func labelsString(lbls []prompb.Label) string {
+	lset := make(labels.Labels, len(lbls))
+	for i := range lbls {
+		lset[i] = labels.Label{Name: lbls[i].Name, Value: lbls[i].Value}
+	}
+	return lset.String()
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "promscale-import")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestImportPrometheusText(t *testing.T) {
+	dir := tempDir(t)
+	path := writeFile(t, dir, "metrics.prom", `# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{code="200"} 10 1000
+http_requests_total{code="500"} 1 1000
+http_requests_total{code="200"} 12 2000
+http_requests_total{code="500"} 2 2000
+up 1 1000
+`)
+
+	inserter := newMockInserter()
+	imp, err := New(Config{BatchSize: 2}, inserter)
+	require.NoError(t, err)
+	n, err := imp.Import(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), n)
+	require.Equal(t, 3, inserter.requests)
+	require.Equal(t, map[string][]prompb.Sample{
+		`{__name__="http_requests_total", code="200"}`: {{Timestamp: 1000, Value: 10}, {Timestamp: 2000, Value: 12}},
+		`{__name__="http_requests_total", code="500"}`: {{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 2}},
+		`{__name__="up"}`: {{Timestamp: 1000, Value: 1}},
+	}, inserter.samples)
+	require.Equal(t, []prompb.MetricMetadata{{
+		MetricFamilyName: "http_requests_total",
+		Type:             prompb.MetricMetadata_COUNTER,
+		Help:             "Total requests.",
+	}}, inserter.metadata)
+
+	// The last line doesn't need a newline.
+	path = writeFile(t, dir, "no_newline.prom", "up 1 3000")
+	n, err = imp.Import(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	require.Equal(t, []prompb.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 3000, Value: 1}}, inserter.samples[`{__name__="up"}`])
+
+	path = writeFile(t, dir, "no_timestamp.prom", "up 1\n")
+	_, err = imp.Import(path)
+	require.Error(t, err)
+}
+
+func TestImportOpenMetrics(t *testing.T) {
+	dir := tempDir(t)
+	path := writeFile(t, dir, "metrics.om", `# TYPE request_duration_seconds histogram
+# UNIT request_duration_seconds seconds
+request_duration_seconds_bucket{le="1.0"} 3 1.5 # {trace_id="abc"} 0.5 1.2
+request_duration_seconds_bucket{le="+Inf"} 4 1.5
+request_duration_seconds_count 4 1.5
+request_duration_seconds_sum 2.5 1.5
+# EOF
+`)
+
+	inserter := newMockInserter()
+	imp, err := New(Config{BatchSize: 100}, inserter)
+	require.NoError(t, err)
+	n, err := imp.Import(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+	require.Equal(t, []prompb.Sample{{Timestamp: 1500, Value: 3}}, inserter.samples[`{__name__="request_duration_seconds_bucket", le="1.0"}`])
+	require.Equal(t, []prompb.Exemplar{{
+		Labels:    []prompb.Label{{Name: "trace_id", Value: "abc"}},
+		Value:     0.5,
+		Timestamp: 1200,
+	}}, inserter.exemplars[`{__name__="request_duration_seconds_bucket", le="1.0"}`])
+	require.Equal(t, []prompb.MetricMetadata{{
+		MetricFamilyName: "request_duration_seconds",
+		Type:             prompb.MetricMetadata_HISTOGRAM,
+		Unit:             "seconds",
+	}}, inserter.metadata)
+}
+
+func TestImportResumesFromCheckpoint(t *testing.T) {
+	dir := tempDir(t)
+	content := ""
+	for i := 0; i < 10; i++ {
+		content += fmt.Sprintf("metric{i=\"%d\"} %d 1000\n", i, i)
+	}
+	path := writeFile(t, dir, "metrics.prom", content)
+	cfg := Config{BatchSize: 3, CheckpointFile: filepath.Join(dir, "checkpoint.json")}
+
+	inserter := newMockInserter()
+	inserter.failAfter = 2
+	imp, err := New(cfg, inserter)
+	require.NoError(t, err)
+	n, err := imp.Import(path)
+	require.Error(t, err)
+	require.Equal(t, int64(6), n)
+	// The checkpoint is the offset of the line after the last written batch.
+	c, err := loadCheckpoints(cfg.CheckpointFile)
+	require.NoError(t, err)
+	require.Equal(t, Position{Offset: int64(len(`metric{i="0"} 0 1000`)+1) * 6}, c.get(path))
+
+	inserter.failAfter = 0
+	imp, err = New(cfg, inserter)
+	require.NoError(t, err)
+	n, err = imp.Import(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+	require.Len(t, inserter.samples, 10)
+	for key, samples := range inserter.samples {
+		require.Len(t, samples, 1, "series %s", key)
+	}
+
+	// A finished input is skipped.
+	n, err = imp.Import(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+}
+
+func TestImportBlock(t *testing.T) {
+	dir := tempDir(t)
+	var series []storage.Series
+	for i := 0; i < 3; i++ {
+		samples := make([]tsdbutil.Sample, 0, 100)
+		for ts := int64(0); ts < 100; ts++ {
+			samples = append(samples, sample{t: ts * 1000, v: float64(i)})
+		}
+		series = append(series, storage.NewListSeries(labels.FromStrings("__name__", "metric", "i", fmt.Sprint(i)), samples))
+	}
+	_, err := tsdb.CreateBlock(series, dir, 0, log.NewNopLogger())
+	require.NoError(t, err)
+
+	cfg := Config{BatchSize: 40, CheckpointFile: filepath.Join(dir, "checkpoint.json")}
+	inserter := newMockInserter()
+	// Fail in the middle of the second series.
+	inserter.failAfter = 4
+	imp, err := New(cfg, inserter)
+	require.NoError(t, err)
+	// The data directory containing the block is expanded to the block.
+	_, err = imp.Import(dir)
+	require.Error(t, err)
+
+	inserter.failAfter = 0
+	imp, err = New(cfg, inserter)
+	require.NoError(t, err)
+	_, err = imp.Import(dir)
+	require.NoError(t, err)
+
+	require.Len(t, inserter.samples, 3)
+	for i := 0; i < 3; i++ {
+		samples := inserter.samples[fmt.Sprintf(`{__name__="metric", i="%d"}`, i)]
+		require.Len(t, samples, 100)
+		for j, s := range samples {
+			require.Equal(t, prompb.Sample{Timestamp: int64(j) * 1000, Value: float64(i)}, s)
+		}
+	}
+}
+
+type sample struct {
+	t int64
+	v float64
+}
+
+func (s sample) T() int64   { return s.t }
+func (s sample) V() float64 { return s.v }
@@ -0,0 +1,191 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/prometheus/prometheus/pkg/exemplar"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/textparse"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+const (
+	openMetricsContentType = "application/openmetrics-text"
+	prometheusContentType  = "text/plain"
+)
+
+var openMetricsEOF = []byte("# EOF")
+
+// contentTypeProbeSize is the number of bytes at the end of a text file that are
+// searched for the OpenMetrics EOF marker.
+const contentTypeProbeSize = 4096
+
+// importTextFile imports a file in the OpenMetrics or Prometheus text exposition
+// format. Since the data is backfilled, every sample needs a timestamp. The file is
+// read line by line, so that files of any size can be imported, and the checkpoint
+// position is the offset of the line after the last imported sample. The metric
+// metadata is written with the batch following the line it was read from.
+func (imp *Importer) importTextFile(path string, pos Position) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	contentType, err := textContentType(f)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Seek(pos.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	var (
+		r        = bufio.NewReader(f)
+		offset   = pos.Offset
+		buf      []byte
+		et       textparse.Entry
+		lset     labels.Labels
+		ex       exemplar.Exemplar
+		metadata = make(map[string]*prompb.MetricMetadata)
+		pending  = make(map[string]struct{})
+		current  = newBatch()
+	)
+	for {
+		line, rerr := r.ReadBytes('\n')
+		if rerr != nil && rerr != io.EOF {
+			return fmt.Errorf("error reading file: %w", rerr)
+		}
+		offset += int64(len(line))
+
+		trimmed := bytes.TrimSpace(line)
+		if contentType == openMetricsContentType && bytes.Equal(trimmed, openMetricsEOF) {
+			break
+		}
+		if len(trimmed) > 0 {
+			// Every entry of the text formats is a single line, so the lines are
+			// parsed one at a time. An OpenMetrics parser needs the EOF marker.
+			buf = append(append(buf[:0], trimmed...), '\n')
+			if contentType == openMetricsContentType {
+				buf = append(append(buf, openMetricsEOF...), '\n')
+			}
+			p := textparse.New(buf, contentType)
+			for {
+				if et, err = p.Next(); err != nil {
+					if err == io.EOF {
+						break
+					}
+					return fmt.Errorf("error parsing text entries at offset %d: %w", offset-int64(len(line)), err)
+				}
+
+				switch et {
+				case textparse.EntryType:
+					name, typ := p.Type()
+					metricMetadata(metadata, pending, name).Type = prompb.MetricMetadata_MetricType(prompb.MetricMetadata_MetricType_value[strings.ToUpper(string(typ))])
+					continue
+				case textparse.EntryHelp:
+					name, help := p.Help()
+					metricMetadata(metadata, pending, name).Help = string(help)
+					continue
+				case textparse.EntryUnit:
+					name, unit := p.Unit()
+					metricMetadata(metadata, pending, name).Unit = string(unit)
+					continue
+				case textparse.EntryComment:
+					continue
+				default:
+				}
+
+				s, tp, v := p.Series()
+				if tp == nil {
+					return fmt.Errorf("sample of series %s has no timestamp", s)
+				}
+				lset = lset[:0]
+				_ = p.Metric(&lset)
+
+				i := current.seriesIndex(lset)
+				current.addSample(i, *tp, v)
+				ex = exemplar.Exemplar{}
+				if p.Exemplar(&ex) {
+					t := *tp
+					if ex.HasTs {
+						t = ex.Ts
+					}
+					current.addExemplar(i, toPromExemplar(ex, t))
+				}
+			}
+		}
+
+		if current.samples >= imp.cfg.BatchSize {
+			addMetadata(current, metadata, pending)
+			if current, err = imp.flush(current, Position{Offset: offset}); err != nil {
+				return err
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+	}
+
+	addMetadata(current, metadata, pending)
+	_, err = imp.flush(current, Position{Offset: offset})
+	return err
+}
+
+// textContentType tells the OpenMetrics format, which must end with an EOF marker,
+// apart from the Prometheus text format.
+func textContentType(f *os.File) (string, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	n := fi.Size()
+	if n > contentTypeProbeSize {
+		n = contentTypeProbeSize
+	}
+	b := make([]byte, n)
+	if _, err = f.ReadAt(b, fi.Size()-n); err != nil && err != io.EOF {
+		return "", err
+	}
+	if bytes.HasSuffix(bytes.TrimSpace(b), openMetricsEOF) {
+		return openMetricsContentType, nil
+	}
+	return prometheusContentType, nil
+}
+
+// addMetadata adds the pending metric metadata to the batch.
+func addMetadata(b *batch, metadata map[string]*prompb.MetricMetadata, pending map[string]struct{}) {
+	for name := range pending {
+		b.wr.Metadata = append(b.wr.Metadata, *metadata[name])
+		delete(pending, name)
+	}
+}
+
+// metricMetadata returns the metadata of the metric family, marking it as pending
+// to be written.
+func metricMetadata(metadata map[string]*prompb.MetricMetadata, pending map[string]struct{}, name []byte) *prompb.MetricMetadata {
+	m, ok := metadata[string(name)]
+	if !ok {
+		m = &prompb.MetricMetadata{MetricFamilyName: string(name)}
+		metadata[string(name)] = m
+	}
+	pending[string(name)] = struct{}{}
+	return m
+}
+
+func toPromExemplar(ex exemplar.Exemplar, t int64) prompb.Exemplar {
+	lbls := make([]prompb.Label, len(ex.Labels))
+	for i := range ex.Labels {
+		lbls[i] = prompb.Label{Name: ex.Labels[i].Name, Value: ex.Labels[i].Value}
+	}
+	return prompb.Exemplar{Labels: lbls, Value: ex.Value, Timestamp: t}
+}
@@ -71,6 +71,7 @@ func New(config ProxyConfig, logger hclog.Logger) (*Proxy, error) {
 		logger:                  logger,
 		conn:                    conn,
 		spanReaderClient:        storage_v1.NewSpanReaderPluginClient(conn),
+		spanWriterClient:        storage_v1.NewSpanWriterPluginClient(conn),
 		dependencyReaderClient:  storage_v1.NewDependenciesReaderPluginClient(conn),
 		capClient:               storage_v1.NewPluginCapabilitiesClient(conn),
 		archiveSpanReaderClient: storage_v1.NewArchiveSpanReaderPluginClient(conn),
@@ -91,7 +92,6 @@ func (p *Proxy) GetDependencies(ctx context.Context, r *storage_v1.GetDependenci
 }
 
 func (p *Proxy) WriteSpan(ctx context.Context, r *storage_v1.WriteSpanRequest) (*storage_v1.WriteSpanResponse, error) {
-	//TODO: figure out what to do here if we want the jaeger-all-in-one to work. Fine if using jaeger-query
 	return p.spanWriterClient.WriteSpan(ctx, r)
 }
 
@@ -0,0 +1,141 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+const (
+	insertArchivedSpanSQL = `
+INSERT INTO _ps_trace.archived_span (trace_id, span_id, start_time, span, tenant)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (trace_id, span_id) DO UPDATE
+SET start_time = excluded.start_time, span = excluded.span, tenant = excluded.tenant`
+
+	getArchivedTraceSQLFormat = `
+SELECT
+	span
+FROM
+	_ps_trace.archived_span
+WHERE
+	trace_id = $1
+	AND %s
+ORDER BY start_time, span_id`
+)
+
+var errArchiveQuery = fmt.Errorf("the archive storage only supports fetching traces by ID")
+
+// archive is the Jaeger archive storage. Archived spans are stored in the Jaeger
+// protobuf format in the _ps_trace.archived_span table, which is independent of
+// the span hypertable and therefore not subject to trace retention. The tenant
+// of archived spans is stored alongside them, to restrict reads like for spans.
+type archive struct {
+	conn      pgxconn.PgxConn
+	writeConn pgxconn.PgxConn
+	wAuth     tenancy.WriteAuthorizer
+	tenants   *tenancy.TenantScope
+}
+
+func (a *archive) GetTrace(ctx context.Context, traceID model.TraceID) (*model.Trace, error) {
+	res, err := getArchivedTrace(ctx, a.conn, traceID, a.tenants)
+	if err == spanstore.ErrTraceNotFound {
+		return nil, err
+	}
+	return res, logError(err)
+}
+
+func (a *archive) WriteSpan(ctx context.Context, span *model.Span) error {
+	return logError(writeArchivedSpan(ctx, a.writeConn, a.wAuth, span))
+}
+
+func (a *archive) GetServices(context.Context) ([]string, error) {
+	return nil, errArchiveQuery
+}
+
+func (a *archive) GetOperations(context.Context, spanstore.OperationQueryParameters) ([]spanstore.Operation, error) {
+	return nil, errArchiveQuery
+}
+
+func (a *archive) FindTraces(context.Context, *spanstore.TraceQueryParameters) ([]*model.Trace, error) {
+	return nil, errArchiveQuery
+}
+
+func (a *archive) FindTraceIDs(context.Context, *spanstore.TraceQueryParameters) ([]model.TraceID, error) {
+	return nil, errArchiveQuery
+}
+
+func getArchivedTrace(ctx context.Context, conn pgxconn.PgxConn, traceID model.TraceID, scope *tenancy.TenantScope) (*model.Trace, error) {
+	uuid, err := traceIDToUUID(traceID)
+	if err != nil {
+		return nil, err
+	}
+	tenantQual, params := archivedTenantClause(scope, []interface{}{uuid})
+	if tenantQual == "" {
+		tenantQual = "TRUE"
+	}
+	rows, err := conn.Query(ctx, fmt.Sprintf(getArchivedTraceSQLFormat, tenantQual), params...)
+	if err != nil {
+		return nil, fmt.Errorf("querying archived trace: %w", err)
+	}
+	defer rows.Close()
+
+	trace := &model.Trace{}
+	for rows.Next() {
+		var b []byte
+		if err = rows.Scan(&b); err != nil {
+			return nil, fmt.Errorf("scanning archived span: %w", err)
+		}
+		span := new(model.Span)
+		if err = span.Unmarshal(b); err != nil {
+			return nil, fmt.Errorf("unmarshaling archived span: %w", err)
+		}
+		trace.Spans = append(trace.Spans, span)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying archived trace: %w", err)
+	}
+	if len(trace.Spans) == 0 {
+		return nil, spanstore.ErrTraceNotFound
+	}
+	return trace, nil
+}
+
+func writeArchivedSpan(ctx context.Context, conn pgxconn.PgxConn, wAuth tenancy.WriteAuthorizer, span *model.Span) error {
+	if conn == nil {
+		return errReadOnly
+	}
+	uuid, err := traceIDToUUID(span.TraceID)
+	if err != nil {
+		return err
+	}
+	traces, err := spanTraces(ctx, wAuth, span)
+	if err != nil {
+		return err
+	}
+	var tenant pgtype.Text
+	if name := tracesTenant(traces); name != "" {
+		tenant = pgtype.Text{String: name, Status: pgtype.Present}
+	} else {
+		tenant.Status = pgtype.Null
+	}
+	b, err := span.Marshal()
+	if err != nil {
+		return fmt.Errorf("marshaling span: %w", err)
+	}
+	_, err = conn.Exec(ctx, insertArchivedSpanSQL, uuid, int64(span.SpanID), span.StartTime.UTC().Truncate(time.Microsecond), b, tenant)
+	if err != nil {
+		return fmt.Errorf("error archiving span %s of trace %s: %w", span.SpanID, span.TraceID, err)
+	}
+	return nil
+}
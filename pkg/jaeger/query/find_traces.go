@@ -12,11 +12,12 @@ import (
 	"github.com/jaegertracing/jaeger/storage/spanstore"
 	jaegertranslator "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/jaeger"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 	"go.opentelemetry.io/collector/model/pdata"
 )
 
-func findTraces(ctx context.Context, conn pgxconn.PgxConn, q *spanstore.TraceQueryParameters) ([]*model.Trace, error) {
-	query, params := findTracesQuery(q)
+func findTraces(ctx context.Context, conn pgxconn.PgxConn, q *spanstore.TraceQueryParameters, scope *tenancy.TenantScope) ([]*model.Trace, error) {
+	query, params := findTracesQuery(q, scope)
 	rows, err := conn.Query(ctx, query, params...)
 	if err != nil {
 		return nil, fmt.Errorf("querying traces error: %w query:\n%s", err, query)
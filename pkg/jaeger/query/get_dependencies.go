@@ -0,0 +1,82 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+const (
+	getDependenciesSQL = `
+SELECT
+	parent_service,
+	child_service,
+	call_count
+FROM
+	_ps_trace.get_service_dependencies($1, $2)`
+
+	// The materialized dependencies are not per tenant, so the dependencies
+	// of tenants are aggregated from their spans, like span_service_dependencies.
+	getTenantDependenciesSQLFormat = `
+SELECT
+	pt.value#>>'{}',
+	ct.value#>>'{}',
+	count(*)
+FROM _ps_trace.span s
+INNER JOIN _ps_trace.span p ON (p.trace_id = s.trace_id AND p.span_id = s.parent_span_id)
+INNER JOIN _ps_trace.operation co ON (co.id = s.operation_id)
+INNER JOIN _ps_trace.operation po ON (po.id = p.operation_id)
+INNER JOIN _ps_trace.tag pt ON (pt.id = po.service_name_id AND pt.key = 'service.name')
+INNER JOIN _ps_trace.tag ct ON (ct.id = co.service_name_id AND ct.key = 'service.name')
+WHERE
+	s.start_time >= $1
+	AND s.start_time < $2
+	AND s.parent_span_id IS NOT NULL
+	AND po.service_name_id != co.service_name_id
+	AND %s
+	AND %s
+GROUP BY 1, 2
+ORDER BY 1, 2`
+)
+
+func getDependencies(ctx context.Context, conn pgxconn.PgxConn, endTs time.Time, lookback time.Duration, scope *tenancy.TenantScope) ([]model.DependencyLink, error) {
+	sqlQuery := getDependenciesSQL
+	params := []interface{}{endTs.Add(-lookback), endTs}
+	childQual, params := tenantClause(scope, "s", params)
+	if childQual != "" {
+		// Both the calling and the called spans must be readable.
+		var parentQual string
+		parentQual, params = tenantClause(scope, "p", params)
+		sqlQuery = fmt.Sprintf(getTenantDependenciesSQLFormat, childQual, parentQual)
+	}
+	rows, err := conn.Query(ctx, sqlQuery, params...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching dependencies: %w", err)
+	}
+	defer rows.Close()
+
+	deps := make([]model.DependencyLink, 0)
+	for rows.Next() {
+		var (
+			dep       model.DependencyLink
+			callCount int64
+		)
+		if err = rows.Scan(&dep.Parent, &dep.Child, &callCount); err != nil {
+			return nil, fmt.Errorf("scanning dependencies: %w", err)
+		}
+		dep.CallCount = uint64(callCount)
+		deps = append(deps, dep)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("fetching dependencies: %w", err)
+	}
+	return deps, nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/jackc/pgtype"
 	"github.com/jaegertracing/jaeger/storage/spanstore"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
 const (
@@ -29,10 +30,20 @@ WHERE
 		AND value = to_jsonb($1::text)
 	)
 	AND %s
+	AND %s
 `
+
+	// The operations of a tenant are those with spans of the tenant.
+	operationTenantSQLFormat = `
+	EXISTS (
+		SELECT 1
+		FROM _ps_trace.span s
+		WHERE s.operation_id = o.id
+		AND %s
+	)`
 )
 
-func getOperations(ctx context.Context, conn pgxconn.PgxConn, query spanstore.OperationQueryParameters) ([]spanstore.Operation, error) {
+func getOperations(ctx context.Context, conn pgxconn.PgxConn, query spanstore.OperationQueryParameters, scope *tenancy.TenantScope) ([]spanstore.Operation, error) {
 	var (
 		pgOperationNames, pgSpanKinds pgtype.TextArray
 		operationsResp                []spanstore.Operation
@@ -45,7 +56,14 @@ func getOperations(ctx context.Context, conn pgxconn.PgxConn, query spanstore.Op
 		kindQual = "o.span_kind = $2"
 	}
 
-	sqlQuery := fmt.Sprintf(getOperationsSQLFormat, kindQual)
+	tenantQual, args := tenantClause(scope, "s", args)
+	if tenantQual != "" {
+		tenantQual = fmt.Sprintf(operationTenantSQLFormat, tenantQual)
+	} else {
+		tenantQual = "TRUE"
+	}
+
+	sqlQuery := fmt.Sprintf(getOperationsSQLFormat, kindQual, tenantQual)
 
 	if err := conn.QueryRow(ctx, sqlQuery, args...).Scan(&pgOperationNames, &pgSpanKinds); err != nil {
 		return operationsResp, fmt.Errorf("fetching operations: %w", err)
@@ -12,19 +12,40 @@ import (
 	"github.com/jackc/pgx/v4"
 	"github.com/pkg/errors"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
-const getServicesSQL = `
+const (
+	getServicesSQLFormat = `
 SELECT
  	array_agg(value#>>'{}' ORDER BY value)
 FROM
-	_ps_trace.tag
+	_ps_trace.tag t
 WHERE
-         key='service.name' and value IS NOT NULL`
+         key='service.name' and value IS NOT NULL
+         AND %s`
 
-func getServices(ctx context.Context, conn pgxconn.PgxConn) ([]string, error) {
+	// The services of a tenant are those with spans of the tenant.
+	serviceTenantSQLFormat = `
+	EXISTS (
+		SELECT 1
+		FROM _ps_trace.operation o
+		INNER JOIN _ps_trace.span s ON (s.operation_id = o.id)
+		WHERE o.service_name_id = t.id
+		AND %s
+	)`
+)
+
+func getServices(ctx context.Context, conn pgxconn.PgxConn, scope *tenancy.TenantScope) ([]string, error) {
 	var pgServices pgtype.TextArray
-	if err := conn.QueryRow(ctx, getServicesSQL).Scan(&pgServices); err != nil {
+	tenantQual, args := tenantClause(scope, "s", nil)
+	if tenantQual != "" {
+		tenantQual = fmt.Sprintf(serviceTenantSQLFormat, tenantQual)
+	} else {
+		tenantQual = "TRUE"
+	}
+	sqlQuery := fmt.Sprintf(getServicesSQLFormat, tenantQual)
+	if err := conn.QueryRow(ctx, sqlQuery, args...).Scan(&pgServices); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return []string{}, nil
 		}
@@ -11,10 +11,11 @@ import (
 	"github.com/jaegertracing/jaeger/model"
 	"github.com/jaegertracing/jaeger/storage/spanstore"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
-func getTrace(ctx context.Context, conn pgxconn.PgxConn, traceID model.TraceID) (*model.Trace, error) {
-	query, params, err := getTraceQuery(traceID)
+func getTrace(ctx context.Context, conn pgxconn.PgxConn, traceID model.TraceID, scope *tenancy.TenantScope) (*model.Trace, error) {
+	query, params, err := getTraceQuery(traceID, scope)
 	if err != nil {
 		return nil, fmt.Errorf("get trace query: %w", err)
 	}
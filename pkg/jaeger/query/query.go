@@ -12,15 +12,46 @@ import (
 	"github.com/jaegertracing/jaeger/storage/dependencystore"
 	"github.com/jaegertracing/jaeger/storage/spanstore"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
 type Query struct {
-	conn pgxconn.PgxConn
+	conn     pgxconn.PgxConn
+	inserter ingestor.DBInserter
+	archive  *archive
+	// wAuth authorizes the tenant of the written spans, if not nil.
+	wAuth tenancy.WriteAuthorizer
+	// tenants restricts the read spans to those of the tenants, if not nil.
+	tenants *tenancy.TenantScope
 }
 
-func New(conn pgxconn.PgxConn) *Query {
-	return &Query{conn}
+// New returns a Jaeger storage plugin that reads traces with conn, ingests the
+// written spans with inserter like spans received over OTLP and archives spans
+// with writeConn. A nil inserter or writeConn rejects the respective writes,
+// e.g. in read-only mode. If mt is not nil, the written spans are authorized
+// for the tenant of the request and the read spans, including archived spans,
+// are restricted to the tenants allowed to be read.
+func New(conn pgxconn.PgxConn, writeConn pgxconn.PgxConn, inserter ingestor.DBInserter, mt tenancy.Authorizer) *Query {
+	var (
+		wAuth   tenancy.WriteAuthorizer
+		tenants *tenancy.TenantScope
+	)
+	if mt != nil {
+		wAuth = mt.WriteAuthorizer()
+		if rAuth := mt.ReadAuthorizer(); rAuth != nil {
+			scope := rAuth.TenantScope()
+			tenants = &scope
+		}
+	}
+	return &Query{
+		conn:     conn,
+		inserter: inserter,
+		archive:  &archive{conn: conn, writeConn: writeConn, wAuth: wAuth, tenants: tenants},
+		wAuth:    wAuth,
+		tenants:  tenants,
+	}
 }
 
 func (p *Query) SpanReader() spanstore.Reader {
@@ -32,38 +63,49 @@ func (p *Query) DependencyReader() dependencystore.Reader {
 }
 
 func (p *Query) SpanWriter() spanstore.Writer {
-	panic("Use Promscale + OTEL-collector to ingest traces")
+	return p
+}
+
+func (p *Query) WriteSpan(ctx context.Context, span *model.Span) error {
+	return logError(writeSpan(ctx, p.inserter, p.wAuth, span))
+}
+
+func (p *Query) ArchiveSpanReader() spanstore.Reader {
+	return p.archive
+}
+
+func (p *Query) ArchiveSpanWriter() spanstore.Writer {
+	return p.archive
 }
 
 func (p *Query) GetTrace(ctx context.Context, traceID model.TraceID) (*model.Trace, error) {
-	res, err := getTrace(ctx, p.conn, traceID)
+	res, err := getTrace(ctx, p.conn, traceID, p.tenants)
 	return res, logError(err)
 }
 
 func (p *Query) GetServices(ctx context.Context) ([]string, error) {
-	res, err := getServices(ctx, p.conn)
+	res, err := getServices(ctx, p.conn, p.tenants)
 	return res, logError(err)
 }
 
 func (p *Query) GetOperations(ctx context.Context, query spanstore.OperationQueryParameters) ([]spanstore.Operation, error) {
-	res, err := getOperations(ctx, p.conn, query)
+	res, err := getOperations(ctx, p.conn, query, p.tenants)
 	return res, logError(err)
 }
 
 func (p *Query) FindTraces(ctx context.Context, query *spanstore.TraceQueryParameters) ([]*model.Trace, error) {
-	res, err := findTraces(ctx, p.conn, query)
+	res, err := findTraces(ctx, p.conn, query, p.tenants)
 	return res, logError(err)
 }
 
 func (p *Query) FindTraceIDs(ctx context.Context, query *spanstore.TraceQueryParameters) ([]model.TraceID, error) {
-	res, err := findTraceIDs(ctx, p.conn, query)
+	res, err := findTraceIDs(ctx, p.conn, query, p.tenants)
 	return res, logError(err)
 }
 
 func (p *Query) GetDependencies(ctx context.Context, endTs time.Time, lookback time.Duration) ([]model.DependencyLink, error) {
-	//query db using pgx here
-	log.Warn("getDependencies")
-	return nil, nil
+	res, err := getDependencies(ctx, p.conn, endTs, lookback, p.tenants)
+	return res, logError(err)
 }
 
 func logError(err error) error {
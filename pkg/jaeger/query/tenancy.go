@@ -0,0 +1,69 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/timescale/promscale/pkg/tenancy"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// tenantClause returns the condition that restricts the spans with the alias
+// span to the tenants of scope, with its params appended to params. The
+// condition is empty if all spans are in scope, including when scope is nil.
+func tenantClause(scope *tenancy.TenantScope, span string, params []interface{}) (string, []interface{}) {
+	if scope == nil || (scope.AllTenants && scope.NonTenants) {
+		return "", params
+	}
+	params = append(params, tenancy.TenantAttributeKey)
+	keyParam := len(params)
+	if scope.AllTenants {
+		return fmt.Sprintf("%s.resource_tags #? $%d", span, keyParam), params
+	}
+
+	quals := make([]string, 0, len(scope.Tenants)+1)
+	for _, tenant := range scope.Tenants {
+		params = append(params, tenant)
+		quals = append(quals, fmt.Sprintf("%s.resource_tags ? ($%d == $%d)", span, keyParam, len(params)))
+	}
+	if scope.NonTenants {
+		quals = append(quals, fmt.Sprintf("NOT %s.resource_tags #? $%d", span, keyParam))
+	}
+	if len(quals) == 0 {
+		return "FALSE", params[:keyParam-1]
+	}
+	return "(" + strings.Join(quals, " OR ") + ")", params
+}
+
+// archivedTenantClause returns the condition that restricts the archived spans
+// to the tenants of scope, like tenantClause does for spans.
+func archivedTenantClause(scope *tenancy.TenantScope, params []interface{}) (string, []interface{}) {
+	switch {
+	case scope == nil || (scope.AllTenants && scope.NonTenants):
+		return "", params
+	case scope.AllTenants:
+		return "tenant IS NOT NULL", params
+	}
+	params = append(params, scope.Tenants)
+	qual := fmt.Sprintf("tenant = ANY($%d::text[])", len(params))
+	if scope.NonTenants {
+		qual = fmt.Sprintf("(%s OR tenant IS NULL)", qual)
+	}
+	return qual, params
+}
+
+// tracesTenant returns the tenant of the resources of traces, or an empty
+// string if they do not have one.
+func tracesTenant(traces pdata.Traces) string {
+	rSpans := traces.ResourceSpans()
+	for i := 0; i < rSpans.Len(); i++ {
+		if tenant, found := rSpans.At(i).Resource().Attributes().Get(tenancy.TenantAttributeKey); found {
+			return tenant.AsString()
+		}
+	}
+	return ""
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/jackc/pgtype"
 	"github.com/jaegertracing/jaeger/model"
 	"github.com/jaegertracing/jaeger/storage/spanstore"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
 const (
@@ -112,36 +113,54 @@ func buildCompleteTraceQuery(traceIDClause string) string {
 		traceIDClause)
 }
 
-func findTracesQuery(q *spanstore.TraceQueryParameters) (string, []interface{}) {
-	subquery, params := buildTraceIDSubquery(q)
+func findTracesQuery(q *spanstore.TraceQueryParameters, scope *tenancy.TenantScope) (string, []interface{}) {
+	subquery, params := buildTraceIDSubquery(q, scope)
 	traceIDClause := "s.trace_id = trace_ids.trace_id"
+	// The spans of other tenants may share the trace IDs.
+	tenantQual, params := tenantClause(scope, "s", params)
+	if tenantQual != "" {
+		traceIDClause += " AND " + tenantQual
+	}
 	completeTraceSQL := buildCompleteTraceQuery(traceIDClause)
 	return fmt.Sprintf(findTraceSQLFormat, subquery, completeTraceSQL), params
 }
 
-func findTraceIDsQuery(q *spanstore.TraceQueryParameters) (string, []interface{}) {
-	subquery, params := buildTraceIDSubquery(q)
+func findTraceIDsQuery(q *spanstore.TraceQueryParameters, scope *tenancy.TenantScope) (string, []interface{}) {
+	subquery, params := buildTraceIDSubquery(q, scope)
 	return subquery, params
 }
 
-func getTraceQuery(traceID model.TraceID) (string, []interface{}, error) {
-	var b [16]byte
-	n, err := traceID.MarshalTo(b[:])
-	if n != 16 || err != nil {
-		return "", nil, fmt.Errorf("marshaling TraceID: %w", err)
-	}
-
-	var uuid pgtype.UUID
-	if err := uuid.Set(b); err != nil {
-		return "", nil, fmt.Errorf("setting TraceID: %w", err)
+func getTraceQuery(traceID model.TraceID, scope *tenancy.TenantScope) (string, []interface{}, error) {
+	uuid, err := traceIDToUUID(traceID)
+	if err != nil {
+		return "", nil, err
 	}
 	params := []interface{}{uuid}
 
 	traceIDClause := "s.trace_id = $1"
+	tenantQual, params := tenantClause(scope, "s", params)
+	if tenantQual != "" {
+		traceIDClause += " AND " + tenantQual
+	}
 	return buildCompleteTraceQuery(traceIDClause), params, nil
 }
 
-func buildTraceIDSubquery(q *spanstore.TraceQueryParameters) (string, []interface{}) {
+func traceIDToUUID(traceID model.TraceID) (pgtype.UUID, error) {
+	var (
+		b    [16]byte
+		uuid pgtype.UUID
+	)
+	n, err := traceID.MarshalTo(b[:])
+	if n != 16 || err != nil {
+		return uuid, fmt.Errorf("marshaling TraceID: %w", err)
+	}
+	if err := uuid.Set(b); err != nil {
+		return uuid, fmt.Errorf("setting TraceID: %w", err)
+	}
+	return uuid, nil
+}
+
+func buildTraceIDSubquery(q *spanstore.TraceQueryParameters, scope *tenancy.TenantScope) (string, []interface{}) {
 	clauses := make([]string, 0, 15)
 	params := make([]interface{}, 0, 15)
 
@@ -217,6 +236,11 @@ func buildTraceIDSubquery(q *spanstore.TraceQueryParameters) (string, []interfac
 
 	}
 
+	tenantQual, params := tenantClause(scope, "s", params)
+	if tenantQual != "" {
+		clauses = append(clauses, tenantQual)
+	}
+
 	query := ""
 	if len(clauses) > 0 {
 		query = fmt.Sprintf(subqueryFormat, strings.Join(clauses, " AND "))
@@ -0,0 +1,52 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jaegertracing/jaeger/model"
+	jaegertranslator "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/jaeger"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/tenancy"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+var errReadOnly = fmt.Errorf("spans cannot be written when Promscale is in read-only mode")
+
+// writeSpan translates a Jaeger span into OpenTelemetry traces and ingests them
+// with inserter, so that spans written by Jaeger collectors are sampled, batched
+// and stored the same way as spans ingested through OTLP.
+func writeSpan(ctx context.Context, inserter ingestor.DBInserter, wAuth tenancy.WriteAuthorizer, span *model.Span) error {
+	if inserter == nil {
+		return errReadOnly
+	}
+	traces, err := spanTraces(ctx, wAuth, span)
+	if err != nil {
+		return err
+	}
+	if err := inserter.IngestTraces(ctx, traces); err != nil {
+		return fmt.Errorf("error writing span %s of trace %s: %w", span.SpanID, span.TraceID, err)
+	}
+	return nil
+}
+
+// spanTraces translates a Jaeger span into OpenTelemetry traces. If wAuth is
+// not nil, the traces are authorized for the tenant of the `tenant` metadata of
+// the request, or that of the `__tenant__` process tag of the span.
+func spanTraces(ctx context.Context, wAuth tenancy.WriteAuthorizer, span *model.Span) (pdata.Traces, error) {
+	batch := model.Batch{
+		Spans:   []*model.Span{span},
+		Process: span.Process,
+	}
+	traces := jaegertranslator.ProtoBatchToInternalTraces(batch)
+	if wAuth != nil {
+		if err := wAuth.ProcessTraces(tenancy.TenantFromMetadata(ctx), traces); err != nil {
+			return traces, fmt.Errorf("error authorizing span %s of trace %s: %w", span.SpanID, span.TraceID, err)
+		}
+	}
+	return traces, nil
+}
@@ -817,6 +817,23 @@ $$
 $$
 LANGUAGE plpgsql;
 
+-- try_drop_metric drops the metric like drop_metric, but instead of waiting for the Promscale
+-- connectors to detach it returns false right away if any of them is still attached.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.try_drop_metric(metric_name_to_be_dropped text) RETURNS BOOLEAN
+AS
+$$
+    BEGIN
+        IF (SELECT NOT pg_try_advisory_xact_lock(SCHEMA_LOCK_ID)) THEN
+            RETURN false;
+        END IF;
+        PERFORM SCHEMA_PROM.drop_metric(metric_name_to_be_dropped);
+        RETURN true;
+    END;
+$$
+LANGUAGE plpgsql;
+REVOKE ALL ON FUNCTION SCHEMA_CATALOG.try_drop_metric(text) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.try_drop_metric(text) TO prom_modifier;
+
 --Get the label_id for a key, value pair
 -- no need for a get function only as users will not be using ids directly
 CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_or_create_label_id(
@@ -1271,6 +1288,36 @@ COMMENT ON FUNCTION SCHEMA_PROM.reset_metric_chunk_interval(TEXT)
 IS 'resets the chunk interval for a specific metric to using the default';
 GRANT EXECUTE ON FUNCTION SCHEMA_PROM.reset_metric_chunk_interval(TEXT) TO prom_admin;
 
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_metric_chunk_interval(metric_name TEXT)
+RETURNS INTERVAL
+AS $func$
+DECLARE
+    metric_table_name name;
+    result INTERVAL;
+BEGIN
+    IF NOT SCHEMA_CATALOG.is_timescaledb_installed() THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT table_name
+    INTO STRICT metric_table_name
+    FROM SCHEMA_CATALOG.get_metric_table_name_if_exists('SCHEMA_DATA', metric_name);
+
+    SELECT d.interval_length * INTERVAL '1 microsecond'
+    INTO result
+    FROM _timescaledb_catalog.dimension d
+    INNER JOIN _timescaledb_catalog.hypertable h ON (h.id = d.hypertable_id)
+    WHERE h.schema_name = 'SCHEMA_DATA'
+      AND h.table_name = metric_table_name
+      AND d.column_name = 'time';
+    RETURN result;
+END
+$func$
+LANGUAGE PLPGSQL STABLE;
+COMMENT ON FUNCTION SCHEMA_CATALOG.get_metric_chunk_interval(TEXT)
+IS 'get the chunk interval of the hypertable of a specific metric';
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.get_metric_chunk_interval(TEXT) TO prom_reader;
+
 CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_metric_retention_period(schema_name TEXT, metric_name TEXT)
 RETURNS INTERVAL
 AS $$
@@ -2214,6 +2261,13 @@ BEGIN
     PERFORM set_config('application_name', format('promscale maintenance: data retention'), false);
     CALL SCHEMA_CATALOG.execute_data_retention_policy(log_verbose=>log_verbose);
 
+    IF log_verbose THEN
+        RAISE LOG 'promscale maintenance: trace retention: starting';
+    END IF;
+
+    PERFORM set_config('application_name', format('promscale maintenance: trace retention'), false);
+    CALL SCHEMA_TRACING.execute_data_retention_policy(log_verbose=>log_verbose);
+
     IF NOT SCHEMA_CATALOG.is_timescaledb_oss() AND SCHEMA_CATALOG.get_timescale_major_version() >= 2 THEN
         IF log_verbose THEN
             RAISE LOG 'promscale maintenance: compression: starting';
@@ -2221,8 +2275,22 @@ BEGIN
 
         PERFORM set_config('application_name', format('promscale maintenance: compression'), false);
         CALL SCHEMA_CATALOG.execute_compression_policy(log_verbose=>log_verbose);
+
+        IF log_verbose THEN
+            RAISE LOG 'promscale maintenance: trace compression: starting';
+        END IF;
+
+        PERFORM set_config('application_name', format('promscale maintenance: trace compression'), false);
+        CALL SCHEMA_TRACING.execute_compression_policy(log_verbose=>log_verbose);
     END IF;
 
+    IF log_verbose THEN
+        RAISE LOG 'promscale maintenance: service dependencies: starting';
+    END IF;
+
+    PERFORM set_config('application_name', format('promscale maintenance: service dependencies'), false);
+    CALL SCHEMA_TRACING.materialize_service_dependencies(log_verbose=>log_verbose);
+
     IF log_verbose THEN
         RAISE LOG 'promscale maintenance: finished in %', clock_timestamp()-startT;
     END IF;
@@ -2641,6 +2709,117 @@ SET search_path = pg_temp;
 REVOKE ALL ON FUNCTION SCHEMA_CATALOG.delete_series_from_metric(text, bigint[])FROM PUBLIC;
 GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.delete_series_from_metric(text, bigint[]) to prom_modifier;
 
+-- get_metric_chunks_in_range returns the tables holding the samples of the metric
+-- between start_time and end_time, so that the samples can be deleted from one table
+-- at a time: the chunks overlapping the time range with TimescaleDB 2, the metric
+-- table itself otherwise.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_metric_chunks_in_range(name text, start_time timestamptz, end_time timestamptz)
+RETURNS TABLE(chunk_schema name, chunk_name name)
+AS
+$$
+DECLARE
+    metric_table name;
+BEGIN
+    SELECT table_name INTO STRICT metric_table FROM SCHEMA_CATALOG.metric m WHERE m.metric_name=name AND m.is_view = false;
+    IF SCHEMA_CATALOG.is_timescaledb_installed() AND SCHEMA_CATALOG.get_timescale_major_version() >= 2 THEN
+        RETURN QUERY
+            SELECT c.chunk_schema, c.chunk_name
+            FROM timescaledb_information.chunks c
+            WHERE c.hypertable_schema = 'SCHEMA_DATA'
+              AND c.hypertable_name = metric_table
+              -- the range_ends are non-inclusive
+              AND c.range_start <= end_time
+              AND c.range_end > start_time
+            ORDER BY c.range_start;
+    ELSE
+        RETURN QUERY SELECT 'SCHEMA_DATA'::name, metric_table;
+    END IF;
+END;
+$$
+LANGUAGE PLPGSQL STABLE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION SCHEMA_CATALOG.get_metric_chunks_in_range(text, timestamptz, timestamptz) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.get_metric_chunks_in_range(text, timestamptz, timestamptz) to prom_modifier;
+
+-- delete_series_from_metric_chunk deletes the samples of the series between start_time
+-- and end_time from a single table returned by get_metric_chunks_in_range. Compressed
+-- chunks cannot be deleted from, so a compressed chunk is decompressed first and
+-- compressed again afterwards. Deleting chunk by chunk, each in its own transaction,
+-- keeps only one chunk locked and decompressed at a time. Tables that aren't chunks
+-- of the metric, e.g. ones dropped by retention in the meantime, are skipped.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.delete_series_from_metric_chunk(name text, chunk_schema name, chunk_name name, series_ids bigint[], start_time timestamptz, end_time timestamptz)
+RETURNS BIGINT
+AS
+$$
+DECLARE
+    metric_table name;
+    chunk_compressed boolean := false;
+    num_rows_deleted bigint := 0;
+BEGIN
+    SELECT table_name INTO STRICT metric_table FROM SCHEMA_CATALOG.metric m WHERE m.metric_name=name AND m.is_view = false;
+    IF SCHEMA_CATALOG.is_timescaledb_installed() AND SCHEMA_CATALOG.get_timescale_major_version() >= 2 THEN
+        SELECT c.is_compressed INTO chunk_compressed
+        FROM timescaledb_information.chunks c
+        WHERE c.hypertable_schema = 'SCHEMA_DATA'
+          AND c.hypertable_name = metric_table
+          AND c.chunk_schema = delete_series_from_metric_chunk.chunk_schema
+          AND c.chunk_name = delete_series_from_metric_chunk.chunk_name;
+        IF NOT FOUND THEN
+            RETURN 0;
+        END IF;
+    ELSIF (chunk_schema, chunk_name) IS DISTINCT FROM ('SCHEMA_DATA'::name, metric_table) THEN
+        RETURN 0;
+    END IF;
+
+    IF chunk_compressed THEN
+        PERFORM SCHEMA_TIMESCALE.decompress_chunk(format('%I.%I', chunk_schema, chunk_name)::regclass);
+    END IF;
+
+    EXECUTE FORMAT('DELETE FROM %1$I.%2$I WHERE series_id = ANY($1) AND time >= $2 AND time <= $3', chunk_schema, chunk_name)
+    USING series_ids, start_time, end_time;
+    GET DIAGNOSTICS num_rows_deleted = ROW_COUNT;
+
+    IF chunk_compressed THEN
+        PERFORM SCHEMA_TIMESCALE.compress_chunk(format('%I.%I', chunk_schema, chunk_name)::regclass, if_not_compressed => true);
+    END IF;
+    RETURN num_rows_deleted;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION SCHEMA_CATALOG.delete_series_from_metric_chunk(text, name, name, bigint[], timestamptz, timestamptz) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.delete_series_from_metric_chunk(text, name, name, bigint[], timestamptz, timestamptz) to prom_modifier;
+
+-- delete_exemplars_from_metric_in_range deletes the exemplars of the series between
+-- start_time and end_time, so that they don't outlive the deleted samples.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.delete_exemplars_from_metric_in_range(name text, series_ids bigint[], start_time timestamptz, end_time timestamptz)
+RETURNS BIGINT
+AS
+$$
+DECLARE
+    exemplar_table name;
+    num_rows_deleted bigint := 0;
+BEGIN
+    SELECT table_name INTO exemplar_table FROM SCHEMA_CATALOG.exemplar e WHERE e.metric_name=name;
+    IF NOT FOUND THEN
+        RETURN 0;
+    END IF;
+    EXECUTE FORMAT('DELETE FROM SCHEMA_DATA_EXEMPLAR.%1$I WHERE series_id = ANY($1) AND time >= $2 AND time <= $3', exemplar_table)
+    USING series_ids, start_time, end_time;
+    GET DIAGNOSTICS num_rows_deleted = ROW_COUNT;
+    RETURN num_rows_deleted;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE;
+REVOKE ALL ON FUNCTION SCHEMA_CATALOG.delete_exemplars_from_metric_in_range(text, bigint[], timestamptz, timestamptz) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.delete_exemplars_from_metric_in_range(text, bigint[], timestamptz, timestamptz) to prom_modifier;
+
 -- the following functions require timescaledb >= 2.0.0
 DO $block$
 BEGIN
@@ -79,3 +79,40 @@ END;
 $$
 LANGUAGE PLPGSQL;
 GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.insert_exemplar_row(NAME, TIMESTAMPTZ[], BIGINT[], SCHEMA_PROM.label_value_array[], DOUBLE PRECISION[]) TO prom_writer;
+
+-- returns the exemplars in [_start, _end] that have one of the label keys set to the trace ID _value,
+-- along with the labels of their series. The label values are compared as 32 lowercase hex digits,
+-- without dashes and with 64-bit trace IDs padded, which is the form _value must be given in.
+CREATE OR REPLACE FUNCTION SCHEMA_CATALOG.get_exemplars_by_label_value(_keys TEXT[], _value TEXT, _start TIMESTAMPTZ, _end TIMESTAMPTZ)
+RETURNS TABLE(metric_name TEXT, series_labels JSONB, "time" TIMESTAMPTZ, value DOUBLE PRECISION, exemplar_labels JSONB)
+AS
+$$
+DECLARE
+    _exemplar RECORD;
+BEGIN
+    FOR _exemplar IN
+        SELECT e.metric_name, e.table_name, array_agg(p.pos) AS positions
+        FROM SCHEMA_CATALOG.exemplar e
+        INNER JOIN SCHEMA_CATALOG.exemplar_label_key_position p ON (p.metric_name = e.metric_name)
+        WHERE p.key = ANY(_keys)
+        GROUP BY e.metric_name, e.table_name
+    LOOP
+        RETURN QUERY EXECUTE FORMAT(
+            'SELECT $1, SCHEMA_PROM.jsonb(s.labels), x.time, x.value,
+                coalesce((
+                    SELECT jsonb_object_agg(p.key, x.exemplar_label_values[p.pos])
+                    FROM SCHEMA_CATALOG.exemplar_label_key_position p
+                    WHERE p.metric_name = $1 AND x.exemplar_label_values[p.pos] != %L
+                ), %L::jsonb)
+             FROM SCHEMA_DATA_EXEMPLAR.%I x
+             INNER JOIN SCHEMA_DATA_SERIES.%I s ON (s.id = x.series_id)
+             WHERE x.time >= $2 AND x.time <= $3
+             AND EXISTS (SELECT 1 FROM unnest($4::INTEGER[]) pos WHERE lpad(lower(replace(x.exemplar_label_values[pos], ''-'', )), 32, 0) = lower($5))
+             ORDER BY x.series_id, x.time',
+            '', '{}', _exemplar.table_name, _exemplar.table_name
+        ) USING _exemplar.metric_name, _start, _end, _exemplar.positions, _value;
+    END LOOP;
+END;
+$$
+LANGUAGE PLPGSQL STABLE;
+GRANT EXECUTE ON FUNCTION SCHEMA_CATALOG.get_exemplars_by_label_value(TEXT[], TEXT, TIMESTAMPTZ, TIMESTAMPTZ) TO prom_reader;
@@ -217,3 +217,419 @@ $func$
 LANGUAGE SQL STABLE PARALLEL SAFE;
 GRANT EXECUTE ON FUNCTION SCHEMA_TRACING.match_greater_than_or_equal(SCHEMA_TRACING_PUBLIC.tag_map, SCHEMA_TAG.tag_op_greater_than_or_equal) TO prom_reader;
 COMMENT ON FUNCTION SCHEMA_TRACING.match_greater_than_or_equal IS $$This function supports the #>= operator.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING.service_dependency_bucket_width()
+RETURNS interval
+AS $func$
+    SELECT interval '10 minutes'
+$func$
+LANGUAGE SQL IMMUTABLE PARALLEL SAFE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING.service_dependency_bucket_width() TO prom_reader;
+COMMENT ON FUNCTION SCHEMA_TRACING.service_dependency_bucket_width IS $$The width of the buckets of the service dependency aggregate.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING.service_dependency_bucket(_time timestamptz)
+RETURNS timestamptz
+AS $func$
+    SELECT to_timestamp(
+        floor(extract(epoch from _time) / extract(epoch from SCHEMA_TRACING.service_dependency_bucket_width()))
+        * extract(epoch from SCHEMA_TRACING.service_dependency_bucket_width())
+    )
+$func$
+LANGUAGE SQL IMMUTABLE PARALLEL SAFE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING.service_dependency_bucket(timestamptz) TO prom_reader;
+COMMENT ON FUNCTION SCHEMA_TRACING.service_dependency_bucket IS $$Returns the start of the service dependency bucket containing _time.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING.span_service_dependencies(_start timestamptz, _end timestamptz)
+RETURNS TABLE (bucket timestamptz, parent_service_name_id bigint, child_service_name_id bigint, call_count bigint)
+AS $func$
+    SELECT
+        SCHEMA_TRACING.service_dependency_bucket(c.start_time),
+        po.service_name_id,
+        co.service_name_id,
+        count(*)
+    FROM SCHEMA_TRACING.span c
+    INNER JOIN SCHEMA_TRACING.span p ON (p.trace_id = c.trace_id AND p.span_id = c.parent_span_id)
+    INNER JOIN SCHEMA_TRACING.operation co ON (co.id = c.operation_id)
+    INNER JOIN SCHEMA_TRACING.operation po ON (po.id = p.operation_id)
+    WHERE c.start_time >= _start
+    AND c.start_time < _end
+    AND c.parent_span_id IS NOT NULL
+    AND po.service_name_id != co.service_name_id
+    GROUP BY 1, 2, 3
+$func$
+LANGUAGE SQL STABLE PARALLEL SAFE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING.span_service_dependencies(timestamptz, timestamptz) TO prom_reader;
+COMMENT ON FUNCTION SCHEMA_TRACING.span_service_dependencies IS
+$$Counts the calls between services by joining the spans that start in [_start, _end) to their parent spans.
+Calls within a service are not counted.$$;
+
+CREATE OR REPLACE PROCEDURE SCHEMA_TRACING.materialize_service_dependencies(log_verbose boolean = false)
+AS $proc$
+DECLARE
+    -- Spans may arrive late, so the most recent buckets are left to be aggregated at query time.
+    _lag interval = interval '10 minutes';
+    -- Materialize at most this much at a time so that a backlog is committed in steps.
+    _step interval = interval '1 hour';
+    _from timestamptz;
+    _to timestamptz;
+    _until timestamptz;
+BEGIN
+    _until := SCHEMA_TRACING.service_dependency_bucket(now() - _lag);
+    LOOP
+        -- Skip if another maintenance job is materializing.
+        SELECT materialized_until INTO _from
+        FROM SCHEMA_TRACING.service_dependency_watermark
+        FOR UPDATE SKIP LOCKED;
+        IF NOT FOUND THEN
+            RETURN;
+        END IF;
+
+        IF _from IS NULL THEN
+            SELECT SCHEMA_TRACING.service_dependency_bucket(min(start_time)) INTO _from
+            FROM SCHEMA_TRACING.span;
+            IF _from IS NULL THEN
+                RETURN;
+            END IF;
+        END IF;
+
+        EXIT WHEN _from >= _until;
+        _to := least(_from + _step, _until);
+
+        INSERT INTO SCHEMA_TRACING.service_dependency (bucket, parent_service_name_id, child_service_name_id, call_count)
+        SELECT d.bucket, d.parent_service_name_id, d.child_service_name_id, d.call_count
+        FROM SCHEMA_TRACING.span_service_dependencies(_from, _to) d
+        ON CONFLICT (bucket, parent_service_name_id, child_service_name_id)
+        DO UPDATE SET call_count = SCHEMA_TRACING.service_dependency.call_count + excluded.call_count;
+
+        UPDATE SCHEMA_TRACING.service_dependency_watermark SET materialized_until = _to;
+
+        IF log_verbose THEN
+            RAISE LOG 'promscale maintenance: service dependencies: materialized until %', _to;
+        END IF;
+        COMMIT;
+    END LOOP;
+END;
+$proc$ LANGUAGE PLPGSQL;
+GRANT EXECUTE ON PROCEDURE SCHEMA_TRACING.materialize_service_dependencies(boolean) TO prom_maintenance;
+COMMENT ON PROCEDURE SCHEMA_TRACING.materialize_service_dependencies IS
+$$Aggregates the calls between services of the spans after the watermark into the service_dependency table and advances the watermark.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING.get_service_dependencies(_start timestamptz, _end timestamptz)
+RETURNS TABLE (parent_service text, child_service text, call_count bigint)
+AS $func$
+DECLARE
+    _agg_start timestamptz;
+    _agg_end timestamptz;
+BEGIN
+    -- Whole buckets in [_start, _end) that are materialized are read from the aggregate,
+    -- the rest of the range is aggregated from the spans.
+    _agg_start := SCHEMA_TRACING.service_dependency_bucket(_start);
+    IF _agg_start < _start THEN
+        _agg_start := _agg_start + SCHEMA_TRACING.service_dependency_bucket_width();
+    END IF;
+
+    SELECT least(SCHEMA_TRACING.service_dependency_bucket(_end), w.materialized_until) INTO _agg_end
+    FROM SCHEMA_TRACING.service_dependency_watermark w;
+    IF _agg_end IS NULL OR _agg_end < _agg_start THEN
+        _agg_end := _agg_start;
+    END IF;
+
+    RETURN QUERY
+    SELECT pt.value#>>'{}', ct.value#>>'{}', sum(d.call_count)::bigint
+    FROM (
+        SELECT a.parent_service_name_id, a.child_service_name_id, a.call_count
+        FROM SCHEMA_TRACING.service_dependency a
+        WHERE a.bucket >= _agg_start AND a.bucket < _agg_end
+        UNION ALL
+        SELECT s.parent_service_name_id, s.child_service_name_id, s.call_count
+        FROM SCHEMA_TRACING.span_service_dependencies(_start, least(_agg_start, _end)) s
+        UNION ALL
+        SELECT s.parent_service_name_id, s.child_service_name_id, s.call_count
+        FROM SCHEMA_TRACING.span_service_dependencies(_agg_end, _end) s
+    ) d
+    INNER JOIN SCHEMA_TRACING.tag pt ON (pt.id = d.parent_service_name_id AND pt.key = 'service.name')
+    INNER JOIN SCHEMA_TRACING.tag ct ON (ct.id = d.child_service_name_id AND ct.key = 'service.name')
+    GROUP BY 1, 2
+    ORDER BY 1, 2;
+END;
+$func$
+LANGUAGE PLPGSQL STABLE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING.get_service_dependencies(timestamptz, timestamptz) TO prom_reader;
+COMMENT ON FUNCTION SCHEMA_TRACING.get_service_dependencies IS
+$$Returns the number of calls between services for the spans that start in [_start, _end).$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING.get_trace_chunk_retention_period()
+RETURNS interval
+AS $func$
+    -- Without a default retention period, the spans of services without an override are
+    -- kept forever, and so are the chunks.
+    SELECT CASE WHEN d.retention_period IS NOT NULL THEN
+        greatest(
+            d.retention_period,
+            (SELECT max(r.retention_period) FROM SCHEMA_TRACING.service_retention_period r)
+        )
+    END
+    FROM (SELECT SCHEMA_TRACING_PUBLIC.get_trace_retention_period() AS retention_period) d
+$func$
+LANGUAGE SQL STABLE PARALLEL SAFE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING.get_trace_chunk_retention_period() TO prom_reader;
+COMMENT ON FUNCTION SCHEMA_TRACING.get_trace_chunk_retention_period IS
+$$Returns the age after which the chunks of the tracing hypertables are dropped, the longest retention period of any service, or NULL if they are never dropped.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING.get_services_to_expire()
+RETURNS TABLE (service_name text, retention_period interval)
+AS $func$
+    SELECT s.service_name, s.retention_period
+    FROM
+    (
+        SELECT DISTINCT t.value#>>'{}' AS service_name, SCHEMA_TRACING_PUBLIC.get_service_retention_period(t.value#>>'{}') AS retention_period
+        FROM SCHEMA_TRACING.tag t
+        WHERE t.key = 'service.name'
+    ) s
+    WHERE s.retention_period IS NOT NULL
+    AND (SCHEMA_TRACING.get_trace_chunk_retention_period() IS NULL OR s.retention_period < SCHEMA_TRACING.get_trace_chunk_retention_period())
+$func$
+LANGUAGE SQL STABLE PARALLEL SAFE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING.get_services_to_expire() TO prom_reader;
+COMMENT ON FUNCTION SCHEMA_TRACING.get_services_to_expire IS
+$$Returns the services whose spans expire before the chunks that contain them are dropped, and thus have to be deleted row by row.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING.get_trace_compression_delay()
+RETURNS interval
+AS $func$
+    -- Spans cannot be deleted from compressed chunks, so a chunk is compressed only once the
+    -- spans of the services with a shorter retention period have expired. The extra hour leaves
+    -- time for the maintenance jobs to delete them.
+    SELECT greatest(interval '1 hour', max(e.retention_period) + interval '1 hour')
+    FROM SCHEMA_TRACING.get_services_to_expire() e
+$func$
+LANGUAGE SQL STABLE PARALLEL SAFE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING.get_trace_compression_delay() TO prom_reader;
+COMMENT ON FUNCTION SCHEMA_TRACING.get_trace_compression_delay IS
+$$Returns the age after which the chunks of the tracing hypertables are compressed.$$;
+
+--drop chunks from the tracing hypertables. Archived spans are not affected.
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING.drop_trace_chunk_data(_older_than timestamptz)
+RETURNS VOID
+AS $func$
+DECLARE
+    _table_name name;
+    _time_column name;
+BEGIN
+    FOR _table_name, _time_column IN
+        VALUES ('span'::name, 'start_time'::name), ('event', 'time'), ('link', 'span_start_time')
+    LOOP
+        IF SCHEMA_CATALOG.is_timescaledb_installed() THEN
+            IF SCHEMA_CATALOG.get_timescale_major_version() >= 2 THEN
+                PERFORM SCHEMA_TIMESCALE.drop_chunks(
+                    relation=>format('%I.%I', 'SCHEMA_TRACING', _table_name),
+                    older_than=>_older_than
+                );
+            ELSE
+                PERFORM SCHEMA_TIMESCALE.drop_chunks(
+                    table_name=>_table_name,
+                    schema_name=>'SCHEMA_TRACING',
+                    older_than=>_older_than,
+                    cascade_to_materializations=>FALSE
+                );
+            END IF;
+        ELSE
+            EXECUTE format($$ DELETE FROM %I.%I WHERE %I < %L $$, 'SCHEMA_TRACING', _table_name, _time_column, _older_than);
+        END IF;
+    END LOOP;
+END
+$func$
+LANGUAGE PLPGSQL VOLATILE
+--security definer to drop chunks of tables owned by the migration user
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION SCHEMA_TRACING.drop_trace_chunk_data(timestamptz) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING.drop_trace_chunk_data(timestamptz) TO prom_maintenance;
+
+--delete the spans of a service, and their events and links, that start before _older_than
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING.delete_service_trace_data(_service_name text, _older_than timestamptz)
+RETURNS VOID
+AS $func$
+DECLARE
+    _operation_ids bigint[];
+    _table_name name;
+    _time_column name;
+    _chunks regclass[];
+    _chunk regclass;
+BEGIN
+    IF SCHEMA_CATALOG.is_timescaledb_installed() AND SCHEMA_CATALOG.get_timescale_major_version() < 2 THEN
+        RAISE EXCEPTION 'deleting the spans of a service requires TimescaleDB 2.0 or later';
+    END IF;
+
+    SELECT array_agg(o.id) INTO _operation_ids
+    FROM SCHEMA_TRACING.operation o
+    INNER JOIN SCHEMA_TRACING.tag t ON (t.id = o.service_name_id AND t.key = 'service.name')
+    WHERE t.value = to_jsonb(_service_name);
+    IF _operation_ids IS NULL THEN
+        RETURN;
+    END IF;
+
+    -- Events and links are found through their spans, so they are deleted before the spans.
+    FOR _table_name, _time_column IN
+        VALUES ('event'::name, 'time'::name), ('link', 'span_start_time'), ('span', 'start_time')
+    LOOP
+        IF SCHEMA_CATALOG.is_timescaledb_installed() THEN
+            -- Rows cannot be deleted from compressed chunks. Chunks are compressed only once
+            -- the spans of the services to delete have expired, see get_trace_compression_delay.
+            SELECT array_agg(format('%I.%I', c.chunk_schema, c.chunk_name)::regclass) INTO _chunks
+            FROM timescaledb_information.chunks c
+            WHERE c.hypertable_schema = 'SCHEMA_TRACING'
+            AND c.hypertable_name = _table_name
+            AND NOT c.is_compressed
+            AND c.range_start < _older_than;
+        ELSE
+            _chunks := ARRAY[format('%I.%I', 'SCHEMA_TRACING', _table_name)::regclass];
+        END IF;
+
+        FOREACH _chunk IN ARRAY coalesce(_chunks, '{}')
+        LOOP
+            IF _table_name = 'span' THEN
+                EXECUTE format('DELETE FROM %s WHERE start_time < $1 AND operation_id = ANY($2)', _chunk)
+                USING _older_than, _operation_ids;
+            ELSE
+                EXECUTE format(
+                    'DELETE FROM %s x USING %I.span s
+                     WHERE x.%I < $1
+                     AND s.trace_id = x.trace_id
+                     AND s.span_id = x.span_id
+                     AND s.start_time < $1
+                     AND s.operation_id = ANY($2)',
+                    _chunk, 'SCHEMA_TRACING', _time_column)
+                USING _older_than, _operation_ids;
+            END IF;
+        END LOOP;
+    END LOOP;
+END
+$func$
+LANGUAGE PLPGSQL VOLATILE
+--security definer to delete from tables owned by the migration user
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION SCHEMA_TRACING.delete_service_trace_data(text, timestamptz) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING.delete_service_trace_data(text, timestamptz) TO prom_maintenance;
+
+CREATE OR REPLACE PROCEDURE SCHEMA_TRACING.execute_data_retention_policy(log_verbose boolean = false)
+AS $proc$
+DECLARE
+    _chunk_retention_period interval;
+    _service_name text;
+    _retention_period interval;
+    _startT timestamptz;
+BEGIN
+    _startT := clock_timestamp();
+    _chunk_retention_period := SCHEMA_TRACING.get_trace_chunk_retention_period();
+    IF _chunk_retention_period IS NOT NULL THEN
+        PERFORM SCHEMA_TRACING.drop_trace_chunk_data(now() - _chunk_retention_period);
+        DELETE FROM SCHEMA_TRACING.service_dependency WHERE bucket < now() - _chunk_retention_period;
+        IF log_verbose THEN
+            RAISE LOG 'promscale maintenance: trace retention: done dropping chunks in %', clock_timestamp()-_startT;
+        END IF;
+        COMMIT;
+    END IF;
+
+    FOR _service_name, _retention_period IN
+        SELECT e.service_name, e.retention_period
+        FROM SCHEMA_TRACING.get_services_to_expire() e
+    LOOP
+        IF SCHEMA_CATALOG.is_timescaledb_installed() AND SCHEMA_CATALOG.get_timescale_major_version() < 2 THEN
+            RAISE WARNING 'promscale maintenance: trace retention: the retention periods of services require TimescaleDB 2.0 or later, the spans of all services are kept for %', coalesce(_chunk_retention_period::text, 'ever');
+            RETURN;
+        END IF;
+
+        _startT := clock_timestamp();
+        PERFORM set_config('application_name', format('promscale maintenance: trace retention: service %s', _service_name), false);
+        PERFORM SCHEMA_TRACING.delete_service_trace_data(_service_name, now() - _retention_period);
+        IF log_verbose THEN
+            RAISE LOG 'promscale maintenance: trace retention: service %: done deleting spans in %', _service_name, clock_timestamp()-_startT;
+        END IF;
+        COMMIT;
+    END LOOP;
+END;
+$proc$ LANGUAGE PLPGSQL;
+GRANT EXECUTE ON PROCEDURE SCHEMA_TRACING.execute_data_retention_policy(boolean) TO prom_maintenance;
+COMMENT ON PROCEDURE SCHEMA_TRACING.execute_data_retention_policy IS
+$$Drops the chunks of the tracing hypertables older than the longest retention period and deletes the expired spans of the services with a shorter retention period.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING.compress_trace_chunk(_table_name name, _chunk_schema name, _chunk_name name)
+RETURNS VOID
+AS $func$
+DECLARE
+    _chunk_full_name text;
+BEGIN
+    SELECT format('%I.%I', c.chunk_schema, c.chunk_name)
+    INTO _chunk_full_name
+    FROM timescaledb_information.chunks c
+    WHERE c.hypertable_schema = 'SCHEMA_TRACING'
+    AND c.hypertable_name = _table_name
+    AND c.hypertable_name IN ('span', 'event', 'link')
+    AND c.chunk_schema = _chunk_schema
+    AND c.chunk_name = _chunk_name;
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+
+    PERFORM SCHEMA_TIMESCALE.compress_chunk(_chunk_full_name, if_not_compressed => true);
+END;
+$func$
+LANGUAGE PLPGSQL
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+REVOKE ALL ON FUNCTION SCHEMA_TRACING.compress_trace_chunk(name, name, name) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING.compress_trace_chunk(name, name, name) TO prom_maintenance;
+
+--only for timescaledb 2.0 in 1.x we use compression policies
+CREATE OR REPLACE PROCEDURE SCHEMA_TRACING.execute_compression_policy(log_verbose boolean = false)
+AS $proc$
+DECLARE
+    _compress_before timestamptz;
+    _table_name name;
+    _chunk_schema name;
+    _chunk_name name;
+    _chunk_num int;
+    _startT timestamptz;
+BEGIN
+    -- Distributed tracing hypertables are not compressed by the maintenance jobs.
+    IF NOT SCHEMA_TRACING_PUBLIC.get_trace_compression_setting() OR SCHEMA_CATALOG.is_multinode() THEN
+        RETURN;
+    END IF;
+
+    _compress_before := now() - SCHEMA_TRACING.get_trace_compression_delay();
+    FOREACH _table_name IN ARRAY ARRAY['span', 'event', 'link']::name[]
+    LOOP
+        _startT := clock_timestamp();
+        PERFORM set_config('application_name', format('promscale maintenance: trace compression: %s', _table_name), false);
+        FOR _chunk_schema, _chunk_name, _chunk_num IN
+            SELECT
+                c.chunk_schema,
+                c.chunk_name,
+                row_number() OVER (ORDER BY c.range_end DESC)
+            FROM timescaledb_information.chunks c
+            WHERE c.hypertable_schema = 'SCHEMA_TRACING'
+            AND c.hypertable_name = _table_name
+            AND NOT c.is_compressed
+            AND c.range_end <= _compress_before
+            ORDER BY c.range_end ASC
+        LOOP
+            CONTINUE WHEN _chunk_num <= 1;
+            PERFORM SCHEMA_TRACING.compress_trace_chunk(_table_name, _chunk_schema, _chunk_name);
+            COMMIT;
+        END LOOP;
+        IF log_verbose THEN
+            RAISE LOG 'promscale maintenance: trace compression: %: finished in %', _table_name, clock_timestamp()-_startT;
+        END IF;
+    END LOOP;
+END;
+$proc$ LANGUAGE PLPGSQL;
+GRANT EXECUTE ON PROCEDURE SCHEMA_TRACING.execute_compression_policy(boolean) TO prom_maintenance;
+COMMENT ON PROCEDURE SCHEMA_TRACING.execute_compression_policy IS
+$$Compresses the chunks of the tracing hypertables according to the trace compression setting.$$;
@@ -604,3 +604,100 @@ LANGUAGE SQL STABLE PARALLEL SAFE STRICT;
 GRANT EXECUTE ON FUNCTION SCHEMA_TRACING_PUBLIC.get_tag_map(jsonb) TO prom_reader;
 
 
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING_PUBLIC.get_trace_retention_period()
+RETURNS interval
+AS $func$
+    SELECT value::interval FROM SCHEMA_CATALOG.default WHERE key = 'trace_retention_period'
+$func$
+LANGUAGE SQL STABLE PARALLEL SAFE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING_PUBLIC.get_trace_retention_period() TO prom_reader;
+COMMENT ON FUNCTION SCHEMA_TRACING_PUBLIC.get_trace_retention_period IS
+$$Returns the retention period of the spans of services without an explicit override, or NULL if they are kept forever.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING_PUBLIC.set_trace_retention_period(_trace_retention_period interval)
+RETURNS boolean
+AS $func$
+BEGIN
+    IF _trace_retention_period IS NULL OR _trace_retention_period <= interval '0' THEN
+        RAISE EXCEPTION 'invalid trace retention period %', _trace_retention_period
+        USING HINT = 'The retention period must be positive.';
+    END IF;
+    INSERT INTO SCHEMA_CATALOG.default(key, value) VALUES ('trace_retention_period', _trace_retention_period::text)
+    ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value;
+    RETURN true;
+END;
+$func$
+LANGUAGE PLPGSQL VOLATILE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING_PUBLIC.set_trace_retention_period(interval) TO prom_admin;
+COMMENT ON FUNCTION SCHEMA_TRACING_PUBLIC.set_trace_retention_period IS
+$$Sets the retention period of the spans of services (existing and new) without an explicit override.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING_PUBLIC.get_service_retention_period(_service_name text)
+RETURNS interval
+AS $func$
+    SELECT coalesce(
+        (SELECT r.retention_period FROM SCHEMA_TRACING.service_retention_period r WHERE r.service_name = _service_name),
+        SCHEMA_TRACING_PUBLIC.get_trace_retention_period()
+    )
+$func$
+LANGUAGE SQL STABLE PARALLEL SAFE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING_PUBLIC.get_service_retention_period(text) TO prom_reader;
+COMMENT ON FUNCTION SCHEMA_TRACING_PUBLIC.get_service_retention_period IS
+$$Returns the retention period of the spans of a service, or NULL if they are kept forever.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING_PUBLIC.set_service_retention_period(_service_name text, _retention_period interval)
+RETURNS boolean
+AS $func$
+BEGIN
+    IF _retention_period IS NULL OR _retention_period <= interval '0' THEN
+        RAISE EXCEPTION 'invalid retention period % for service %', _retention_period, _service_name
+        USING HINT = 'The retention period must be positive.';
+    END IF;
+    INSERT INTO SCHEMA_TRACING.service_retention_period(service_name, retention_period) VALUES (_service_name, _retention_period)
+    ON CONFLICT (service_name) DO UPDATE SET retention_period = EXCLUDED.retention_period;
+    RETURN true;
+END;
+$func$
+LANGUAGE PLPGSQL VOLATILE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING_PUBLIC.set_service_retention_period(text, interval) TO prom_admin;
+COMMENT ON FUNCTION SCHEMA_TRACING_PUBLIC.set_service_retention_period IS
+$$Sets the retention period of the spans of a service (this overrides the default).$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING_PUBLIC.reset_service_retention_period(_service_name text)
+RETURNS boolean
+AS $func$
+    DELETE FROM SCHEMA_TRACING.service_retention_period WHERE service_name = _service_name;
+    SELECT true;
+$func$
+LANGUAGE SQL VOLATILE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING_PUBLIC.reset_service_retention_period(text) TO prom_admin;
+COMMENT ON FUNCTION SCHEMA_TRACING_PUBLIC.reset_service_retention_period IS
+$$Resets the retention period of the spans of a service to the default.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING_PUBLIC.get_trace_compression_setting()
+RETURNS boolean
+AS $func$
+    SELECT value::boolean FROM SCHEMA_CATALOG.default WHERE key = 'trace_compression'
+$func$
+LANGUAGE SQL STABLE PARALLEL SAFE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING_PUBLIC.get_trace_compression_setting() TO prom_reader;
+COMMENT ON FUNCTION SCHEMA_TRACING_PUBLIC.get_trace_compression_setting IS
+$$Returns whether the maintenance jobs compress the tracing hypertables.$$;
+
+CREATE OR REPLACE FUNCTION SCHEMA_TRACING_PUBLIC.set_trace_compression_setting(_compression_setting boolean)
+RETURNS boolean
+AS $func$
+BEGIN
+    IF _compression_setting AND NOT exists(SELECT 1 FROM pg_proc WHERE proname = 'compress_chunk') THEN
+        RAISE EXCEPTION 'Cannot enable trace compression, feature not found';
+    END IF;
+    INSERT INTO SCHEMA_CATALOG.default(key, value) VALUES ('trace_compression', _compression_setting::text)
+    ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value;
+    RETURN true;
+END;
+$func$
+LANGUAGE PLPGSQL VOLATILE;
+GRANT EXECUTE ON FUNCTION SCHEMA_TRACING_PUBLIC.set_trace_compression_setting(boolean) TO prom_admin;
+COMMENT ON FUNCTION SCHEMA_TRACING_PUBLIC.set_trace_compression_setting IS
+$$Sets whether the maintenance jobs compress the tracing hypertables. Chunks that are already compressed stay compressed.$$;
@@ -0,0 +1,25 @@
+/*
+    Incrementally maintained aggregate of the calls between services, used for
+    the service dependency graph. Each row counts the spans of the child service
+    whose parent span belongs to the parent service, bucketed by the start time
+    of the child span. The aggregate is materialized by the maintenance jobs up
+    to the watermark; spans after the watermark are aggregated at query time.
+*/
+CREATE TABLE IF NOT EXISTS SCHEMA_TRACING.service_dependency
+(
+    bucket timestamptz NOT NULL,
+    parent_service_name_id bigint NOT NULL, -- references id column of tag table for the service.name tag value
+    child_service_name_id bigint NOT NULL, -- references id column of tag table for the service.name tag value
+    call_count bigint NOT NULL,
+    PRIMARY KEY (bucket, parent_service_name_id, child_service_name_id)
+);
+GRANT SELECT ON TABLE SCHEMA_TRACING.service_dependency TO prom_reader;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE SCHEMA_TRACING.service_dependency TO prom_maintenance;
+
+CREATE TABLE IF NOT EXISTS SCHEMA_TRACING.service_dependency_watermark
+(
+    materialized_until timestamptz NULL
+);
+INSERT INTO SCHEMA_TRACING.service_dependency_watermark (materialized_until) VALUES (NULL);
+GRANT SELECT ON TABLE SCHEMA_TRACING.service_dependency_watermark TO prom_reader;
+GRANT SELECT, UPDATE ON TABLE SCHEMA_TRACING.service_dependency_watermark TO prom_maintenance;
@@ -0,0 +1,16 @@
+/*
+    Spans archived through the Jaeger archive storage, e.g. traces pinned for a
+    postmortem. The spans are stored in the Jaeger protobuf format in a regular
+    table, so they are not affected by the retention of the span hypertable.
+*/
+CREATE TABLE IF NOT EXISTS SCHEMA_TRACING.archived_span
+(
+    trace_id SCHEMA_TRACING_PUBLIC.trace_id NOT NULL,
+    span_id bigint NOT NULL CHECK (span_id != 0),
+    start_time timestamptz NOT NULL,
+    archived_at timestamptz NOT NULL DEFAULT now(),
+    span bytea NOT NULL,
+    PRIMARY KEY (trace_id, span_id)
+);
+GRANT SELECT ON TABLE SCHEMA_TRACING.archived_span TO prom_reader;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE SCHEMA_TRACING.archived_span TO prom_writer;
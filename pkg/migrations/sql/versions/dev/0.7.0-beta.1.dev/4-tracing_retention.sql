@@ -0,0 +1,16 @@
+INSERT INTO SCHEMA_CATALOG.default(key,value) VALUES
+('trace_retention_period', (30 * INTERVAL '1 day')::text),
+('trace_compression', (exists(select * from pg_proc where proname = 'compress_chunk')::text))
+ON CONFLICT (key) DO NOTHING;
+
+/*
+    Per-service overrides of the trace retention period. The spans of a service
+    without an override are kept for the default trace_retention_period.
+*/
+CREATE TABLE IF NOT EXISTS SCHEMA_TRACING.service_retention_period
+(
+    service_name text NOT NULL PRIMARY KEY,
+    retention_period interval NOT NULL CHECK (retention_period > interval '0')
+);
+GRANT SELECT ON TABLE SCHEMA_TRACING.service_retention_period TO prom_reader;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE SCHEMA_TRACING.service_retention_period TO prom_admin;
@@ -0,0 +1,3 @@
+-- The tenant of archived spans, to restrict reading them to the tenants allowed
+-- to read traces. Spans archived before have no tenant.
+ALTER TABLE SCHEMA_TRACING.archived_span ADD COLUMN IF NOT EXISTS tenant text;
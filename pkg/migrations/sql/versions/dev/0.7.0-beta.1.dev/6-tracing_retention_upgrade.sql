@@ -0,0 +1,5 @@
+-- Databases upgraded from an earlier version keep their spans forever, as they did
+-- before trace retention existed, until a trace retention period is set. Only new
+-- installations default to a trace retention period of 30 days.
+DELETE FROM SCHEMA_CATALOG.default
+WHERE key = 'trace_retention_period' AND value::interval = 30 * INTERVAL '1 day';
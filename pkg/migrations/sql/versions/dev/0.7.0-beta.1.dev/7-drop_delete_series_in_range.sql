@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS SCHEMA_CATALOG.delete_series_from_metric_in_range(TEXT, BIGINT[], TIMESTAMPTZ, TIMESTAMPTZ);
@@ -16,6 +16,7 @@ import (
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
 	"github.com/timescale/promscale/pkg/pgmodel/health"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace"
 	"github.com/timescale/promscale/pkg/pgmodel/lreader"
 	"github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/pgxconn"
@@ -137,6 +138,27 @@ func NewClientWithPool(cfg *Config, numCopiers int, connPool *pgxpool.Pool, mt t
 	c := ingestor.Cfg{
 		NumCopiers:             numCopiers,
 		IgnoreCompressedChunks: cfg.IgnoreCompressedChunks,
+		SpanMetrics:            cfg.SpanMetrics,
+		SpanMetricsInterval:    cfg.SpanMetricsInterval,
+		SpanMetricsInstance:    cfg.SpanMetricsInstance,
+		SpanMetricsMaxSeries:   cfg.SpanMetricsMaxSeries,
+		TraceSampling: trace.SamplingConfig{
+			Rate:             cfg.TraceSamplingRate,
+			ServiceRates:     cfg.ServiceSamplingRates,
+			KeepErrors:       cfg.TailSamplingErrors,
+			LatencyThreshold: cfg.TailSamplingLatency,
+			KeepAttributes:   cfg.TailSamplingAttributes,
+			DecisionWait:     cfg.TailSamplingWait,
+			MaxTraces:        cfg.TailSamplingMaxTraces,
+		},
+		AttributeRules:   cfg.AttributeRules,
+		AttributeHashKey: []byte(cfg.AttributeHashKey),
+		TraceDispatcher: trace.DispatcherConfig{
+			NumWriters:   cfg.TraceBatchWriters,
+			MaxBatchSize: cfg.TraceMaxBatchSize,
+			BatchTimeout: cfg.TraceBatchTimeout,
+			AsyncAcks:    cfg.TraceAsyncAcks,
+		},
 	}
 
 	var (
@@ -145,6 +167,7 @@ func NewClientWithPool(cfg *Config, numCopiers int, connPool *pgxpool.Pool, mt t
 	)
 	if !readOnly {
 		var err error
+		c.TraceCaches = trace.NewCaches(cfg.CacheConfig)
 		dbIngestor, err = ingestor.NewPgxIngestor(dbConn, metricsCache, seriesCache, exemplarKeyPosCache, &c)
 		if err != nil {
 			log.Error("msg", "err starting the ingestor", "err", err)
@@ -152,9 +175,8 @@ func NewClientWithPool(cfg *Config, numCopiers int, connPool *pgxpool.Pool, mt t
 		}
 	}
 
-	labelsReader := lreader.NewLabelsReader(dbConn, labelsCache)
-
 	dbQuerierConn := pgxconn.NewQueryLoggingPgxConn(connPool)
+	labelsReader := lreader.NewLabelsReader(dbConn, labelsCache, querier.NewLabelsQuerier(dbQuerierConn, metricsCache, mt.ReadAuthorizer()))
 	dbQuerier := querier.NewQuerier(dbQuerierConn, metricsCache, labelsReader, exemplarKeyPosCache, mt.ReadAuthorizer())
 	queryable := query.NewQueryable(dbQuerier, labelsReader)
 
@@ -228,6 +250,11 @@ func (c *Client) Read(req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
 	return &resp, nil
 }
 
+// ReadSeriesSet returns the series matching a single remote-read query.
+func (c *Client) ReadSeriesSet(q *prompb.Query) (querier.SeriesSet, error) {
+	return c.querier.QuerySeriesSet(q)
+}
+
 func (c *Client) NumCachedMetricNames() int {
 	return c.metricCache.Len()
 }
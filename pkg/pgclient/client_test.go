@@ -40,10 +40,18 @@ func (q *mockQuerier) Query(*prompb.Query) ([]*prompb.TimeSeries, error) {
 	return q.tts, q.err
 }
 
+func (q *mockQuerier) QuerySeriesSet(*prompb.Query) (querier.SeriesSet, error) {
+	return nil, q.err
+}
+
 func (q *mockQuerier) ExemplarsQuerier(_ context.Context) querier.ExemplarQuerier {
 	return nil
 }
 
+func (q *mockQuerier) LabelsQuerier() querier.LabelsQuerier {
+	return nil
+}
+
 func (q *mockQuerier) LabelNames() ([]string, error) {
 	return q.labelNames, q.labelNamesErr
 }
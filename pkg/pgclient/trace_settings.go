@@ -0,0 +1,186 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+)
+
+const (
+	setTraceRetentionPeriodSQL   = "SELECT " + schema.TracePublic + ".set_trace_retention_period($1)"
+	setServiceRetentionPeriodSQL = "SELECT " + schema.TracePublic + ".set_service_retention_period($1, $2)"
+	setTraceCompressionSQL       = "SELECT " + schema.TracePublic + ".set_trace_compression_setting($1)"
+)
+
+// ServiceRetentionPeriods is a CLI flag type for the trace retention periods
+// of services, in the form `service=duration,service=duration`.
+type ServiceRetentionPeriods map[string]time.Duration
+
+// Set implements the flag interface to set value from the CLI
+func (s *ServiceRetentionPeriods) Set(val string) error {
+	periods := make(ServiceRetentionPeriods)
+	err := parsePairs(val, "service retention period", strings.LastIndex, func(service, value string) error {
+		period, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		if period <= 0 {
+			return fmt.Errorf("duration must be positive")
+		}
+		periods[service] = period
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*s = periods
+	return nil
+}
+
+func (s *ServiceRetentionPeriods) String() string {
+	if s == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*s))
+	for service, period := range *s {
+		pairs = append(pairs, service+"="+period.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ServiceSamplingRates is a CLI flag type for the head sampling rates of
+// services, in the form `service=rate,service=rate`.
+type ServiceSamplingRates map[string]float64
+
+// Set implements the flag interface to set value from the CLI
+func (s *ServiceSamplingRates) Set(val string) error {
+	rates := make(ServiceSamplingRates)
+	err := parsePairs(val, "service sampling rate", strings.LastIndex, func(service, value string) error {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("rate must be between 0 and 1")
+		}
+		rates[service] = rate
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*s = rates
+	return nil
+}
+
+func (s *ServiceSamplingRates) String() string {
+	if s == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*s))
+	for service, rate := range *s {
+		pairs = append(pairs, service+"="+strconv.FormatFloat(rate, 'f', -1, 64))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// SamplingAttributes is a CLI flag type for the attributes that keep a trace
+// by tail sampling, in the form `key=value,key=value`.
+type SamplingAttributes map[string]string
+
+// Set implements the flag interface to set value from the CLI
+func (s *SamplingAttributes) Set(val string) error {
+	attrs := make(SamplingAttributes)
+	err := parsePairs(val, "sampling attribute", strings.Index, func(key, value string) error {
+		attrs[key] = value
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*s = attrs
+	return nil
+}
+
+func (s *SamplingAttributes) String() string {
+	if s == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*s))
+	for key, value := range *s {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// parsePairs calls fn with the key and value of each pair of a comma
+// separated list of `key=value` pairs. index finds the `=` that separates
+// the key from the value: strings.LastIndex for keys that may contain `=`,
+// such as service names, and strings.Index for values that may.
+func parsePairs(val, what string, index func(s, substr string) int, fn func(key, value string) error) error {
+	for _, pair := range strings.Split(val, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := index(pair, "=")
+		if i <= 0 {
+			return fmt.Errorf("invalid %s %q: expected key=value", what, pair)
+		}
+		if err := fn(pair[:i], pair[i+1:]); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", what, pair, err)
+		}
+	}
+	return nil
+}
+
+// ApplyTraceSettings stores the trace retention and compression settings that
+// were set through flags in the database, where the maintenance jobs apply them.
+func ApplyTraceSettings(conn *pgx.Conn, cfg *Config) error {
+	ctx := context.Background()
+	if cfg.TraceRetentionPeriod > 0 {
+		if _, err := conn.Exec(ctx, setTraceRetentionPeriodSQL, cfg.TraceRetentionPeriod); err != nil {
+			return fmt.Errorf("setting trace retention period: %w", err)
+		}
+		log.Info("msg", "Trace retention period set", "retention-period", cfg.TraceRetentionPeriod)
+	}
+
+	services := make([]string, 0, len(cfg.ServiceRetentionPeriods))
+	for service := range cfg.ServiceRetentionPeriods {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		period := cfg.ServiceRetentionPeriods[service]
+		if _, err := conn.Exec(ctx, setServiceRetentionPeriodSQL, service, period); err != nil {
+			return fmt.Errorf("setting trace retention period of service %s: %w", service, err)
+		}
+		log.Info("msg", "Trace retention period of service set", "service", service, "retention-period", period)
+	}
+
+	if cfg.TraceCompression != "" {
+		compression, err := strconv.ParseBool(cfg.TraceCompression)
+		if err != nil {
+			return fmt.Errorf("parsing trace compression setting: %w", err)
+		}
+		if _, err := conn.Exec(ctx, setTraceCompressionSQL, compression); err != nil {
+			return fmt.Errorf("setting trace compression: %w", err)
+		}
+		log.Info("msg", "Trace compression set", "compression", compression)
+	}
+	return nil
+}
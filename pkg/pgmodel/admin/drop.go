@@ -0,0 +1,115 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+)
+
+// ErrConnectorsAttached is returned if a metric cannot be dropped because Promscale
+// connectors are still attached to the database. Every running connector holds the
+// schema version lease, so metrics can't be dropped from within a connector.
+var ErrConnectorsAttached = fmt.Errorf("metrics can only be dropped when no Promscale connectors are attached to the database. " +
+	"Please shutdown the Promscale connectors")
+
+const (
+	queryMetrics = `SELECT m.id, m.metric_name
+	FROM ` + schema.Catalog + `.metric m
+	WHERE m.table_schema = '` + schema.Data + `' AND NOT m.is_view
+	ORDER BY m.metric_name`
+	queryMetricSeriesCount = "SELECT count(*) FROM " + schema.Catalog + ".series WHERE metric_id = $1"
+	queryTryDropMetric     = "SELECT " + schema.Catalog + ".try_drop_metric($1)"
+)
+
+// DroppedMetric is a metric that is, or would be in a dry run, dropped.
+type DroppedMetric struct {
+	Metric  string `json:"metric"`
+	Series  int64  `json:"series"`
+	Dropped bool   `json:"dropped"`
+}
+
+// MetricDropper drops whole metrics.
+type MetricDropper interface {
+	MatchMetrics(matcher *regexp.Regexp) ([]DroppedMetric, error)
+	DropMetric(metric string) error
+}
+
+// CompileMetricMatcher compiles a fully anchored regex for selecting metrics by name.
+func CompileMetricMatcher(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// MatchMetrics returns the metrics whose name matches the regex along with their
+// number of series, sorted by metric name.
+func (a *PgAdmin) MatchMetrics(matcher *regexp.Regexp) ([]DroppedMetric, error) {
+	rows, err := a.Conn.Query(context.Background(), queryMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("get metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		ids     []int64
+		metrics = make([]DroppedMetric, 0)
+	)
+	for rows.Next() {
+		var (
+			id   int64
+			name string
+		)
+		if err = rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("get metrics: %w", err)
+		}
+		if !matcher.MatchString(name) {
+			continue
+		}
+		ids = append(ids, id)
+		metrics = append(metrics, DroppedMetric{Metric: name})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("get metrics: %w", err)
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		if err = a.Conn.QueryRow(context.Background(), queryMetricSeriesCount, id).Scan(&metrics[i].Series); err != nil {
+			return nil, fmt.Errorf("count series of metric_name=%s: %w", metrics[i].Metric, err)
+		}
+	}
+	return metrics, nil
+}
+
+// DropMetric drops the metric with all its data and series. It returns
+// ErrConnectorsAttached instead of waiting if Promscale connectors are attached
+// to the database, as they cache the IDs of the metric and its series. Connectors
+// running without the schema version lease aren't detected, and their caches are
+// stale after the drop until they are restarted.
+func (a *PgAdmin) DropMetric(metric string) error {
+	var dropped bool
+	if err := a.Conn.QueryRow(context.Background(), queryTryDropMetric, metric).Scan(&dropped); err != nil {
+		return fmt.Errorf("drop metric_name=%s: %w", metric, err)
+	}
+	if !dropped {
+		return ErrConnectorsAttached
+	}
+	return nil
+}
+
+// DropMetrics drops the metrics one after the other and marks each dropped one
+// as such. It stops at the first failure, as the remaining metrics would most
+// likely fail alike, and returns its error.
+func DropMetrics(dropper MetricDropper, metrics []DroppedMetric) error {
+	for i := range metrics {
+		if err := dropper.DropMetric(metrics[i].Metric); err != nil {
+			return err
+		}
+		metrics[i].Dropped = true
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+// ErrMetricNotFound is returned if the settings of a metric that does not exist are requested.
+var ErrMetricNotFound = fmt.Errorf("metric not found")
+
+const (
+	queryMetricSettings = `SELECT
+		m.metric_name,
+		` + schema.Catalog + `.get_metric_retention_period(m.metric_name),
+		m.retention_period IS NULL,
+		` + schema.Catalog + `.get_metric_chunk_interval(m.metric_name),
+		m.default_chunk_interval,
+		` + schema.Catalog + `.get_metric_compression_setting(m.metric_name),
+		m.default_compression
+	FROM ` + schema.Catalog + `.metric m
+	WHERE m.table_schema = '` + schema.Data + `' AND NOT m.is_view AND ($1::TEXT IS NULL OR m.metric_name = $1)
+	ORDER BY m.metric_name`
+
+	querySetRetentionPeriod   = "SELECT " + schema.Prom + ".set_metric_retention_period($1, $2)"
+	queryResetRetentionPeriod = "SELECT " + schema.Prom + ".reset_metric_retention_period($1)"
+	querySetChunkInterval     = "SELECT " + schema.Prom + ".set_metric_chunk_interval($1, $2)"
+	queryResetChunkInterval   = "SELECT " + schema.Prom + ".reset_metric_chunk_interval($1)"
+	querySetCompression       = "SELECT " + schema.Prom + ".set_metric_compression_setting($1, $2)"
+	queryResetCompression     = "SELECT " + schema.Prom + ".reset_metric_compression_setting($1)"
+)
+
+// MetricSettings are the effective storage settings of a metric. The *IsDefault
+// fields tell if the setting follows the default or is overridden for the metric.
+type MetricSettings struct {
+	Metric                   string          `json:"metric"`
+	RetentionPeriod          model.Duration  `json:"retention_period"`
+	RetentionPeriodIsDefault bool            `json:"retention_period_is_default"`
+	ChunkInterval            *model.Duration `json:"chunk_interval"`
+	ChunkIntervalIsDefault   bool            `json:"chunk_interval_is_default"`
+	Compression              bool            `json:"compression"`
+	CompressionIsDefault     bool            `json:"compression_is_default"`
+}
+
+// PgAdmin manages the storage settings of metrics.
+type PgAdmin struct {
+	Conn pgxconn.PgxConn
+}
+
+// MetricSettings returns the effective storage settings of the given metric.
+func (a *PgAdmin) MetricSettings(metric string) (MetricSettings, error) {
+	settings, err := a.metricSettings(&metric)
+	if err != nil {
+		return MetricSettings{}, err
+	}
+	if len(settings) == 0 {
+		return MetricSettings{}, ErrMetricNotFound
+	}
+	return settings[0], nil
+}
+
+// AllMetricSettings returns the effective storage settings of all metrics, sorted by metric name.
+func (a *PgAdmin) AllMetricSettings() ([]MetricSettings, error) {
+	return a.metricSettings(nil)
+}
+
+func (a *PgAdmin) metricSettings(metric *string) ([]MetricSettings, error) {
+	rows, err := a.Conn.Query(context.Background(), queryMetricSettings, metric)
+	if err != nil {
+		return nil, fmt.Errorf("get metric settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := make([]MetricSettings, 0)
+	for rows.Next() {
+		var (
+			s               MetricSettings
+			retentionPeriod time.Duration
+			chunkInterval   *time.Duration
+		)
+		if err = rows.Scan(
+			&s.Metric,
+			&retentionPeriod,
+			&s.RetentionPeriodIsDefault,
+			&chunkInterval,
+			&s.ChunkIntervalIsDefault,
+			&s.Compression,
+			&s.CompressionIsDefault,
+		); err != nil {
+			return nil, fmt.Errorf("get metric settings: %w", err)
+		}
+		s.RetentionPeriod = model.Duration(retentionPeriod)
+		if chunkInterval != nil {
+			d := model.Duration(*chunkInterval)
+			s.ChunkInterval = &d
+		}
+		settings = append(settings, s)
+	}
+	return settings, rows.Err()
+}
+
+// SetRetentionPeriod overrides the retention period of the metric.
+func (a *PgAdmin) SetRetentionPeriod(metric string, retentionPeriod time.Duration) error {
+	return a.exec("set retention period", querySetRetentionPeriod, metric, retentionPeriod)
+}
+
+// ResetRetentionPeriod resets the retention period of the metric to the default.
+func (a *PgAdmin) ResetRetentionPeriod(metric string) error {
+	return a.exec("reset retention period", queryResetRetentionPeriod, metric)
+}
+
+// SetChunkInterval overrides the chunk interval of the metric.
+func (a *PgAdmin) SetChunkInterval(metric string, chunkInterval time.Duration) error {
+	return a.exec("set chunk interval", querySetChunkInterval, metric, chunkInterval)
+}
+
+// ResetChunkInterval resets the chunk interval of the metric to the default.
+func (a *PgAdmin) ResetChunkInterval(metric string) error {
+	return a.exec("reset chunk interval", queryResetChunkInterval, metric)
+}
+
+// SetCompression overrides the compression setting of the metric.
+func (a *PgAdmin) SetCompression(metric string, compression bool) error {
+	return a.exec("set compression", querySetCompression, metric, compression)
+}
+
+// ResetCompression resets the compression setting of the metric to the default.
+func (a *PgAdmin) ResetCompression(metric string) error {
+	return a.exec("reset compression", queryResetCompression, metric)
+}
+
+func (a *PgAdmin) exec(operation, query string, args ...interface{}) error {
+	var ok bool
+	if err := a.Conn.QueryRow(context.Background(), query, args...).Scan(&ok); err != nil {
+		return fmt.Errorf("%s: %w", operation, err)
+	}
+	return nil
+}
@@ -30,6 +30,11 @@ type Config struct {
 	MetricsCacheSize        uint64
 	LabelsCacheSize         uint64
 	ExemplarKeyPosCacheSize uint64
+
+	TraceTagCacheSize       uint64
+	TraceOperationCacheSize uint64
+	TraceInstLibCacheSize   uint64
+	TraceSchemaURLCacheSize uint64
 }
 
 var DefaultConfig = Config{
@@ -39,8 +44,20 @@ var DefaultConfig = Config{
 	MetricsCacheSize:        DefaultMetricCacheSize,
 	LabelsCacheSize:         1000,
 	ExemplarKeyPosCacheSize: DefaultExemplarKeyPosCacheSize,
+
+	TraceTagCacheSize:       DefaultTraceTagCacheSize,
+	TraceOperationCacheSize: DefaultTraceOperationCacheSize,
+	TraceInstLibCacheSize:   DefaultTraceInstLibCacheSize,
+	TraceSchemaURLCacheSize: DefaultTraceSchemaURLCacheSize,
 }
 
+const (
+	DefaultTraceTagCacheSize       = 100000
+	DefaultTraceOperationCacheSize = 10000
+	DefaultTraceInstLibCacheSize   = 1000
+	DefaultTraceSchemaURLCacheSize = 1000
+)
+
 func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 	/* set defaults */
 	cfg.seriesCacheMemoryMaxFlag.SetPercent(50)
@@ -52,6 +69,10 @@ func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 		"It has one-to-one mapping with number of metrics that have exemplar, as key positions are saved per metric basis.")
 	fs.Var(&cfg.seriesCacheMemoryMaxFlag, "series-cache-max-bytes", "Initial number of elements in the series cache. "+
 		"Specified in bytes or as a percentage of the memory-target (e.g. 50%).")
+	fs.Uint64Var(&cfg.TraceTagCacheSize, "tracing-tag-cache-size", DefaultTraceTagCacheSize, "Maximum number of span, resource, event and link attribute IDs to cache.")
+	fs.Uint64Var(&cfg.TraceOperationCacheSize, "tracing-operation-cache-size", DefaultTraceOperationCacheSize, "Maximum number of operation IDs to cache.")
+	fs.Uint64Var(&cfg.TraceInstLibCacheSize, "tracing-instrumentation-lib-cache-size", DefaultTraceInstLibCacheSize, "Maximum number of instrumentation library IDs to cache.")
+	fs.Uint64Var(&cfg.TraceSchemaURLCacheSize, "tracing-schema-url-cache-size", DefaultTraceSchemaURLCacheSize, "Maximum number of schema URL IDs to cache.")
 	return cfg
 }
 
@@ -13,7 +13,6 @@ var (
 	ErrInvalidRowData              = fmt.Errorf("invalid row data, length of arrays does not match")
 	ErrExtUnavailable              = fmt.Errorf("the extension is not available")
 	ErrMissingTableName            = fmt.Errorf("missing metric table name")
-	ErrTimeBasedDeletion           = fmt.Errorf("time based series deletion is unsupported")
 	ErrInvalidSemverFormat         = fmt.Errorf("app version is not semver format, aborting migration")
 	ErrQueryMismatchTimestampValue = fmt.Errorf("query returned a mismatch in timestamps and values")
 
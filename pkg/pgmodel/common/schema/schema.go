@@ -14,7 +14,8 @@ const (
 	Catalog   = "_prom_catalog"
 	Timescale = "public"
 
-	LockID = 0x4D829C732AAFCEDE // Chosen randomly.
+	LockID      = 0x4D829C732AAFCEDE // Chosen randomly.
+	RulesLockID = 0x2F6B3A91C05E7D14 // Chosen randomly.
 
 	SeriesView = "prom_series"
 	MetricView = "prom_metric"
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgtype"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
@@ -16,43 +17,113 @@ import (
 	"github.com/timescale/promscale/pkg/pgxconn"
 )
 
-const queryDeleteSeries = "SELECT _prom_catalog.delete_series_from_metric($1, $2)"
+const (
+	queryDeleteSeries           = "SELECT _prom_catalog.delete_series_from_metric($1, $2)"
+	queryMetricChunksInRange    = "SELECT chunk_schema, chunk_name FROM _prom_catalog.get_metric_chunks_in_range($1, $2, $3)"
+	queryDeleteSeriesFromChunk  = "SELECT _prom_catalog.delete_series_from_metric_chunk($1, $2, $3, $4, $5, $6)"
+	queryDeleteExemplarsInRange = "SELECT _prom_catalog.delete_exemplars_from_metric_in_range($1, $2, $3, $4)"
+)
 
 // PgDelete deletes the series based on matchers.
 type PgDelete struct {
 	Conn pgxconn.PgxConn
 }
 
-// DeleteSeries deletes the series that matches the provided label_matchers.
-func (pgDel *PgDelete) DeleteSeries(matchers []*labels.Matcher, _, _ time.Time) ([]string, []model.SeriesID, int, error) {
+// DeleteSeries deletes the samples of the series that match the provided label_matchers
+// between start and end (both inclusive). If the time range is unbounded, i.e. start is
+// model.MinTime and end is model.MaxTime, the series are deleted entirely. It returns the
+// touched metrics, the matched series IDs and the number of rows deleted per metric.
+func (pgDel *PgDelete) DeleteSeries(matchers []*labels.Matcher, start, end time.Time) ([]string, []model.SeriesID, map[string]int, error) {
 	var (
 		deletedSeriesIDs []model.SeriesID
-		totalRowsDeleted int
 		err              error
-		metricsTouched   = make(map[string]struct{})
+		rowsDeleted      = make(map[string]int)
+		entireSeries     = start.Equal(model.MinTime) && end.Equal(model.MaxTime)
 	)
 	metricNames, seriesIDMatrix, err := getMetricNameSeriesIDFromMatchers(pgDel.Conn, matchers)
 	if err != nil {
-		return nil, nil, -1, fmt.Errorf("delete-series: %w", err)
+		return nil, nil, nil, fmt.Errorf("delete-series: %w", err)
 	}
 	for metricIndex, metricName := range metricNames {
 		seriesIDs := seriesIDMatrix[metricIndex]
-		var rowsDeleted int
-		if err = pgDel.Conn.QueryRow(
-			context.Background(),
-			queryDeleteSeries,
-			metricName,
-			convertSeriesIDsToInt64s(seriesIDs),
-		).Scan(&rowsDeleted); err != nil {
-			return getKeys(metricsTouched), deletedSeriesIDs, totalRowsDeleted, fmt.Errorf("deleting series with metric_name=%s and series_ids=%v : %w", metricName, seriesIDs, err)
+		var metricRowsDeleted int
+		if entireSeries {
+			err = pgDel.Conn.QueryRow(
+				context.Background(),
+				queryDeleteSeries,
+				metricName,
+				convertSeriesIDsToInt64s(seriesIDs),
+			).Scan(&metricRowsDeleted)
+		} else {
+			metricRowsDeleted, err = pgDel.deleteSeriesInRange(metricName, convertSeriesIDsToInt64s(seriesIDs), start, end)
 		}
-		if _, ok := metricsTouched[metricName]; !ok {
-			metricsTouched[metricName] = struct{}{}
+		if err != nil {
+			return getKeys(rowsDeleted), deletedSeriesIDs, rowsDeleted, fmt.Errorf("deleting series with metric_name=%s and series_ids=%v : %w", metricName, seriesIDs, err)
 		}
+		rowsDeleted[metricName] += metricRowsDeleted
 		deletedSeriesIDs = append(deletedSeriesIDs, seriesIDs...)
-		totalRowsDeleted += rowsDeleted
 	}
-	return getKeys(metricsTouched), deletedSeriesIDs, totalRowsDeleted, nil
+	return getKeys(rowsDeleted), deletedSeriesIDs, rowsDeleted, nil
+}
+
+// deleteSeriesInRange deletes the samples and exemplars of the series between start
+// and end. The samples are deleted chunk by chunk, each chunk in its own transaction,
+// so that a wide time range doesn't lock and decompress all the chunks at once.
+func (pgDel *PgDelete) deleteSeriesInRange(metricName string, seriesIDs []int64, start, end time.Time) (int, error) {
+	startTs, endTs := toTimestamptz(start), toTimestamptz(end)
+	rows, err := pgDel.Conn.Query(context.Background(), queryMetricChunksInRange, metricName, startTs, endTs)
+	if err != nil {
+		return 0, fmt.Errorf("get chunks: %w", err)
+	}
+	defer rows.Close()
+	var chunks [][2]string
+	for rows.Next() {
+		var chunkSchema, chunkName string
+		if err = rows.Scan(&chunkSchema, &chunkName); err != nil {
+			return 0, fmt.Errorf("get chunks: %w", err)
+		}
+		chunks = append(chunks, [2]string{chunkSchema, chunkName})
+	}
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("get chunks: %w", err)
+	}
+	rows.Close()
+
+	rowsDeleted := 0
+	for _, chunk := range chunks {
+		var chunkRowsDeleted int
+		err = pgDel.Conn.QueryRow(
+			context.Background(),
+			queryDeleteSeriesFromChunk,
+			metricName,
+			chunk[0],
+			chunk[1],
+			seriesIDs,
+			startTs,
+			endTs,
+		).Scan(&chunkRowsDeleted)
+		if err != nil {
+			return rowsDeleted, fmt.Errorf("delete from chunk %s.%s: %w", chunk[0], chunk[1], err)
+		}
+		rowsDeleted += chunkRowsDeleted
+	}
+	if _, err = pgDel.Conn.Exec(context.Background(), queryDeleteExemplarsInRange, metricName, seriesIDs, startTs, endTs); err != nil {
+		return rowsDeleted, fmt.Errorf("delete exemplars: %w", err)
+	}
+	return rowsDeleted, nil
+}
+
+// toTimestamptz converts the time to a timestamptz, mapping model.MinTime and
+// model.MaxTime to -infinity and infinity since they are out of the range
+// supported by PostgreSQL.
+func toTimestamptz(t time.Time) pgtype.Timestamptz {
+	switch {
+	case !t.After(model.MinTime):
+		return pgtype.Timestamptz{Status: pgtype.Present, InfinityModifier: pgtype.NegativeInfinity}
+	case !t.Before(model.MaxTime):
+		return pgtype.Timestamptz{Status: pgtype.Present, InfinityModifier: pgtype.Infinity}
+	}
+	return pgtype.Timestamptz{Time: t, Status: pgtype.Present}
 }
 
 // getMetricNameSeriesIDFromMatchers returns the metric name list and the corresponding series ID array
@@ -98,7 +169,7 @@ func convertSeriesIDsToInt64s(s []model.SeriesID) []int64 {
 	return temp
 }
 
-func getKeys(mapStr map[string]struct{}) (keys []string) {
+func getKeys(mapStr map[string]int) (keys []string) {
 	if mapStr == nil {
 		return nil
 	}
@@ -0,0 +1,146 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package delete
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+// MaxExampleSeries is the max number of example label sets returned per metric by a dry run.
+const MaxExampleSeries = 5
+
+const (
+	queryMetricTableName = "SELECT table_name FROM " + schema.Catalog + ".get_metric_table_name_if_exists($1, $2)"
+	queryExampleSeries   = "SELECT (" + schema.Prom + ".key_value_array(labels)).* FROM " + schema.Catalog + ".series WHERE id = ANY($1) ORDER BY id LIMIT $2"
+	// The row count is estimated by the planner instead of being counted, since
+	// counting would need to decompress all compressed chunks in the time range.
+	queryEstimateRowsTemplate = "EXPLAIN (FORMAT JSON) SELECT 1 FROM %s WHERE series_id = ANY($1) AND time >= $2 AND time <= $3"
+)
+
+// MetricDryRun describes the data of a metric that would be deleted.
+type MetricDryRun struct {
+	Metric        string          `json:"metric"`
+	Series        int             `json:"series"`
+	ExampleSeries []labels.Labels `json:"example_series"`
+	EstimatedRows int64           `json:"estimated_rows"`
+}
+
+// DryRun returns what DeleteSeries would delete for the union of the series that match any
+// of the matcher sets between start and end, without touching any data. The results are
+// sorted by metric name.
+func (pgDel *PgDelete) DryRun(matcherSets [][]*labels.Matcher, start, end time.Time) ([]MetricDryRun, error) {
+	seriesPerMetric := make(map[string]map[model.SeriesID]struct{})
+	for _, matchers := range matcherSets {
+		metricNames, seriesIDMatrix, err := getMetricNameSeriesIDFromMatchers(pgDel.Conn, matchers)
+		if err != nil {
+			return nil, fmt.Errorf("delete-series dry-run: %w", err)
+		}
+		for metricIndex, metricName := range metricNames {
+			if _, ok := seriesPerMetric[metricName]; !ok {
+				seriesPerMetric[metricName] = make(map[model.SeriesID]struct{})
+			}
+			for _, id := range seriesIDMatrix[metricIndex] {
+				seriesPerMetric[metricName][id] = struct{}{}
+			}
+		}
+	}
+
+	result := make([]MetricDryRun, 0, len(seriesPerMetric))
+	for metricName, seriesSet := range seriesPerMetric {
+		seriesIDs := make([]int64, 0, len(seriesSet))
+		for id := range seriesSet {
+			seriesIDs = append(seriesIDs, int64(id))
+		}
+		sort.Slice(seriesIDs, func(i, j int) bool { return seriesIDs[i] < seriesIDs[j] })
+
+		examples, err := pgDel.exampleSeries(seriesIDs)
+		if err != nil {
+			return nil, fmt.Errorf("fetching example series of metric_name=%s: %w", metricName, err)
+		}
+		estimatedRows, err := pgDel.estimateRows(metricName, seriesIDs, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("estimating rows of metric_name=%s: %w", metricName, err)
+		}
+		result = append(result, MetricDryRun{
+			Metric:        metricName,
+			Series:        len(seriesIDs),
+			ExampleSeries: examples,
+			EstimatedRows: estimatedRows,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Metric < result[j].Metric })
+	return result, nil
+}
+
+func (pgDel *PgDelete) exampleSeries(seriesIDs []int64) ([]labels.Labels, error) {
+	rows, err := pgDel.Conn.Query(context.Background(), queryExampleSeries, seriesIDs, MaxExampleSeries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	examples := make([]labels.Labels, 0, MaxExampleSeries)
+	for rows.Next() {
+		var keys, values []string
+		if err = rows.Scan(&keys, &values); err != nil {
+			return nil, err
+		}
+		if len(keys) != len(values) {
+			return nil, fmt.Errorf("label keys and values do not match in length")
+		}
+		lbls := make(labels.Labels, 0, len(keys))
+		for i := range keys {
+			lbls = append(lbls, labels.Label{Name: keys[i], Value: values[i]})
+		}
+		sort.Sort(lbls)
+		examples = append(examples, lbls)
+	}
+	return examples, rows.Err()
+}
+
+func (pgDel *PgDelete) estimateRows(metricName string, seriesIDs []int64, start, end time.Time) (int64, error) {
+	var tableName string
+	if err := pgDel.Conn.QueryRow(context.Background(), queryMetricTableName, schema.Data, metricName).Scan(&tableName); err != nil {
+		return 0, fmt.Errorf("get metric table name: %w", err)
+	}
+
+	var plan []byte
+	if err := pgDel.Conn.QueryRow(
+		context.Background(),
+		fmt.Sprintf(queryEstimateRowsTemplate, pgx.Identifier{schema.Data, tableName}.Sanitize()),
+		seriesIDs,
+		toTimestamptz(start),
+		toTimestamptz(end),
+	).Scan(&plan); err != nil {
+		return 0, err
+	}
+	return parsePlanRows(plan)
+}
+
+// parsePlanRows returns the estimated number of rows of the top level node of
+// a query plan in the JSON format.
+func parsePlanRows(plan []byte) (int64, error) {
+	var explained []struct {
+		Plan struct {
+			PlanRows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &explained); err != nil {
+		return 0, fmt.Errorf("parsing query plan: %w", err)
+	}
+	if len(explained) == 0 {
+		return 0, fmt.Errorf("empty query plan")
+	}
+	return int64(explained[0].Plan.PlanRows), nil
+}
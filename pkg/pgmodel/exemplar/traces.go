@@ -0,0 +1,201 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package exemplar
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+// TraceIDLabels are the exemplar label names that hold a trace ID, in order of preference.
+var TraceIDLabels = []string{"trace_id", "traceID", "traceId"}
+
+const (
+	traceSummariesSQL = `
+SELECT
+	s.trace_id,
+	min(s.start_time),
+	max(s.end_time),
+	count(*),
+	(array_agg(t.value#>>'{}' ORDER BY s.start_time) FILTER (WHERE s.parent_span_id IS NULL))[1],
+	(array_agg(o.span_name ORDER BY s.start_time) FILTER (WHERE s.parent_span_id IS NULL))[1]
+FROM
+	_ps_trace.span s
+INNER JOIN
+	_ps_trace.operation o ON (o.id = s.operation_id)
+LEFT JOIN
+	_ps_trace.tag t ON (t.id = o.service_name_id AND t.key = 'service.name')
+WHERE
+	s.trace_id = ANY($1::uuid[])
+GROUP BY s.trace_id`
+
+	traceExemplarsSQL = `
+SELECT
+	series_labels,
+	time,
+	value,
+	exemplar_labels
+FROM
+	_prom_catalog.get_exemplars_by_label_value($1, $2, $3, $4)`
+)
+
+// TraceSummary describes a stored trace.
+type TraceSummary struct {
+	TraceID      string    `json:"traceID"`
+	RootService  string    `json:"rootServiceName,omitempty"`
+	RootSpanName string    `json:"rootSpanName,omitempty"`
+	StartTime    time.Time `json:"startTime"`
+	DurationMs   float64   `json:"durationMs"`
+	SpanCount    int64     `json:"spanCount"`
+}
+
+// TraceID returns the trace ID that the exemplar labels point to, if any.
+func TraceID(lbls labels.Labels) (string, bool) {
+	for _, name := range TraceIDLabels {
+		if id := lbls.Get(name); id != "" {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// ParseTraceID converts a hex encoded trace ID of 64 or 128 bits, optionally in
+// the dashed UUID form, to the UUID that the trace is stored with.
+func ParseTraceID(id string) (pgtype.UUID, error) {
+	uuid := pgtype.UUID{Status: pgtype.Present}
+	b, err := hex.DecodeString(strings.ReplaceAll(id, "-", ""))
+	if err != nil || len(b) == 0 || len(b) > len(uuid.Bytes) {
+		return pgtype.UUID{}, fmt.Errorf("invalid trace ID: %q", id)
+	}
+	// 64-bit trace IDs are stored in the lower half.
+	copy(uuid.Bytes[len(uuid.Bytes)-len(b):], b)
+	return uuid, nil
+}
+
+// TraceSummaries fetches the summaries of the stored traces among traceIDs,
+// keyed by the trace ID as given. Invalid and unknown trace IDs are skipped.
+func TraceSummaries(ctx context.Context, conn pgxconn.PgxConn, traceIDs []string) (map[string]TraceSummary, error) {
+	summaries := make(map[string]TraceSummary)
+	ids := make(map[[16]byte]string, len(traceIDs))
+	uuids := make([]pgtype.UUID, 0, len(traceIDs))
+	for _, id := range traceIDs {
+		uuid, err := ParseTraceID(id)
+		if err != nil {
+			continue
+		}
+		if _, ok := ids[uuid.Bytes]; !ok {
+			uuids = append(uuids, uuid)
+		}
+		ids[uuid.Bytes] = id
+	}
+	if len(uuids) == 0 {
+		return summaries, nil
+	}
+
+	rows, err := conn.Query(ctx, traceSummariesSQL, uuids)
+	if err != nil {
+		return nil, fmt.Errorf("fetching trace summaries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			uuid        pgtype.UUID
+			start, end  time.Time
+			spanCount   int64
+			rootService pgtype.Text
+			rootSpan    pgtype.Text
+		)
+		if err = rows.Scan(&uuid, &start, &end, &spanCount, &rootService, &rootSpan); err != nil {
+			return nil, fmt.Errorf("scanning trace summaries: %w", err)
+		}
+		id := ids[uuid.Bytes]
+		summaries[id] = TraceSummary{
+			TraceID:      id,
+			RootService:  rootService.String,
+			RootSpanName: rootSpan.String,
+			StartTime:    start,
+			DurationMs:   float64(end.Sub(start)) / float64(time.Millisecond),
+			SpanCount:    spanCount,
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("fetching trace summaries: %w", err)
+	}
+	return summaries, nil
+}
+
+// TraceExemplars fetches the exemplars between start and end that point to the
+// trace, in any of the forms accepted by ParseTraceID. Only the exemplars of the
+// series that match all of seriesMatchers are returned, e.g. to restrict them to
+// the tenants allowed to be read.
+func TraceExemplars(ctx context.Context, conn pgxconn.PgxConn, traceID string, start, end time.Time, seriesMatchers []*labels.Matcher) ([]model.ExemplarQueryResult, error) {
+	uuid, err := ParseTraceID(traceID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn.Query(ctx, traceExemplarsSQL, TraceIDLabels, hex.EncodeToString(uuid.Bytes[:]), start, end)
+	if err != nil {
+		return nil, fmt.Errorf("fetching trace exemplars: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		results     = make([]model.ExemplarQueryResult, 0)
+		seriesIndex = make(map[string]int)
+	)
+	for rows.Next() {
+		var (
+			seriesLabels   map[string]string
+			ts             time.Time
+			value          float64
+			exemplarLabels map[string]string
+		)
+		if err = rows.Scan(&seriesLabels, &ts, &value, &exemplarLabels); err != nil {
+			return nil, fmt.Errorf("scanning trace exemplars: %w", err)
+		}
+		series := labels.FromMap(seriesLabels)
+		if !matchesAll(seriesMatchers, series) {
+			continue
+		}
+		i, ok := seriesIndex[series.String()]
+		if !ok {
+			i = len(results)
+			seriesIndex[series.String()] = i
+			results = append(results, model.ExemplarQueryResult{SeriesLabels: series})
+		}
+		results[i].Exemplars = append(results[i].Exemplars, model.ExemplarData{
+			Labels: labels.FromMap(exemplarLabels),
+			Value:  value,
+			Ts:     timestamp.FromTime(ts),
+		})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("fetching trace exemplars: %w", err)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return labels.Compare(results[i].SeriesLabels, results[j].SeriesLabels) < 0
+	})
+	return results, nil
+}
+
+func matchesAll(ms []*labels.Matcher, lbls labels.Labels) bool {
+	for _, m := range ms {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
@@ -24,6 +24,24 @@ type Cfg struct {
 	NumCopiers             int
 	DisableEpochSync       bool
 	IgnoreCompressedChunks bool
+	// SpanMetrics enables deriving RED metrics from ingested spans, written to
+	// the metric tables every SpanMetricsInterval. The series are labelled with
+	// SpanMetricsInstance and at most SpanMetricsMaxSeries of them are kept.
+	SpanMetrics          bool
+	SpanMetricsInterval  time.Duration
+	SpanMetricsInstance  string
+	SpanMetricsMaxSeries int
+	// TraceSampling configures the sampling of the ingested traces.
+	TraceSampling trace.SamplingConfig
+	// AttributeRules are applied to the attributes of the ingested spans,
+	// hashing values with AttributeHashKey as the HMAC key.
+	AttributeRules   trace.AttributeRules
+	AttributeHashKey []byte
+	// TraceCaches hold the IDs of the stored tags, operations, instrumentation
+	// libraries and schema URLs of the ingested spans.
+	TraceCaches *trace.Caches
+	// TraceDispatcher configures the batching of the ingested traces.
+	TraceDispatcher trace.DispatcherConfig
 }
 
 // DBIngestor ingest the TimeSeries data into Timescale database.
@@ -31,6 +49,12 @@ type DBIngestor struct {
 	sCache     cache.SeriesCache
 	dispatcher model.Dispatcher
 	tWriter    trace.Writer
+	tSampler   *trace.Sampler
+	tDispatch  *trace.Dispatcher
+
+	spanMetrics     *spanMetrics
+	spanMetricsStop chan struct{}
+	spanMetricsDone chan struct{}
 }
 
 // NewPgxIngestor returns a new Ingestor that uses connection pool and a metrics cache
@@ -40,11 +64,25 @@ func NewPgxIngestor(conn pgxconn.PgxConn, cache cache.MetricCache, sCache cache.
 	if err != nil {
 		return nil, err
 	}
-	return &DBIngestor{
+	tWriter := trace.NewWriter(conn, trace.WriterConfig{AttributeRules: cfg.AttributeRules, AttributeHashKey: cfg.AttributeHashKey, Caches: cfg.TraceCaches})
+	tDispatch := trace.NewDispatcher(tWriter, cfg.TraceDispatcher)
+	ingestor := &DBIngestor{
 		sCache:     sCache,
 		dispatcher: dispatcher,
-		tWriter:    trace.NewWriter(conn),
-	}, nil
+		tWriter:    tDispatch,
+		tDispatch:  tDispatch,
+	}
+	if cfg.TraceSampling.Enabled() {
+		ingestor.tSampler = trace.NewSampler(ingestor.tWriter, cfg.TraceSampling)
+		ingestor.tWriter = ingestor.tSampler
+	}
+	if cfg.SpanMetrics {
+		ingestor.spanMetrics = newSpanMetrics(cfg.SpanMetricsInstance, cfg.SpanMetricsMaxSeries)
+		ingestor.spanMetricsStop = make(chan struct{})
+		ingestor.spanMetricsDone = make(chan struct{})
+		go ingestor.runSpanMetrics(cfg.SpanMetricsInterval, ingestor.spanMetricsStop, ingestor.spanMetricsDone)
+	}
+	return ingestor, nil
 }
 
 // NewPgxIngestorForTests returns a new Ingestor that write to PostgreSQL using PGX
@@ -73,7 +111,13 @@ type result struct {
 }
 
 func (ingestor *DBIngestor) IngestTraces(ctx context.Context, traces pdata.Traces) error {
-	return ingestor.tWriter.InsertTraces(ctx, traces)
+	if err := ingestor.tWriter.InsertTraces(ctx, traces); err != nil {
+		return err
+	}
+	if ingestor.spanMetrics != nil {
+		ingestor.spanMetrics.record(traces)
+	}
+	return nil
 }
 
 // Ingest transforms and ingests the timeseries data into Timescale database.
@@ -244,5 +288,15 @@ func (ingestor *DBIngestor) CompleteMetricCreation() error {
 
 // Close closes the ingestor
 func (ingestor *DBIngestor) Close() {
+	if ingestor.tSampler != nil {
+		ingestor.tSampler.Close()
+	}
+	if ingestor.tDispatch != nil {
+		ingestor.tDispatch.Close()
+	}
+	if ingestor.spanMetrics != nil {
+		close(ingestor.spanMetricsStop)
+		<-ingestor.spanMetricsDone
+	}
 	ingestor.dispatcher.Close()
 }
@@ -0,0 +1,229 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ingestor
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace"
+	"github.com/timescale/promscale/pkg/prompb"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+const (
+	spanMetricsCallsName   = "traces_spanmetrics_calls_total"
+	spanMetricsLatencyName = "traces_spanmetrics_latency"
+
+	spanMetricsServiceLabel   = "service_name"
+	spanMetricsOperationLabel = "operation"
+	spanMetricsKindLabel      = "span_kind"
+	spanMetricsStatusLabel    = "status_code"
+	// spanMetricsInstanceLabel tells apart the series of the Promscale instances,
+	// as each instance counts only the spans it ingests.
+	spanMetricsInstanceLabel = "promscale_instance"
+
+	// spanMetricsExpiry is how long the counters of a series are kept after
+	// its last span. A series that reappears restarts from zero.
+	spanMetricsExpiry = 15 * time.Minute
+)
+
+// spanMetricsLatencyBuckets are the upper bounds of the latency histogram buckets
+// in milliseconds. They are the defaults of the span metrics processor of the
+// OpenTelemetry Collector, so that dashboards built for it work unchanged.
+var spanMetricsLatencyBuckets = []float64{2, 4, 6, 8, 10, 50, 100, 200, 400, 800, 1000, 1400, 2000, 5000, 10000, 15000}
+
+type spanMetricsKey struct {
+	service   string
+	operation string
+	kind      string
+	status    string
+}
+
+type spanMetricsSeries struct {
+	calls uint64
+	// bucketCounts counts the spans per latency bucket, the last one being +Inf.
+	bucketCounts []uint64
+	sumMillis    float64
+	updated      bool
+	lastSpan     time.Time
+}
+
+// spanMetrics derives RED (rate, errors, duration) metrics from spans. It keeps
+// cumulative counters per service, operation, span kind and status code, and
+// collects the counters that changed since the previous collection. Series
+// without spans for spanMetricsExpiry are dropped, and spans that would create
+// more than maxSeries series are not counted.
+type spanMetrics struct {
+	mu           sync.Mutex
+	instance     string
+	maxSeries    int
+	series       map[spanMetricsKey]*spanMetricsSeries
+	numDropped   uint64
+	metadataSent bool
+}
+
+func newSpanMetrics(instance string, maxSeries int) *spanMetrics {
+	return &spanMetrics{
+		instance:  instance,
+		maxSeries: maxSeries,
+		series:    make(map[spanMetricsKey]*spanMetricsSeries),
+	}
+}
+
+// record adds the spans of traces to the counters.
+func (s *spanMetrics) record(traces pdata.Traces) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	rSpans := traces.ResourceSpans()
+	for i := 0; i < rSpans.Len(); i++ {
+		rSpan := rSpans.At(i)
+		serviceName := trace.ServiceName(rSpan)
+		instLibSpans := rSpan.InstrumentationLibrarySpans()
+		for j := 0; j < instLibSpans.Len(); j++ {
+			spans := instLibSpans.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				key := spanMetricsKey{
+					service:   serviceName,
+					operation: span.Name(),
+					kind:      span.Kind().String(),
+					status:    span.Status().Code().String(),
+				}
+				series, ok := s.series[key]
+				if !ok {
+					if len(s.series) >= s.maxSeries {
+						s.numDropped++
+						continue
+					}
+					series = &spanMetricsSeries{bucketCounts: make([]uint64, len(spanMetricsLatencyBuckets)+1)}
+					s.series[key] = series
+				}
+
+				var latency float64
+				if start, end := span.StartTimestamp(), span.EndTimestamp(); end > start {
+					latency = float64(end-start) / float64(time.Millisecond)
+				}
+				series.calls++
+				series.bucketCounts[sort.SearchFloat64s(spanMetricsLatencyBuckets, latency)]++
+				series.sumMillis += latency
+				series.updated = true
+				series.lastSpan = now
+			}
+		}
+	}
+}
+
+// collect returns a write request with the samples at time now of the counters
+// that changed since the previous collection, or nil if none changed. It also
+// drops the series that expired.
+func (s *spanMetrics) collect(now time.Time) *prompb.WriteRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.numDropped > 0 {
+		log.Warn("msg", "Span metrics series limit reached, spans of new series were not counted", "limit", s.maxSeries, "spans", s.numDropped)
+		s.numDropped = 0
+	}
+
+	ts := now.UnixNano() / int64(time.Millisecond)
+	wr := NewWriteRequest()
+	for key, series := range s.series {
+		if !series.updated {
+			if now.Sub(series.lastSpan) > spanMetricsExpiry {
+				delete(s.series, key)
+			}
+			continue
+		}
+		series.updated = false
+
+		wr.Timeseries = append(wr.Timeseries, s.sample(key, spanMetricsCallsName, ts, float64(series.calls)))
+		var cumulative uint64
+		for i, count := range series.bucketCounts {
+			cumulative += count
+			le := math.Inf(1)
+			if i < len(spanMetricsLatencyBuckets) {
+				le = spanMetricsLatencyBuckets[i]
+			}
+			sample := s.sample(key, spanMetricsLatencyName+"_bucket", ts, float64(cumulative))
+			sample.Labels = append(sample.Labels, prompb.Label{Name: "le", Value: strconv.FormatFloat(le, 'g', -1, 64)})
+			wr.Timeseries = append(wr.Timeseries, sample)
+		}
+		wr.Timeseries = append(wr.Timeseries,
+			s.sample(key, spanMetricsLatencyName+"_sum", ts, series.sumMillis),
+			s.sample(key, spanMetricsLatencyName+"_count", ts, float64(series.calls)),
+		)
+	}
+	if len(wr.Timeseries) == 0 {
+		FinishWriteRequest(wr)
+		return nil
+	}
+
+	if !s.metadataSent {
+		wr.Metadata = append(wr.Metadata,
+			prompb.MetricMetadata{
+				Type:             prompb.MetricMetadata_COUNTER,
+				MetricFamilyName: spanMetricsCallsName,
+				Help:             "Number of spans, derived from the ingested traces.",
+			},
+			prompb.MetricMetadata{
+				Type:             prompb.MetricMetadata_HISTOGRAM,
+				MetricFamilyName: spanMetricsLatencyName,
+				Help:             "Duration of spans, derived from the ingested traces.",
+				Unit:             "milliseconds",
+			},
+		)
+		s.metadataSent = true
+	}
+	return wr
+}
+
+func (s *spanMetrics) sample(key spanMetricsKey, name string, ts int64, value float64) prompb.TimeSeries {
+	return prompb.TimeSeries{
+		Labels: []prompb.Label{
+			{Name: "__name__", Value: name},
+			{Name: spanMetricsOperationLabel, Value: key.operation},
+			{Name: spanMetricsInstanceLabel, Value: s.instance},
+			{Name: spanMetricsServiceLabel, Value: key.service},
+			{Name: spanMetricsKindLabel, Value: key.kind},
+			{Name: spanMetricsStatusLabel, Value: key.status},
+		},
+		Samples: []prompb.Sample{{Timestamp: ts, Value: value}},
+	}
+}
+
+// runSpanMetrics writes the span metrics to the metric tables every interval
+// until stop is closed.
+func (ingestor *DBIngestor) runSpanMetrics(interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ingestor.flushSpanMetrics()
+		case <-stop:
+			ingestor.flushSpanMetrics()
+			return
+		}
+	}
+}
+
+func (ingestor *DBIngestor) flushSpanMetrics() {
+	wr := ingestor.spanMetrics.collect(time.Now())
+	if wr == nil {
+		return
+	}
+	if _, _, err := ingestor.Ingest(wr); err != nil {
+		log.Warn("msg", "Error writing span metrics", "err", err)
+	}
+}
@@ -0,0 +1,107 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ingestor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/prompb"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+func testSpanMetricsTraces(durations ...time.Duration) pdata.Traces {
+	traces := pdata.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().InsertString("service.name", "frontend")
+	spans := rs.InstrumentationLibrarySpans().AppendEmpty().Spans()
+	start := time.Unix(1600000000, 0)
+	for _, d := range durations {
+		span := spans.AppendEmpty()
+		span.SetName("GET /")
+		span.SetKind(pdata.SpanKindServer)
+		span.SetStartTimestamp(pdata.NewTimestampFromTime(start))
+		span.SetEndTimestamp(pdata.NewTimestampFromTime(start.Add(d)))
+	}
+	return traces
+}
+
+func TestSpanMetrics(t *testing.T) {
+	s := newSpanMetrics("promscale-0", 10)
+	require.Nil(t, s.collect(time.Now()))
+
+	s.record(testSpanMetricsTraces(time.Millisecond, 3*time.Millisecond, time.Minute))
+	errTraces := testSpanMetricsTraces(time.Millisecond)
+	errTraces.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans().At(0).Status().SetCode(pdata.StatusCodeError)
+	s.record(errTraces)
+
+	now := time.Unix(1600000100, 0)
+	wr := s.collect(now)
+	require.NotNil(t, wr)
+	require.Len(t, wr.Metadata, 2)
+
+	values := make(map[string]float64)
+	for _, ts := range wr.Timeseries {
+		labels := make(map[string]string)
+		for _, l := range ts.Labels {
+			labels[l.Name] = l.Value
+		}
+		require.Equal(t, "frontend", labels["service_name"])
+		require.Equal(t, "GET /", labels["operation"])
+		require.Equal(t, "SPAN_KIND_SERVER", labels["span_kind"])
+		require.Equal(t, "promscale-0", labels["promscale_instance"])
+		require.Equal(t, []prompb.Sample{{Timestamp: 1600000100000, Value: ts.Samples[0].Value}}, ts.Samples)
+		values[labels["__name__"]+"/"+labels["status_code"]+"/"+labels["le"]] = ts.Samples[0].Value
+	}
+	require.Equal(t, 3.0, values["traces_spanmetrics_calls_total/STATUS_CODE_UNSET/"])
+	require.Equal(t, 1.0, values["traces_spanmetrics_calls_total/STATUS_CODE_ERROR/"])
+	require.Equal(t, 1.0, values["traces_spanmetrics_latency_bucket/STATUS_CODE_UNSET/2"])
+	require.Equal(t, 2.0, values["traces_spanmetrics_latency_bucket/STATUS_CODE_UNSET/4"])
+	require.Equal(t, 2.0, values["traces_spanmetrics_latency_bucket/STATUS_CODE_UNSET/15000"])
+	require.Equal(t, 3.0, values["traces_spanmetrics_latency_bucket/STATUS_CODE_UNSET/+Inf"])
+	require.Equal(t, 60004.0, values["traces_spanmetrics_latency_sum/STATUS_CODE_UNSET/"])
+	require.Equal(t, 3.0, values["traces_spanmetrics_latency_count/STATUS_CODE_UNSET/"])
+	FinishWriteRequest(wr)
+
+	// Only the series that changed are collected again, with cumulative values.
+	require.Nil(t, s.collect(now))
+	s.record(errTraces)
+	wr = s.collect(now)
+	require.NotNil(t, wr)
+	require.Empty(t, wr.Metadata)
+	require.Len(t, wr.Timeseries, 1+len(spanMetricsLatencyBuckets)+1+2)
+	require.Equal(t, "traces_spanmetrics_calls_total", wr.Timeseries[0].Labels[0].Value)
+	require.Equal(t, 2.0, wr.Timeseries[0].Samples[0].Value)
+
+	FinishWriteRequest(wr)
+
+	// Series without spans expire, and are counted from zero when they reappear.
+	require.Nil(t, s.collect(time.Now().Add(spanMetricsExpiry+time.Minute)))
+	require.Empty(t, s.series)
+	s.record(errTraces)
+	wr = s.collect(now)
+	require.NotNil(t, wr)
+	require.Equal(t, 1.0, wr.Timeseries[0].Samples[0].Value)
+	FinishWriteRequest(wr)
+}
+
+func TestSpanMetricsMaxSeries(t *testing.T) {
+	s := newSpanMetrics("promscale-0", 1)
+	s.record(testSpanMetricsTraces(time.Millisecond))
+	errTraces := testSpanMetricsTraces(time.Millisecond)
+	errTraces.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans().At(0).Status().SetCode(pdata.StatusCodeError)
+	s.record(errTraces)
+	require.Len(t, s.series, 1)
+	require.Equal(t, uint64(1), s.numDropped)
+
+	// Spans of existing series are still counted.
+	s.record(testSpanMetricsTraces(time.Millisecond))
+	wr := s.collect(time.Now())
+	require.NotNil(t, wr)
+	require.Equal(t, 2.0, wr.Timeseries[0].Samples[0].Value)
+	require.Zero(t, s.numDropped)
+	FinishWriteRequest(wr)
+}
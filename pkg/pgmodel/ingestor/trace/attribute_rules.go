@@ -0,0 +1,210 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package trace
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+// AttributeAction is what an AttributeRule does to the attributes it matches.
+type AttributeAction int
+
+const (
+	// DropAttribute removes the attribute.
+	DropAttribute AttributeAction = iota
+	// HashAttribute replaces the value with its HMAC-SHA256 hash in hex.
+	HashAttribute
+	// TruncateAttribute shortens string values to a maximum number of characters.
+	TruncateAttribute
+)
+
+// AttributeRule changes the span, resource, event and link attributes whose
+// key matches Key and whose string value contains a match of Value. A nil
+// regexp matches any key or value.
+type AttributeRule struct {
+	Action AttributeAction
+	Key    *regexp.Regexp
+	Value  *regexp.Regexp
+	// Length is the number of characters that TruncateAttribute keeps.
+	Length int
+
+	spec string
+}
+
+// ParseAttributeRule parses a rule of the form `action:key-regex[:value-regex]`,
+// where action is `drop`, `hash` or `truncate=<length>`. The key regex is fully
+// anchored and the value regex is not.
+func ParseAttributeRule(spec string) (AttributeRule, error) {
+	rule := AttributeRule{spec: spec}
+	parts := strings.SplitN(spec, ":", 3)
+	if len(parts) < 2 {
+		return rule, fmt.Errorf("invalid attribute rule %q: expected action:key-regex[:value-regex]", spec)
+	}
+
+	action := parts[0]
+	switch {
+	case action == "drop":
+		rule.Action = DropAttribute
+	case action == "hash":
+		rule.Action = HashAttribute
+	case strings.HasPrefix(action, "truncate="):
+		length, err := strconv.Atoi(strings.TrimPrefix(action, "truncate="))
+		if err != nil || length < 0 {
+			return rule, fmt.Errorf("invalid attribute rule %q: invalid truncate length", spec)
+		}
+		rule.Action = TruncateAttribute
+		rule.Length = length
+	default:
+		return rule, fmt.Errorf("invalid attribute rule %q: unknown action %q, valid actions are [drop, hash, truncate=<length>]", spec, action)
+	}
+
+	var err error
+	if parts[1] != "" {
+		if rule.Key, err = regexp.Compile("^(?:" + parts[1] + ")$"); err != nil {
+			return rule, fmt.Errorf("invalid attribute rule %q: %w", spec, err)
+		}
+	}
+	if len(parts) == 3 && parts[2] != "" {
+		if rule.Value, err = regexp.Compile(parts[2]); err != nil {
+			return rule, fmt.Errorf("invalid attribute rule %q: %w", spec, err)
+		}
+	}
+	if rule.Key == nil && rule.Value == nil {
+		return rule, fmt.Errorf("invalid attribute rule %q: a key or value regex is required", spec)
+	}
+	return rule, nil
+}
+
+func (r AttributeRule) String() string {
+	return r.spec
+}
+
+func (r AttributeRule) matches(key string, value interface{}) bool {
+	if r.Key != nil && !r.Key.MatchString(key) {
+		return false
+	}
+	if r.Value != nil {
+		s, ok := value.(string)
+		if !ok || !r.Value.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// AttributeRules are applied in order to the attributes of ingested spans
+// before their tags are stored. It is a CLI flag type that appends a rule
+// each time the flag is set.
+type AttributeRules []AttributeRule
+
+// Set implements the flag interface to set value from the CLI
+func (rules *AttributeRules) Set(spec string) error {
+	rule, err := ParseAttributeRule(spec)
+	if err != nil {
+		return err
+	}
+	*rules = append(*rules, rule)
+	return nil
+}
+
+func (rules *AttributeRules) String() string {
+	if rules == nil {
+		return ""
+	}
+	specs := make([]string, len(*rules))
+	for i, rule := range *rules {
+		specs[i] = rule.String()
+	}
+	return strings.Join(specs, ", ")
+}
+
+// Apply returns the attributes with the rules applied, except for the service
+// name and the tenant. Values are hashed with HMAC-SHA256 using hashKey. The
+// attributes are returned as they are if no rule matches.
+func (rules AttributeRules) Apply(attrs map[string]interface{}, hashKey []byte) map[string]interface{} {
+	if len(rules) == 0 {
+		return attrs
+	}
+	var result map[string]interface{}
+	for key, value := range attrs {
+		if key == serviceNameTagKey || key == tenancy.TenantAttributeKey {
+			// The service name identifies the operations of spans and
+			// the tenant restricts who reads them.
+			continue
+		}
+		newValue, keep, changed := rules.apply(key, value, hashKey)
+		if !changed {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]interface{}, len(attrs))
+			for k, v := range attrs {
+				result[k] = v
+			}
+		}
+		if keep {
+			result[key] = newValue
+		} else {
+			delete(result, key)
+		}
+	}
+	if result == nil {
+		return attrs
+	}
+	return result
+}
+
+// HasHashRule tells if any of the rules hashes attributes.
+func (rules AttributeRules) HasHashRule() bool {
+	for _, rule := range rules {
+		if rule.Action == HashAttribute {
+			return true
+		}
+	}
+	return false
+}
+
+func (rules AttributeRules) apply(key string, value interface{}, hashKey []byte) (newValue interface{}, keep, changed bool) {
+	for _, rule := range rules {
+		if !rule.matches(key, value) {
+			continue
+		}
+		switch rule.Action {
+		case DropAttribute:
+			return nil, false, true
+		case HashAttribute:
+			value = hashValue(value, hashKey)
+			changed = true
+		case TruncateAttribute:
+			if s, ok := value.(string); ok {
+				if runes := []rune(s); len(runes) > rule.Length {
+					value = string(runes[:rule.Length])
+					changed = true
+				}
+			}
+		}
+	}
+	return value, true, changed
+}
+
+func hashValue(value interface{}, key []byte) string {
+	s, ok := value.(string)
+	if !ok {
+		b, _ := json.Marshal(value)
+		s = string(b)
+	}
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
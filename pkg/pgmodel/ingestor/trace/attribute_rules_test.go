@@ -0,0 +1,95 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package trace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAttributeRule(t *testing.T) {
+	tcs := []struct {
+		spec   string
+		action AttributeAction
+		length int
+		err    bool
+	}{
+		{spec: "drop:password", action: DropAttribute},
+		{spec: "hash::@", action: HashAttribute},
+		{spec: "truncate=10:db\\.statement", action: TruncateAttribute, length: 10},
+		{spec: "drop", err: true},
+		{spec: "drop:", err: true},
+		{spec: "redact:password", err: true},
+		{spec: "truncate=x:db", err: true},
+		{spec: "truncate=-1:db", err: true},
+		{spec: "drop:(", err: true},
+		{spec: "drop::(", err: true},
+	}
+	for _, tc := range tcs {
+		rule, err := ParseAttributeRule(tc.spec)
+		if tc.err {
+			require.Error(t, err, tc.spec)
+			continue
+		}
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.action, rule.Action, tc.spec)
+		require.Equal(t, tc.length, rule.Length, tc.spec)
+		require.Equal(t, tc.spec, rule.String(), tc.spec)
+	}
+}
+
+func TestHashValue(t *testing.T) {
+	// HMAC-SHA256 test case 2 of RFC 4231.
+	require.Equal(t, "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843", hashValue("what do ya want for nothing?", []byte("Jefe")))
+	require.Equal(t, hashValue("500", nil), hashValue(int64(500), nil), "non-string values are hashed as JSON")
+}
+
+func TestAttributeRulesApply(t *testing.T) {
+	var rules AttributeRules
+	for _, spec := range []string{
+		"drop:.*token.*",
+		"hash:user\\.email",
+		"hash::[^@\\s]+@[^@\\s]+",
+		"truncate=6:db\\.statement",
+		"drop:service\\.name",
+		"hash:__.*",
+	} {
+		require.NoError(t, rules.Set(spec))
+	}
+
+	attrs := map[string]interface{}{
+		"auth.token":   "secret",
+		"user.email":   "jane@example.com",
+		"message":      "sent to joe@example.com",
+		"db.statement": "SELECT * FROM users",
+		"http.status":  int64(500),
+		"user.id":      "42",
+		"service.name": "frontend",
+		"__tenant__":   "tenant-a",
+		"admin":        true,
+	}
+	key := []byte("secret-key")
+	res := rules.Apply(attrs, key)
+	require.Equal(t, map[string]interface{}{
+		"user.email":   hashValue("jane@example.com", key),
+		"message":      hashValue("sent to joe@example.com", key),
+		"db.statement": "SELECT",
+		"http.status":  int64(500),
+		"user.id":      "42",
+		"service.name": "frontend",
+		"__tenant__":   "tenant-a",
+		"admin":        true,
+	}, res)
+	require.Len(t, hashValue("jane@example.com", key), 64)
+	require.NotEqual(t, hashValue("jane@example.com", key), hashValue("jane@example.com", []byte("other-key")), "the hash must depend on the key")
+	require.Equal(t, "secret", attrs["auth.token"], "the attributes must not be modified")
+
+	unchanged := map[string]interface{}{"user.id": "42"}
+	require.Equal(t, unchanged, rules.Apply(unchanged, key))
+	require.Equal(t, attrs, AttributeRules(nil).Apply(attrs, key))
+	require.True(t, rules.HasHashRule())
+	require.False(t, AttributeRules(rules[:1]).HasHashRule())
+}
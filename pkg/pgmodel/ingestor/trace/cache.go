@@ -0,0 +1,61 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package trace
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/clockcache"
+	"github.com/timescale/promscale/pkg/pgmodel/cache"
+)
+
+// Caches hold the IDs of the stored tags, operations, instrumentation
+// libraries and schema URLs, so that the batches of a Writer only send the
+// ones that are missing from the caches to the database. The IDs never change
+// once stored, so the caches can be shared by Writers.
+type Caches struct {
+	tags       *idCache
+	operations *idCache
+	instLibs   *idCache
+	schemaURLs *idCache
+}
+
+// NewCaches returns caches of the trace cache sizes of cfg.
+func NewCaches(cfg cache.Config) *Caches {
+	return &Caches{
+		tags:       newIDCache("tag", cfg.TraceTagCacheSize),
+		operations: newIDCache("operation", cfg.TraceOperationCacheSize),
+		instLibs:   newIDCache("instrumentation_lib", cfg.TraceInstLibCacheSize),
+		schemaURLs: newIDCache("schema_url", cfg.TraceSchemaURLCacheSize),
+	}
+}
+
+// idCache is a cache of IDs that counts its hits and misses.
+type idCache struct {
+	ids    *clockcache.Cache
+	hits   prometheus.Counter
+	misses prometheus.Counter
+}
+
+func newIDCache(name string, size uint64) *idCache {
+	return &idCache{
+		ids:    clockcache.WithMax(size),
+		hits:   cacheLookups.WithLabelValues(name, cacheHit),
+		misses: cacheLookups.WithLabelValues(name, cacheMiss),
+	}
+}
+
+func (c *idCache) get(key interface{}) (interface{}, bool) {
+	id, ok := c.ids.Get(key)
+	if ok {
+		c.hits.Inc()
+	} else {
+		c.misses.Inc()
+	}
+	return id, ok
+}
+
+func (c *idCache) insert(key interface{}, id interface{}, sizeBytes uint64) {
+	c.ids.Insert(key, id, sizeBytes)
+}
@@ -0,0 +1,100 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/cache"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+func TestOperationBatchCache(t *testing.T) {
+	caches := NewCaches(cache.DefaultConfig)
+	conn := model.NewSqlRecorder([]model.SqlQuery{
+		{
+			Sql:     "SELECT ps_trace.put_operation($1, $2, $3)",
+			Args:    []interface{}{"frontend", "GET /", "SPAN_KIND_SERVER"},
+			Results: model.RowResults{{int64(7)}},
+		},
+		{
+			Sql:     "SELECT ps_trace.put_operation($1, $2, $3)",
+			Args:    []interface{}{"frontend", "POST /", "SPAN_KIND_SERVER"},
+			Results: model.RowResults{{int64(8)}},
+		},
+	}, t)
+
+	batch := newOperationBatch(caches.operations)
+	batch.Queue("frontend", "GET /", "SPAN_KIND_SERVER")
+	require.NoError(t, batch.SendBatch(context.Background(), conn))
+
+	// Only the operation that is not cached is sent.
+	batch = newOperationBatch(caches.operations)
+	batch.Queue("frontend", "GET /", "SPAN_KIND_SERVER")
+	batch.Queue("frontend", "POST /", "SPAN_KIND_SERVER")
+	require.NoError(t, batch.SendBatch(context.Background(), conn))
+
+	// A batch of cached operations does not send anything.
+	batch = newOperationBatch(caches.operations)
+	batch.Queue("frontend", "GET /", "SPAN_KIND_SERVER")
+	batch.Queue("frontend", "POST /", "SPAN_KIND_SERVER")
+	require.NoError(t, batch.SendBatch(context.Background(), conn))
+
+	id, err := batch.GetID("frontend", "GET /", "SPAN_KIND_SERVER")
+	require.NoError(t, err)
+	require.Equal(t, pgtype.Int8{Int: 7, Status: pgtype.Present}, id)
+	id, err = batch.GetID("frontend", "POST /", "SPAN_KIND_SERVER")
+	require.NoError(t, err)
+	require.Equal(t, pgtype.Int8{Int: 8, Status: pgtype.Present}, id)
+}
+
+func TestTagBatchCache(t *testing.T) {
+	caches := NewCaches(cache.DefaultConfig)
+	conn := model.NewSqlRecorder([]model.SqlQuery{
+		{
+			Sql:     "SELECT ps_trace.put_tag_key($1, $2::ps_trace.tag_type)",
+			Args:    []interface{}{"http.method", SpanTagType},
+			Results: model.RowResults{{int64(1)}},
+		},
+		{
+			Sql:     "SELECT ps_trace.put_tag($1, $2, $3::ps_trace.tag_type)",
+			Args:    []interface{}{"http.method", `"GET"`, SpanTagType},
+			Results: model.RowResults{{int64(2)}},
+		},
+		{
+			Sql:     "SELECT ps_trace.put_tag_key($1, $2::ps_trace.tag_type)",
+			Args:    []interface{}{"http.method", ResourceTagType},
+			Results: model.RowResults{{int64(1)}},
+		},
+		{
+			Sql:     "SELECT ps_trace.put_tag($1, $2, $3::ps_trace.tag_type)",
+			Args:    []interface{}{"http.method", `"GET"`, ResourceTagType},
+			Results: model.RowResults{{int64(2)}},
+		},
+	}, t)
+	tags := map[string]interface{}{"http.method": "GET"}
+
+	batch := newTagBatch(caches.tags)
+	require.NoError(t, batch.Queue(tags, SpanTagType))
+	require.NoError(t, batch.SendBatch(context.Background(), conn))
+
+	// Tags are cached per tag type, as storing a tag of a new type updates it.
+	batch = newTagBatch(caches.tags)
+	require.NoError(t, batch.Queue(tags, SpanTagType))
+	require.NoError(t, batch.Queue(tags, ResourceTagType))
+	require.NoError(t, batch.SendBatch(context.Background(), conn))
+
+	batch = newTagBatch(caches.tags)
+	require.NoError(t, batch.Queue(tags, SpanTagType))
+	require.NoError(t, batch.Queue(tags, ResourceTagType))
+	require.NoError(t, batch.SendBatch(context.Background(), conn))
+
+	json, err := batch.GetTagMapJSON(tags, ResourceTagType)
+	require.NoError(t, err)
+	require.Equal(t, `{"1":2}`, string(json))
+}
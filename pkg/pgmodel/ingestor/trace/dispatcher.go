@@ -0,0 +1,212 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package trace
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/timescale/promscale/pkg/log"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+const dispatcherChannelCap = 1000
+
+// DispatcherConfig configures a Dispatcher.
+type DispatcherConfig struct {
+	// NumWriters is the number of batches written concurrently.
+	NumWriters int
+	// MaxBatchSize is the number of spans at which a batch is written
+	// without waiting for more traces.
+	MaxBatchSize int
+	// BatchTimeout is how long a batch waits for more traces.
+	BatchTimeout time.Duration
+	// AsyncAcks makes InsertTraces return before the traces are written.
+	AsyncAcks bool
+}
+
+// Dispatcher is a Writer that buffers the traces of many inserts into batches
+// that are written by a pool of writers, to write fewer and larger batches
+// when many small requests are received.
+type Dispatcher struct {
+	writer Writer
+	cfg    DispatcherConfig
+
+	in   chan *insertTracesRequest
+	done sync.WaitGroup
+	// gatherMu makes one writer at a time gather a batch, for larger batches.
+	gatherMu sync.Mutex
+}
+
+type insertTracesRequest struct {
+	traces   pdata.Traces
+	numSpans int
+	received time.Time
+	// errChan receives the result of the write, unless the request is
+	// acknowledged asynchronously.
+	errChan chan error
+}
+
+func (r *insertTracesRequest) reportResult(err error) {
+	if r.errChan != nil {
+		r.errChan <- err
+		return
+	}
+	if err != nil {
+		log.Error("msg", fmt.Sprintf("error on async trace write, dropping %d spans", r.numSpans), "err", err)
+	}
+}
+
+// NewDispatcher returns a Writer that writes the traces in batches to writer.
+// It must be closed to write the buffered traces.
+func NewDispatcher(writer Writer, cfg DispatcherConfig) *Dispatcher {
+	if cfg.NumWriters < 1 {
+		log.Warn("msg", "num trace writers less than 1, setting to 1")
+		cfg.NumWriters = 1
+	}
+	if cfg.MaxBatchSize < 1 {
+		cfg.MaxBatchSize = 1
+	}
+	d := &Dispatcher{
+		writer: writer,
+		cfg:    cfg,
+		in:     make(chan *insertTracesRequest, dispatcherChannelCap),
+	}
+	d.done.Add(cfg.NumWriters)
+	for i := 0; i < cfg.NumWriters; i++ {
+		go func() {
+			defer d.done.Done()
+			d.run()
+		}()
+	}
+	return d
+}
+
+// InsertTraces queues traces to be written with the next batch. Unless the
+// inserts are acknowledged asynchronously, it waits until the batch is written.
+func (d *Dispatcher) InsertTraces(ctx context.Context, traces pdata.Traces) error {
+	numSpans := traces.SpanCount()
+	if numSpans == 0 {
+		return nil
+	}
+	req := &insertTracesRequest{
+		numSpans: numSpans,
+		received: time.Now(),
+	}
+	if d.cfg.AsyncAcks {
+		// The caller may reuse the traces once acknowledged.
+		req.traces = traces.Clone()
+	} else {
+		req.traces = traces
+		req.errChan = make(chan error, 1)
+	}
+
+	select {
+	case d.in <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	dispatcherQueueLength.Set(float64(len(d.in)))
+	if req.errChan == nil {
+		return nil
+	}
+	// The traces are in use until written, so wait regardless of ctx.
+	return <-req.errChan
+}
+
+// Close writes the queued traces and stops the writers.
+func (d *Dispatcher) Close() {
+	close(d.in)
+	d.done.Wait()
+}
+
+func (d *Dispatcher) run() {
+	reqs := make([]*insertTracesRequest, 0, dispatcherChannelCap)
+	for {
+		var ok bool
+		reqs, ok = d.gatherBatch(reqs)
+		if !ok {
+			return
+		}
+		d.write(reqs)
+		for i := range reqs {
+			reqs[i] = nil
+		}
+		reqs = reqs[:0]
+	}
+}
+
+// gatherBatch waits for a request and then gathers requests until the batch
+// has MaxBatchSize spans or BatchTimeout has passed. It returns false when
+// the dispatcher is closed.
+func (d *Dispatcher) gatherBatch(batch []*insertTracesRequest) ([]*insertTracesRequest, bool) {
+	d.gatherMu.Lock()
+	defer d.gatherMu.Unlock()
+	req, ok := <-d.in
+	if !ok {
+		return batch, false
+	}
+	batch = append(batch, req)
+	numSpans := req.numSpans
+
+	timeout := time.NewTimer(d.cfg.BatchTimeout)
+	defer timeout.Stop()
+	for numSpans < d.cfg.MaxBatchSize {
+		select {
+		case req, ok := <-d.in:
+			if !ok {
+				return batch, true
+			}
+			batch = append(batch, req)
+			numSpans += req.numSpans
+		case <-timeout.C:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+// write writes the traces of reqs in a single batch. If that fails, the
+// traces of each request are written separately so that one bad request does
+// not fail the others.
+func (d *Dispatcher) write(reqs []*insertTracesRequest) {
+	traces := reqs[0].traces
+	numSpans := reqs[0].numSpans
+	if len(reqs) > 1 {
+		traces = pdata.NewTraces()
+		rSpans := traces.ResourceSpans()
+		for i, req := range reqs {
+			if i > 0 {
+				numSpans += req.numSpans
+			}
+			reqSpans := req.traces.ResourceSpans()
+			for i := 0; i < reqSpans.Len(); i++ {
+				reqSpans.At(i).CopyTo(rSpans.AppendEmpty())
+			}
+		}
+	}
+
+	dispatcherBatchSpans.Observe(float64(numSpans))
+	start := time.Now()
+	err := d.writer.InsertTraces(context.Background(), traces)
+	dispatcherWriteDuration.Observe(time.Since(start).Seconds())
+	if err != nil && len(reqs) > 1 {
+		log.Debug("msg", "writing batch of traces failed, writing each request separately", "err", err)
+		for _, req := range reqs {
+			d.report(req, d.writer.InsertTraces(context.Background(), req.traces))
+		}
+		return
+	}
+	for _, req := range reqs {
+		d.report(req, err)
+	}
+}
+
+func (d *Dispatcher) report(req *insertTracesRequest, err error) {
+	dispatcherProcessingTime.Observe(time.Since(req.received).Seconds())
+	req.reportResult(err)
+}
@@ -0,0 +1,123 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package trace
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// batchWriter counts the written batches and fails batches with a span
+// named "bad".
+type batchWriter struct {
+	mockWriter
+	batches int
+}
+
+func (w *batchWriter) InsertTraces(ctx context.Context, traces pdata.Traces) error {
+	w.mu.Lock()
+	w.batches++
+	w.mu.Unlock()
+	rSpans := traces.ResourceSpans()
+	for i := 0; i < rSpans.Len(); i++ {
+		spans := rSpans.At(i).InstrumentationLibrarySpans().At(0).Spans()
+		for j := 0; j < spans.Len(); j++ {
+			if spans.At(j).Name() == "bad" {
+				return fmt.Errorf("bad span")
+			}
+		}
+	}
+	return w.mockWriter.InsertTraces(ctx, traces)
+}
+
+func (w *batchWriter) numBatches() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.batches
+}
+
+func insertConcurrently(d *Dispatcher, traces ...pdata.Traces) []error {
+	errs := make([]error, len(traces))
+	var wg sync.WaitGroup
+	wg.Add(len(traces))
+	for i := range traces {
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = d.InsertTraces(context.Background(), traces[i])
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestDispatcherBatching(t *testing.T) {
+	w := &batchWriter{}
+	d := NewDispatcher(w, DispatcherConfig{NumWriters: 1, MaxBatchSize: 3, BatchTimeout: time.Minute})
+	defer d.Close()
+
+	errs := insertConcurrently(d,
+		testSamplingTraces(testSpan{service: "frontend", name: "a"}),
+		testSamplingTraces(testSpan{service: "frontend", name: "b"}),
+		testSamplingTraces(testSpan{service: "backend", name: "c"}),
+	)
+	require.Equal(t, []error{nil, nil, nil}, errs)
+	require.Equal(t, 1, w.numBatches(), "the batch is written once it reaches the max batch size")
+	require.Equal(t, []string{"backend/c", "frontend/a", "frontend/b"}, w.written())
+
+	require.NoError(t, d.InsertTraces(context.Background(), pdata.NewTraces()))
+	require.Equal(t, 1, w.numBatches(), "traces without spans are not written")
+}
+
+func TestDispatcherBatchTimeout(t *testing.T) {
+	w := &batchWriter{}
+	d := NewDispatcher(w, DispatcherConfig{NumWriters: 2, MaxBatchSize: 1000, BatchTimeout: 10 * time.Millisecond})
+	defer d.Close()
+
+	require.NoError(t, d.InsertTraces(context.Background(), testSamplingTraces(testSpan{service: "frontend", name: "a"})))
+	require.Equal(t, []string{"frontend/a"}, w.written())
+}
+
+func TestDispatcherFallback(t *testing.T) {
+	w := &batchWriter{}
+	d := NewDispatcher(w, DispatcherConfig{NumWriters: 1, MaxBatchSize: 3, BatchTimeout: time.Minute})
+	defer d.Close()
+
+	errs := insertConcurrently(d,
+		testSamplingTraces(testSpan{service: "frontend", name: "a"}),
+		testSamplingTraces(testSpan{service: "frontend", name: "bad"}),
+		testSamplingTraces(testSpan{service: "backend", name: "c"}),
+	)
+	numErrs := 0
+	for _, err := range errs {
+		if err != nil {
+			numErrs++
+		}
+	}
+	require.Equal(t, 1, numErrs, "only the request with the bad span fails")
+	require.Equal(t, 4, w.numBatches(), "the failed batch is written again per request")
+	require.Equal(t, []string{"backend/c", "frontend/a"}, w.written())
+}
+
+func TestDispatcherAsyncAcks(t *testing.T) {
+	w := &batchWriter{}
+	d := NewDispatcher(w, DispatcherConfig{NumWriters: 1, MaxBatchSize: 1000, BatchTimeout: time.Minute, AsyncAcks: true})
+
+	traces := testSamplingTraces(testSpan{service: "frontend", name: "a"})
+	require.NoError(t, d.InsertTraces(context.Background(), traces))
+	require.NoError(t, d.InsertTraces(context.Background(), testSamplingTraces(testSpan{service: "frontend", name: "bad"})))
+	require.Empty(t, w.written(), "the traces are written after the batch timeout")
+
+	// The caller may reuse the traces once the insert is acknowledged.
+	traces.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans().At(0).SetName("changed")
+
+	d.Close()
+	require.Equal(t, []string{"frontend/a"}, w.written(), "closing writes the buffered traces")
+}
@@ -24,25 +24,43 @@ type instrumentationLibrary struct {
 
 //instrumentationLibraryBatch queues up items to send to the DB but it sorts before sending
 //this avoids deadlocks in the DB. It also avoids sending the same instrumentation
-//libraries repeatedly.
-type instrumentationLibraryBatch map[instrumentationLibrary]pgtype.Int8
+//libraries repeatedly and the instrumentation libraries whose IDs are cached.
+type instrumentationLibraryBatch struct {
+	b     map[instrumentationLibrary]pgtype.Int8
+	cache *idCache
+}
 
-func newInstrumentationLibraryBatch() instrumentationLibraryBatch {
-	return make(map[instrumentationLibrary]pgtype.Int8)
+func newInstrumentationLibraryBatch(cache *idCache) instrumentationLibraryBatch {
+	return instrumentationLibraryBatch{
+		b:     make(map[instrumentationLibrary]pgtype.Int8),
+		cache: cache,
+	}
 }
 
 func (batch instrumentationLibraryBatch) Queue(name, version string, schemaUrlID pgtype.Int8) {
-	if name != "" {
-		batch[instrumentationLibrary{name, version, schemaUrlID}] = pgtype.Int8{}
+	if name == "" {
+		return
+	}
+	lib := instrumentationLibrary{name, version, schemaUrlID}
+	if _, ok := batch.b[lib]; ok {
+		return
+	}
+	var id pgtype.Int8
+	if cached, ok := batch.cache.get(lib); ok {
+		id = cached.(pgtype.Int8)
 	}
+	batch.b[lib] = id
 }
 
 func (batch instrumentationLibraryBatch) SendBatch(ctx context.Context, conn pgxconn.PgxConn) error {
-	libs := make([]instrumentationLibrary, len(batch))
-	i := 0
-	for lib := range batch {
-		libs[i] = lib
-		i++
+	libs := make([]instrumentationLibrary, 0, len(batch.b))
+	for lib, id := range batch.b {
+		if id.Status != pgtype.Present {
+			libs = append(libs, lib)
+		}
+	}
+	if len(libs) == 0 {
+		return nil
 	}
 	sort.Slice(libs, func(i, j int) bool {
 		if libs[i].name != libs[j].name {
@@ -71,11 +89,16 @@ func (batch instrumentationLibraryBatch) SendBatch(ctx context.Context, conn pgx
 		if err := br.QueryRow().Scan(&id); err != nil {
 			return err
 		}
-		batch[lib] = id
+		batch.b[lib] = id
 	}
 	if err = br.Close(); err != nil {
 		return err
 	}
+	for _, lib := range libs {
+		if id := batch.b[lib]; id.Status == pgtype.Present {
+			batch.cache.insert(lib, id, uint64(len(lib.name)+len(lib.version))+24)
+		}
+	}
 	return nil
 }
 
@@ -83,7 +106,7 @@ func (batch instrumentationLibraryBatch) GetID(name, version string, schemaUrlID
 	if name == "" {
 		return pgtype.Int8{Status: pgtype.Null}, nil
 	}
-	id, ok := batch[instrumentationLibrary{name, version, schemaUrlID}]
+	id, ok := batch.b[instrumentationLibrary{name, version, schemaUrlID}]
 	if !ok {
 		return pgtype.Int8{Status: pgtype.Null}, fmt.Errorf("instrumention library id not found: %s %s", name, version)
 	}
@@ -0,0 +1,90 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package trace
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+const (
+	headPolicy = "head"
+	tailPolicy = "tail"
+
+	keptDecision    = "kept"
+	droppedDecision = "dropped"
+
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+var (
+	samplingSpans = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Name:      "trace_sampling_spans_total",
+			Help:      "Total spans kept or dropped by trace sampling, by the policy that decided.",
+		},
+		[]string{"policy", "decision"},
+	)
+	samplingBufferedTraces = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Name:      "trace_sampling_buffered_traces",
+			Help:      "Number of traces buffered until the tail sampling decision.",
+		},
+	)
+	dispatcherQueueLength = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Name:      "trace_dispatcher_queue_length",
+			Help:      "Number of trace insert requests waiting to be written in a batch.",
+		},
+	)
+	dispatcherBatchSpans = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: util.PromNamespace,
+			Name:      "trace_batch_spans",
+			Help:      "Number of spans in a written batch of traces.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		},
+	)
+	dispatcherWriteDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: util.PromNamespace,
+			Name:      "trace_batch_write_duration_seconds",
+			Help:      "Duration of writing a batch of traces to the database.",
+			Buckets:   prometheus.DefBuckets,
+		},
+	)
+	dispatcherProcessingTime = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: util.PromNamespace,
+			Name:      "trace_insert_processing_time_seconds",
+			Help:      "Time from receiving a trace insert request until its spans are written.",
+			Buckets:   prometheus.DefBuckets,
+		},
+	)
+	cacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Name:      "trace_cache_lookups_total",
+			Help:      "Total lookups in the caches of the IDs of tags, operations, instrumentation libraries and schema URLs of ingested spans, by whether the ID was cached.",
+		},
+		[]string{"cache", "result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		samplingSpans,
+		samplingBufferedTraces,
+		cacheLookups,
+		dispatcherQueueLength,
+		dispatcherBatchSpans,
+		dispatcherWriteDuration,
+		dispatcherProcessingTime,
+	)
+}
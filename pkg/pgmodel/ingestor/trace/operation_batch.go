@@ -23,23 +23,40 @@ type operation struct {
 }
 
 //Operation batch queues up items to send to the db but it sorts before sending
-//this avoids deadlocks in the db
-type operationBatch map[operation]pgtype.Int8
+//this avoids deadlocks in the db. It also avoids sending the operations whose IDs are cached.
+type operationBatch struct {
+	b     map[operation]pgtype.Int8
+	cache *idCache
+}
 
-func newOperationBatch() operationBatch {
-	return make(map[operation]pgtype.Int8)
+func newOperationBatch(cache *idCache) operationBatch {
+	return operationBatch{
+		b:     make(map[operation]pgtype.Int8),
+		cache: cache,
+	}
 }
 
 func (o operationBatch) Queue(serviceName, spanName, spanKind string) {
-	o[operation{serviceName, spanName, spanKind}] = pgtype.Int8{}
+	op := operation{serviceName, spanName, spanKind}
+	if _, ok := o.b[op]; ok {
+		return
+	}
+	var id pgtype.Int8
+	if cached, ok := o.cache.get(op); ok {
+		id = cached.(pgtype.Int8)
+	}
+	o.b[op] = id
 }
 
 func (batch operationBatch) SendBatch(ctx context.Context, conn pgxconn.PgxConn) error {
-	ops := make([]operation, len(batch))
-	i := 0
-	for op := range batch {
-		ops[i] = op
-		i++
+	ops := make([]operation, 0, len(batch.b))
+	for op, id := range batch.b {
+		if id.Status != pgtype.Present {
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) == 0 {
+		return nil
 	}
 	sort.Slice(ops, func(i, j int) bool {
 		if ops[i].serviceName != ops[j].serviceName {
@@ -64,15 +81,20 @@ func (batch operationBatch) SendBatch(ctx context.Context, conn pgxconn.PgxConn)
 		if err := br.QueryRow().Scan(&id); err != nil {
 			return err
 		}
-		batch[op] = id
+		batch.b[op] = id
 	}
 	if err = br.Close(); err != nil {
 		return err
 	}
+	for _, op := range ops {
+		if id := batch.b[op]; id.Status == pgtype.Present {
+			batch.cache.insert(op, id, uint64(len(op.serviceName)+len(op.spanName)+len(op.spanKind))+8)
+		}
+	}
 	return nil
 }
 func (batch operationBatch) GetID(serviceName, spanName, spanKind string) (pgtype.Int8, error) {
-	id, ok := batch[operation{serviceName, spanName, spanKind}]
+	id, ok := batch.b[operation{serviceName, spanName, spanKind}]
 	if !ok {
 		return pgtype.Int8{Status: pgtype.Null}, fmt.Errorf("operation id not found: %s %s %s", serviceName, spanName, spanKind)
 	}
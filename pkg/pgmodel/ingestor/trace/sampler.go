@@ -0,0 +1,328 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package trace
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/timescale/promscale/pkg/clockcache"
+	"github.com/timescale/promscale/pkg/log"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+const maxDecisionTick = time.Second
+
+// SamplingConfig configures the sampling of traces before they are written.
+type SamplingConfig struct {
+	// Rate is the probability of keeping a trace, for the spans of services
+	// without a rate in ServiceRates.
+	Rate         float64
+	ServiceRates map[string]float64
+
+	// The spans dropped by head sampling are buffered per trace for
+	// DecisionWait if any of the tail sampling rules is set, and kept if the
+	// trace has an error span, lasts at least LatencyThreshold or has a span
+	// or resource attribute of KeepAttributes.
+	KeepErrors       bool
+	LatencyThreshold time.Duration
+	KeepAttributes   map[string]string
+	DecisionWait     time.Duration
+	// MaxTraces bounds the number of buffered traces. When the buffer is
+	// full, the oldest trace is decided early.
+	MaxTraces int
+}
+
+// Enabled returns whether any spans may be dropped.
+func (c SamplingConfig) Enabled() bool {
+	if c.Rate < 1 || c.TailSampling() {
+		return true
+	}
+	for _, rate := range c.ServiceRates {
+		if rate < 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// TailSampling returns whether any of the tail sampling rules is set.
+func (c SamplingConfig) TailSampling() bool {
+	return c.KeepErrors || c.LatencyThreshold > 0 || len(c.KeepAttributes) > 0
+}
+
+// Sampler is a Writer that samples traces before writing them with another Writer.
+type Sampler struct {
+	writer Writer
+	cfg    SamplingConfig
+
+	mu     sync.Mutex
+	buffer map[[16]byte]*bufferedTrace
+	// queue holds the buffered traces in the order of their decision time.
+	queue []*bufferedTrace
+	// decisions holds whether the recently decided traces were kept, to
+	// decide their late spans the same way.
+	decisions *clockcache.Cache
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type bufferedTrace struct {
+	id         [16]byte
+	deadline   time.Time
+	traces     pdata.Traces
+	numSpans   int
+	start, end time.Time
+	matched    bool
+}
+
+type spanSliceKey struct {
+	traceID        [16]byte
+	rSpanIdx       int
+	instLibSpanIdx int
+}
+
+// NewSampler returns a Writer that samples traces as configured by cfg
+// before writing them to writer. It must be closed to write the buffered
+// traces that are kept by tail sampling.
+func NewSampler(writer Writer, cfg SamplingConfig) *Sampler {
+	s := &Sampler{
+		writer: writer,
+		cfg:    cfg,
+	}
+	if cfg.TailSampling() {
+		s.buffer = make(map[[16]byte]*bufferedTrace)
+		s.decisions = clockcache.WithMax(uint64(cfg.MaxTraces))
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		tick := cfg.DecisionWait
+		if tick > maxDecisionTick {
+			tick = maxDecisionTick
+		}
+		go s.run(tick)
+	}
+	return s
+}
+
+// InsertTraces writes the spans of traces that are kept by head sampling or by
+// an earlier tail sampling decision, and buffers the spans that await a tail
+// sampling decision.
+func (s *Sampler) InsertTraces(ctx context.Context, traces pdata.Traces) error {
+	var (
+		kept          = pdata.NewTraces()
+		keptSlices    = make(map[spanSliceKey]pdata.SpanSlice)
+		pendingSlices = make(map[spanSliceKey]pdata.SpanSlice)
+		numKept       int
+		numAll        int
+		pending       []spanSliceKey
+		spans         []pdata.Span
+	)
+
+	rSpans := traces.ResourceSpans()
+	for i := 0; i < rSpans.Len(); i++ {
+		rSpan := rSpans.At(i)
+		rate := s.rate(ServiceName(rSpan))
+		instLibSpans := rSpan.InstrumentationLibrarySpans()
+		for j := 0; j < instLibSpans.Len(); j++ {
+			instLibSpan := instLibSpans.At(j)
+			ilSpans := instLibSpan.Spans()
+			for k := 0; k < ilSpans.Len(); k++ {
+				span := ilSpans.At(k)
+				numAll++
+				traceID := span.TraceID().Bytes()
+				if headSample(traceID, rate) {
+					copySpan(kept, keptSlices, spanSliceKey{rSpanIdx: i, instLibSpanIdx: j}, rSpan, instLibSpan, span)
+					numKept++
+					continue
+				}
+				if s.buffer == nil {
+					continue
+				}
+				pending = append(pending, spanSliceKey{traceID, i, j})
+				spans = append(spans, span)
+			}
+		}
+	}
+	samplingSpans.WithLabelValues(headPolicy, keptDecision).Add(float64(numKept))
+	if s.buffer == nil {
+		samplingSpans.WithLabelValues(headPolicy, droppedDecision).Add(float64(numAll - numKept))
+	}
+
+	if len(pending) > 0 {
+		now := time.Now()
+		s.mu.Lock()
+		for n, key := range pending {
+			rSpan := rSpans.At(key.rSpanIdx)
+			instLibSpan := rSpan.InstrumentationLibrarySpans().At(key.instLibSpanIdx)
+			if decision, ok := s.decisions.Get(key.traceID); ok {
+				if decision.(bool) {
+					copySpan(kept, keptSlices, spanSliceKey{rSpanIdx: key.rSpanIdx, instLibSpanIdx: key.instLibSpanIdx}, rSpan, instLibSpan, spans[n])
+					samplingSpans.WithLabelValues(tailPolicy, keptDecision).Inc()
+				} else {
+					samplingSpans.WithLabelValues(tailPolicy, droppedDecision).Inc()
+				}
+				continue
+			}
+			bt, ok := s.buffer[key.traceID]
+			if !ok {
+				if len(s.buffer) >= s.cfg.MaxTraces {
+					s.decideOldest(kept)
+				}
+				bt = &bufferedTrace{id: key.traceID, deadline: now.Add(s.cfg.DecisionWait), traces: pdata.NewTraces()}
+				s.buffer[key.traceID] = bt
+				s.queue = append(s.queue, bt)
+			}
+			copySpan(bt.traces, pendingSlices, key, rSpan, instLibSpan, spans[n])
+			s.observe(bt, rSpan, spans[n])
+		}
+		samplingBufferedTraces.Set(float64(len(s.buffer)))
+		s.mu.Unlock()
+	}
+
+	if numKept == numAll {
+		// Write the original traces if head sampling kept all the spans.
+		return s.writer.InsertTraces(ctx, traces)
+	}
+	if kept.SpanCount() == 0 {
+		return nil
+	}
+	return s.writer.InsertTraces(ctx, kept)
+}
+
+// Close writes the buffered traces that are kept by tail sampling.
+func (s *Sampler) Close() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Sampler) run(tick time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush(time.Now())
+		case <-s.stop:
+			s.flush(time.Time{})
+			return
+		}
+	}
+}
+
+// flush decides the buffered traces whose decision time is before now, or
+// all of them if now is zero, and writes the kept ones.
+func (s *Sampler) flush(now time.Time) {
+	kept := pdata.NewTraces()
+	s.mu.Lock()
+	for len(s.queue) > 0 && (now.IsZero() || s.queue[0].deadline.Before(now)) {
+		s.decideOldest(kept)
+	}
+	samplingBufferedTraces.Set(float64(len(s.buffer)))
+	s.mu.Unlock()
+
+	if kept.SpanCount() == 0 {
+		return
+	}
+	if err := s.writer.InsertTraces(context.Background(), kept); err != nil {
+		log.Error("msg", "Error writing traces kept by tail sampling", "err", err)
+	}
+}
+
+// decideOldest removes the oldest trace from the buffer and moves its spans to
+// kept if a tail sampling rule matches. It must be called with s.mu held.
+func (s *Sampler) decideOldest(kept pdata.Traces) {
+	bt := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	delete(s.buffer, bt.id)
+
+	keep := bt.matched || (s.cfg.LatencyThreshold > 0 && bt.end.Sub(bt.start) >= s.cfg.LatencyThreshold)
+	s.decisions.Insert(bt.id, keep, uint64(len(bt.id))+1)
+	if !keep {
+		samplingSpans.WithLabelValues(tailPolicy, droppedDecision).Add(float64(bt.numSpans))
+		return
+	}
+	samplingSpans.WithLabelValues(tailPolicy, keptDecision).Add(float64(bt.numSpans))
+	bt.traces.ResourceSpans().MoveAndAppendTo(kept.ResourceSpans())
+}
+
+// observe updates the buffered trace with the properties of span that the
+// tail sampling rules look at. It must be called with s.mu held.
+func (s *Sampler) observe(bt *bufferedTrace, rSpan pdata.ResourceSpans, span pdata.Span) {
+	start, end := span.StartTimestamp().AsTime(), span.EndTimestamp().AsTime()
+	if bt.numSpans == 0 || start.Before(bt.start) {
+		bt.start = start
+	}
+	if bt.numSpans == 0 || end.After(bt.end) {
+		bt.end = end
+	}
+	bt.numSpans++
+	if bt.matched {
+		return
+	}
+	if s.cfg.KeepErrors && span.Status().Code() == pdata.StatusCodeError {
+		bt.matched = true
+		return
+	}
+	bt.matched = hasAttribute(span.Attributes(), s.cfg.KeepAttributes) ||
+		hasAttribute(rSpan.Resource().Attributes(), s.cfg.KeepAttributes)
+}
+
+func (s *Sampler) rate(serviceName string) float64 {
+	if rate, ok := s.cfg.ServiceRates[serviceName]; ok {
+		return rate
+	}
+	return s.cfg.Rate
+}
+
+func hasAttribute(attrs pdata.AttributeMap, want map[string]string) bool {
+	for key, value := range want {
+		if av, ok := attrs.Get(key); ok && av.AsString() == value {
+			return true
+		}
+	}
+	return false
+}
+
+// headSample decides whether to keep a span by its trace ID, so that all the
+// spans of a trace are decided the same way, also by other Promscale instances.
+// A trace kept at a rate is kept by the services with higher rates too.
+func headSample(traceID [16]byte, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(traceID[:])
+	return float64(h.Sum64()) < rate*math.MaxUint64
+}
+
+// copySpan copies span with its resource and instrumentation library to dest.
+// The copies of the resource and instrumentation library are shared by the
+// spans with the same key.
+func copySpan(dest pdata.Traces, slices map[spanSliceKey]pdata.SpanSlice, key spanSliceKey, rSpan pdata.ResourceSpans, instLibSpan pdata.InstrumentationLibrarySpans, span pdata.Span) {
+	spans, ok := slices[key]
+	if !ok {
+		rs := dest.ResourceSpans().AppendEmpty()
+		rSpan.Resource().CopyTo(rs.Resource())
+		rs.SetSchemaUrl(rSpan.SchemaUrl())
+		ils := rs.InstrumentationLibrarySpans().AppendEmpty()
+		instLibSpan.InstrumentationLibrary().CopyTo(ils.InstrumentationLibrary())
+		ils.SetSchemaUrl(instLibSpan.SchemaUrl())
+		spans = ils.Spans()
+		slices[key] = spans
+	}
+	span.CopyTo(spans.AppendEmpty())
+}
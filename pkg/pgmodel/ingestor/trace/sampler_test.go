@@ -0,0 +1,165 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package trace
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+type mockWriter struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (m *mockWriter) InsertTraces(_ context.Context, traces pdata.Traces) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rSpans := traces.ResourceSpans()
+	for i := 0; i < rSpans.Len(); i++ {
+		service := ServiceName(rSpans.At(i))
+		instLibSpans := rSpans.At(i).InstrumentationLibrarySpans()
+		for j := 0; j < instLibSpans.Len(); j++ {
+			spans := instLibSpans.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				m.spans = append(m.spans, service+"/"+spans.At(k).Name())
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockWriter) written() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	spans := append([]string(nil), m.spans...)
+	sort.Strings(spans)
+	return spans
+}
+
+type testSpan struct {
+	service  string
+	name     string
+	traceID  byte
+	duration time.Duration
+	err      bool
+	attrs    map[string]string
+}
+
+func testSamplingTraces(spans ...testSpan) pdata.Traces {
+	traces := pdata.NewTraces()
+	start := time.Unix(1600000000, 0)
+	for _, s := range spans {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().InsertString("service.name", s.service)
+		span := rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetTraceID(pdata.NewTraceID([16]byte{15: s.traceID}))
+		span.SetName(s.name)
+		span.SetStartTimestamp(pdata.NewTimestampFromTime(start))
+		span.SetEndTimestamp(pdata.NewTimestampFromTime(start.Add(s.duration)))
+		if s.err {
+			span.Status().SetCode(pdata.StatusCodeError)
+		}
+		for k, v := range s.attrs {
+			span.Attributes().InsertString(k, v)
+		}
+	}
+	return traces
+}
+
+func TestSamplingConfigEnabled(t *testing.T) {
+	require.False(t, SamplingConfig{Rate: 1}.Enabled())
+	require.False(t, SamplingConfig{Rate: 1, ServiceRates: map[string]float64{"frontend": 1}}.Enabled())
+	require.True(t, SamplingConfig{Rate: 0.5}.Enabled())
+	require.True(t, SamplingConfig{Rate: 1, ServiceRates: map[string]float64{"frontend": 0}}.Enabled())
+	require.True(t, SamplingConfig{Rate: 1, KeepErrors: true}.Enabled())
+}
+
+func TestHeadSample(t *testing.T) {
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		id := [16]byte{byte(i), byte(i >> 8), 7}
+		decision := headSample(id, 0.3)
+		require.Equal(t, decision, headSample(id, 0.3), "decisions must be deterministic")
+		if decision {
+			kept++
+			require.True(t, headSample(id, 0.6), "a trace kept at a rate must be kept at higher rates")
+		}
+	}
+	require.InDelta(t, 3000, kept, 300)
+	require.True(t, headSample([16]byte{1}, 1))
+	require.False(t, headSample([16]byte{1}, 0))
+}
+
+func TestSamplerHeadSampling(t *testing.T) {
+	w := &mockWriter{}
+	s := NewSampler(w, SamplingConfig{Rate: 0, ServiceRates: map[string]float64{"backend": 1}})
+	defer s.Close()
+
+	err := s.InsertTraces(context.Background(), testSamplingTraces(
+		testSpan{service: "frontend", name: "a", traceID: 1},
+		testSpan{service: "backend", name: "b", traceID: 1},
+		testSpan{service: "backend", name: "c", traceID: 2},
+	))
+	require.NoError(t, err)
+	require.Equal(t, []string{"backend/b", "backend/c"}, w.written())
+}
+
+func TestSamplerTailSampling(t *testing.T) {
+	w := &mockWriter{}
+	s := NewSampler(w, SamplingConfig{
+		Rate:             0,
+		KeepErrors:       true,
+		LatencyThreshold: time.Second,
+		KeepAttributes:   map[string]string{"tenant": "acme"},
+		DecisionWait:     time.Hour,
+		MaxTraces:        100,
+	})
+
+	err := s.InsertTraces(context.Background(), testSamplingTraces(
+		testSpan{service: "frontend", name: "error", traceID: 1},
+		testSpan{service: "backend", name: "error", traceID: 1, err: true},
+		testSpan{service: "frontend", name: "slow", traceID: 2, duration: 2 * time.Second},
+		testSpan{service: "frontend", name: "attr", traceID: 3, attrs: map[string]string{"tenant": "acme"}},
+		testSpan{service: "frontend", name: "other-attr", traceID: 4, attrs: map[string]string{"tenant": "other"}},
+		testSpan{service: "frontend", name: "fast", traceID: 5, duration: time.Millisecond},
+	))
+	require.NoError(t, err)
+	require.Empty(t, w.written(), "spans must be buffered until the decision")
+
+	s.flush(time.Now().Add(2 * time.Hour))
+	expected := []string{"backend/error", "frontend/attr", "frontend/error", "frontend/slow"}
+	require.Equal(t, expected, w.written())
+
+	// Late spans follow the decision of their trace.
+	err = s.InsertTraces(context.Background(), testSamplingTraces(
+		testSpan{service: "backend", name: "late", traceID: 1},
+		testSpan{service: "backend", name: "late", traceID: 5, err: true},
+	))
+	require.NoError(t, err)
+	s.Close()
+	require.Equal(t, []string{"backend/error", "backend/late", "frontend/attr", "frontend/error", "frontend/slow"}, w.written())
+}
+
+func TestSamplerTailSamplingBufferFull(t *testing.T) {
+	w := &mockWriter{}
+	s := NewSampler(w, SamplingConfig{Rate: 0, KeepErrors: true, DecisionWait: time.Hour, MaxTraces: 1})
+	defer s.Close()
+
+	err := s.InsertTraces(context.Background(), testSamplingTraces(testSpan{service: "frontend", name: "a", traceID: 1, err: true}))
+	require.NoError(t, err)
+	require.Empty(t, w.written())
+
+	// Buffering a second trace decides the first one early.
+	err = s.InsertTraces(context.Background(), testSamplingTraces(testSpan{service: "frontend", name: "b", traceID: 2}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"frontend/a"}, w.written())
+}
@@ -19,25 +19,43 @@ const insertSchemaURLSQL = `SELECT %s.put_schema_url($1)`
 type schemaURL string
 
 //schemaURLBatch queues up items to send to the DB but it sorts before sending
-//this avoids deadlocks in the DB. It also avoids sending the same URLs repeatedly.
-type schemaURLBatch map[schemaURL]pgtype.Int8
+//this avoids deadlocks in the DB. It also avoids sending the same URLs repeatedly
+//and the URLs whose IDs are cached.
+type schemaURLBatch struct {
+	b     map[schemaURL]pgtype.Int8
+	cache *idCache
+}
 
-func newSchemaUrlBatch() schemaURLBatch {
-	return make(map[schemaURL]pgtype.Int8)
+func newSchemaUrlBatch(cache *idCache) schemaURLBatch {
+	return schemaURLBatch{
+		b:     make(map[schemaURL]pgtype.Int8),
+		cache: cache,
+	}
 }
 
 func (batch schemaURLBatch) Queue(url string) {
-	if url != "" {
-		batch[schemaURL(url)] = pgtype.Int8{}
+	if url == "" {
+		return
+	}
+	if _, ok := batch.b[schemaURL(url)]; ok {
+		return
+	}
+	var id pgtype.Int8
+	if cached, ok := batch.cache.get(schemaURL(url)); ok {
+		id = cached.(pgtype.Int8)
 	}
+	batch.b[schemaURL(url)] = id
 }
 
 func (batch schemaURLBatch) SendBatch(ctx context.Context, conn pgxconn.PgxConn) error {
-	urls := make([]schemaURL, len(batch))
-	i := 0
-	for url := range batch {
-		urls[i] = url
-		i++
+	urls := make([]schemaURL, 0, len(batch.b))
+	for url, id := range batch.b {
+		if id.Status != pgtype.Present {
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) == 0 {
+		return nil
 	}
 	sort.Slice(urls, func(i, j int) bool {
 		return urls[i] < urls[j]
@@ -57,11 +75,16 @@ func (batch schemaURLBatch) SendBatch(ctx context.Context, conn pgxconn.PgxConn)
 		if err := br.QueryRow().Scan(&id); err != nil {
 			return err
 		}
-		batch[sURL] = id
+		batch.b[sURL] = id
 	}
 	if err = br.Close(); err != nil {
 		return err
 	}
+	for _, sURL := range urls {
+		if id := batch.b[sURL]; id.Status == pgtype.Present {
+			batch.cache.insert(sURL, id, uint64(len(sURL))+8)
+		}
+	}
 	return nil
 }
 
@@ -69,7 +92,7 @@ func (batch schemaURLBatch) GetID(url string) (pgtype.Int8, error) {
 	if url == "" {
 		return pgtype.Int8{Status: pgtype.Null}, nil
 	}
-	id, ok := batch[schemaURL(url)]
+	id, ok := batch.b[schemaURL(url)]
 	if !ok {
 		return pgtype.Int8{Status: pgtype.Null}, fmt.Errorf("schema url id not found")
 	}
@@ -32,11 +32,18 @@ type tagIDs struct {
 }
 
 //tagBatch queues up items to send to the db but it sorts before sending
-//this avoids deadlocks in the db. It also avoids sending the same tags repeatedly.
-type tagBatch map[tag]tagIDs
+//this avoids deadlocks in the db. It also avoids sending the same tags repeatedly
+//and the tags whose IDs are cached.
+type tagBatch struct {
+	b     map[tag]tagIDs
+	cache *idCache
+}
 
-func newTagBatch() tagBatch {
-	return make(map[tag]tagIDs)
+func newTagBatch(cache *idCache) tagBatch {
+	return tagBatch{
+		b:     make(map[tag]tagIDs),
+		cache: cache,
+	}
 }
 
 func (batch tagBatch) Queue(tags map[string]interface{}, typ TagType) error {
@@ -45,17 +52,28 @@ func (batch tagBatch) Queue(tags map[string]interface{}, typ TagType) error {
 		if err != nil {
 			return err
 		}
-		batch[tag{k, string(byteVal), typ}] = tagIDs{}
+		t := tag{k, string(byteVal), typ}
+		if _, ok := batch.b[t]; ok {
+			continue
+		}
+		var ids tagIDs
+		if cached, ok := batch.cache.get(t); ok {
+			ids = cached.(tagIDs)
+		}
+		batch.b[t] = ids
 	}
 	return nil
 }
 
 func (batch tagBatch) SendBatch(ctx context.Context, conn pgxconn.PgxConn) error {
-	tags := make([]tag, len(batch))
-	i := 0
-	for op := range batch {
-		tags[i] = op
-		i++
+	tags := make([]tag, 0, len(batch.b))
+	for t, ids := range batch.b {
+		if ids.keyID.Status != pgtype.Present || ids.valueID.Status != pgtype.Present {
+			tags = append(tags, t)
+		}
+	}
+	if len(tags) == 0 {
+		return nil
 	}
 	sort.Slice(tags, func(i, j int) bool {
 		if tags[i].key != tags[j].key {
@@ -90,11 +108,16 @@ func (batch tagBatch) SendBatch(ctx context.Context, conn pgxconn.PgxConn) error
 		if err := br.QueryRow().Scan(&valueID); err != nil {
 			return err
 		}
-		batch[tag] = tagIDs{keyID: keyID, valueID: valueID}
+		batch.b[tag] = tagIDs{keyID: keyID, valueID: valueID}
 	}
 	if err = br.Close(); err != nil {
 		return err
 	}
+	for _, t := range tags {
+		if ids := batch.b[t]; ids.keyID.Status == pgtype.Present && ids.valueID.Status == pgtype.Present {
+			batch.cache.insert(t, ids, uint64(len(t.key)+len(t.value))+24)
+		}
+	}
 	return nil
 }
 
@@ -105,7 +128,7 @@ func (batch tagBatch) GetTagMapJSON(tags map[string]interface{}, typ TagType) ([
 		if err != nil {
 			return nil, err
 		}
-		ids, ok := batch[tag{k, string(byteVal), typ}]
+		ids, ok := batch.b[tag{k, string(byteVal), typ}]
 		if !ok {
 			return nil, fmt.Errorf("tag id not found: %s %v(rendered as %s) %v", k, v, string(byteVal), typ)
 
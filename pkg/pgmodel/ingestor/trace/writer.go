@@ -11,6 +11,9 @@ import (
 	"time"
 
 	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/cache"
 	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
 	"github.com/timescale/promscale/pkg/pgxconn"
 	"go.opentelemetry.io/collector/model/pdata"
@@ -30,79 +33,133 @@ const (
 )
 
 const (
-	insertSpanLinkSQL = `INSERT INTO %s.link (trace_id, span_id, span_start_time, linked_trace_id, linked_span_id, trace_state, tags, dropped_tags_count, link_nbr)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
-	insertSpanEventSQL = `INSERT INTO %s.event (time, trace_id, span_id, name, event_nbr, tags, dropped_tags_count)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`
 	insertSpanSQL = `INSERT INTO %s.span (trace_id, span_id, trace_state, parent_span_id, operation_id, start_time, end_time, span_tags, dropped_tags_count,
 		event_time, dropped_events_count, dropped_link_count, status_code, status_message, instrumentation_lib_id, resource_tags, resource_dropped_tags_count, resource_schema_url_id)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
-		ON CONFLICT DO NOTHING`  // Most cases conflict only happens on retries, safe to ignore duplicate data.
+		ON CONFLICT DO NOTHING
+		RETURNING true`  // Most cases conflict only happens on retries, safe to ignore duplicate data.
+)
+
+var (
+	spanColumns = []string{"trace_id", "span_id", "trace_state", "parent_span_id", "operation_id", "start_time", "end_time", "span_tags", "dropped_tags_count",
+		"event_time", "dropped_events_count", "dropped_link_count", "status_code", "status_message", "instrumentation_lib_id", "resource_tags", "resource_dropped_tags_count", "resource_schema_url_id"}
+	linkColumns  = []string{"trace_id", "span_id", "span_start_time", "linked_trace_id", "linked_span_id", "trace_state", "tags", "dropped_tags_count", "link_nbr"}
+	eventColumns = []string{"time", "trace_id", "span_id", "name", "event_nbr", "tags", "dropped_tags_count"}
 )
 
 type Writer interface {
 	InsertTraces(ctx context.Context, traces pdata.Traces) error
 }
 
+// WriterConfig configures a Writer.
+type WriterConfig struct {
+	// AttributeRules are applied to the attributes of the spans.
+	AttributeRules AttributeRules
+	// AttributeHashKey is the secret key of the HMAC-SHA256 hash of the
+	// attribute values hashed by the attribute rules.
+	AttributeHashKey []byte
+	// Caches hold the IDs of the stored tags, operations, instrumentation
+	// libraries and schema URLs. Caches of the default sizes are used if nil.
+	Caches *Caches
+}
+
 type traceWriterImpl struct {
-	conn pgxconn.PgxConn
+	conn    pgxconn.PgxConn
+	rules   AttributeRules
+	hashKey []byte
+	caches  *Caches
 }
 
-func NewWriter(conn pgxconn.PgxConn) *traceWriterImpl {
+// NewWriter returns a Writer that stores traces with conn.
+func NewWriter(conn pgxconn.PgxConn, cfg WriterConfig) *traceWriterImpl {
+	caches := cfg.Caches
+	if caches == nil {
+		caches = NewCaches(cache.DefaultConfig)
+	}
 	return &traceWriterImpl{
-		conn: conn,
+		conn:    conn,
+		rules:   cfg.AttributeRules,
+		hashKey: cfg.AttributeHashKey,
+		caches:  caches,
 	}
 }
 
-func (t *traceWriterImpl) queueSpanLinks(linkBatch pgxconn.PgxBatch, tagsBatch tagBatch, links pdata.SpanLinkSlice, traceID pgtype.UUID, spanID pgtype.Int8, spanStartTime time.Time) error {
+// attributes returns the attributes with the attribute rules applied.
+func (t *traceWriterImpl) attributes(attrs pdata.AttributeMap) map[string]interface{} {
+	return t.rules.Apply(attrs.AsRaw(), t.hashKey)
+}
+
+// spanAttributes holds the attributes of a span and of its events and links
+// with the attribute rules applied, so that the rules are applied only once
+// per span.
+type spanAttributes struct {
+	span   map[string]interface{}
+	events []map[string]interface{}
+	links  []map[string]interface{}
+}
+
+func (t *traceWriterImpl) spanAttributes(span pdata.Span) spanAttributes {
+	attrs := spanAttributes{
+		span:   t.attributes(span.Attributes()),
+		events: make([]map[string]interface{}, span.Events().Len()),
+		links:  make([]map[string]interface{}, span.Links().Len()),
+	}
+	for i := range attrs.events {
+		attrs.events[i] = t.attributes(span.Events().At(i).Attributes())
+	}
+	for i := range attrs.links {
+		attrs.links[i] = t.attributes(span.Links().At(i).Attributes())
+	}
+	return attrs
+}
+
+func (t *traceWriterImpl) appendSpanLinks(rows [][]interface{}, tagsBatch tagBatch, links pdata.SpanLinkSlice, linkAttrs []map[string]interface{}, traceID pgtype.UUID, spanID pgtype.Int8, spanStartTime time.Time) ([][]interface{}, error) {
 	for i := 0; i < links.Len(); i++ {
 		link := links.At(i)
 		linkedSpanID := getSpanID(link.SpanID().Bytes())
 
-		jsonTags, err := tagsBatch.GetTagMapJSON(link.Attributes().AsRaw(), LinkTagType)
+		jsonTags, err := tagsBatch.GetTagMapJSON(linkAttrs[i], LinkTagType)
 		if err != nil {
-			return err
+			return rows, err
 		}
-		linkBatch.Queue(fmt.Sprintf(insertSpanLinkSQL, schema.Trace),
+		rows = append(rows, []interface{}{
 			traceID,
 			spanID,
 			spanStartTime,
 			TraceIDToUUID(link.TraceID().Bytes()),
 			linkedSpanID,
 			getTraceStateValue(link.TraceState()),
-			string(jsonTags),
+			getTagMap(jsonTags),
 			link.DroppedAttributesCount(),
 			i,
-		)
+		})
 	}
-	return nil
+	return rows, nil
 }
 
-func (t *traceWriterImpl) queueSpanEvents(eventBatch pgxconn.PgxBatch, tagsBatch tagBatch, events pdata.SpanEventSlice, traceID pgtype.UUID, spanID pgtype.Int8) error {
+func (t *traceWriterImpl) appendSpanEvents(rows [][]interface{}, tagsBatch tagBatch, events pdata.SpanEventSlice, eventAttrs []map[string]interface{}, traceID pgtype.UUID, spanID pgtype.Int8) ([][]interface{}, error) {
 	for i := 0; i < events.Len(); i++ {
 		event := events.At(i)
-		jsonTags, err := tagsBatch.GetTagMapJSON(event.Attributes().AsRaw(), EventTagType)
+		jsonTags, err := tagsBatch.GetTagMapJSON(eventAttrs[i], EventTagType)
 		if err != nil {
-			return err
+			return rows, err
 		}
-		eventBatch.Queue(fmt.Sprintf(insertSpanEventSQL, schema.Trace),
+		rows = append(rows, []interface{}{
 			event.Timestamp().AsTime(),
 			traceID,
 			spanID,
 			event.Name(),
 			i,
-			string(jsonTags),
+			getTagMap(jsonTags),
 			event.DroppedAttributesCount(),
-		)
-
-		if err != nil {
-			return err
-		}
+		})
 	}
-	return nil
+	return rows, nil
 }
 
-func getServiceName(rSpan pdata.ResourceSpans) string {
+// ServiceName returns the service.name resource attribute of the spans, or a
+// placeholder if the resource has no service name.
+func ServiceName(rSpan pdata.ResourceSpans) string {
 	serviceName := missingServiceName
 	av, found := rSpan.Resource().Attributes().Get(serviceNameTagKey)
 	if found {
@@ -114,7 +171,7 @@ func getServiceName(rSpan pdata.ResourceSpans) string {
 func (t *traceWriterImpl) InsertTraces(ctx context.Context, traces pdata.Traces) error {
 	rSpans := traces.ResourceSpans()
 
-	sURLBatch := newSchemaUrlBatch()
+	sURLBatch := newSchemaUrlBatch(t.caches.schemaURLs)
 	for i := 0; i < rSpans.Len(); i++ {
 		rSpan := rSpans.At(i)
 		url := rSpan.SchemaUrl()
@@ -131,12 +188,15 @@ func (t *traceWriterImpl) InsertTraces(ctx context.Context, traces pdata.Traces)
 		return err
 	}
 
-	instrLibBatch := newInstrumentationLibraryBatch()
-	operationBatch := newOperationBatch()
-	tagsBatch := newTagBatch()
+	instrLibBatch := newInstrumentationLibraryBatch(t.caches.instLibs)
+	operationBatch := newOperationBatch(t.caches.operations)
+	tagsBatch := newTagBatch(t.caches.tags)
+	resourceAttrs := make([]map[string]interface{}, rSpans.Len())
+	var spanAttrs []spanAttributes
 	for i := 0; i < rSpans.Len(); i++ {
 		rSpan := rSpans.At(i)
-		serviceName := getServiceName(rSpan)
+		serviceName := ServiceName(rSpan)
+		resourceAttrs[i] = t.attributes(rSpan.Resource().Attributes())
 		instLibSpans := rSpan.InstrumentationLibrarySpans()
 		for j := 0; j < instLibSpans.Len(); j++ {
 			instLibSpan := instLibSpans.At(j)
@@ -156,32 +216,25 @@ func (t *traceWriterImpl) InsertTraces(ctx context.Context, traces pdata.Traces)
 
 				operationBatch.Queue(serviceName, spanName, spanKind)
 
-				rawResourceTags := rSpan.Resource().Attributes().AsRaw()
-				if err := tagsBatch.Queue(rawResourceTags, ResourceTagType); err != nil {
+				if err := tagsBatch.Queue(resourceAttrs[i], ResourceTagType); err != nil {
 					return err
 				}
 
-				rawSpanTags := span.Attributes().AsRaw()
-				if err := tagsBatch.Queue(rawSpanTags, SpanTagType); err != nil {
+				attrs := t.spanAttributes(span)
+				spanAttrs = append(spanAttrs, attrs)
+				if err := tagsBatch.Queue(attrs.span, SpanTagType); err != nil {
 					return err
 				}
-				for i := 0; i < span.Events().Len(); i++ {
-					event := span.Events().At(i)
-					rawTags := event.Attributes().AsRaw()
+				for _, rawTags := range attrs.events {
 					if err := tagsBatch.Queue(rawTags, EventTagType); err != nil {
 						return err
 					}
 				}
-
-				for i := 0; i < span.Links().Len(); i++ {
-					link := span.Links().At(i)
-
-					rawTags := link.Attributes().AsRaw()
+				for _, rawTags := range attrs.links {
 					if err := tagsBatch.Queue(rawTags, LinkTagType); err != nil {
 						return err
 					}
 				}
-
 			}
 		}
 	}
@@ -195,13 +248,12 @@ func (t *traceWriterImpl) InsertTraces(ctx context.Context, traces pdata.Traces)
 		return err
 	}
 
-	spanBatch := t.conn.NewBatch()
-	linkBatch := t.conn.NewBatch()
-	eventBatch := t.conn.NewBatch()
+	var spanRows, linkRows, eventRows [][]interface{}
+	spanIdx := 0
 	for i := 0; i < rSpans.Len(); i++ {
 		rSpan := rSpans.At(i)
 		instLibSpans := rSpan.InstrumentationLibrarySpans()
-		serviceName := getServiceName(rSpan)
+		serviceName := ServiceName(rSpan)
 
 		url := rSpan.SchemaUrl()
 		rSchemaURLID, err := sURLBatch.GetID(url)
@@ -234,27 +286,29 @@ func (t *traceWriterImpl) InsertTraces(ctx context.Context, traces pdata.Traces)
 					return err
 				}
 
-				if err := t.queueSpanEvents(eventBatch, tagsBatch, span.Events(), traceID, spanID); err != nil {
+				attrs := spanAttrs[spanIdx]
+				spanIdx++
+
+				if eventRows, err = t.appendSpanEvents(eventRows, tagsBatch, span.Events(), attrs.events, traceID, spanID); err != nil {
 					return err
 				}
-				if err := t.queueSpanLinks(linkBatch, tagsBatch, span.Links(), traceID, spanID, span.StartTimestamp().AsTime()); err != nil {
+				if linkRows, err = t.appendSpanLinks(linkRows, tagsBatch, span.Links(), attrs.links, traceID, spanID, span.StartTimestamp().AsTime()); err != nil {
 					return err
 				}
 
-				jsonResourceTags, err := tagsBatch.GetTagMapJSON(rSpan.Resource().Attributes().AsRaw(), ResourceTagType)
+				jsonResourceTags, err := tagsBatch.GetTagMapJSON(resourceAttrs[i], ResourceTagType)
 				if err != nil {
 					return err
 				}
 
-				jsonTags, err := tagsBatch.GetTagMapJSON(span.Attributes().AsRaw(), SpanTagType)
+				jsonTags, err := tagsBatch.GetTagMapJSON(attrs.span, SpanTagType)
 				if err != nil {
 					return err
 				}
 
 				eventTimeRange := getEventTimeRange(span.Events())
 
-				spanBatch.Queue(
-					fmt.Sprintf(insertSpanSQL, schema.Trace),
+				spanRows = append(spanRows, []interface{}{
 					traceID,
 					spanID,
 					getTraceStateValue(span.TraceState()),
@@ -262,7 +316,7 @@ func (t *traceWriterImpl) InsertTraces(ctx context.Context, traces pdata.Traces)
 					operationID,
 					span.StartTimestamp().AsTime(),
 					span.EndTimestamp().AsTime(),
-					string(jsonTags),
+					getTagMap(jsonTags),
 					span.DroppedAttributesCount(),
 					eventTimeRange,
 					span.DroppedEventsCount(),
@@ -270,29 +324,118 @@ func (t *traceWriterImpl) InsertTraces(ctx context.Context, traces pdata.Traces)
 					span.Status().Code().String(),
 					span.Status().Message(),
 					instLibID,
-					string(jsonResourceTags),
+					getTagMap(jsonResourceTags),
 					0, // TODO: Add resource_dropped_tags_count when it gets exposed upstream.
 					rSchemaURLID,
-				)
+				})
 			}
 		}
 	}
 
-	return t.sendBatches(ctx, eventBatch, linkBatch, spanBatch)
+	return t.writeRows(ctx, spanRows, eventRows, linkRows)
+}
 
+// writeRows writes the span, event and link rows in a single transaction, so
+// that a failed write leaves nothing behind and can be retried. Events and
+// links have no unique constraint, so the ones of spans that are already
+// stored, e.g. by an export whose acknowledgement got lost, are skipped
+// instead of being stored twice.
+func (t *traceWriterImpl) writeRows(ctx context.Context, spanRows, eventRows, linkRows [][]interface{}) error {
+	tx, err := t.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin trace write: %w", err)
+	}
+	// Rolling back a committed transaction does nothing.
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	storedSpans, err := copySpans(ctx, tx, spanRows)
+	if err != nil {
+		return err
+	}
+	if len(storedSpans) > 0 {
+		eventRows = withoutSpans(eventRows, 1, storedSpans)
+		linkRows = withoutSpans(linkRows, 0, storedSpans)
+	}
+	if err = copyRows(ctx, tx, "event", eventColumns, eventRows); err != nil {
+		return err
+	}
+	if err = copyRows(ctx, tx, "link", linkColumns, linkRows); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
-func (t *traceWriterImpl) sendBatches(ctx context.Context, batches ...pgxconn.PgxBatch) error {
-	for _, batch := range batches {
-		br, err := t.conn.SendBatch(ctx, batch)
-		if err != nil {
-			return err
+// spanKey identifies a span by its trace ID and span ID.
+type spanKey struct {
+	traceID [16]byte
+	spanID  int64
+}
+
+func newSpanKey(traceID, spanID interface{}) spanKey {
+	return spanKey{traceID: traceID.(pgtype.UUID).Bytes, spanID: spanID.(pgtype.Int8).Int}
+}
+
+// withoutSpans returns the rows that don't belong to any of the spans. The
+// trace ID and span ID of a row are at idx and idx+1.
+func withoutSpans(rows [][]interface{}, idx int, spans map[spanKey]struct{}) [][]interface{} {
+	kept := rows[:0]
+	for _, row := range rows {
+		if _, ok := spans[newSpanKey(row[idx], row[idx+1])]; !ok {
+			kept = append(kept, row)
 		}
-		if err = br.Close(); err != nil {
-			return err
+	}
+	return kept
+}
+
+// copySpans copies the span rows into the span table. The copy fails if any of
+// the spans is already stored, which mostly happens when an export is retried,
+// in which case the spans are inserted ignoring the duplicates instead, and the
+// spans that were already stored are returned. The copy runs in a savepoint, as
+// its failure aborts the transaction otherwise.
+func copySpans(ctx context.Context, tx pgx.Tx, rows [][]interface{}) (map[spanKey]struct{}, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = copyRows(ctx, sp, "span", spanColumns, rows)
+	if err == nil {
+		return nil, sp.Commit(ctx)
+	}
+	if rbErr := sp.Rollback(ctx); rbErr != nil {
+		return nil, rbErr
+	}
+	log.Debug("msg", "copying spans failed, falling back to insert", "err", err)
+
+	batch := &pgx.Batch{}
+	for _, row := range rows {
+		batch.Queue(fmt.Sprintf(insertSpanSQL, schema.Trace), row...)
+	}
+	results := tx.SendBatch(ctx, batch)
+	stored := make(map[spanKey]struct{})
+	for _, row := range rows {
+		var inserted bool
+		err = results.QueryRow().Scan(&inserted)
+		if err == pgx.ErrNoRows {
+			stored[newSpanKey(row[0], row[1])] = struct{}{}
+			continue
+		}
+		if err != nil {
+			_ = results.Close()
+			return nil, err
 		}
 	}
-	return nil
+	return stored, results.Close()
+}
+
+func copyRows(ctx context.Context, tx pgx.Tx, table string, columns []string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{schema.Trace, table}, columns, pgx.CopyFromRows(rows))
+	return err
 }
 
 func ByteArrayToInt64(buf [8]byte) int64 {
@@ -357,6 +500,12 @@ func getEventTimeRange(events pdata.SpanEventSlice) (result pgtype.Tstzrange) {
 	return result
 }
 
+// getTagMap returns the JSON of a tag map as a value that can be copied into a
+// tag_map column.
+func getTagMap(json []byte) pgtype.JSONB {
+	return pgtype.JSONB{Bytes: json, Status: pgtype.Present}
+}
+
 func getTraceStateValue(ts pdata.TraceState) (result pgtype.Text) {
 	if string(ts) == "" {
 		result.Status = pgtype.Null
@@ -11,9 +11,11 @@ import (
 	"unsafe"
 
 	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
 	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/pgmodel/model/pgutf8str"
 	"github.com/timescale/promscale/pkg/pgxconn"
 )
@@ -24,28 +26,55 @@ const (
 	getLabelsSQL      = "SELECT (" + schema.Prom + ".labels_info($1::int[])).*"
 )
 
+var (
+	minTime = timestamp.FromTime(model.MinTime)
+	maxTime = timestamp.FromTime(model.MaxTime)
+)
+
 // LabelsReader defines the methods for accessing labels data
 type LabelsReader interface {
-	// LabelNames returns all the distinct label names in the system.
-	LabelNames() ([]string, error)
-	// LabelValues returns all the distinct values for a given label name.
-	LabelValues(labelName string) ([]string, error)
+	// LabelNames returns the distinct label names of the series that match the
+	// matchers and have samples within [mint, maxt].
+	LabelNames(mint, maxt int64, ms ...*labels.Matcher) ([]string, error)
+	// LabelValues returns the distinct values for a given label name of the series
+	// that match the matchers and have samples within [mint, maxt].
+	LabelValues(labelName string, mint, maxt int64, ms ...*labels.Matcher) ([]string, error)
 	// LabelsForIdMap fills in the label.Label values in a map of label id => labels.Label.
 	LabelsForIdMap(idMap map[int64]labels.Label) (err error)
 }
 
-func NewLabelsReader(conn pgxconn.PgxConn, labels cache.LabelsCache) LabelsReader {
-	return &labelsReader{conn: conn, labels: labels}
+// SeriesLabelsQuerier queries the label names and values of the series that match
+// the provided matchers and have samples within the provided time range.
+type SeriesLabelsQuerier interface {
+	LabelNames(mint, maxt int64, ms ...*labels.Matcher) ([]string, error)
+	LabelValues(name string, mint, maxt int64, ms ...*labels.Matcher) ([]string, error)
+}
+
+// NewLabelsReader returns a LabelsReader that reads unbounded lookups without
+// matchers straight from the label catalog and all other lookups from the series
+// through the series querier.
+func NewLabelsReader(conn pgxconn.PgxConn, labels cache.LabelsCache, series SeriesLabelsQuerier) LabelsReader {
+	return &labelsReader{conn: conn, labels: labels, series: series}
 }
 
 type labelsReader struct {
 	conn   pgxconn.PgxConn
 	labels cache.LabelsCache
+	series SeriesLabelsQuerier
+}
+
+// fromCatalog returns true if a lookup covers all the series, so it can be
+// answered from the label catalog without looking at the series and their samples.
+func fromCatalog(mint, maxt int64, ms []*labels.Matcher) bool {
+	return len(ms) == 0 && mint <= minTime && maxt >= maxTime
 }
 
 // LabelValues implements the LabelsReader interface. It returns all distinct values
-// for a specified label name.
-func (lr *labelsReader) LabelValues(labelName string) ([]string, error) {
+// for a specified label name of the matching series.
+func (lr *labelsReader) LabelValues(labelName string, mint, maxt int64, ms ...*labels.Matcher) ([]string, error) {
+	if !fromCatalog(mint, maxt, ms) {
+		return lr.series.LabelValues(labelName, mint, maxt, ms...)
+	}
 	rows, err := lr.conn.Query(context.Background(), getLabelValuesSQL, labelName)
 	if err != nil {
 		return nil, err
@@ -69,8 +98,11 @@ func (lr *labelsReader) LabelValues(labelName string) ([]string, error) {
 }
 
 // LabelNames implements the LabelReader interface. It returns all distinct
-// label names available in the database.
-func (lr *labelsReader) LabelNames() ([]string, error) {
+// label names of the matching series.
+func (lr *labelsReader) LabelNames(mint, maxt int64, ms ...*labels.Matcher) ([]string, error) {
+	if !fromCatalog(mint, maxt, ms) {
+		return lr.series.LabelNames(mint, maxt, ms...)
+	}
 	rows, err := lr.conn.Query(context.Background(), getLabelNamesSQL)
 	if err != nil {
 		return nil, err
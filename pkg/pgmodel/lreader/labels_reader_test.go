@@ -10,6 +10,7 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 )
 
@@ -65,7 +66,7 @@ func TestLabelsReaderLabelsNames(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			mock := model.NewSqlRecorder(tc.sqlQueries, t)
 			reader := labelsReader{conn: mock}
-			res, err := reader.LabelNames()
+			res, err := reader.LabelNames(minTime, maxTime)
 
 			var expectedErr error
 			for _, q := range tc.sqlQueries {
@@ -152,7 +153,7 @@ func TestLabelsReaderLabelsValues(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			mock := model.NewSqlRecorder(tc.sqlQueries, t)
 			querier := labelsReader{conn: mock}
-			res, err := querier.LabelValues("m")
+			res, err := querier.LabelValues("m", minTime, maxTime)
 
 			var expectedErr error
 			for _, q := range tc.sqlQueries {
@@ -186,3 +187,52 @@ func TestLabelsReaderLabelsValues(t *testing.T) {
 		})
 	}
 }
+
+type mockSeriesLabelsQuerier struct {
+	names  []string
+	values []string
+}
+
+func (m mockSeriesLabelsQuerier) LabelNames(int64, int64, ...*labels.Matcher) ([]string, error) {
+	return m.names, nil
+}
+
+func (m mockSeriesLabelsQuerier) LabelValues(string, int64, int64, ...*labels.Matcher) ([]string, error) {
+	return m.values, nil
+}
+
+func TestLabelsReaderSeriesLookups(t *testing.T) {
+	series := mockSeriesLabelsQuerier{names: []string{"job"}, values: []string{"a"}}
+	matcher := labels.MustNewMatcher(labels.MatchEqual, model.MetricNameLabelName, "up")
+	testCases := []struct {
+		name       string
+		mint, maxt int64
+		matchers   []*labels.Matcher
+	}{
+		{name: "With matchers", mint: minTime, maxt: maxTime, matchers: []*labels.Matcher{matcher}},
+		{name: "With start", mint: 1, maxt: maxTime},
+		{name: "With end", mint: minTime, maxt: 10},
+		{name: "With matchers and time range", mint: 1, maxt: 10, matchers: []*labels.Matcher{matcher}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The label catalog must not be queried.
+			reader := labelsReader{conn: model.NewSqlRecorder(nil, t), series: series}
+			names, err := reader.LabelNames(tc.mint, tc.maxt, tc.matchers...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(series.names, names) {
+				t.Errorf("expected: %v, got: %v", series.names, names)
+			}
+			values, err := reader.LabelValues("job", tc.mint, tc.maxt, tc.matchers...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(series.values, values) {
+				t.Errorf("expected: %v, got: %v", series.values, values)
+			}
+		})
+	}
+}
@@ -94,6 +94,10 @@ func (r *SqlRecorder) CopyFromRows(rows [][]interface{}) pgx.CopyFromSource {
 	panic("should never be called")
 }
 
+func (r *SqlRecorder) Begin(ctx context.Context) (pgx.Tx, error) {
+	panic("should never be called")
+}
+
 func (r *SqlRecorder) NewBatch() pgxconn.PgxBatch {
 	return &MockBatch{}
 }
@@ -399,6 +403,12 @@ func (m *MockRows) Scan(dest ...interface{}) error {
 			dvp := reflect.Indirect(dv)
 			dvp.SetUint(m.results[m.idx][i].(uint64))
 		case int64:
+			if d, ok := dest[i].(*pgtype.Int8); ok {
+				if err := d.Set(s); err != nil {
+					return err
+				}
+				continue
+			}
 			_, ok1 := dest[i].(*int64)
 			_, ok2 := dest[i].(*SeriesID)
 			_, ok3 := dest[i].(*SeriesEpoch)
@@ -14,6 +14,9 @@ import (
 // Reader reads the data based on the provided read request.
 type Reader interface {
 	Read(*prompb.ReadRequest) (*prompb.ReadResponse, error)
+	// ReadSeriesSet returns the series matching a single query of a read
+	// request. It is used to stream the response as XOR chunks.
+	ReadSeriesSet(*prompb.Query) (SeriesSet, error)
 }
 
 // SeriesSet adds a Close method to storage.SeriesSet to provide a way to free memory/
@@ -27,10 +30,14 @@ type SeriesSet interface {
 type Querier interface {
 	// Query returns resulting timeseries for a query.
 	Query(*prompb.Query) ([]*prompb.TimeSeries, error)
+	// QuerySeriesSet returns the resulting timeseries for a query as a series set sorted by labels.
+	QuerySeriesSet(*prompb.Query) (SeriesSet, error)
 	// SamplesQuerier returns a sample querier.
 	SamplesQuerier() SamplesQuerier
 	// ExemplarsQuerier returns an exemplar querier.
 	ExemplarsQuerier(ctx context.Context) ExemplarQuerier
+	// LabelsQuerier returns a labels querier.
+	LabelsQuerier() LabelsQuerier
 }
 
 // SamplesQuerier queries data using the provided query data and returns the
@@ -46,3 +53,12 @@ type ExemplarQuerier interface {
 	// Select returns a series set containing the exemplar that matches the supplied query parameters.
 	Select(start, end time.Time, ms ...[]*labels.Matcher) ([]model.ExemplarQueryResult, error)
 }
+
+// LabelsQuerier queries the label names and values of the series that match
+// the provided matchers and have samples within the provided time range.
+type LabelsQuerier interface {
+	// LabelNames returns the sorted distinct label names of the matching series.
+	LabelNames(mint, maxt int64, ms ...*labels.Matcher) ([]string, error)
+	// LabelValues returns the sorted distinct values for a label name of the matching series.
+	LabelValues(name string, mint, maxt int64, ms ...*labels.Matcher) ([]string, error)
+}
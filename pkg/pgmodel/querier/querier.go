@@ -52,6 +52,10 @@ func (q *pgxQuerier) ExemplarsQuerier(ctx context.Context) ExemplarQuerier {
 	return newQueryExemplars(q)
 }
 
+func (q *pgxQuerier) LabelsQuerier() LabelsQuerier {
+	return newQueryLabels(q)
+}
+
 // Query implements the Querier interface. It is the entry point for
 // remote-storage queries.
 func (q *pgxQuerier) Query(query *prompb.Query) ([]*prompb.TimeSeries, error) {
@@ -76,6 +80,26 @@ func (q *pgxQuerier) Query(query *prompb.Query) ([]*prompb.TimeSeries, error) {
 	return results, nil
 }
 
+// QuerySeriesSet implements the Querier interface. It returns the result of a
+// remote-storage query as a series set sorted by labels. The series are
+// ordered in the database and read row by row while iterating, so that
+// callers can encode and stream each series without materializing the whole
+// response. The series set must be closed to release the connection.
+func (q *pgxQuerier) QuerySeriesSet(query *prompb.Query) (SeriesSet, error) {
+	if query == nil {
+		return errorSeriesSet{}, nil
+	}
+
+	seriesSet, err := q.fetchRemoteReadRows(query)
+	if err != nil {
+		return nil, err
+	}
+	if seriesSet == nil {
+		return errorSeriesSet{}, nil
+	}
+	return seriesSet, nil
+}
+
 // errorSeriesSet represents an error result in a form of a series set.
 // This behavior is inherited from Prometheus codebase.
 type errorSeriesSet struct {
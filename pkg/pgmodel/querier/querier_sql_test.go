@@ -719,7 +719,7 @@ func TestPGXQuerierQuery(t *testing.T) {
 			if err != nil {
 				t.Fatalf("error setting up mock cache: %s", err.Error())
 			}
-			querier := pgxQuerier{&queryTools{conn: mock, metricTableNames: mockMetrics, labelsReader: lreader.NewLabelsReader(mock, clockcache.WithMax(0))}}
+			querier := pgxQuerier{&queryTools{conn: mock, metricTableNames: mockMetrics, labelsReader: lreader.NewLabelsReader(mock, clockcache.WithMax(0), nil)}}
 
 			result, err := querier.Query(c.query)
 
@@ -750,3 +750,177 @@ func TestPGXQuerierQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestPGXQuerierQuerySeriesSet(t *testing.T) {
+	// The series are ordered in the database, so the rows are returned ordered
+	// by the label sets including the labels of a non-default column.
+	sqlFormat := "SELECT keys, vals, time_array, value_array\n\t" +
+		"FROM (SELECT lbl.label_set, lbl.keys, lbl.vals, result.time_array, result.value_array\n\t" +
+		"FROM \"prom_data_series\".\"metric_1\" series\n\t" +
+		"INNER JOIN LATERAL (\n\t\t" +
+		"SELECT array_agg(time) as time_array, array_agg(value) as value_array\n\t\t" +
+		"FROM\n\t\t" +
+		"(\n\t\t\t" +
+		"SELECT time, \"%s\" as value\n\t\t\t" +
+		"FROM \"prom_data\".\"metric_1\" metric\n\t\t\t" +
+		"WHERE metric.series_id = series.id\n\t\t\t" +
+		"AND time >= '1970-01-01T00:00:01Z'\n\t\t\t" +
+		"AND time <= '1970-01-01T00:00:02Z'\n\t\t\t" +
+		"ORDER BY time\n\t\t" +
+		") as time_ordered_rows\n\t" +
+		") as result ON (result.value_array is not null)\n\t" +
+		"INNER JOIN LATERAL (\n\t\t" +
+		"SELECT\n\t\t\t" +
+		"array_agg(kv.str ORDER BY lk.key COLLATE \"C\", kv.pos) as label_set,\n\t\t\t" +
+		"array_agg(lk.key ORDER BY lk.key COLLATE \"C\") FILTER (WHERE kv.pos = 0) as keys,\n\t\t\t" +
+		"array_agg(lk.value ORDER BY lk.key COLLATE \"C\") FILTER (WHERE kv.pos = 0) as vals\n\t\t" +
+		"FROM (\n\t\t\t" +
+		"SELECT l.key, l.value\n\t\t\t" +
+		"FROM _prom_catalog.label l\n\t\t\t" +
+		"WHERE l.id = ANY(series.labels)%s\n\t\t" +
+		") as lk\n\t\t" +
+		"CROSS JOIN LATERAL (VALUES (0, lk.key), (1, lk.value)) as kv(pos, str)\n\t" +
+		") as lbl ON TRUE\n\t" +
+		"WHERE labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $1 and l.value = $2)) as series_rows\n\t" +
+		"ORDER BY label_set COLLATE \"C\""
+	testCases := []struct {
+		name     string
+		matchers []*prompb.LabelMatcher
+		sqlQuery model.SqlQuery
+		expected []string
+	}{
+		{
+			name: "default column",
+			matchers: []*prompb.LabelMatcher{
+				{Type: prompb.LabelMatcher_EQ, Name: model.MetricNameLabelName, Value: "metric_1"},
+				{Type: prompb.LabelMatcher_EQ, Name: "foo", Value: "bar"},
+			},
+			sqlQuery: model.SqlQuery{
+				Sql:  fmt.Sprintf(sqlFormat, "value", ""),
+				Args: []interface{}{"foo", "bar"},
+				Results: model.RowResults{
+					{[]string{"__name__", "foo"}, []string{"metric_1", "bar"}, []time.Time{time.Unix(1, 0)}, []float64{1}},
+					{[]string{"__name__", "foo", "job"}, []string{"metric_1", "bar", "x"}, []time.Time{time.Unix(1, 0), time.Unix(2, 0)}, []float64{2, 3}},
+				},
+			},
+			expected: []string{
+				`{__name__="metric_1", foo="bar"} 1`,
+				`{__name__="metric_1", foo="bar", job="x"} 2`,
+			},
+		},
+		{
+			name: "custom column",
+			matchers: []*prompb.LabelMatcher{
+				{Type: prompb.LabelMatcher_EQ, Name: model.MetricNameLabelName, Value: "metric_1"},
+				{Type: prompb.LabelMatcher_EQ, Name: model.ColumnNameLabelName, Value: "max"},
+				{Type: prompb.LabelMatcher_EQ, Name: "foo", Value: "bar"},
+			},
+			sqlQuery: model.SqlQuery{
+				Sql:  fmt.Sprintf(sqlFormat, "max", "\n\t\t\tUNION ALL SELECT '__column__', $3::text"),
+				Args: []interface{}{"foo", "bar", "max"},
+				Results: model.RowResults{
+					{[]string{"X", "__column__", "__name__", "foo"}, []string{"1", "max", "metric_1", "bar"}, []time.Time{time.Unix(1, 0)}, []float64{1}},
+					{[]string{"X", "Y", "__column__", "__name__", "foo"}, []string{"1", "2", "max", "metric_1", "bar"}, []time.Time{time.Unix(1, 0)}, []float64{2}},
+				},
+			},
+			expected: []string{
+				`{X="1", __column__="max", __name__="metric_1", foo="bar"} 1`,
+				`{X="1", Y="2", __column__="max", __name__="metric_1", foo="bar"} 1`,
+			},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := model.NewSqlRecorder([]model.SqlQuery{c.sqlQuery}, t)
+			mockMetrics := &model.MockMetricCache{
+				MetricCache: make(map[string]model.MetricInfo),
+			}
+			err := mockMetrics.Set(
+				"",
+				"metric_1",
+				model.MetricInfo{
+					TableSchema: "prom_data",
+					TableName:   "metric_1",
+					SeriesTable: "metric_1",
+				}, false,
+			)
+			if err != nil {
+				t.Fatalf("error setting up mock cache: %s", err.Error())
+			}
+			querier := pgxQuerier{&queryTools{conn: mock, metricTableNames: mockMetrics, labelsReader: lreader.NewLabelsReader(mock, clockcache.WithMax(0), nil)}}
+
+			ss, err := querier.QuerySeriesSet(&prompb.Query{
+				StartTimestampMs: 1000,
+				EndTimestampMs:   2000,
+				Matchers:         c.matchers,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer ss.Close()
+
+			var got []string
+			for ss.Next() {
+				s := ss.At()
+				it := s.Iterator()
+				samples := 0
+				for it.Next() {
+					samples++
+				}
+				got = append(got, fmt.Sprintf("%s %d", s.Labels(), samples))
+			}
+			if err = ss.Err(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("unexpected result:\ngot\n%v\nwanted\n%v", got, c.expected)
+			}
+		})
+	}
+}
+
+func TestQueryLabelsWithoutMatchers(t *testing.T) {
+	sqlQueries := []model.SqlQuery{
+		{
+			Sql:  "SELECT m.table_schema, m.table_name, m.series_table FROM _prom_catalog.metric m",
+			Args: []interface{}(nil),
+			Results: model.RowResults{
+				{"prom_data", "metric_1", "metric_1"},
+				{"prom_data", "metric_2", "metric_2"},
+			},
+		},
+		{
+			Sql: "SELECT DISTINCT l.value FROM _prom_catalog.label l WHERE l.key = $1 AND l.id IN (SELECT unnest(s.labels)\n\t" +
+				"FROM \"prom_data_series\".\"metric_1\" s\n\t" +
+				"WHERE EXISTS (\n\t\t" +
+				"SELECT 1 FROM \"prom_data\".\"metric_1\" m\n\t\t" +
+				"WHERE m.time >= '1970-01-01T00:00:01Z'\n\t\t" +
+				"AND m.time <= '1970-01-01T00:00:02Z'\n\t" +
+				"))",
+			Args:    []interface{}{"job"},
+			Results: model.RowResults{{"b"}, {"a"}},
+		},
+		{
+			Sql: "SELECT DISTINCT l.value FROM _prom_catalog.label l WHERE l.key = $1 AND l.id IN (SELECT unnest(s.labels)\n\t" +
+				"FROM \"prom_data_series\".\"metric_2\" s\n\t" +
+				"WHERE EXISTS (\n\t\t" +
+				"SELECT 1 FROM \"prom_data\".\"metric_2\" m\n\t\t" +
+				"WHERE m.time >= '1970-01-01T00:00:01Z'\n\t\t" +
+				"AND m.time <= '1970-01-01T00:00:02Z'\n\t" +
+				"))",
+			Args:    []interface{}{"job"},
+			Results: model.RowResults{{"a"}, {"c"}},
+		},
+	}
+	mock := model.NewSqlRecorder(sqlQueries, t)
+	querier := newQueryLabels(&pgxQuerier{&queryTools{conn: mock}})
+
+	values, err := querier.LabelValues("job", 1000, 2000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("unexpected result:\ngot\n%v\nwanted\n%v", values, expected)
+	}
+}
@@ -0,0 +1,234 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/timescale/promscale/pkg/pgmodel/cache"
+	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+const (
+	// seriesLabelIDsSQLFormat returns the label ids of the series that pass the filter.
+	seriesLabelIDsSQLFormat = `SELECT unnest(s.labels)
+	FROM %[1]s s
+	WHERE %[2]s`
+	// seriesIDsFilterFormat restricts the series to the ids given by the parameter.
+	seriesIDsFilterFormat = "s.id = ANY($%d::bigint[])"
+	// seriesTimeRangeFilterFormat restricts the series to the ones that have samples
+	// within the time range.
+	seriesTimeRangeFilterFormat = `
+	AND EXISTS (
+		SELECT 1 FROM %[1]s m
+		WHERE m.series_id = s.id
+		AND m.time >= '%[2]s'
+		AND m.time <= '%[3]s'
+	)`
+	// metricTimeRangeFilterFormat keeps all the series of a metric if the metric has
+	// any samples within the time range. The check doesn't depend on the series, so
+	// it's evaluated once per metric, only looks at the chunks overlapping the time
+	// range and stops at the first sample.
+	metricTimeRangeFilterFormat = `EXISTS (
+		SELECT 1 FROM %[1]s m
+		WHERE m.time >= '%[2]s'
+		AND m.time <= '%[3]s'
+	)`
+
+	metricTablesSQL = "SELECT m.table_schema, m.table_name, m.series_table FROM " + schema.Catalog + ".metric m"
+
+	labelNamesForIDsSQLFormat  = "SELECT DISTINCT l.key FROM " + schema.Catalog + ".label l WHERE l.id IN (%s)"
+	labelValuesForIDsSQLFormat = "SELECT DISTINCT l.value FROM " + schema.Catalog + ".label l WHERE l.key = $1 AND l.id IN (%s)"
+)
+
+type queryLabels struct {
+	*pgxQuerier
+}
+
+func newQueryLabels(qr *pgxQuerier) *queryLabels {
+	return &queryLabels{qr}
+}
+
+// NewLabelsQuerier returns a LabelsQuerier that reads from PostgreSQL using PGX
+// and caches metric table names using the supplied cache. Unlike NewQuerier, it
+// doesn't need a labels reader, so it can be used to create one.
+func NewLabelsQuerier(conn pgxconn.PgxConn, metricCache cache.MetricCache, rAuth tenancy.ReadAuthorizer) LabelsQuerier {
+	return newQueryLabels(&pgxQuerier{
+		tools: &queryTools{
+			conn:             conn,
+			metricTableNames: metricCache,
+			rAuth:            rAuth,
+		},
+	})
+}
+
+// LabelNames implements the LabelsQuerier interface. It returns the sorted
+// distinct label names of the series that match the matchers and have samples
+// within [mint, maxt]. Without matchers, all the series of the metrics that
+// have samples within [mint, maxt] are considered.
+func (q *queryLabels) LabelNames(mint, maxt int64, ms ...*labels.Matcher) ([]string, error) {
+	return q.fetchLabels(labelNamesForIDsSQLFormat, nil, mint, maxt, ms)
+}
+
+// LabelValues implements the LabelsQuerier interface. It returns the sorted
+// distinct values of the label name among the series that match the matchers
+// and have samples within [mint, maxt]. Without matchers, all the series of the
+// metrics that have samples within [mint, maxt] are considered.
+func (q *queryLabels) LabelValues(name string, mint, maxt int64, ms ...*labels.Matcher) ([]string, error) {
+	return q.fetchLabels(labelValuesForIDsSQLFormat, []interface{}{name}, mint, maxt, ms)
+}
+
+// fetchLabels resolves the series matching the matchers per metric and queries
+// the label keys or values, depending on sqlFormat, of those series in a single
+// batch. extraArgs are passed as the arguments of sqlFormat, followed by the
+// series ids.
+func (q *queryLabels) fetchLabels(sqlFormat string, extraArgs []interface{}, mint, maxt int64, ms []*labels.Matcher) ([]string, error) {
+	matchers := ms
+	if q.tools.rAuth != nil {
+		matchers = q.tools.rAuth.AppendTenantMatcher(matchers)
+	}
+	builder, err := BuildSubQueries(matchers)
+	if err != nil {
+		return nil, fmt.Errorf("build subQueries: %w", err)
+	}
+	clauses, values, err := builder.Build(true)
+	if err == errors.ErrNoClausesGen {
+		return q.fetchLabelsInTimeRange(sqlFormat, extraArgs, mint, maxt)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("build clauses: %w", err)
+	}
+	metrics, schemas, seriesIDs, err := GetMetricNameSeriesIds(q.tools.conn, GetMetadata(clauses, values))
+	if err != nil {
+		return nil, fmt.Errorf("get metric-name series-ids: %w", err)
+	}
+
+	var (
+		batch      = q.tools.conn.NewBatch()
+		numQueries = 0
+	)
+	for i := range metrics {
+		metricInfo, err := q.tools.getMetricTableName(schemas[i], metrics[i], false)
+		if err != nil {
+			// Metrics without a table have no samples, hence no labels.
+			if err == errors.ErrMissingTableName {
+				continue
+			}
+			return nil, fmt.Errorf("get metric table name: %w", err)
+		}
+
+		filter := fmt.Sprintf(seriesIDsFilterFormat, len(extraArgs)+1)
+		if mint > minTime || maxt < maxTime {
+			filter += fmt.Sprintf(
+				seriesTimeRangeFilterFormat,
+				pgx.Identifier{metricInfo.TableSchema, metricInfo.TableName}.Sanitize(),
+				toRFC3339Nano(mint),
+				toRFC3339Nano(maxt),
+			)
+		}
+		labelIDsQuery := fmt.Sprintf(
+			seriesLabelIDsSQLFormat,
+			pgx.Identifier{schema.DataSeries, metricInfo.SeriesTable}.Sanitize(),
+			filter,
+		)
+
+		ids := make([]int64, len(seriesIDs[i]))
+		for j, id := range seriesIDs[i] {
+			ids[j] = int64(id)
+		}
+		batch.Queue(fmt.Sprintf(sqlFormat, labelIDsQuery), append(append([]interface{}{}, extraArgs...), ids)...)
+		numQueries++
+	}
+	return q.collectLabels(batch, numQueries)
+}
+
+// fetchLabelsInTimeRange queries the label keys or values, depending on sqlFormat,
+// of the series of all the metrics that have samples within [mint, maxt]. Without
+// matchers, the series aren't resolved one by one, as that would read the whole
+// series catalog.
+func (q *queryLabels) fetchLabelsInTimeRange(sqlFormat string, extraArgs []interface{}, mint, maxt int64) ([]string, error) {
+	rows, err := q.tools.conn.Query(context.Background(), metricTablesSQL)
+	if err != nil {
+		return nil, fmt.Errorf("get metric tables: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		batch      = q.tools.conn.NewBatch()
+		numQueries = 0
+	)
+	for rows.Next() {
+		var tableSchema, tableName, seriesTable string
+		if err = rows.Scan(&tableSchema, &tableName, &seriesTable); err != nil {
+			return nil, fmt.Errorf("get metric tables: %w", err)
+		}
+		labelIDsQuery := fmt.Sprintf(
+			seriesLabelIDsSQLFormat,
+			pgx.Identifier{schema.DataSeries, seriesTable}.Sanitize(),
+			fmt.Sprintf(
+				metricTimeRangeFilterFormat,
+				pgx.Identifier{tableSchema, tableName}.Sanitize(),
+				toRFC3339Nano(mint),
+				toRFC3339Nano(maxt),
+			),
+		)
+		batch.Queue(fmt.Sprintf(sqlFormat, labelIDsQuery), extraArgs...)
+		numQueries++
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("get metric tables: %w", err)
+	}
+	rows.Close()
+	return q.collectLabels(batch, numQueries)
+}
+
+// collectLabels sends the batch of label queries and returns the sorted distinct
+// labels of all their results.
+func (q *queryLabels) collectLabels(batch pgxconn.PgxBatch, numQueries int) ([]string, error) {
+	if numQueries == 0 {
+		return []string{}, nil
+	}
+
+	results, err := q.tools.conn.SendBatch(context.Background(), batch)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	unique := make(map[string]struct{})
+	for i := 0; i < numQueries; i++ {
+		rows, err := results.Query()
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var s string
+			if err = rows.Scan(&s); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			unique[s] = struct{}{}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]string, 0, len(unique))
+	for s := range unique {
+		result = append(result, s)
+	}
+	sort.Strings(result)
+	return result, nil
+}
@@ -0,0 +1,275 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgmodel/model/pgutf8str"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+const (
+	/* The remote-read queries return the samples of every series together
+	with its label set, so that the series can be ordered by their labels
+	in the database and streamed to the client row by row. The label set is
+	returned as keys and values ordered by key, including the labels added
+	for the schema and column of the query. The label_set column holds the
+	keys and values alternately; compared with the "C" collation it orders
+	the series the same way as labels.Compare. */
+	remoteReadSeriesSQLFormat = `SELECT lbl.label_set, lbl.keys, lbl.vals, result.time_array, result.value_array
+	FROM %[2]s series
+	INNER JOIN LATERAL (
+		SELECT array_agg(time) as time_array, array_agg(value) as value_array
+		FROM
+		(
+			SELECT time, %[6]s as value
+			FROM %[1]s metric
+			WHERE metric.series_id = series.id
+			AND time >= '%[4]s'
+			AND time <= '%[5]s'
+			ORDER BY time
+		) as time_ordered_rows
+	) as result ON (result.value_array is not null)
+	INNER JOIN LATERAL (
+		SELECT
+			array_agg(kv.str ORDER BY lk.key COLLATE "C", kv.pos) as label_set,
+			array_agg(lk.key ORDER BY lk.key COLLATE "C") FILTER (WHERE kv.pos = 0) as keys,
+			array_agg(lk.value ORDER BY lk.key COLLATE "C") FILTER (WHERE kv.pos = 0) as vals
+		FROM (
+			SELECT l.key, l.value
+			FROM _prom_catalog.label l
+			WHERE l.id = ANY(series.labels)%[7]s
+		) as lk
+		CROSS JOIN LATERAL (VALUES (0, lk.key), (1, lk.value)) as kv(pos, str)
+	) as lbl ON TRUE
+	WHERE %[3]s`
+	remoteReadAdditionalLabelSQLFormat = `
+			UNION ALL SELECT '%s', $%d::text`
+
+	remoteReadSQLFormat = `SELECT keys, vals, time_array, value_array
+	FROM (%s) as series_rows
+	ORDER BY label_set COLLATE "C"`
+)
+
+// fetchRemoteReadRows returns the rows of all series matching a remote-read
+// query, ordered by their label sets. The rows are not read, so that the
+// caller can stream them.
+func (q *pgxQuerier) fetchRemoteReadRows(query *prompb.Query) (*pgxRowsSeriesSet, error) {
+	matchers, err := fromLabelMatchers(query.Matchers)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := getEvaluationMetadata(q.tools, query.StartTimestampMs, query.EndTimestampMs, GetPromQLMetadata(matchers, nil, nil, nil))
+	if err != nil {
+		return nil, fmt.Errorf("get evaluation metadata: %w", err)
+	}
+
+	filter := metadata.timeFilter
+	if metadata.isSingleMetric {
+		mInfo, err := q.tools.getMetricTableName(filter.schema, filter.metric, false)
+		if err != nil {
+			if err == errors.ErrMissingTableName {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("get metric table name: %w", err)
+		}
+		filter.metric = mInfo.TableName
+		filter.schema = mInfo.TableSchema
+		filter.seriesTable = mInfo.SeriesTable
+
+		// The labels for the schema and column are part of the label set the
+		// series are ordered by, as they could change the order otherwise.
+		values := metadata.values
+		additionalLabels := ""
+		for _, l := range (&sampleRow{schema: filter.schema, column: filter.column}).GetAdditionalLabels() {
+			values = append(values, l.Value)
+			additionalLabels += fmt.Sprintf(remoteReadAdditionalLabelSQLFormat, l.Name, len(values))
+		}
+		sqlQuery := buildRemoteReadQuery([]string{buildRemoteReadSeriesQuery(filter, strings.Join(metadata.clauses, " AND "), additionalLabels)})
+		rows, err := q.tools.conn.Query(context.Background(), sqlQuery, values...)
+		if err != nil {
+			if e, ok := err.(*pgconn.PgError); ok {
+				switch e.Code {
+				case pgerrcode.UndefinedTable:
+					return nil, fmt.Errorf(errors.ErrTmplMissingUnderlyingRelation, filter.schema, filter.metric)
+				case pgerrcode.UndefinedColumn:
+					return nil, nil
+				}
+			}
+			return nil, err
+		}
+
+		// If the table name and series table name don't match, this is a custom metric view which
+		// shares the series table with the raw metric, hence we have to update the metric name label.
+		metricOverride := ""
+		if filter.metric != filter.seriesTable {
+			metricOverride = filter.metric
+		}
+		return newPgxRowsSeriesSet(rows, metricOverride), nil
+	}
+
+	metrics, schemas, series, err := GetMetricNameSeriesIds(q.tools.conn, metadata)
+	if err != nil {
+		return nil, err
+	}
+	seriesQueries := make([]string, 0, len(metrics))
+	for i := range metrics {
+		metricInfo, err := q.tools.getMetricTableName(schemas[i], metrics[i], false)
+		if err != nil {
+			if err == errors.ErrMissingTableName {
+				continue
+			}
+			return nil, err
+		}
+		// We only support default data schema for multi-metric queries.
+		if metricInfo.TableSchema != schema.Data {
+			return nil, fmt.Errorf("found unsupported metric schema in multi-metric matching query")
+		}
+
+		ids := make([]string, len(series[i]))
+		for j, sID := range series[i] {
+			ids[j] = fmt.Sprintf("%d", sID)
+		}
+		seriesFilter := timeFilter{
+			metric:      metricInfo.TableName,
+			schema:      metricInfo.TableSchema,
+			seriesTable: metricInfo.SeriesTable,
+			start:       filter.start,
+			end:         filter.end,
+		}
+		seriesQueries = append(seriesQueries, buildRemoteReadSeriesQuery(seriesFilter, "series.id IN ("+strings.Join(ids, ",")+")", ""))
+	}
+	if len(seriesQueries) == 0 {
+		return nil, nil
+	}
+
+	rows, err := q.tools.conn.Query(context.Background(), buildRemoteReadQuery(seriesQueries))
+	if err != nil {
+		return nil, err
+	}
+	return newPgxRowsSeriesSet(rows, ""), nil
+}
+
+func buildRemoteReadSeriesQuery(filter timeFilter, clauses, additionalLabels string) string {
+	column := filter.column
+	if column == "" {
+		column = defaultColumnName
+	}
+	return fmt.Sprintf(remoteReadSeriesSQLFormat,
+		pgx.Identifier{filter.schema, filter.metric}.Sanitize(),
+		pgx.Identifier{schema.DataSeries, filter.seriesTable}.Sanitize(),
+		clauses,
+		filter.start,
+		filter.end,
+		pgx.Identifier{column}.Sanitize(),
+		additionalLabels,
+	)
+}
+
+func buildRemoteReadQuery(seriesQueries []string) string {
+	return fmt.Sprintf(remoteReadSQLFormat, strings.Join(seriesQueries, "\n\tUNION ALL\n\t"))
+}
+
+// pgxRowsSeriesSet implements storage.SeriesSet over the rows of a
+// remote-read query. The rows are read one at a time while iterating, so
+// only the current series is kept in memory.
+type pgxRowsSeriesSet struct {
+	rows           pgxconn.PgxRows
+	metricOverride string
+	cur            *pgxSeries
+	err            error
+}
+
+// pgxRowsSeriesSet must implement storage.SeriesSet
+var _ storage.SeriesSet = (*pgxRowsSeriesSet)(nil)
+
+func newPgxRowsSeriesSet(rows pgxconn.PgxRows, metricOverride string) *pgxRowsSeriesSet {
+	return &pgxRowsSeriesSet{
+		rows:           rows,
+		metricOverride: metricOverride,
+	}
+}
+
+// Next reads the next row and forwards the internal cursor to its series.
+func (p *pgxRowsSeriesSet) Next() bool {
+	p.cur = nil
+	if p.err != nil {
+		return false
+	}
+	if !p.rows.Next() {
+		p.err = p.rows.Err()
+		return false
+	}
+
+	var (
+		keys   pgutf8str.TextArray
+		vals   pgutf8str.TextArray
+		times  = new(pgtype.TimestamptzArray)
+		values = new(pgtype.Float8Array)
+	)
+	if p.err = p.rows.Scan(&keys, &vals, times, values); p.err != nil {
+		return false
+	}
+	if len(keys.Elements) != len(vals.Elements) || len(times.Elements) != len(values.Elements) {
+		p.err = errors.ErrInvalidRowData
+		return false
+	}
+
+	keyStrArr := keys.Get().([]string)
+	valStrArr := vals.Get().([]string)
+	// The labels are already sorted by name. Overriding the metric name
+	// doesn't change the order of the series, as all of them share it.
+	lls := make(labels.Labels, 0, len(keyStrArr))
+	for i := range keyStrArr {
+		l := labels.Label{Name: keyStrArr[i], Value: valStrArr[i]}
+		if p.metricOverride != "" && l.Name == model.MetricNameLabelName {
+			l.Value = p.metricOverride
+		}
+		lls = append(lls, l)
+	}
+
+	p.cur = &pgxSeries{
+		labels: lls,
+		times:  newRowTimestampSeries(times),
+		values: values,
+	}
+	return true
+}
+
+// At returns the current storage.Series.
+func (p *pgxRowsSeriesSet) At() storage.Series {
+	if p.cur == nil {
+		return nil
+	}
+	return p.cur
+}
+
+// Err implements storage.SeriesSet.
+func (p *pgxRowsSeriesSet) Err() error {
+	if p.err != nil {
+		return fmt.Errorf("error retrieving series set: %w", p.err)
+	}
+	return nil
+}
+
+func (p *pgxRowsSeriesSet) Warnings() storage.Warnings { return nil }
+
+// Close closes the rows, releasing the connection.
+func (p *pgxRowsSeriesSet) Close() {
+	p.rows.Close()
+}
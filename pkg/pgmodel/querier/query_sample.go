@@ -23,12 +23,18 @@ func newQuerySamples(qr *pgxQuerier) *querySamples {
 
 // Select implements the Querier interface. It is the entry point for our
 // own version of the Prometheus engine.
-func (q *querySamples) Select(mint, maxt int64, _ bool, hints *storage.SelectHints, qh *QueryHints, path []parser.Node, ms ...*labels.Matcher) (seriesSet SeriesSet, node parser.Node) {
+func (q *querySamples) Select(mint, maxt int64, sortSeries bool, hints *storage.SelectHints, qh *QueryHints, path []parser.Node, ms ...*labels.Matcher) (seriesSet SeriesSet, node parser.Node) {
 	sampleRows, topNode, err := q.fetchSamplesRows(mint, maxt, hints, qh, path, ms)
 	if err != nil {
 		return errorSeriesSet{err: err}, nil
 	}
 	responseSeriesSet := buildSeriesSet(sampleRows, q.tools.labelsReader)
+	if ss, ok := responseSeriesSet.(*pgxSamplesSeriesSet); ok && sortSeries {
+		if err = ss.sortByLabels(); err != nil {
+			ss.Close()
+			return errorSeriesSet{err: fmt.Errorf("sorting series set: %w", err)}, nil
+		}
+	}
 	return responseSeriesSet, topNode
 }
 
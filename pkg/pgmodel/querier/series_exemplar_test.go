@@ -78,12 +78,12 @@ func newMockLabelsReader(keys []int64, lbls []labels.Label) mockLabelsReader {
 	return mockLabelsReader{items}
 }
 
-func (m mockLabelsReader) LabelNames() ([]string, error) {
+func (m mockLabelsReader) LabelNames(int64, int64, ...*labels.Matcher) ([]string, error) {
 	return nil, nil
 }
 
 // LabelValues returns all the distinct values for a given label name.
-func (m mockLabelsReader) LabelValues(_ string) ([]string, error) {
+func (m mockLabelsReader) LabelValues(string, int64, int64, ...*labels.Matcher) ([]string, error) {
 	return nil, nil
 }
 
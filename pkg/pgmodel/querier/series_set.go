@@ -29,6 +29,9 @@ type pgxSamplesSeriesSet struct {
 	labelIDMap map[int64]labels.Label
 	err        error
 	querier    labelQuerier
+	// rowLabels holds the resolved labels of every row once the set has been
+	// sorted. It is nil for unsorted sets, which resolve labels lazily in At().
+	rowLabels []labels.Labels
 }
 
 // pgxSamplesSeriesSet must implement storage.SeriesSet
@@ -87,15 +90,32 @@ func (p *pgxSamplesSeriesSet) At() storage.Series {
 		values: row.values,
 	}
 
+	if p.rowLabels != nil {
+		ps.labels = p.rowLabels[p.rowIdx]
+		return ps
+	}
+
+	lls, err := p.labelsForRow(row)
+	if err != nil {
+		p.err = err
+	}
+	ps.labels = lls
+
+	return ps
+}
+
+// labelsForRow resolves the sorted label set of a row, including the metric
+// name override and the additional schema and column labels.
+func (p *pgxSamplesSeriesSet) labelsForRow(row *sampleRow) (labels.Labels, error) {
 	// this should pretty much always be non-empty due to __name__, but it
 	// costs little to check here
 	if len(row.labelIds) == 0 {
-		return ps
+		return nil, nil
 	}
 
 	lls, err := getLabelsFromLabelIds(row.labelIds, p.labelIDMap)
 	if err != nil {
-		p.err = err
+		return nil, err
 	}
 
 	if row.metricOverride != "" {
@@ -109,9 +129,43 @@ func (p *pgxSamplesSeriesSet) At() storage.Series {
 	lls = append(lls, row.GetAdditionalLabels()...)
 
 	sort.Sort(lls)
-	ps.labels = lls
+	return lls, nil
+}
 
-	return ps
+// sortByLabels orders the rows of the series set by their label sets, which is
+// the order in which the PromQL engine expects series when it asks for sorted
+// ones, e.g. for federation.
+func (p *pgxSamplesSeriesSet) sortByLabels() error {
+	p.rowLabels = make([]labels.Labels, len(p.rows))
+	for i := range p.rows {
+		if p.rows[i].err != nil {
+			return p.rows[i].err
+		}
+		lls, err := p.labelsForRow(&p.rows[i])
+		if err != nil {
+			return err
+		}
+		p.rowLabels[i] = lls
+	}
+	sort.Sort(rowsByLabels{p})
+	return nil
+}
+
+// rowsByLabels implements sort.Interface, sorting rows together with their
+// resolved labels.
+type rowsByLabels struct {
+	*pgxSamplesSeriesSet
+}
+
+func (r rowsByLabels) Len() int { return len(r.rows) }
+
+func (r rowsByLabels) Less(i, j int) bool {
+	return labels.Compare(r.rowLabels[i], r.rowLabels[j]) < 0
+}
+
+func (r rowsByLabels) Swap(i, j int) {
+	r.rows[i], r.rows[j] = r.rows[j], r.rows[i]
+	r.rowLabels[i], r.rowLabels[j] = r.rowLabels[j], r.rowLabels[i]
 }
 
 func getLabelsFromLabelIds(labelIds []int64, index map[int64]labels.Label) (labels.Labels, error) {
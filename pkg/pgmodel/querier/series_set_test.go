@@ -532,3 +532,43 @@ func genSeries(labels []int64, ts []pgtype.Timestamptz, vs []pgtype.Float8, sche
 		column:     column,
 	}
 }
+
+func TestPgxSeriesSetSortByLabels(t *testing.T) {
+	labelMapping := map[int64]struct {
+		k string
+		v string
+	}{
+		1: {model.MetricNameLabelName, "b"},
+		2: {model.MetricNameLabelName, "a"},
+		3: {"job", "y"},
+		4: {"job", "x"},
+	}
+	ts := []pgtype.Timestamptz{{Time: time.Unix(0, 0)}}
+	vs := []pgtype.Float8{{Float: 1}}
+
+	rows := genPgxRows([][]seriesSetRow{{
+		genSeries([]int64{1, 4}, ts, vs, "", ""),
+		genSeries([]int64{2, 3}, ts, vs, "", ""),
+		genSeries([]int64{2, 4}, ts, vs, "", ""),
+	}}, nil)
+	ss := buildSeriesSet(rows, mapQuerier{labelMapping}).(*pgxSamplesSeriesSet)
+	if err := ss.sortByLabels(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []labels.Labels{
+		labels.FromStrings(model.MetricNameLabelName, "a", "job", "x"),
+		labels.FromStrings(model.MetricNameLabelName, "a", "job", "y"),
+		labels.FromStrings(model.MetricNameLabelName, "b", "job", "x"),
+	}
+	var got []labels.Labels
+	for ss.Next() {
+		got = append(got, ss.At().Labels())
+	}
+	if ss.Err() != nil {
+		t.Fatal(ss.Err())
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("unexpected series order: got %v, wanted %v", got, expected)
+	}
+}
@@ -29,6 +29,7 @@ type PgxConn interface {
 	CopyFromRows(rows [][]interface{}) pgx.CopyFromSource
 	NewBatch() PgxBatch
 	SendBatch(ctx context.Context, b PgxBatch) (pgx.BatchResults, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
 type PgxRows interface {
@@ -135,6 +136,10 @@ func (p *connImpl) SendBatch(ctx context.Context, b PgxBatch) (pgx.BatchResults,
 	return p.Conn.SendBatch(ctx, b.(*pgx.Batch)), nil
 }
 
+func (p *connImpl) Begin(ctx context.Context) (pgx.Tx, error) {
+	return p.Conn.Begin(ctx)
+}
+
 // filters out indentation characters from the
 // SQL query for better query logging
 func filterIndentChars(query string) string {
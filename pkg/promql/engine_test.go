@@ -197,7 +197,7 @@ type errQuerier struct {
 func (q *errQuerier) Select(bool, *storage.SelectHints, *querier.QueryHints, []parser.Node, ...*labels.Matcher) (storage.SeriesSet, parser.Node) {
 	return errSeriesSet{err: q.err}, nil
 }
-func (*errQuerier) LabelValues(string) ([]string, storage.Warnings, error) {
+func (*errQuerier) LabelValues(string, ...*labels.Matcher) ([]string, storage.Warnings, error) {
 	return nil, nil, nil
 }
 func (*errQuerier) LabelNames(...*labels.Matcher) ([]string, storage.Warnings, error) {
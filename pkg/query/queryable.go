@@ -48,14 +48,17 @@ func (q queryable) newSamplesQuerier(ctx context.Context, mint, maxt int64) *sam
 	}
 }
 
-func (q samplesQuerier) LabelValues(name string) ([]string, storage.Warnings, error) {
-	lVals, err := q.labelsReader.LabelValues(name)
+// LabelValues returns the values of a label name among the series that match
+// and have samples within the querier time range.
+func (q samplesQuerier) LabelValues(name string, matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	lVals, err := q.labelsReader.LabelValues(name, q.mint, q.maxt, matchers...)
 	return lVals, nil, err
 }
 
-func (q samplesQuerier) LabelNames(_ ...*labels.Matcher) ([]string, storage.Warnings, error) {
-	// todo: implement labels matcher
-	lNames, err := q.labelsReader.LabelNames()
+// LabelNames returns the label names of the series that match and have samples
+// within the querier time range.
+func (q samplesQuerier) LabelNames(matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	lNames, err := q.labelsReader.LabelNames(q.mint, q.maxt, matchers...)
 	return lNames, nil, err
 }
 
@@ -0,0 +1,135 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/exemplar"
+	"github.com/prometheus/prometheus/pkg/labels"
+	promPromql "github.com/prometheus/prometheus/promql"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/promql"
+)
+
+// queryFunc evaluates rule expressions with Promscale's PromQL engine and
+// converts the result into the upstream types that the rules manager expects.
+func queryFunc(engine *promql.Engine, queryable promql.Queryable) promRules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promPromql.Vector, error) {
+		qry, err := engine.NewInstantQuery(queryable, qs, t)
+		if err != nil {
+			return nil, err
+		}
+		defer qry.Close()
+
+		res := qry.Exec(ctx)
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		switch v := res.Value.(type) {
+		case promql.Vector:
+			vec := make(promPromql.Vector, len(v))
+			for i := range v {
+				vec[i] = promPromql.Sample{
+					Point:  promPromql.Point{T: v[i].T, V: v[i].V},
+					Metric: v[i].Metric,
+				}
+			}
+			return vec, nil
+		case promql.Scalar:
+			return promPromql.Vector{promPromql.Sample{
+				Point:  promPromql.Point{T: v.T, V: v.V},
+				Metric: labels.Labels{},
+			}}, nil
+		default:
+			return nil, fmt.Errorf("rule result is not a vector or scalar")
+		}
+	}
+}
+
+// ingestAppendable writes the samples produced by rule evaluations through the
+// ingestor, so that they take the same path as remote-write data.
+type ingestAppendable struct {
+	inserter ingestor.DBInserter
+}
+
+func (a ingestAppendable) Appender(_ context.Context) storage.Appender {
+	return &ingestAppender{inserter: a.inserter}
+}
+
+// ingestAppender buffers the samples of a single group evaluation and ingests
+// them as one write request on Commit.
+type ingestAppender struct {
+	inserter   ingestor.DBInserter
+	timeseries []prompb.TimeSeries
+}
+
+func (a *ingestAppender) Append(_ uint64, l labels.Labels, t int64, v float64) (uint64, error) {
+	lbls := make([]prompb.Label, len(l))
+	for i := range l {
+		lbls[i] = prompb.Label{Name: l[i].Name, Value: l[i].Value}
+	}
+	a.timeseries = append(a.timeseries, prompb.TimeSeries{
+		Labels:  lbls,
+		Samples: []prompb.Sample{{Timestamp: t, Value: v}},
+	})
+	return 0, nil
+}
+
+// AppendExemplar is a no-op since rule evaluations do not produce exemplars.
+func (a *ingestAppender) AppendExemplar(_ uint64, _ labels.Labels, _ exemplar.Exemplar) (uint64, error) {
+	return 0, nil
+}
+
+func (a *ingestAppender) Commit() error {
+	if len(a.timeseries) == 0 {
+		return nil
+	}
+	wr := ingestor.NewWriteRequest()
+	wr.Timeseries = append(wr.Timeseries, a.timeseries...)
+	a.timeseries = nil
+	_, _, err := a.inserter.Ingest(wr)
+	return err
+}
+
+func (a *ingestAppender) Rollback() error {
+	a.timeseries = nil
+	return nil
+}
+
+// storageQueryable exposes Promscale's queryable as a storage.Queryable, which
+// is what the rules manager uses to read back previously written series.
+type storageQueryable struct {
+	queryable promql.Queryable
+}
+
+func (q storageQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	sq, err := q.queryable.SamplesQuerier(ctx, mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	return storageQuerier{sq}, nil
+}
+
+type storageQuerier struct {
+	promql.SamplesQuerier
+}
+
+// Select returns the series matching the matchers. The node returned by the
+// samples querier is only used by the PromQL engine for pushdowns, so it is
+// ignored here. Errors are returned as an error series set, so that rule
+// evaluation fails instead of seeing an empty result.
+func (q storageQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	ss, _ := q.SamplesQuerier.Select(sortSeries, hints, nil, nil, matchers...)
+	if err := ss.Err(); err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+	return ss
+}
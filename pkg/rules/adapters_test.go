@@ -0,0 +1,105 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rules
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+	pgquerier "github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/promql"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+type mockInserter struct {
+	timeseries []prompb.TimeSeries
+	err        error
+}
+
+func (m *mockInserter) Ingest(r *prompb.WriteRequest) (uint64, uint64, error) {
+	// The write request is returned to a pool, so the series are copied.
+	for _, ts := range r.Timeseries {
+		m.timeseries = append(m.timeseries, prompb.TimeSeries{
+			Labels:  append([]prompb.Label{}, ts.Labels...),
+			Samples: append([]prompb.Sample{}, ts.Samples...),
+		})
+	}
+	return uint64(len(r.Timeseries)), 0, m.err
+}
+
+func (m *mockInserter) IngestTraces(_ context.Context, _ pdata.Traces) error {
+	return nil
+}
+
+func TestIngestAppender(t *testing.T) {
+	inserter := &mockInserter{}
+	app := ingestAppendable{inserter}.Appender(context.Background())
+
+	_, err := app.Append(0, labels.FromStrings("__name__", "job:up:sum", "job", "a"), 1000, 1)
+	require.NoError(t, err)
+	_, err = app.Append(0, labels.FromStrings("__name__", "job:up:sum", "job", "b"), 1000, 2)
+	require.NoError(t, err)
+	require.Empty(t, inserter.timeseries, "samples must not be ingested before commit")
+
+	require.NoError(t, app.Commit())
+	require.Equal(t, []prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "job:up:sum"}, {Name: "job", Value: "a"}},
+			Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "job:up:sum"}, {Name: "job", Value: "b"}},
+			Samples: []prompb.Sample{{Timestamp: 1000, Value: 2}},
+		},
+	}, inserter.timeseries)
+
+	inserter = &mockInserter{}
+	app = ingestAppendable{inserter}.Appender(context.Background())
+	_, err = app.Append(0, labels.FromStrings("__name__", "job:up:sum"), 1000, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Rollback())
+	require.NoError(t, app.Commit())
+	require.Empty(t, inserter.timeseries)
+
+	inserter = &mockInserter{err: fmt.Errorf("some error")}
+	app = ingestAppendable{inserter}.Appender(context.Background())
+	_, err = app.Append(0, labels.FromStrings("__name__", "job:up:sum"), 1000, 1)
+	require.NoError(t, err)
+	require.Error(t, app.Commit())
+}
+
+type mockSamplesQuerier struct {
+	promql.SamplesQuerier
+	err error
+}
+
+func (m mockSamplesQuerier) Select(bool, *storage.SelectHints, *pgquerier.QueryHints, []parser.Node, ...*labels.Matcher) (storage.SeriesSet, parser.Node) {
+	return storage.ErrSeriesSet(m.err), nil
+}
+
+func TestStorageQuerierSelect(t *testing.T) {
+	querier := storageQuerier{mockSamplesQuerier{err: fmt.Errorf("some error")}}
+	ss := querier.Select(false, nil, labels.MustNewMatcher(labels.MatchEqual, "__name__", "ALERTS_FOR_STATE"))
+	require.False(t, ss.Next())
+	require.EqualError(t, ss.Err(), "some error")
+}
+
+func TestExpandRuleFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.yml", "b.yml", "c.yaml"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(""), 0600))
+	}
+	files, err := expandRuleFiles([]string{filepath.Join(dir, "*.yml"), filepath.Join(dir, "c.yaml"), filepath.Join(dir, "missing.yml")})
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "a.yml"), filepath.Join(dir, "b.yml"), filepath.Join(dir, "c.yaml")}, files)
+}
@@ -0,0 +1,119 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rules
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultEvaluationInterval  = time.Minute
+	DefaultOutageTolerance     = time.Hour
+	DefaultForGracePeriod      = 10 * time.Minute
+	DefaultResendDelay         = time.Minute
+	DefaultAlertmanagerTimeout = 10 * time.Second
+)
+
+type Config struct {
+	RuleFilesStr       string
+	RuleFiles          []string
+	EvaluationInterval time.Duration
+	OutageTolerance    time.Duration
+	ForGracePeriod     time.Duration
+	ResendDelay        time.Duration
+	ExternalURLStr     string
+	ExternalURL        *url.URL
+
+	AlertmanagerURLsStr string
+	AlertmanagerURLs    []*url.URL
+	AlertmanagerTimeout time.Duration
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.RuleFilesStr, "rule-files", "", "Comma separated list of Prometheus rule files to be evaluated by Promscale. "+
+		"File paths can contain glob patterns, e.g. 'rules/*.yml'. Rule evaluation is disabled if this is empty (default).")
+	fs.DurationVar(&cfg.EvaluationInterval, "rule-evaluation-interval", DefaultEvaluationInterval, "Default interval at which rule groups are evaluated. "+
+		"It is used for rule groups that do not specify their own interval.")
+	fs.DurationVar(&cfg.OutageTolerance, "rule-outage-tolerance", DefaultOutageTolerance, "Max time to tolerate a Promscale outage for restoring the 'for' state of alerts.")
+	fs.DurationVar(&cfg.ForGracePeriod, "rule-for-grace-period", DefaultForGracePeriod, "Minimum duration between alert and restored 'for' state. "+
+		"This is maintained only for alerts with a configured 'for' time greater than the grace period.")
+	fs.DurationVar(&cfg.ResendDelay, "rule-resend-delay", DefaultResendDelay, "Minimum amount of time to wait before resending an alert to Alertmanager.")
+	fs.StringVar(&cfg.ExternalURLStr, "rule-external-url", "", "URL under which Promscale is externally reachable. "+
+		"It is used for the generator URL of alerts sent to Alertmanager and as the external URL in alert templates.")
+	fs.StringVar(&cfg.AlertmanagerURLsStr, "alertmanager-urls", "", "Comma separated list of Alertmanager URLs to send alerts to, e.g. 'http://alertmanager:9093'. "+
+		"Alerts are not sent if this is empty (default).")
+	fs.DurationVar(&cfg.AlertmanagerTimeout, "alertmanager-timeout", DefaultAlertmanagerTimeout, "Timeout for sending alerts to Alertmanager.")
+}
+
+func Validate(cfg *Config) error {
+	if cfg.EvaluationInterval <= 0 {
+		return fmt.Errorf("'rule-evaluation-interval' must be greater than 0")
+	}
+	if cfg.AlertmanagerTimeout <= 0 {
+		return fmt.Errorf("'alertmanager-timeout' must be greater than 0")
+	}
+	cfg.RuleFiles = nil
+	for _, pattern := range splitList(cfg.RuleFilesStr) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid rule file pattern '%s': %w", pattern, err)
+		}
+		cfg.RuleFiles = append(cfg.RuleFiles, pattern)
+	}
+	cfg.AlertmanagerURLs = nil
+	for _, s := range splitList(cfg.AlertmanagerURLsStr) {
+		u, err := parseHTTPURL(s)
+		if err != nil {
+			return fmt.Errorf("invalid Alertmanager URL '%s': %w", s, err)
+		}
+		cfg.AlertmanagerURLs = append(cfg.AlertmanagerURLs, u)
+	}
+	cfg.ExternalURL = nil
+	if cfg.ExternalURLStr != "" {
+		u, err := parseHTTPURL(cfg.ExternalURLStr)
+		if err != nil {
+			return fmt.Errorf("invalid external URL '%s': %w", cfg.ExternalURLStr, err)
+		}
+		u.Path = strings.TrimRight(u.Path, "/")
+		cfg.ExternalURL = u
+	}
+	return nil
+}
+
+// Enabled returns true if rule files were configured for evaluation.
+func (cfg *Config) Enabled() bool {
+	return len(cfg.RuleFiles) > 0
+}
+
+// splitList splits a comma separated list, ignoring empty entries.
+func splitList(s string) []string {
+	var res []string
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if len(e) == 0 {
+			continue
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+func parseHTTPURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("host must not be empty")
+	}
+	return u, nil
+}
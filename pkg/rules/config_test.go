@@ -0,0 +1,66 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rules
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/peterbourgon/ff/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlags(t *testing.T) {
+	config, err := fullyParse(t, []string{})
+	require.NoError(t, err)
+	require.Equal(t, Config{
+		EvaluationInterval:  DefaultEvaluationInterval,
+		OutageTolerance:     DefaultOutageTolerance,
+		ForGracePeriod:      DefaultForGracePeriod,
+		ResendDelay:         DefaultResendDelay,
+		AlertmanagerTimeout: DefaultAlertmanagerTimeout,
+	}, config)
+	require.False(t, config.Enabled())
+
+	config, err = fullyParse(t, []string{
+		"-rule-files=rules/*.yml, other.yaml,",
+		"-rule-evaluation-interval=30s",
+		"-alertmanager-urls=http://am1:9093,https://am2/prefix",
+		"-rule-external-url=https://promscale.example.com/",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"rules/*.yml", "other.yaml"}, config.RuleFiles)
+	require.Equal(t, 30*time.Second, config.EvaluationInterval)
+	require.Len(t, config.AlertmanagerURLs, 2)
+	require.Equal(t, "http://am1:9093", config.AlertmanagerURLs[0].String())
+	require.Equal(t, "https://am2/prefix", config.AlertmanagerURLs[1].String())
+	require.Equal(t, "https://promscale.example.com", config.ExternalURL.String())
+	require.True(t, config.Enabled())
+
+	_, err = fullyParse(t, []string{"-alertmanager-urls=am1:9093"})
+	require.Error(t, err)
+
+	_, err = fullyParse(t, []string{"-rule-external-url=/promscale"})
+	require.Error(t, err)
+
+	_, err = fullyParse(t, []string{"-rule-files=rules/[.yml"})
+	require.Error(t, err)
+
+	_, err = fullyParse(t, []string{"-rule-evaluation-interval=0s"})
+	require.Error(t, err)
+}
+
+func fullyParse(t *testing.T, args []string) (Config, error) {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	config := &Config{}
+	ParseFlags(fs, config)
+	require.NoError(t, ff.Parse(fs, args))
+	err := Validate(config)
+	return *config, err
+}
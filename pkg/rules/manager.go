@@ -0,0 +1,196 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rules
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	kitLog "github.com/go-kit/kit/log"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/promql"
+)
+
+// leadershipCheckInterval is the interval at which the manager checks if it
+// should start or stop evaluating the rule groups.
+const leadershipCheckInterval = 5 * time.Second
+
+// Manager evaluates Prometheus rule groups against Promscale and writes the
+// results, including the ALERTS and ALERTS_FOR_STATE series of alerting rules,
+// back through the ingestor. Alerts are sent to the configured Alertmanagers.
+//
+// Only the leader among the connectors evaluates the rules. The rule groups
+// of the other connectors are loaded, but not evaluated. When a connector
+// becomes the leader, it restores the state of the alerts from the
+// ALERTS_FOR_STATE series.
+type Manager struct {
+	cfg      *Config
+	ctx      context.Context
+	cancel   context.CancelFunc
+	opts     *promRules.ManagerOptions
+	isLeader func() bool
+	notifier *notifier
+	wg       sync.WaitGroup
+
+	mux     sync.RWMutex
+	manager *promRules.Manager
+	files   []string
+}
+
+// NewManager returns a rules manager that evaluates the rule files from the
+// config with the given engine and queryable while isLeader returns true.
+// Rule results are ingested via the inserter. The queryable is also used to
+// restore the 'for' state of alerts from the ALERTS_FOR_STATE series.
+func NewManager(cfg *Config, engine *promql.Engine, queryable promql.Queryable, inserter ingestor.DBInserter, isLeader func() bool) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := newNotifier(cfg.AlertmanagerURLs, cfg.AlertmanagerTimeout, cfg.ExternalURL, isLeader)
+	opts := &promRules.ManagerOptions{
+		ExternalURL:     cfg.ExternalURL,
+		QueryFunc:       queryFunc(engine, queryable),
+		NotifyFunc:      n.notifyFunc(),
+		Context:         ctx,
+		Appendable:      ingestAppendable{inserter},
+		Queryable:       storageQueryable{queryable},
+		Logger:          kitLog.With(log.GetLogger(), "component", "rules"),
+		OutageTolerance: cfg.OutageTolerance,
+		ForGracePeriod:  cfg.ForGracePeriod,
+		ResendDelay:     cfg.ResendDelay,
+	}
+	return &Manager{
+		cfg:      cfg,
+		ctx:      ctx,
+		cancel:   cancel,
+		opts:     opts,
+		isLeader: isLeader,
+		notifier: n,
+		manager:  promRules.NewManager(opts),
+	}
+}
+
+// LoadRules (re)loads the rule groups from the configured rule files.
+func (m *Manager) LoadRules() error {
+	files, err := expandRuleFiles(m.cfg.RuleFiles)
+	if err != nil {
+		return err
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if err = m.update(m.manager, files); err != nil {
+		return err
+	}
+	m.files = files
+	return nil
+}
+
+func (m *Manager) update(manager *promRules.Manager, files []string) error {
+	externalURL := ""
+	if m.cfg.ExternalURL != nil {
+		externalURL = m.cfg.ExternalURL.String()
+	}
+	if err := manager.Update(m.cfg.EvaluationInterval, files, nil, externalURL); err != nil {
+		return fmt.Errorf("loading rule files: %w", err)
+	}
+	return nil
+}
+
+// Run starts the evaluation of the loaded rule groups and the sending of
+// alerts whenever this connector is the leader. It does not block.
+func (m *Manager) Run() {
+	go m.notifier.run(m.ctx)
+	m.wg.Add(1)
+	go m.run()
+}
+
+func (m *Manager) run() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(leadershipCheckInterval)
+	defer ticker.Stop()
+
+	evaluating := false
+	for {
+		leader := m.isLeader()
+		switch {
+		case leader && !evaluating:
+			log.Info("msg", "Acting as leader: starting rule evaluation")
+			m.startEvaluation()
+			evaluating = true
+		case !leader && evaluating:
+			log.Info("msg", "No longer a leader: stopping rule evaluation")
+			if err := m.stopEvaluation(); err != nil {
+				log.Error("msg", "Reloading rules after losing leadership failed", "err", err)
+			}
+			evaluating = false
+		}
+
+		select {
+		case <-m.ctx.Done():
+			if evaluating {
+				m.mux.RLock()
+				m.manager.Stop()
+				m.mux.RUnlock()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startEvaluation starts the evaluation of the loaded rule groups. The 'for'
+// state of the alerts is restored on the first evaluation of each group.
+func (m *Manager) startEvaluation() {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	// Run blocks until the manager is stopped.
+	go m.manager.Run()
+}
+
+// stopEvaluation stops the evaluation of the rule groups, drops the alerts
+// that were not sent yet and loads the rule groups into a new manager, since
+// a stopped manager cannot be run again.
+func (m *Manager) stopEvaluation() error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.manager.Stop()
+	m.notifier.drop()
+	m.manager = promRules.NewManager(m.opts)
+	return m.update(m.manager, m.files)
+}
+
+// Stop stops the evaluation of all rule groups.
+func (m *Manager) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// RuleGroups returns the loaded rule groups.
+func (m *Manager) RuleGroups() []*promRules.Group {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return m.manager.RuleGroups()
+}
+
+// AlertingRules returns the loaded alerting rules.
+func (m *Manager) AlertingRules() []*promRules.AlertingRule {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return m.manager.AlertingRules()
+}
+
+func expandRuleFiles(patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("expanding rule file pattern '%s': %w", pattern, err)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
@@ -0,0 +1,44 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rules
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+var (
+	sentAlerts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Name:      "alerts_sent_total",
+			Help:      "Total number of alerts sent to Alertmanager.",
+		},
+		[]string{"alertmanager"},
+	)
+	alertNotificationErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Name:      "alert_notification_errors_total",
+			Help:      "Total number of errors while sending alerts to Alertmanager.",
+		},
+		[]string{"alertmanager"},
+	)
+	droppedAlerts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Name:      "alerts_dropped_total",
+			Help:      "Total number of alerts dropped because the notification queue was full.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		sentAlerts,
+		alertNotificationErrors,
+		droppedAlerts,
+	)
+}
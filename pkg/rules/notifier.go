@@ -0,0 +1,212 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/util/strutil"
+	"github.com/timescale/promscale/pkg/log"
+)
+
+const (
+	alertsQueueCapacity = 10000
+	maxAlertsBatchSize  = 64
+	alertmanagerAPIPath = "/api/v2/alerts"
+)
+
+// alert is an alert in the format of the Alertmanager v2 API.
+type alert struct {
+	Labels       labels.Labels `json:"labels"`
+	Annotations  labels.Labels `json:"annotations"`
+	StartsAt     time.Time     `json:"startsAt"`
+	EndsAt       time.Time     `json:"endsAt"`
+	GeneratorURL string        `json:"generatorURL,omitempty"`
+}
+
+// notifier sends the alerts of the alerting rules to a static list of
+// Alertmanagers. Alerts are queued and sent in batches in the background,
+// so that rule evaluation is never blocked by a slow Alertmanager. Alerts are
+// only queued and sent while the connector is the leader, so that a connector
+// that lost the leadership does not send stale alerts.
+type notifier struct {
+	alertmanagers []string
+	client        *http.Client
+	externalURL   string
+	isLeader      func() bool
+
+	mux   sync.Mutex
+	queue []*alert
+	more  chan struct{}
+}
+
+func newNotifier(alertmanagers []*url.URL, timeout time.Duration, externalURL *url.URL, isLeader func() bool) *notifier {
+	n := &notifier{
+		alertmanagers: make([]string, len(alertmanagers)),
+		client:        &http.Client{Timeout: timeout},
+		isLeader:      isLeader,
+		more:          make(chan struct{}, 1),
+	}
+	for i, am := range alertmanagers {
+		u := *am
+		u.Path = path.Join(u.Path, alertmanagerAPIPath)
+		n.alertmanagers[i] = u.String()
+	}
+	if externalURL != nil {
+		n.externalURL = externalURL.String()
+	}
+	return n
+}
+
+// notifyFunc converts the alerts of a rule into Alertmanager alerts and queues them.
+func (n *notifier) notifyFunc() promRules.NotifyFunc {
+	return func(_ context.Context, expr string, alerts ...*promRules.Alert) {
+		if len(alerts) == 0 || !n.isLeader() {
+			return
+		}
+		res := make([]*alert, 0, len(alerts))
+		for _, a := range alerts {
+			amAlert := &alert{
+				Labels:       a.Labels,
+				Annotations:  a.Annotations,
+				StartsAt:     a.FiredAt,
+				GeneratorURL: n.externalURL + strutil.TableLinkForExpression(expr),
+			}
+			if !a.ResolvedAt.IsZero() {
+				amAlert.EndsAt = a.ResolvedAt
+			} else {
+				amAlert.EndsAt = a.ValidUntil
+			}
+			res = append(res, amAlert)
+		}
+		n.send(res...)
+	}
+}
+
+// send queues the alerts. If the queue is full, the oldest alerts are dropped.
+func (n *notifier) send(alerts ...*alert) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if d := len(alerts) - alertsQueueCapacity; d > 0 {
+		alerts = alerts[d:]
+		droppedAlerts.Add(float64(d))
+	}
+	if d := len(n.queue) + len(alerts) - alertsQueueCapacity; d > 0 {
+		n.queue = n.queue[d:]
+		droppedAlerts.Add(float64(d))
+	}
+	n.queue = append(n.queue, alerts...)
+	n.setMore()
+}
+
+func (n *notifier) setMore() {
+	select {
+	case n.more <- struct{}{}:
+	default:
+	}
+}
+
+// drop removes all queued alerts.
+func (n *notifier) drop() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.queue = n.queue[:0]
+}
+
+func (n *notifier) nextBatch() []*alert {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	var batch []*alert
+	if len(n.queue) > maxAlertsBatchSize {
+		batch = append(make([]*alert, 0, maxAlertsBatchSize), n.queue[:maxAlertsBatchSize]...)
+		n.queue = n.queue[maxAlertsBatchSize:]
+	} else {
+		batch = append(make([]*alert, 0, len(n.queue)), n.queue...)
+		n.queue = n.queue[:0]
+	}
+	return batch
+}
+
+// run sends the queued alerts until the context is done. The queued alerts
+// are dropped if the connector is no longer the leader.
+func (n *notifier) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.more:
+		}
+		for {
+			if !n.isLeader() {
+				n.drop()
+				break
+			}
+			batch := n.nextBatch()
+			if len(batch) == 0 {
+				break
+			}
+			n.sendAll(ctx, batch)
+		}
+	}
+}
+
+// sendAll sends the alerts to all Alertmanagers concurrently.
+func (n *notifier) sendAll(ctx context.Context, alerts []*alert) {
+	b, err := json.Marshal(alerts)
+	if err != nil {
+		log.Error("msg", "Encoding alerts failed", "err", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, am := range n.alertmanagers {
+		wg.Add(1)
+		go func(am string) {
+			defer wg.Done()
+			if err := n.sendOne(ctx, am, b); err != nil {
+				log.Error("msg", "Error sending alerts", "alertmanager", am, "count", len(alerts), "err", err)
+				alertNotificationErrors.WithLabelValues(am).Inc()
+				return
+			}
+			sentAlerts.WithLabelValues(am).Add(float64(len(alerts)))
+		}(am)
+	}
+	wg.Wait()
+}
+
+func (n *notifier) sendOne(ctx context.Context, url string, b []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bad response status %s", resp.Status)
+	}
+	return nil
+}
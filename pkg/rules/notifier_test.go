@@ -0,0 +1,125 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifier(t *testing.T) {
+	var (
+		mux      sync.Mutex
+		received []map[string]interface{}
+		paths    []string
+		done     = make(chan struct{}, 2)
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alerts []map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&alerts))
+		mux.Lock()
+		received = append(received, alerts...)
+		paths = append(paths, r.URL.Path)
+		mux.Unlock()
+		done <- struct{}{}
+	})
+	am1 := httptest.NewServer(handler)
+	defer am1.Close()
+	am2 := httptest.NewServer(handler)
+	defer am2.Close()
+
+	am1URL, err := url.Parse(am1.URL)
+	require.NoError(t, err)
+	am2URL, err := url.Parse(am2.URL + "/prefix")
+	require.NoError(t, err)
+	externalURL, err := url.Parse("http://promscale:9201")
+	require.NoError(t, err)
+
+	n := newNotifier([]*url.URL{am1URL, am2URL}, time.Second, externalURL, func() bool { return true })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.run(ctx)
+
+	firedAt := time.Unix(100, 0).UTC()
+	resolvedAt := time.Unix(200, 0).UTC()
+	n.notifyFunc()(ctx, "up == 0", &promRules.Alert{
+		State:       promRules.StateFiring,
+		Labels:      labels.FromStrings("alertname", "InstanceDown", "instance", "a"),
+		Annotations: labels.FromStrings("summary", "instance a is down"),
+		FiredAt:     firedAt,
+		ResolvedAt:  resolvedAt,
+	})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for alerts")
+		}
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	require.ElementsMatch(t, []string{"/api/v2/alerts", "/prefix/api/v2/alerts"}, paths)
+	require.Len(t, received, 2)
+	for _, a := range received {
+		require.Equal(t, map[string]interface{}{"alertname": "InstanceDown", "instance": "a"}, a["labels"])
+		require.Equal(t, map[string]interface{}{"summary": "instance a is down"}, a["annotations"])
+		require.Equal(t, firedAt.Format(time.RFC3339), a["startsAt"])
+		require.Equal(t, resolvedAt.Format(time.RFC3339), a["endsAt"])
+		require.Equal(t, "http://promscale:9201/graph?g0.expr=up+%3D%3D+0&g0.tab=1", a["generatorURL"])
+	}
+}
+
+func TestNotifierQueueCapacity(t *testing.T) {
+	n := newNotifier(nil, time.Second, nil, func() bool { return true })
+	alerts := make([]*alert, alertsQueueCapacity+10)
+	for i := range alerts {
+		alerts[i] = &alert{Labels: labels.FromStrings("i", string(rune('a'+i%26)))}
+	}
+	n.send(alerts[:10]...)
+	n.send(alerts[10:]...)
+	require.Len(t, n.queue, alertsQueueCapacity)
+	require.Equal(t, alerts[10], n.queue[0], "oldest alerts must be dropped first")
+
+	batch := n.nextBatch()
+	require.Len(t, batch, maxAlertsBatchSize)
+	require.Len(t, n.queue, alertsQueueCapacity-maxAlertsBatchSize)
+}
+
+func TestNotifierNotLeader(t *testing.T) {
+	var leader atomic.Value
+	leader.Store(true)
+	n := newNotifier(nil, time.Second, nil, func() bool { return leader.Load().(bool) })
+	notify := n.notifyFunc()
+	a := &promRules.Alert{Labels: labels.FromStrings("alertname", "InstanceDown")}
+
+	notify(context.Background(), "up == 0", a)
+	require.Len(t, n.queue, 1)
+
+	leader.Store(false)
+	notify(context.Background(), "up == 0", a)
+	require.Len(t, n.queue, 1, "alerts must not be queued by a follower")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.run(ctx)
+	require.Eventually(t, func() bool {
+		n.mux.Lock()
+		defer n.mux.Unlock()
+		return len(n.queue) == 0
+	}, 5*time.Second, 10*time.Millisecond, "queued alerts must be dropped by a follower")
+}
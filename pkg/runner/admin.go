@@ -0,0 +1,140 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package runner
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/timescale/promscale/pkg/pgclient"
+	"github.com/timescale/promscale/pkg/pgmodel/admin"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+const adminUsage = `Usage: promscale admin <command> [flags] [args]
+
+Commands:
+  drop-metric [flags] <regex>  Drop all metrics whose name matches the fully anchored regex,
+                               along with all their data and series. No Promscale connectors
+                               may be attached to the database while metrics are dropped.
+                               Connectors running without the schema version lease must be
+                               restarted afterwards, as their caches are stale.
+`
+
+// RunAdmin runs the administrative command given by args, e.g. "drop-metric", reading
+// confirmations from in and writing its output to out.
+func RunAdmin(args []string, in io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, adminUsage)
+		return fmt.Errorf("no admin command provided")
+	}
+	switch args[0] {
+	case "drop-metric":
+		return runDropMetric(args[1:], in, out)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(out, adminUsage)
+		return nil
+	}
+	fmt.Fprint(out, adminUsage)
+	return fmt.Errorf("unknown admin command: %s", args[0])
+}
+
+func runDropMetric(args []string, in io.Reader, out io.Writer) error {
+	var (
+		fs     = flag.NewFlagSet("promscale admin drop-metric", flag.ContinueOnError)
+		cfg    pgclient.Config
+		dryRun bool
+		yes    bool
+	)
+	fs.SetOutput(out)
+	pgclient.ParseFlags(fs, &cfg)
+	fs.BoolVar(&dryRun, "dry-run", false, "Only list the metrics that would be dropped.")
+	fs.BoolVar(&yes, "yes", false, "Drop the metrics without asking for confirmation.")
+	fs.Usage = func() {
+		fmt.Fprintf(out, "Usage: promscale admin drop-metric [flags] <regex>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := util.ParseEnv("PROMSCALE", fs); err != nil {
+		return fmt.Errorf("error parsing env variables: %w", err)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one metric name regex, got %d arguments", fs.NArg())
+	}
+	matcher, err := admin.CompileMetricMatcher(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid metric name regex: %w", err)
+	}
+
+	pool, err := pgxpool.Connect(context.Background(), cfg.GetConnectionStr())
+	if err != nil {
+		return fmt.Errorf("connecting to the database: %w", err)
+	}
+	conn := pgxconn.NewPgxConn(pool)
+	defer conn.Close()
+
+	return dropMetrics(&admin.PgAdmin{Conn: conn}, matcher, dryRun, yes, in, out)
+}
+
+// dropMetrics lists the metrics matching the regex and, unless it is a dry run,
+// drops them after the user confirmed it or if yes is set.
+func dropMetrics(dropper admin.MetricDropper, matcher *regexp.Regexp, dryRun, yes bool, in io.Reader, out io.Writer) error {
+	metrics, err := dropper.MatchMetrics(matcher)
+	if err != nil {
+		return err
+	}
+	if len(metrics) == 0 {
+		fmt.Fprintf(out, "No metrics match %s.\n", matcher)
+		return nil
+	}
+
+	var totalSeries int64
+	fmt.Fprintf(out, "Metrics matching %s:\n", matcher)
+	for _, m := range metrics {
+		fmt.Fprintf(out, "  %s (%d series)\n", m.Metric, m.Series)
+		totalSeries += m.Series
+	}
+	if dryRun {
+		fmt.Fprintf(out, "Dry run: %d metrics with %d series in total would be dropped.\n", len(metrics), totalSeries)
+		return nil
+	}
+
+	if !yes {
+		fmt.Fprintf(out, "Drop %d metrics with %d series in total? This cannot be undone. [y/N] ", len(metrics), totalSeries)
+		answer, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("reading confirmation: %w", err)
+		}
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Fprintln(out, "Aborted, no metrics were dropped.")
+			return nil
+		}
+	}
+
+	err = admin.DropMetrics(dropper, metrics)
+	dropped := 0
+	for _, m := range metrics {
+		if m.Dropped {
+			fmt.Fprintf(out, "Dropped %s\n", m.Metric)
+			dropped++
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("dropped %d of %d metrics: %w", dropped, len(metrics), err)
+	}
+	fmt.Fprintf(out, "Dropped %d metrics with %d series in total.\n", len(metrics), totalSeries)
+	return nil
+}
@@ -0,0 +1,121 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package runner
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/admin"
+)
+
+type mockMetricDropper struct {
+	metrics []admin.DroppedMetric
+	dropped []string
+}
+
+func (m *mockMetricDropper) MatchMetrics(matcher *regexp.Regexp) ([]admin.DroppedMetric, error) {
+	res := make([]admin.DroppedMetric, 0)
+	for _, metric := range m.metrics {
+		if matcher.MatchString(metric.Metric) {
+			res = append(res, metric)
+		}
+	}
+	return res, nil
+}
+
+func (m *mockMetricDropper) DropMetric(metric string) error {
+	m.dropped = append(m.dropped, metric)
+	return nil
+}
+
+func TestDropMetrics(t *testing.T) {
+	testCases := []struct {
+		name    string
+		regex   string
+		dryRun  bool
+		yes     bool
+		input   string
+		dropped []string
+		output  string
+	}{
+		{
+			name:   "no match",
+			regex:  "container",
+			output: "No metrics match ^(?:container)$.\n",
+		},
+		{
+			name:   "dry run",
+			regex:  "container_.*",
+			dryRun: true,
+			output: `Metrics matching ^(?:container_.*)$:
+  container_cpu (10 series)
+  container_memory (20 series)
+Dry run: 2 metrics with 30 series in total would be dropped.
+`,
+		},
+		{
+			name:  "aborted",
+			regex: "container_.*",
+			input: "n\n",
+			output: `Metrics matching ^(?:container_.*)$:
+  container_cpu (10 series)
+  container_memory (20 series)
+Drop 2 metrics with 30 series in total? This cannot be undone. [y/N] Aborted, no metrics were dropped.
+`,
+		},
+		{
+			name:    "confirmed",
+			regex:   "container_cpu|up",
+			input:   "yes\n",
+			dropped: []string{"container_cpu", "up"},
+			output: `Metrics matching ^(?:container_cpu|up)$:
+  container_cpu (10 series)
+  up (1 series)
+Drop 2 metrics with 11 series in total? This cannot be undone. [y/N] Dropped container_cpu
+Dropped up
+Dropped 2 metrics with 11 series in total.
+`,
+		},
+		{
+			name:    "no confirmation needed",
+			regex:   "up",
+			yes:     true,
+			dropped: []string{"up"},
+			output: `Metrics matching ^(?:up)$:
+  up (1 series)
+Dropped up
+Dropped 1 metrics with 1 series in total.
+`,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			dropper := &mockMetricDropper{metrics: []admin.DroppedMetric{
+				{Metric: "container_cpu", Series: 10},
+				{Metric: "container_memory", Series: 20},
+				{Metric: "up", Series: 1},
+			}}
+			matcher, err := admin.CompileMetricMatcher(c.regex)
+			require.NoError(t, err)
+			out := &bytes.Buffer{}
+			require.NoError(t, dropMetrics(dropper, matcher, c.dryRun, c.yes, strings.NewReader(c.input), out))
+			require.Equal(t, c.output, out.String())
+			require.Equal(t, c.dropped, dropper.dropped)
+		})
+	}
+}
+
+func TestRunAdminErrors(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.Error(t, RunAdmin(nil, nil, out))
+	require.Error(t, RunAdmin([]string{"unknown"}, nil, out))
+	require.Error(t, RunAdmin([]string{"drop-metric"}, nil, out))
+	require.Error(t, RunAdmin([]string{"drop-metric", "("}, nil, out))
+	require.NoError(t, RunAdmin([]string{"help"}, nil, out))
+}
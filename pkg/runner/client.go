@@ -123,6 +123,12 @@ func CreateClient(cfg *Config, promMetrics *api.Metrics) (*pgclient.Client, erro
 		return nil, err
 	}
 
+	if !cfg.APICfg.ReadOnly {
+		if err = pgclient.ApplyTraceSettings(conn, &cfg.PgmodelCfg); err != nil {
+			return nil, fmt.Errorf("applying trace settings: %w", err)
+		}
+	}
+
 	if cfg.InstallExtensions {
 		// Only check for background workers if TimessaleDB is installed.
 		if notOk, err := isBGWLessThanDBs(conn); err != nil {
@@ -257,6 +263,17 @@ func initElector(cfg *Config, metrics *api.Metrics) (*util.Elector, error) {
 	return &scheduledElector.Elector, nil
 }
 
+// initRulesElector creates the elector for rule evaluation. All connectors
+// evaluating rules compete for the same advisory lock, irrespective of their
+// HA setup, so that the rules are evaluated by a single connector only.
+func initRulesElector(cfg *Config) (*util.Elector, error) {
+	lock, err := util.NewPgLeaderLock(schema.RulesLockID, cfg.PgmodelCfg.GetConnectionStr(), getSchemaLease)
+	if err != nil {
+		return nil, fmt.Errorf("creating advisory lock for rule evaluation: %w", err)
+	}
+	return &util.NewScheduledElector(lock, cfg.ElectionInterval).Elector, nil
+}
+
 func SetupDBState(conn *pgx.Conn, appVersion pgmodel.VersionInfo, leaseLock *util.PgAdvisoryLock, extOptions extension.ExtensionMigrateOptions) error {
 	// At startup migrators attempt to grab the schema-version lock. If this
 	// fails that means some other connector is running. All is not lost: some
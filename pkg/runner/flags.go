@@ -17,6 +17,7 @@ import (
 	"github.com/timescale/promscale/pkg/limits"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgclient"
+	"github.com/timescale/promscale/pkg/rules"
 	"github.com/timescale/promscale/pkg/tenancy"
 	"github.com/timescale/promscale/pkg/util"
 )
@@ -30,6 +31,7 @@ type Config struct {
 	APICfg                      api.Config
 	LimitsCfg                   limits.Config
 	TenancyCfg                  tenancy.Config
+	RulesCfg                    rules.Config
 	ConfigFile                  string
 	TLSCertFile                 string
 	TLSKeyFile                  string
@@ -59,6 +61,7 @@ func ParseFlags(cfg *Config, args []string) (*Config, error) {
 	api.ParseFlags(fs, &cfg.APICfg)
 	limits.ParseFlags(fs, &cfg.LimitsCfg)
 	tenancy.ParseFlags(fs, &cfg.TenancyCfg)
+	rules.ParseFlags(fs, &cfg.RulesCfg)
 
 	fs.StringVar(&cfg.ConfigFile, "config", "config.yml", "YAML configuration file path for Promscale.")
 	fs.StringVar(&cfg.ListenAddr, "web-listen-address", ":9201", "Address to listen on for web endpoints.")
@@ -136,6 +139,9 @@ func ParseFlags(cfg *Config, args []string) (*Config, error) {
 		if flagset["install-extensions"] && cfg.InstallExtensions {
 			return nil, fmt.Errorf("Cannot install or update TimescaleDB extension in read-only mode")
 		}
+		if cfg.RulesCfg.Enabled() {
+			return nil, fmt.Errorf("Cannot evaluate rules in read-only mode since rule results are written to the database")
+		}
 		cfg.Migrate = false
 		cfg.StopAfterMigrate = false
 		cfg.UseVersionLease = false
@@ -163,5 +169,8 @@ func validate(cfg *Config) error {
 	if err := tenancy.Validate(&cfg.TenancyCfg); err != nil {
 		return fmt.Errorf("error validating multi-tenancy configuration: %w", err)
 	}
+	if err := rules.Validate(&cfg.RulesCfg); err != nil {
+		return fmt.Errorf("error validating rules configuration: %w", err)
+	}
 	return nil
 }
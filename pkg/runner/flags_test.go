@@ -9,6 +9,9 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/timescale/promscale/pkg/pgclient"
 )
 
 func TestParseFlags(t *testing.T) {
@@ -138,6 +141,101 @@ func TestParseFlags(t *testing.T) {
 			},
 			shouldError: true,
 		},
+		{
+			name: "Trace retention and compression settings",
+			args: []string{
+				"-tracing-retention-period", "168h",
+				"-tracing-service-retention-periods", "frontend=72h, backend=720h, env=prod=24h",
+				"-tracing-compression", "false",
+			},
+			result: func(c Config) Config {
+				c.PgmodelCfg.TraceRetentionPeriod = 168 * time.Hour
+				c.PgmodelCfg.ServiceRetentionPeriods = pgclient.ServiceRetentionPeriods{
+					"frontend": 72 * time.Hour,
+					"backend":  720 * time.Hour,
+					"env=prod": 24 * time.Hour,
+				}
+				c.PgmodelCfg.TraceCompression = "false"
+				return c
+			},
+		},
+		{
+			name:        "Invalid service retention period",
+			args:        []string{"-tracing-service-retention-periods", "frontend"},
+			shouldError: true,
+		},
+		{
+			name:        "Negative service retention period",
+			args:        []string{"-tracing-service-retention-periods", "frontend=-1h"},
+			shouldError: true,
+		},
+		{
+			name:        "Invalid trace compression option",
+			args:        []string{"-tracing-compression", "maybe"},
+			shouldError: true,
+		},
+		{
+			name: "Trace sampling settings",
+			args: []string{
+				"-tracing-sampling-rate", "0.1",
+				"-tracing-service-sampling-rates", "frontend=0.5,backend=1",
+				"-tracing-tail-sampling-errors",
+				"-tracing-tail-sampling-latency", "2s",
+				"-tracing-tail-sampling-attributes", "http.status_code=500,http.url=/?a=b",
+			},
+			result: func(c Config) Config {
+				c.PgmodelCfg.TraceSamplingRate = 0.1
+				c.PgmodelCfg.ServiceSamplingRates = pgclient.ServiceSamplingRates{
+					"frontend": 0.5,
+					"backend":  1,
+				}
+				c.PgmodelCfg.TailSamplingErrors = true
+				c.PgmodelCfg.TailSamplingLatency = 2 * time.Second
+				c.PgmodelCfg.TailSamplingAttributes = pgclient.SamplingAttributes{"http.status_code": "500", "http.url": "/?a=b"}
+				return c
+			},
+		},
+		{
+			name:        "Invalid trace sampling rate",
+			args:        []string{"-tracing-sampling-rate", "1.5"},
+			shouldError: true,
+		},
+		{
+			name:        "Invalid service sampling rate",
+			args:        []string{"-tracing-service-sampling-rates", "frontend=2"},
+			shouldError: true,
+		},
+		{
+			name: "Trace batching settings",
+			args: []string{
+				"-tracing-async-acks",
+				"-tracing-batch-writers", "8",
+				"-tracing-max-batch-size", "10000",
+				"-tracing-batch-timeout", "1s",
+			},
+			result: func(c Config) Config {
+				c.PgmodelCfg.TraceAsyncAcks = true
+				c.PgmodelCfg.TraceBatchWriters = 8
+				c.PgmodelCfg.TraceMaxBatchSize = 10000
+				c.PgmodelCfg.TraceBatchTimeout = time.Second
+				return c
+			},
+		},
+		{
+			name:        "Invalid trace max batch size",
+			args:        []string{"-tracing-max-batch-size", "0"},
+			shouldError: true,
+		},
+		{
+			name:        "Invalid attribute rule",
+			args:        []string{"-tracing-attribute-rule", "redact:password"},
+			shouldError: true,
+		},
+		{
+			name:        "Hash attribute rule without hash key",
+			args:        []string{"-tracing-attribute-rule", "hash:user\\.email"},
+			shouldError: true,
+		},
 		{
 			name: "invalid env variable type causing parse error, PROMSCALE prefix",
 			env: map[string]string{
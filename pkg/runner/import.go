@@ -0,0 +1,95 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package runner
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/timescale/promscale/pkg/importer"
+	"github.com/timescale/promscale/pkg/limits"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgclient"
+	"github.com/timescale/promscale/pkg/tenancy"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+// ImportConfig is the configuration of the import command.
+type ImportConfig struct {
+	PgmodelCfg  pgclient.Config
+	LogCfg      log.Config
+	LimitsCfg   limits.Config
+	ImporterCfg importer.Config
+	Paths       []string
+}
+
+// ParseImportFlags parses the flags and arguments of the import command.
+func ParseImportFlags(args []string, out io.Writer) (*ImportConfig, error) {
+	var (
+		cfg = &ImportConfig{}
+		fs  = flag.NewFlagSet("promscale import", flag.ContinueOnError)
+	)
+	fs.SetOutput(out)
+	pgclient.ParseFlags(fs, &cfg.PgmodelCfg)
+	log.ParseFlags(fs, &cfg.LogCfg)
+	limits.ParseFlags(fs, &cfg.LimitsCfg)
+	importer.ParseFlags(fs, &cfg.ImporterCfg)
+	fs.Usage = func() {
+		fmt.Fprintf(out, `Usage: promscale import [flags] <path>...
+
+Backfills the data of OpenMetrics or Prometheus text files, Prometheus TSDB block
+directories or Prometheus data directories containing TSDB blocks.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+
+	if err := util.ParseEnv("PROMSCALE", fs); err != nil {
+		return nil, fmt.Errorf("error parsing env variables: %w", err)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return nil, fmt.Errorf("no paths to import provided")
+	}
+	cfg.Paths = fs.Args()
+
+	if err := limits.Validate(&cfg.LimitsCfg); err != nil {
+		return nil, fmt.Errorf("error validating limits configuration: %w", err)
+	}
+	if err := pgclient.Validate(&cfg.PgmodelCfg, cfg.LimitsCfg); err != nil {
+		return nil, fmt.Errorf("error validating client configuration: %w", err)
+	}
+	if err := importer.Validate(&cfg.ImporterCfg); err != nil {
+		return nil, fmt.Errorf("error validating import configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// RunImport imports the data of the configured paths through the ingestor, holding
+// the schema version lease like a connector does.
+func RunImport(cfg *ImportConfig) error {
+	// The checkpoint of a batch is recorded once the batch is ingested, so the
+	// batches must be written before the ingestor acknowledges them.
+	cfg.PgmodelCfg.AsyncAcks = false
+	client, err := pgclient.NewClient(&cfg.PgmodelCfg, tenancy.NewNoopAuthorizer(), getSchemaLease, false)
+	if err != nil {
+		return fmt.Errorf("client creation error: %w", err)
+	}
+	defer client.Close()
+
+	imp, err := importer.New(cfg.ImporterCfg, client.Ingestor())
+	if err != nil {
+		return err
+	}
+	if _, err = imp.Import(cfg.Paths...); err != nil {
+		return err
+	}
+	return client.Ingestor().CompleteMetricCreation()
+}
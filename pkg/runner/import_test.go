@@ -0,0 +1,26 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package runner
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImportFlags(t *testing.T) {
+	cfg, err := ParseImportFlags([]string{"-import-batch-size", "1000", "a.prom", "data/"}, ioutil.Discard)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.prom", "data/"}, cfg.Paths)
+	require.Equal(t, 1000, cfg.ImporterCfg.BatchSize)
+	require.Equal(t, "promscale-import-checkpoint.json", cfg.ImporterCfg.CheckpointFile)
+
+	_, err = ParseImportFlags([]string{}, ioutil.Discard)
+	require.Error(t, err)
+
+	_, err = ParseImportFlags([]string{"-import-batch-size", "0", "a.prom"}, ioutil.Discard)
+	require.Error(t, err)
+}
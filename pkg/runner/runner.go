@@ -19,6 +19,12 @@ import (
 	"github.com/timescale/promscale/pkg/api"
 	"github.com/timescale/promscale/pkg/jaeger/query"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgclient"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	promscaleQuery "github.com/timescale/promscale/pkg/query"
+	"github.com/timescale/promscale/pkg/rules"
+	"github.com/timescale/promscale/pkg/tenancy"
 	"github.com/timescale/promscale/pkg/thanos"
 	"github.com/timescale/promscale/pkg/util"
 	tput "github.com/timescale/promscale/pkg/util/throughput"
@@ -57,6 +63,9 @@ func Run(cfg *Config) error {
 	redacted := *cfg
 	redacted.PgmodelCfg.Password = "****"
 	redacted.PgmodelCfg.DbUri = "****"
+	if redacted.PgmodelCfg.AttributeHashKey != "" {
+		redacted.PgmodelCfg.AttributeHashKey = "****"
+	}
 	log.Info("config", fmt.Sprintf("%+v", redacted))
 
 	if cfg.APICfg.ReadOnly {
@@ -78,7 +87,19 @@ func Run(cfg *Config) error {
 
 	defer client.Close()
 
-	router, err := api.GenerateRouter(&cfg.APICfg, client, elector)
+	if cfg.RulesCfg.Enabled() {
+		rulesManager, err := createRulesManager(cfg, client)
+		if err != nil {
+			log.Error("msg", "aborting startup due to error", "err", fmt.Sprintf("create rules manager: %s", err.Error()))
+			return fmt.Errorf("create rules manager: %w", err)
+		}
+		rulesManager.Run()
+		defer rulesManager.Stop()
+		cfg.APICfg.Rules = rulesManager
+	}
+
+	dataParser := api.NewWriteParser(&cfg.APICfg, client)
+	router, err := api.GenerateRouter(&cfg.APICfg, client, dataParser, elector)
 	if err != nil {
 		log.Error("msg", "aborting startup due to error", "err", fmt.Sprintf("generate router: %s", err.Error()))
 		return fmt.Errorf("generate router: %w", err)
@@ -131,10 +152,27 @@ func Run(cfg *Config) error {
 			options = append(options, grpc.Creds(creds))
 		}
 		grpcServer := grpc.NewServer(options...)
-		otlpgrpc.RegisterTracesServer(grpcServer, api.NewTraceServer(client))
+		var traceWriteAuth tenancy.WriteAuthorizer
+		if cfg.APICfg.MultiTenancy != nil {
+			traceWriteAuth = cfg.APICfg.MultiTenancy.WriteAuthorizer()
+		}
+		otlpgrpc.RegisterTracesServer(grpcServer, api.NewTraceServer(client, traceWriteAuth))
+		if !cfg.APICfg.ReadOnly {
+			otlpgrpc.RegisterMetricsServer(grpcServer, api.NewMetricsServer(client, dataParser, elector))
+		}
 
+		var (
+			writeConn pgxconn.PgxConn
+			inserter  ingestor.DBInserter
+		)
+		if !cfg.APICfg.ReadOnly {
+			writeConn = client.Connection
+			inserter = client
+		}
+		jaegerQuery := query.New(client.QuerierConnection, writeConn, inserter, cfg.APICfg.MultiTenancy)
 		queryPlugin := shared.StorageGRPCPlugin{
-			Impl: query.New(client.QuerierConnection),
+			Impl:        jaegerQuery,
+			ArchiveImpl: jaegerQuery,
 		}
 		err := queryPlugin.GRPCServer(nil, grpcServer)
 		if err != nil {
@@ -174,3 +212,30 @@ func Run(cfg *Config) error {
 
 	return nil
 }
+
+// createRulesManager creates a rules manager that evaluates the configured
+// rule files with its own PromQL engine and loads the rule groups. The rules
+// are only evaluated by the connector that holds the rules advisory lock.
+func createRulesManager(cfg *Config, client *pgclient.Client) (*rules.Manager, error) {
+	apiCfg := cfg.APICfg
+	engine, err := promscaleQuery.NewEngine(log.GetLogger(), apiCfg.MaxQueryTimeout, apiCfg.LookBackDelta, apiCfg.SubQueryStepInterval, apiCfg.MaxSamples, apiCfg.EnabledFeaturesList)
+	if err != nil {
+		return nil, fmt.Errorf("creating query-engine: %w", err)
+	}
+	rulesElector, err := initRulesElector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	manager := rules.NewManager(&cfg.RulesCfg, engine, client.Queryable(), client.Ingestor(), func() bool {
+		leader, err := rulesElector.IsLeader()
+		if err != nil {
+			log.Error("msg", "IsLeader check for rule evaluation failed", "err", err)
+			return false
+		}
+		return leader
+	})
+	if err = manager.LoadRules(); err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
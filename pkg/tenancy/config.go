@@ -15,12 +15,20 @@ import (
 // TenantLabelKey is a label key reserved for tenancy.
 const TenantLabelKey = "__tenant__"
 
+// TenantHeaderKey is the header of the tenant of write requests.
+const TenantHeaderKey = "TENANT"
+
+// TenantAttributeKey is the resource attribute key of the tenant of spans.
+const TenantAttributeKey = TenantLabelKey
+
 // AuthConfig defines configuration type for tenancy.
 type AuthConfig interface {
 	// allowNonTenants returns true if tenancy is asked to accept write-requests from non-multi-tenants.
 	allowNonTenants() bool
 	// getTenantSafetyMatcher returns a safety matcher that ensures queries only have data of tenants that are authorized.
 	getTenantSafetyMatcher() (*labels.Matcher, error)
+	// tenants returns the valid tenants, or nil if all tenants are valid.
+	tenants() []string
 	// IsTenantAllowed returns true if the given tenantName is allowed to be ingested.
 	IsTenantAllowed(string) bool
 }
@@ -93,7 +101,6 @@ func NewAllowAllTenantsConfig(allowNonTenants bool) AuthConfig {
 	return &AllowAllTenantsConfig{nonTenants: allowNonTenants}
 }
 
-//nolint | kept inorder to implement the interface.
 func (cfg *AllowAllTenantsConfig) tenants() []string {
 	return nil
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/timescale/promscale/pkg/prompb"
+	"go.opentelemetry.io/collector/model/pdata"
 )
 
 const regexOR = "|"
@@ -21,10 +22,24 @@ type ReadAuthorizer interface {
 	// AppendTenantMatcher applies a safety matcher to incoming query matchers. This safety matcher is responsible
 	// from prevent unauthorized query reads from tenants that the incoming query is not supposed to read.
 	AppendTenantMatcher(ms []*labels.Matcher) []*labels.Matcher
+	// TenantScope returns the tenants whose traces are allowed to be read.
+	TenantScope() TenantScope
+}
+
+// TenantScope is the set of tenants whose data is allowed to be read.
+type TenantScope struct {
+	// AllTenants allows the data of any tenant, otherwise only that of Tenants.
+	AllTenants bool
+	Tenants    []string
+	// NonTenants allows the data without a tenant.
+	NonTenants bool
 }
 
 // WriteAuthorizer tells if a write request is authorized to be written.
 type WriteAuthorizer interface {
 	// Process processes the incoming write requests to be multi-tenancy compatible.
 	Process(*http.Request, *prompb.WriteRequest) error
+	// ProcessTraces authorizes the tenant of the resources of incoming traces,
+	// applying the tenant of the request to the resources without one.
+	ProcessTraces(tenant string, traces pdata.Traces) error
 }
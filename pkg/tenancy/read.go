@@ -15,6 +15,7 @@ type readAuthorizer struct {
 	// mtSafetyLabelPair is a label-pair that is applied to incoming multi-tenant read requests for security reasons.
 	// This matcher helps prevent a query from querying a tenant for the query has not been authorized.
 	mtSafetyLabelMatcher *labels.Matcher
+	scope                TenantScope
 }
 
 // NewReadAuthorizer is a authorizer for performing read operations on valid tenants.
@@ -23,9 +24,15 @@ func NewReadAuthorizer(cfg AuthConfig) (ReadAuthorizer, error) {
 	if err != nil {
 		return nil, fmt.Errorf("get safety tenant matcher: %w", err)
 	}
+	tenants := cfg.tenants()
 	return &readAuthorizer{
 		AuthConfig:           cfg,
 		mtSafetyLabelMatcher: matcher,
+		scope: TenantScope{
+			AllTenants: tenants == nil,
+			Tenants:    tenants,
+			NonTenants: cfg.allowNonTenants(),
+		},
 	}, nil
 }
 
@@ -36,3 +43,7 @@ func (a *readAuthorizer) AppendTenantMatcher(ms []*labels.Matcher) []*labels.Mat
 	ms = append(ms, a.mtSafetyLabelMatcher)
 	return ms
 }
+
+func (a *readAuthorizer) TenantScope() TenantScope {
+	return a.scope
+}
@@ -57,6 +57,16 @@ func TestMultiTenancyRead(t *testing.T) {
 	require.False(t, present)
 }
 
+func TestTenantScope(t *testing.T) {
+	authr, err := NewReadAuthorizer(NewSelectiveTenancyConfig([]string{"tenant-a", "tenant-b"}, true))
+	require.NoError(t, err)
+	require.Equal(t, TenantScope{Tenants: []string{"tenant-a", "tenant-b"}, NonTenants: true}, authr.TenantScope())
+
+	authr, err = NewReadAuthorizer(NewAllowAllTenantsConfig(false))
+	require.NoError(t, err)
+	require.Equal(t, TenantScope{AllTenants: true}, authr.TenantScope())
+}
+
 func getSafetyMatcher(ms []*labels.Matcher) (string, bool) {
 	for _, m := range ms {
 		if m.Name == TenantLabelKey {
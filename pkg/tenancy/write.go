@@ -5,10 +5,13 @@
 package tenancy
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/timescale/promscale/pkg/prompb"
+	"go.opentelemetry.io/collector/model/pdata"
+	"google.golang.org/grpc/metadata"
 )
 
 // writeAuthorizer is a write authorizer that authorizes if the incoming write request is valid to be written or not.
@@ -44,7 +47,7 @@ func (a *writeAuthorizer) verifyAndApplyTenantLabel(tenantNameFromHeader string,
 // Process implements the Preprocessor interface.
 func (a *writeAuthorizer) Process(r *http.Request, wr *prompb.WriteRequest) error {
 	var (
-		tenantFromHeader = getTenant(r)
+		tenantFromHeader = TenantFromHeader(r)
 		num              = len(wr.Timeseries)
 	)
 	if num == 0 {
@@ -60,9 +63,59 @@ func (a *writeAuthorizer) Process(r *http.Request, wr *prompb.WriteRequest) erro
 	return nil
 }
 
-func getTenant(r *http.Request) string {
+// ProcessTraces implements the WriteAuthorizer interface.
+func (a *writeAuthorizer) ProcessTraces(tenantFromRequest string, traces pdata.Traces) error {
+	rSpans := traces.ResourceSpans()
+	for i := 0; i < rSpans.Len(); i++ {
+		if err := a.verifyAndApplyTenantAttribute(tenantFromRequest, rSpans.At(i).Resource().Attributes()); err != nil {
+			return fmt.Errorf("write-authorizer process traces: %w", err)
+		}
+	}
+	return nil
+}
+
+func (a *writeAuthorizer) verifyAndApplyTenantAttribute(tenantNameFromRequest string, attrs pdata.AttributeMap) error {
+	var tenantNameFromAttrs string
+	value, found := attrs.Get(TenantAttributeKey)
+	if found {
+		tenantNameFromAttrs = value.AsString()
+	}
+	if tenantNameFromRequest == "" {
+		return a.isAuthorized(tenantNameFromAttrs)
+	}
+	if err := a.isAuthorized(tenantNameFromRequest); err != nil {
+		return err
+	}
+	switch {
+	case !found:
+		attrs.InsertString(TenantAttributeKey, tenantNameFromRequest)
+		return nil
+	case tenantNameFromAttrs == tenantNameFromRequest:
+		return nil
+	case tenantNameFromAttrs == "":
+		// Tenant attribute exists but no tenant value. This is invalid.
+		return fmt.Errorf("%s exists with an empty value", TenantAttributeKey)
+	default:
+		return errTenantMismatch
+	}
+}
+
+// TenantFromHeader returns the tenant of an HTTP request.
+func TenantFromHeader(r *http.Request) string {
 	// We do not look for `X-` since it has been deprecated as mentioned in https://datatracker.ietf.org/doc/html/rfc6648.
-	return r.Header.Get("TENANT")
+	return r.Header.Get(TenantHeaderKey)
+}
+
+// TenantFromMetadata returns the tenant of a gRPC request from its metadata.
+func TenantFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if tenants := md.Get("tenant"); len(tenants) > 0 {
+		return tenants[0]
+	}
+	return ""
 }
 
 func (a *writeAuthorizer) getTenantLabelMatchingHeader(tenantNameFromHeader string, labels []prompb.Label) ([]prompb.Label, error) {
@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/prompb"
+	"go.opentelemetry.io/collector/model/pdata"
 )
 
 func TestVerifyAndApplyTenantLabel(t *testing.T) {
@@ -75,6 +76,60 @@ func TestVerifyAndApplyTenantLabel(t *testing.T) {
 	require.Equal(t, expectedLbls[0], newLbls)
 }
 
+func TestProcessTraces(t *testing.T) {
+	newTraces := func(tenants ...string) pdata.Traces {
+		traces := pdata.NewTraces()
+		for _, tenant := range tenants {
+			attrs := traces.ResourceSpans().AppendEmpty().Resource().Attributes()
+			attrs.InsertString("service.name", "frontend")
+			if tenant != "-" {
+				attrs.InsertString(TenantAttributeKey, tenant)
+			}
+		}
+		return traces
+	}
+	tenantsOf := func(traces pdata.Traces) []string {
+		var tenants []string
+		rSpans := traces.ResourceSpans()
+		for i := 0; i < rSpans.Len(); i++ {
+			tenant := "-"
+			if v, found := rSpans.At(i).Resource().Attributes().Get(TenantAttributeKey); found {
+				tenant = v.AsString()
+			}
+			tenants = append(tenants, tenant)
+		}
+		return tenants
+	}
+
+	authr := NewWriteAuthorizer(NewSelectiveTenancyConfig([]string{"tenant-a", "tenant-b"}, false))
+
+	// Tenant from the request is applied to the resources without one.
+	traces := newTraces("-", "tenant-a")
+	require.NoError(t, authr.ProcessTraces("tenant-a", traces))
+	require.Equal(t, []string{"tenant-a", "tenant-a"}, tenantsOf(traces))
+
+	// Tenant from the resources.
+	require.NoError(t, authr.ProcessTraces("", newTraces("tenant-a", "tenant-b")))
+
+	// Mismatching tenants.
+	require.Error(t, authr.ProcessTraces("tenant-a", newTraces("tenant-b")))
+
+	// Empty tenant attribute.
+	require.Error(t, authr.ProcessTraces("tenant-a", newTraces("")))
+
+	// Unauthorized tenant.
+	err := authr.ProcessTraces("tenant-c", newTraces("-"))
+	require.ErrorIs(t, err, ErrUnauthorizedTenant)
+
+	// Non-tenant traces.
+	require.Error(t, authr.ProcessTraces("", newTraces("-")))
+
+	authr = NewWriteAuthorizer(NewSelectiveTenancyConfig([]string{"tenant-a", "tenant-b"}, true))
+	traces = newTraces("-")
+	require.NoError(t, authr.ProcessTraces("", traces))
+	require.Equal(t, []string{"-"}, tenantsOf(traces))
+}
+
 func TestLabelsWithoutTenants(t *testing.T) {
 	var (
 		lblsArr    = getlbls()
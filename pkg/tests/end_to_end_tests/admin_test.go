@@ -0,0 +1,139 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package end_to_end_tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	promModel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/admin"
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	ingstr "github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+func TestMetricSettingsAdmin(t *testing.T) {
+	if !*useTimescaleDB {
+		t.Skip("metric settings need TimescaleDB support")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ts := []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: model.MetricNameLabelName, Value: "admin_metric"}},
+				Samples: []prompb.Sample{{Timestamp: 1, Value: 0.1}},
+			},
+		}
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+		_, _, err = ingestor.Ingest(newWriteRequestWithTs(copyMetrics(ts)))
+		require.NoError(t, err)
+
+		pgAdmin := &admin.PgAdmin{Conn: pgxconn.NewPgxConn(db)}
+		settings, err := pgAdmin.MetricSettings("admin_metric")
+		require.NoError(t, err)
+		require.Equal(t, "admin_metric", settings.Metric)
+		require.Equal(t, promModel.Duration(90*24*time.Hour), settings.RetentionPeriod)
+		require.True(t, settings.RetentionPeriodIsDefault)
+		require.NotNil(t, settings.ChunkInterval)
+		require.Equal(t, 8*time.Hour, time.Duration(*settings.ChunkInterval).Round(time.Hour), "chunk intervals are staggered by up to 1%")
+		require.True(t, settings.ChunkIntervalIsDefault)
+		require.True(t, settings.CompressionIsDefault)
+
+		require.NoError(t, pgAdmin.SetRetentionPeriod("admin_metric", 30*24*time.Hour))
+		require.NoError(t, pgAdmin.SetChunkInterval("admin_metric", 2*time.Hour))
+		require.NoError(t, pgAdmin.SetCompression("admin_metric", false))
+		settings, err = pgAdmin.MetricSettings("admin_metric")
+		require.NoError(t, err)
+		require.Equal(t, promModel.Duration(30*24*time.Hour), settings.RetentionPeriod)
+		require.False(t, settings.RetentionPeriodIsDefault)
+		require.Equal(t, 2*time.Hour, time.Duration(*settings.ChunkInterval).Round(time.Hour))
+		require.False(t, settings.ChunkIntervalIsDefault)
+		require.False(t, settings.Compression)
+		require.False(t, settings.CompressionIsDefault)
+
+		require.NoError(t, pgAdmin.ResetRetentionPeriod("admin_metric"))
+		require.NoError(t, pgAdmin.ResetChunkInterval("admin_metric"))
+		require.NoError(t, pgAdmin.ResetCompression("admin_metric"))
+		settings, err = pgAdmin.MetricSettings("admin_metric")
+		require.NoError(t, err)
+		require.True(t, settings.RetentionPeriodIsDefault)
+		require.True(t, settings.ChunkIntervalIsDefault)
+		require.Equal(t, 8*time.Hour, time.Duration(*settings.ChunkInterval).Round(time.Hour))
+		require.True(t, settings.CompressionIsDefault)
+
+		all, err := pgAdmin.AllMetricSettings()
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+
+		_, err = pgAdmin.MetricSettings("missing_metric")
+		require.Equal(t, admin.ErrMetricNotFound, err)
+	})
+}
+
+func TestDropMetricsAdmin(t *testing.T) {
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ts := []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: model.MetricNameLabelName, Value: "drop_me_1"}, {Name: "instance", Value: "1"}},
+				Samples: []prompb.Sample{{Timestamp: 1, Value: 0.1}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: model.MetricNameLabelName, Value: "drop_me_1"}, {Name: "instance", Value: "2"}},
+				Samples: []prompb.Sample{{Timestamp: 1, Value: 0.1}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: model.MetricNameLabelName, Value: "drop_me_2"}},
+				Samples: []prompb.Sample{{Timestamp: 1, Value: 0.1}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: model.MetricNameLabelName, Value: "keep_me"}},
+				Samples: []prompb.Sample{{Timestamp: 1, Value: 0.1}},
+			},
+		}
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		_, _, err = ingestor.Ingest(newWriteRequestWithTs(copyMetrics(ts)))
+		require.NoError(t, err)
+		require.NoError(t, ingestor.CompleteMetricCreation())
+		ingestor.Close()
+
+		pgAdmin := &admin.PgAdmin{Conn: pgxconn.NewPgxConn(db)}
+		matcher, err := admin.CompileMetricMatcher("drop_me_.*")
+		require.NoError(t, err)
+		metrics, err := pgAdmin.MatchMetrics(matcher)
+		require.NoError(t, err)
+		require.Equal(t, []admin.DroppedMetric{{Metric: "drop_me_1", Series: 2}, {Metric: "drop_me_2", Series: 1}}, metrics)
+
+		// An attached connector holds the schema version lease.
+		conn, err := db.Acquire(context.Background())
+		require.NoError(t, err)
+		_, err = conn.Exec(context.Background(), "SELECT pg_advisory_lock_shared($1)", schema.LockID)
+		require.NoError(t, err)
+		require.Equal(t, admin.ErrConnectorsAttached, pgAdmin.DropMetric("drop_me_1"))
+		_, err = conn.Exec(context.Background(), "SELECT pg_advisory_unlock_shared($1)", schema.LockID)
+		require.NoError(t, err)
+		conn.Release()
+
+		for _, m := range metrics {
+			require.NoError(t, pgAdmin.DropMetric(m.Metric))
+		}
+		metrics, err = pgAdmin.MatchMetrics(matcher)
+		require.NoError(t, err)
+		require.Empty(t, metrics)
+
+		all, err := admin.CompileMetricMatcher(".*")
+		require.NoError(t, err)
+		metrics, err = pgAdmin.MatchMetrics(all)
+		require.NoError(t, err)
+		require.Equal(t, []admin.DroppedMetric{{Metric: "keep_me", Series: 1}}, metrics)
+	})
+}
@@ -25,6 +25,7 @@ import (
 	ingstr "github.com/timescale/promscale/pkg/pgmodel/ingestor"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/prompb"
 )
 
 type deleteStr struct {
@@ -259,6 +260,133 @@ func TestDeleteWithCompressedChunks(t *testing.T) {
 	})
 }
 
+func TestDeleteInTimeRange(t *testing.T) {
+	if *useMultinode {
+		t.Skip("time range deletion of compressed chunks is not supported for multi-node")
+	}
+	const (
+		metricName = "delete_time_range"
+		hour       = int64(time.Hour / time.Millisecond)
+	)
+	ts := []prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: model.MetricNameLabelName, Value: metricName}, {Name: "instance", Value: "1"}}},
+		{Labels: []prompb.Label{{Name: model.MetricNameLabelName, Value: metricName}, {Name: "instance", Value: "2"}}},
+	}
+	// One sample and exemplar per hour over 3 days, so that the data spans multiple chunks.
+	for i := range ts {
+		for j := int64(0); j < 72; j++ {
+			ts[i].Samples = append(ts[i].Samples, prompb.Sample{Timestamp: j * hour, Value: float64(j)})
+			ts[i].Exemplars = append(ts[i].Exemplars, prompb.Exemplar{Timestamp: j * hour, Value: float64(j), Labels: []prompb.Label{{Name: "TraceID", Value: "abcde"}}})
+		}
+	}
+
+	withDB(t, *testDatabase, func(dbOwner *pgxpool.Pool, t testing.TB) {
+		db := testhelpers.PgxPoolWithRole(t, *testDatabase, "prom_modifier")
+		defer db.Close()
+
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+		_, _, err = ingestor.Ingest(newWriteRequestWithTs(copyMetrics(ts)))
+		require.NoError(t, err)
+		require.NoError(t, ingestor.CompleteMetricCreation())
+
+		var tableName string
+		err = dbOwner.QueryRow(context.Background(), "SELECT table_name from _prom_catalog.metric WHERE metric_name=$1", metricName).Scan(&tableName)
+		require.NoError(t, err)
+		_, err = dbOwner.Exec(context.Background(), fmt.Sprintf("SELECT compress_chunk(i, if_not_compressed => true) from show_chunks('prom_data.\"%s\"') i;", tableName))
+		require.NoError(t, err)
+
+		var (
+			start = time.Unix(0, 0).Add(10 * time.Hour).UTC()
+			end   = time.Unix(0, 0).Add(40 * time.Hour).UTC()
+		)
+		matchers, err := getMatchers(fmt.Sprintf(`{__name__="%s", instance="1"}`, metricName))
+		require.NoError(t, err)
+		pgDelete := &pgDel.PgDelete{Conn: pgxconn.NewPgxConn(db)}
+		touchedMetrics, deletedSeriesIDs, rowsDeleted, err := pgDelete.DeleteSeries(matchers, start, end)
+		require.NoError(t, err)
+		require.Equal(t, []string{metricName}, touchedMetrics)
+		require.Len(t, deletedSeriesIDs, 1)
+		require.Equal(t, map[string]int{metricName: 31}, rowsDeleted)
+
+		var remaining, remainingInRange int
+		err = db.QueryRow(context.Background(), fmt.Sprintf("SELECT count(*) FROM prom_data.\"%s\"", tableName)).Scan(&remaining)
+		require.NoError(t, err)
+		require.Equal(t, 2*72-31, remaining)
+		err = db.QueryRow(context.Background(),
+			fmt.Sprintf("SELECT count(*) FROM prom_data.\"%s\" WHERE time >= $1 AND time <= $2", tableName), start, end).Scan(&remainingInRange)
+		require.NoError(t, err)
+		require.Equal(t, 31, remainingInRange, "only the samples of the other series should remain in the time range")
+
+		var exemplarTable string
+		err = dbOwner.QueryRow(context.Background(), "SELECT table_name from _prom_catalog.exemplar WHERE metric_name=$1", metricName).Scan(&exemplarTable)
+		require.NoError(t, err)
+		err = db.QueryRow(context.Background(), fmt.Sprintf("SELECT count(*) FROM prom_data_exemplar.\"%s\"", exemplarTable)).Scan(&remaining)
+		require.NoError(t, err)
+		require.Equal(t, 2*72-31, remaining)
+		err = db.QueryRow(context.Background(),
+			fmt.Sprintf("SELECT count(*) FROM prom_data_exemplar.\"%s\" WHERE time >= $1 AND time <= $2", exemplarTable), start, end).Scan(&remainingInRange)
+		require.NoError(t, err)
+		require.Equal(t, 31, remainingInRange, "only the exemplars of the other series should remain in the time range")
+
+		var uncompressed int
+		err = dbOwner.QueryRow(context.Background(),
+			"SELECT count(*) FROM timescaledb_information.chunks WHERE hypertable_schema = 'prom_data' AND hypertable_name = $1 AND NOT is_compressed", tableName).Scan(&uncompressed)
+		require.NoError(t, err)
+		require.Equal(t, 0, uncompressed, "decompressed chunks should be compressed again")
+
+		// Deleting the series over the entire time range still removes it completely.
+		_, deletedSeriesIDs, rowsDeleted, err = pgDelete.DeleteSeries(matchers, model.MinTime, model.MaxTime)
+		require.NoError(t, err)
+		require.Len(t, deletedSeriesIDs, 1)
+		require.Equal(t, map[string]int{metricName: 72 - 31}, rowsDeleted)
+	})
+}
+
+func TestDeleteDryRun(t *testing.T) {
+	withDB(t, *testDatabase, func(dbOwner *pgxpool.Pool, t testing.TB) {
+		db := testhelpers.PgxPoolWithRole(t, *testDatabase, "prom_modifier")
+		defer db.Close()
+
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+		_, _, err = ingestor.Ingest(newWriteRequestWithTs(copyMetrics(generateLargeTimeseries())))
+		require.NoError(t, err)
+		require.NoError(t, ingestor.CompleteMetricCreation())
+
+		var countBefore, countAfter int
+		err = db.QueryRow(context.Background(), `SELECT count(*) FROM prom_data."metric_1"`).Scan(&countBefore)
+		require.NoError(t, err)
+
+		metric1, err := getMatchers(`{__name__="metric_1"}`)
+		require.NoError(t, err)
+		metric1And2, err := getMatchers(`{__name__=~"metric_(1|2)", instance="1"}`)
+		require.NoError(t, err)
+
+		pgDelete := &pgDel.PgDelete{Conn: pgxconn.NewPgxConn(db)}
+		result, err := pgDelete.DryRun([][]*labels.Matcher{metric1, metric1And2}, model.MinTime, model.MaxTime)
+		require.NoError(t, err)
+		require.Len(t, result, 2)
+
+		// Series matched by both matcher sets are counted once.
+		require.Equal(t, "metric_1", result[0].Metric)
+		require.Equal(t, 3, result[0].Series)
+		require.Len(t, result[0].ExampleSeries, 3)
+		require.Equal(t, "metric_1", result[0].ExampleSeries[0].Get(model.MetricNameLabelName))
+		require.Greater(t, result[0].EstimatedRows, int64(0))
+
+		require.Equal(t, "metric_2", result[1].Metric)
+		require.Equal(t, 1, result[1].Series)
+		require.Equal(t, labels.FromStrings(model.MetricNameLabelName, "metric_2", "foo", "bat", "instance", "1"), result[1].ExampleSeries[0])
+
+		err = db.QueryRow(context.Background(), `SELECT count(*) FROM prom_data."metric_1"`).Scan(&countAfter)
+		require.NoError(t, err)
+		require.Equal(t, countBefore, countAfter, "dry run must not delete any data")
+	})
+}
+
 func TestDeleteWithMetricNameEQLRegex(t *testing.T) {
 	if *useMultinode && !*extendedTest {
 		t.Skip("delete tests run in extended mode only for multi-node configuration")
@@ -6,12 +6,18 @@ package end_to_end_tests
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
 	"github.com/stretchr/testify/require"
 	"github.com/timescale/promscale/pkg/internal/testhelpers"
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
@@ -23,6 +29,8 @@ import (
 	"github.com/timescale/promscale/pkg/pgxconn"
 	"github.com/timescale/promscale/pkg/prompb"
 	"github.com/timescale/promscale/pkg/query"
+	"github.com/timescale/promscale/pkg/tenancy"
+	"go.opentelemetry.io/collector/model/pdata"
 )
 
 var rawExemplar = []prompb.Exemplar{
@@ -198,7 +206,7 @@ func TestExemplarQueryingAPI(t *testing.T) {
 		// We do not check num of insertablesIngested and metadataIngested returned above from ingestor.Ingest,
 		// since the return will be 0, as they have already been ingested by TestExemplarIngestion.
 
-		labelsReader := lreader.NewLabelsReader(pgxconn.NewPgxConn(db), cache.NewLabelsCache(cache.DefaultConfig))
+		labelsReader := lreader.NewLabelsReader(pgxconn.NewPgxConn(db), cache.NewLabelsCache(cache.DefaultConfig), querier.NewLabelsQuerier(pgxconn.NewPgxConn(db), cache.NewMetricCache(cache.DefaultConfig), nil))
 		r := querier.NewQuerier(
 			pgxconn.NewPgxConn(db),
 			cache.NewMetricCache(cache.DefaultConfig),
@@ -269,3 +277,78 @@ func nonNullTsExemplar(ts []prompb.TimeSeries) (num int) {
 	}
 	return
 }
+
+func TestExemplarTraces(t *testing.T) {
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		conn := pgxconn.NewPgxConn(db)
+		ingestor, err := ingstr.NewPgxIngestorForTests(conn, nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+
+		traces := pdata.NewTraces()
+		addSpan := func(service, name string, spanID, parentID byte, end pdata.Timestamp) {
+			rs := traces.ResourceSpans().AppendEmpty()
+			rs.Resource().Attributes().InsertString("service.name", service)
+			span := rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty()
+			span.SetTraceID(pdata.NewTraceID(traceID1))
+			span.SetSpanID(pdata.NewSpanID([8]byte{spanID}))
+			if parentID != 0 {
+				span.SetParentSpanID(pdata.NewSpanID([8]byte{parentID}))
+			}
+			span.SetName(name)
+			span.SetStartTimestamp(testSpanStartTimestamp)
+			span.SetEndTimestamp(end)
+		}
+		addSpan("frontend", "GET /", 1, 0, testSpanEndTimestamp)
+		addSpan("backend", "query", 2, 1, testSpanEventTimestamp)
+		require.NoError(t, ingestor.IngestTraces(context.Background(), traces))
+
+		traceID := hex.EncodeToString(traceID1[:])
+		ts := timestamp.FromTime(testSpanEndTime)
+		_, _, err = ingestor.Ingest(newWriteRequestWithTs([]prompb.TimeSeries{
+			{
+				Labels:    []prompb.Label{{Name: model.MetricNameLabelName, Value: "test_trace_metric"}, {Name: "job", Value: "frontend"}},
+				Samples:   []prompb.Sample{{Timestamp: ts, Value: 1}},
+				Exemplars: []prompb.Exemplar{{Timestamp: ts, Value: 0.5, Labels: []prompb.Label{{Name: "trace_id", Value: traceID}}}},
+			},
+		}))
+		require.NoError(t, err)
+
+		summaries, err := exemplar.TraceSummaries(context.Background(), conn, []string{traceID, "abc", "not a trace ID"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]exemplar.TraceSummary{
+			traceID: {
+				TraceID:      traceID,
+				RootService:  "frontend",
+				RootSpanName: "GET /",
+				StartTime:    testSpanStartTime,
+				DurationMs:   float64(testSpanEndTime.Sub(testSpanStartTime)) / float64(time.Millisecond),
+				SpanCount:    2,
+			},
+		}, summaries)
+
+		results, err := exemplar.TraceExemplars(context.Background(), conn, traceID, testSpanStartTime, testSpanEndTime, nil)
+		require.NoError(t, err)
+		bSlice, err := json.Marshal(results)
+		require.NoError(t, err)
+		require.Equal(t,
+			`[{"seriesLabels":{"__name__":"test_trace_metric","job":"frontend"},"exemplars":[{"labels":{"trace_id":"`+traceID+`"},"value":0.5,"timestamp":`+strconv.FormatInt(ts, 10)+`}]}]`,
+			string(bSlice))
+
+		results, err = exemplar.TraceExemplars(context.Background(), conn, traceID, testSpanEndTime.Add(time.Second), testSpanEndTime.Add(time.Hour), nil)
+		require.NoError(t, err)
+		require.Empty(t, results)
+
+		// The trace ID may be given in the dashed UUID form and in upper case.
+		dashedTraceID := strings.ToUpper(fmt.Sprintf("%s-%s-%s-%s-%s", traceID[:8], traceID[8:12], traceID[12:16], traceID[16:20], traceID[20:]))
+		results, err = exemplar.TraceExemplars(context.Background(), conn, dashedTraceID, testSpanStartTime, testSpanEndTime, nil)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+
+		// Series of other tenants are not returned.
+		tenantMatcher := labels.MustNewMatcher(labels.MatchRegexp, tenancy.TenantLabelKey, "tenant-a")
+		results, err = exemplar.TraceExemplars(context.Background(), conn, traceID, testSpanStartTime, testSpanEndTime, []*labels.Matcher{tenantMatcher})
+		require.NoError(t, err)
+		require.Empty(t, results)
+	})
+}
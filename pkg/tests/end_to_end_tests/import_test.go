@@ -0,0 +1,62 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package end_to_end_tests
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/importer"
+	ingstr "github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+func TestImportTextFile(t *testing.T) {
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		dir, err := ioutil.TempDir("", "promscale-import")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		var sb strings.Builder
+		sb.WriteString("# TYPE import_metric gauge\n")
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(&sb, "import_metric{instance=\"%d\"} %d %d\n", i%10, i, 1600000000000+int64(i)*1000)
+		}
+		path := filepath.Join(dir, "metrics.prom")
+		require.NoError(t, ioutil.WriteFile(path, []byte(sb.String()), 0644))
+
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+
+		imp, err := importer.New(importer.Config{BatchSize: 300, CheckpointFile: filepath.Join(dir, "checkpoint.json")}, ingestor)
+		require.NoError(t, err)
+		n, err := imp.Import(path)
+		require.NoError(t, err)
+		require.Equal(t, int64(1000), n)
+
+		var count int
+		err = db.QueryRow(context.Background(), `SELECT count(*) FROM prom_data."import_metric"`).Scan(&count)
+		require.NoError(t, err)
+		require.Equal(t, 1000, count)
+		err = db.QueryRow(context.Background(), `SELECT count(*) FROM prom_data_series."import_metric"`).Scan(&count)
+		require.NoError(t, err)
+		require.Equal(t, 10, count)
+
+		// Importing again is a no-op thanks to the checkpoint.
+		imp, err = importer.New(importer.Config{BatchSize: 300, CheckpointFile: filepath.Join(dir, "checkpoint.json")}, ingestor)
+		require.NoError(t, err)
+		n, err = imp.Import(path)
+		require.NoError(t, err)
+		require.Equal(t, int64(0), n)
+	})
+}
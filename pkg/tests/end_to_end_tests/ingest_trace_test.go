@@ -2,18 +2,25 @@ package end_to_end_tests
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"testing"
 	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jaegertracing/jaeger/model"
 	"github.com/jaegertracing/jaeger/storage/spanstore"
 	"github.com/stretchr/testify/require"
 	"github.com/timescale/promscale/pkg/jaeger/query"
 	ingstr "github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 	"go.opentelemetry.io/collector/model/pdata"
+	"google.golang.org/grpc/metadata"
 )
 
 var (
@@ -44,6 +51,83 @@ func TestIngestTraces(t *testing.T) {
 	})
 }
 
+func TestIngestTracesBatched(t *testing.T) {
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		cfg := &ingstr.Cfg{TraceDispatcher: trace.DispatcherConfig{NumWriters: 2, MaxBatchSize: 100, BatchTimeout: 50 * time.Millisecond}}
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), cfg)
+		require.NoError(t, err)
+		defer ingestor.Close()
+
+		traces := generateTestTraceManyRS()
+		errs := make(chan error, len(traces))
+		for i := range traces {
+			go func(traces pdata.Traces) {
+				errs <- ingestor.IngestTraces(context.Background(), traces)
+			}(traces[i])
+		}
+		numSpans := 0
+		for i := range traces {
+			require.NoError(t, <-errs)
+			numSpans += traces[i].SpanCount()
+		}
+
+		count := func(table string) (count int) {
+			err := db.QueryRow(context.Background(), "SELECT count(*) FROM _ps_trace."+table).Scan(&count)
+			require.NoError(t, err)
+			return count
+		}
+		require.Equal(t, numSpans, count("span"))
+		numEvents, numLinks := count("event"), count("link")
+		require.NotZero(t, numEvents)
+		require.NotZero(t, numLinks)
+
+		// Copying the spans of a retried export fails on the stored spans, so
+		// they are inserted ignoring the duplicates instead, and their events
+		// and links are skipped.
+		require.NoError(t, ingestor.IngestTraces(context.Background(), traces[0]))
+		require.Equal(t, numSpans, count("span"))
+		require.Equal(t, numEvents, count("event"))
+		require.Equal(t, numLinks, count("link"))
+	})
+}
+
+func TestIngestTracesAttributeRules(t *testing.T) {
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		var rules trace.AttributeRules
+		for _, spec := range []string{"drop:span-event-attr", "hash:span-attr", "truncate=8:resource-attr", "hash::span-link"} {
+			require.NoError(t, rules.Set(spec))
+		}
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), &ingstr.Cfg{AttributeRules: rules, AttributeHashKey: []byte("secret-key")})
+		require.NoError(t, err)
+		defer ingestor.Close()
+		err = ingestor.IngestTraces(context.Background(), generateTestTrace())
+		require.NoError(t, err)
+
+		tagValues := func(key string) (values []string) {
+			rows, err := db.Query(context.Background(), "SELECT value#>>'{}' FROM _ps_trace.tag WHERE key = $1 ORDER BY 1", key)
+			require.NoError(t, err)
+			defer rows.Close()
+			for rows.Next() {
+				var value string
+				require.NoError(t, rows.Scan(&value))
+				values = append(values, value)
+			}
+			require.NoError(t, rows.Err())
+			return values
+		}
+		require.Empty(t, tagValues("span-event-attr"))
+		require.Equal(t, []string{hmacSHA256Hex("span-attr-val")}, tagValues("span-attr"))
+		require.Equal(t, []string{"resource"}, tagValues("resource-attr"))
+		require.Equal(t, []string{hmacSHA256Hex("span-link-attr-val")}, tagValues("span-link-attr"))
+	})
+}
+
+func hmacSHA256Hex(s string) string {
+	mac := hmac.New(sha256.New, []byte("secret-key"))
+	_, _ = mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func TestIngestTracesMultiTraces(t *testing.T) {
 	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
 		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
@@ -190,7 +274,7 @@ func TestQueryTraces(t *testing.T) {
 		err = ingestor.IngestTraces(context.Background(), traces)
 		require.NoError(t, err)
 
-		q := query.New(pgxconn.NewQueryLoggingPgxConn(db))
+		q := query.New(pgxconn.NewQueryLoggingPgxConn(db), nil, nil, nil)
 
 		getOperationsTest(t, q)
 		findTraceTest(t, q)
@@ -394,3 +478,303 @@ func findTraceTest(t testing.TB, q *query.Query) {
 	require.NoError(t, err)
 	require.Equal(t, 1, len(traces))
 }
+
+func TestWriteSpan(t *testing.T) {
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		conn := pgxconn.NewPgxConn(db)
+		ingestor, err := ingstr.NewPgxIngestorForTests(conn, nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+		q := query.New(conn, conn, ingestor, nil)
+
+		traceID := model.NewTraceID(1, 2)
+		span := &model.Span{
+			TraceID:       traceID,
+			SpanID:        model.NewSpanID(3),
+			OperationName: "GET /dispatch",
+			StartTime:     testSpanStartTime,
+			Duration:      testSpanEndTime.Sub(testSpanStartTime),
+			Tags:          []model.KeyValue{model.String("http.method", "GET"), model.String("span.kind", "server")},
+			Process:       model.NewProcess("jaeger-service", []model.KeyValue{model.String("hostname", "host-1")}),
+		}
+		require.NoError(t, q.SpanWriter().WriteSpan(context.Background(), span))
+
+		services, err := q.GetServices(context.Background())
+		require.NoError(t, err)
+		require.Contains(t, services, "jaeger-service")
+
+		res, err := q.GetTrace(context.Background(), traceID)
+		require.NoError(t, err)
+		require.Len(t, res.Spans, 1)
+		require.Equal(t, "GET /dispatch", res.Spans[0].OperationName)
+		require.Equal(t, "jaeger-service", res.Spans[0].Process.ServiceName)
+		tag, ok := model.KeyValues(res.Spans[0].Tags).FindByKey("http.method")
+		require.True(t, ok)
+		require.Equal(t, "GET", tag.VStr)
+
+		err = query.New(conn, nil, nil, nil).SpanWriter().WriteSpan(context.Background(), span)
+		require.Error(t, err)
+	})
+}
+
+func TestGetDependencies(t *testing.T) {
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+
+		// frontend calls backend twice and backend calls itself once.
+		traces := pdata.NewTraces()
+		addSpan := func(service string, spanID, parentID byte) {
+			rs := traces.ResourceSpans().AppendEmpty()
+			rs.Resource().Attributes().InsertString("service.name", service)
+			span := rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty()
+			span.SetTraceID(pdata.NewTraceID(traceID1))
+			span.SetSpanID(pdata.NewSpanID([8]byte{spanID}))
+			if parentID != 0 {
+				span.SetParentSpanID(pdata.NewSpanID([8]byte{parentID}))
+			}
+			span.SetName("operation")
+			span.SetStartTimestamp(testSpanStartTimestamp)
+			span.SetEndTimestamp(testSpanEndTimestamp)
+		}
+		addSpan("frontend", 1, 0)
+		addSpan("backend", 2, 1)
+		addSpan("backend", 3, 1)
+		addSpan("backend", 4, 3)
+		require.NoError(t, ingestor.IngestTraces(context.Background(), traces))
+
+		q := query.New(pgxconn.NewPgxConn(db), nil, nil, nil)
+		expected := []model.DependencyLink{{Parent: "frontend", Child: "backend", CallCount: 2}}
+
+		// Before materialization the dependencies are aggregated from the spans.
+		deps, err := q.GetDependencies(context.Background(), testSpanEndTime.Add(time.Hour), 24*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, expected, deps)
+
+		_, err = db.Exec(context.Background(), "CALL _ps_trace.materialize_service_dependencies()")
+		require.NoError(t, err)
+
+		deps, err = q.GetDependencies(context.Background(), testSpanEndTime.Add(time.Hour), 24*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, expected, deps)
+
+		// A window that starts and ends within a materialized bucket.
+		deps, err = q.GetDependencies(context.Background(), testSpanStartTime.Add(time.Second), 2*time.Second)
+		require.NoError(t, err)
+		require.Equal(t, expected, deps)
+
+		deps, err = q.GetDependencies(context.Background(), testSpanStartTime, time.Hour)
+		require.NoError(t, err)
+		require.Empty(t, deps)
+	})
+}
+
+func TestQueryTracesMultiTenancy(t *testing.T) {
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+
+		traces := pdata.NewTraces()
+		addSpan := func(service, tenant string, traceID [16]byte, spanID, parentID byte) {
+			rs := traces.ResourceSpans().AppendEmpty()
+			rs.Resource().Attributes().InsertString("service.name", service)
+			if tenant != "" {
+				rs.Resource().Attributes().InsertString(tenancy.TenantAttributeKey, tenant)
+			}
+			span := rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty()
+			span.SetTraceID(pdata.NewTraceID(traceID))
+			span.SetSpanID(pdata.NewSpanID([8]byte{spanID}))
+			if parentID != 0 {
+				span.SetParentSpanID(pdata.NewSpanID([8]byte{parentID}))
+			}
+			span.SetName(service + "-operation")
+			span.SetStartTimestamp(testSpanStartTimestamp)
+			span.SetEndTimestamp(testSpanEndTimestamp)
+		}
+		addSpan("frontend", "tenant-a", traceID1, 1, 0)
+		addSpan("auth", "tenant-a", traceID1, 2, 1)
+		addSpan("backend", "tenant-b", traceID1, 3, 1)
+		addSpan("database", "", traceID2, 4, 0)
+		require.NoError(t, ingestor.IngestTraces(context.Background(), traces))
+
+		conn := pgxconn.NewPgxConn(db)
+		newQuery := func(cfg tenancy.AuthConfig) *query.Query {
+			authr, err := tenancy.NewAuthorizer(cfg)
+			require.NoError(t, err)
+			return query.New(conn, conn, ingestor, authr)
+		}
+		spanServices := func(tr *model.Trace) []string {
+			services := make([]string, 0, len(tr.Spans))
+			for _, span := range tr.Spans {
+				services = append(services, span.Process.ServiceName)
+			}
+			return services
+		}
+
+		q := newQuery(tenancy.NewSelectiveTenancyConfig([]string{"tenant-a"}, false))
+		services, err := q.GetServices(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []string{"auth", "frontend"}, services)
+
+		deps, err := q.GetDependencies(context.Background(), testSpanEndTime.Add(time.Hour), 24*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, []model.DependencyLink{{Parent: "frontend", Child: "auth", CallCount: 1}}, deps)
+
+		ops, err := q.GetOperations(context.Background(), spanstore.OperationQueryParameters{ServiceName: "backend"})
+		require.NoError(t, err)
+		require.Empty(t, ops)
+
+		jaegerTraceID1, err := model.TraceIDFromBytes(traceID1[:])
+		require.NoError(t, err)
+		jaegerTraceID2, err := model.TraceIDFromBytes(traceID2[:])
+		require.NoError(t, err)
+
+		tr, err := q.GetTrace(context.Background(), jaegerTraceID1)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"frontend", "auth"}, spanServices(tr), "spans of other tenants are not read")
+
+		_, err = q.GetTrace(context.Background(), jaegerTraceID2)
+		require.Error(t, err)
+
+		// Spans written by Jaeger collectors are authorized for the tenant of the request.
+		jaegerSpan := &model.Span{
+			TraceID:       model.NewTraceID(1, 2),
+			SpanID:        model.NewSpanID(3),
+			OperationName: "GET /dispatch",
+			StartTime:     testSpanStartTime,
+			Duration:      testSpanEndTime.Sub(testSpanStartTime),
+			Process:       model.NewProcess("jaeger-service", nil),
+		}
+		require.Error(t, q.SpanWriter().WriteSpan(context.Background(), jaegerSpan), "spans without a tenant are rejected")
+		require.Error(t, q.ArchiveSpanWriter().WriteSpan(context.Background(), jaegerSpan), "spans without a tenant are rejected")
+		tenantCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", "tenant-a"))
+		require.NoError(t, q.SpanWriter().WriteSpan(tenantCtx, jaegerSpan))
+		require.NoError(t, q.ArchiveSpanWriter().WriteSpan(tenantCtx, jaegerSpan))
+		_, err = q.GetTrace(context.Background(), jaegerSpan.TraceID)
+		require.NoError(t, err)
+		_, err = q.ArchiveSpanReader().GetTrace(context.Background(), jaegerSpan.TraceID)
+		require.NoError(t, err)
+
+		q = newQuery(tenancy.NewAllowAllTenantsConfig(false))
+		services, err = q.GetServices(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []string{"backend", "frontend"}, services)
+
+		q = newQuery(tenancy.NewSelectiveTenancyConfig([]string{"tenant-b"}, true))
+		services, err = q.GetServices(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []string{"backend", "database"}, services)
+
+		deps, err = q.GetDependencies(context.Background(), testSpanEndTime.Add(time.Hour), 24*time.Hour)
+		require.NoError(t, err)
+		require.Empty(t, deps, "calls from spans of other tenants are not read")
+
+		_, err = q.ArchiveSpanReader().GetTrace(context.Background(), jaegerSpan.TraceID)
+		require.Equal(t, spanstore.ErrTraceNotFound, err, "archived spans of other tenants are not read")
+	})
+}
+
+func TestArchiveTrace(t *testing.T) {
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		conn := pgxconn.NewPgxConn(db)
+		q := query.New(conn, conn, nil, nil)
+
+		traceID := model.NewTraceID(1, 2)
+		_, err := q.ArchiveSpanReader().GetTrace(context.Background(), traceID)
+		require.Equal(t, spanstore.ErrTraceNotFound, err)
+
+		process := model.NewProcess("jaeger-service", nil)
+		spans := []*model.Span{
+			{TraceID: traceID, SpanID: model.NewSpanID(4), OperationName: "child", StartTime: testSpanStartTime.Add(time.Second), Process: process},
+			{TraceID: traceID, SpanID: model.NewSpanID(3), OperationName: "root", StartTime: testSpanStartTime, Process: process},
+		}
+		for _, span := range spans {
+			require.NoError(t, q.ArchiveSpanWriter().WriteSpan(context.Background(), span))
+		}
+		// Archiving a span again replaces it.
+		require.NoError(t, q.ArchiveSpanWriter().WriteSpan(context.Background(), spans[0]))
+
+		res, err := q.ArchiveSpanReader().GetTrace(context.Background(), traceID)
+		require.NoError(t, err)
+		require.Len(t, res.Spans, 2)
+		require.Equal(t, "root", res.Spans[0].OperationName)
+		require.Equal(t, "child", res.Spans[1].OperationName)
+		require.Equal(t, "jaeger-service", res.Spans[1].Process.ServiceName)
+
+		// Archived spans are not part of the primary storage.
+		_, err = q.GetTrace(context.Background(), traceID)
+		require.Equal(t, spanstore.ErrTraceNotFound, err)
+
+		err = query.New(conn, nil, nil, nil).ArchiveSpanWriter().WriteSpan(context.Background(), spans[0])
+		require.Error(t, err)
+	})
+}
+
+func TestTraceRetention(t *testing.T) {
+	if *useTimescaleDB && !*useTimescale2 {
+		t.Skip("the retention periods of services require TimescaleDB 2")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+
+		traces := pdata.NewTraces()
+		for i, service := range []string{"frontend", "backend"} {
+			rs := traces.ResourceSpans().AppendEmpty()
+			rs.Resource().Attributes().InsertString("service.name", service)
+			span := rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty()
+			span.SetTraceID(pdata.NewTraceID(traceID1))
+			span.SetSpanID(pdata.NewSpanID([8]byte{byte(i + 1)}))
+			span.SetName("operation")
+			span.SetStartTimestamp(testSpanStartTimestamp)
+			span.SetEndTimestamp(testSpanEndTimestamp)
+		}
+		require.NoError(t, ingestor.IngestTraces(context.Background(), traces))
+
+		_, err = db.Exec(context.Background(), "SELECT ps_trace.set_trace_retention_period(INTERVAL '0 days')")
+		require.Error(t, err)
+		// Upgraded databases have no default retention period and keep the spans
+		// of services without an override forever.
+		_, err = db.Exec(context.Background(), "DELETE FROM _prom_catalog.default WHERE key = 'trace_retention_period'")
+		require.NoError(t, err)
+		var noRetention bool
+		err = db.QueryRow(context.Background(), "SELECT ps_trace.get_trace_retention_period() IS NULL").Scan(&noRetention)
+		require.NoError(t, err)
+		require.True(t, noRetention)
+		_, err = db.Exec(context.Background(), "SELECT ps_trace.set_service_retention_period('backend', INTERVAL '1 day')")
+		require.NoError(t, err)
+
+		var overridden bool
+		err = db.QueryRow(context.Background(), "SELECT ps_trace.get_service_retention_period('backend') = INTERVAL '1 day'").Scan(&overridden)
+		require.NoError(t, err)
+		require.True(t, overridden)
+
+		_, err = db.Exec(context.Background(), "CALL _ps_trace.execute_data_retention_policy()")
+		require.NoError(t, err)
+
+		var services []string
+		err = db.QueryRow(context.Background(), `
+			SELECT array_agg(t.value#>>'{}')
+			FROM _ps_trace.span s
+			INNER JOIN _ps_trace.operation o ON (o.id = s.operation_id)
+			INNER JOIN _ps_trace.tag t ON (t.id = o.service_name_id)`).Scan(&services)
+		require.NoError(t, err)
+		require.Equal(t, []string{"frontend"}, services)
+
+		// Resetting the override falls back to the default retention period.
+		_, err = db.Exec(context.Background(), "SELECT ps_trace.reset_service_retention_period('backend')")
+		require.NoError(t, err)
+		_, err = db.Exec(context.Background(), "SELECT ps_trace.set_trace_retention_period(INTERVAL '1 day')")
+		require.NoError(t, err)
+		_, err = db.Exec(context.Background(), "CALL _ps_trace.execute_data_retention_policy()")
+		require.NoError(t, err)
+
+		var count int
+		err = db.QueryRow(context.Background(), "SELECT count(*) FROM _ps_trace.span").Scan(&count)
+		require.NoError(t, err)
+		require.Equal(t, 0, count)
+	})
+}
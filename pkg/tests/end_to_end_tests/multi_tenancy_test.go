@@ -50,7 +50,7 @@ func TestMultiTenancyWithoutValidTenants(t *testing.T) {
 		mCache := &cache.MetricNameCache{Metrics: clockcache.WithMax(cache.DefaultMetricCacheSize)}
 		lCache := clockcache.WithMax(100)
 		dbConn := pgxconn.NewPgxConn(db)
-		labelsReader := lreader.NewLabelsReader(dbConn, lCache)
+		labelsReader := lreader.NewLabelsReader(dbConn, lCache, querier.NewLabelsQuerier(dbConn, mCache, mt.ReadAuthorizer()))
 		qr := querier.NewQuerier(dbConn, mCache, labelsReader, nil, mt.ReadAuthorizer())
 
 		// ----- query-test: querying a single tenant (tenant-a) -----
@@ -243,7 +243,7 @@ func TestMultiTenancyWithValidTenants(t *testing.T) {
 		mCache := &cache.MetricNameCache{Metrics: clockcache.WithMax(cache.DefaultMetricCacheSize)}
 		lCache := clockcache.WithMax(100)
 		dbConn := pgxconn.NewPgxConn(db)
-		labelsReader := lreader.NewLabelsReader(dbConn, lCache)
+		labelsReader := lreader.NewLabelsReader(dbConn, lCache, querier.NewLabelsQuerier(dbConn, mCache, mt.ReadAuthorizer()))
 		qr := querier.NewQuerier(dbConn, mCache, labelsReader, nil, mt.ReadAuthorizer())
 
 		// ----- query-test: querying a valid tenant (tenant-a) -----
@@ -366,7 +366,7 @@ func TestMultiTenancyWithValidTenants(t *testing.T) {
 		mt, err = tenancy.NewAuthorizer(cfg)
 		require.NoError(t, err)
 
-		labelsReader = lreader.NewLabelsReader(dbConn, lCache)
+		labelsReader = lreader.NewLabelsReader(dbConn, lCache, querier.NewLabelsQuerier(dbConn, mCache, mt.ReadAuthorizer()))
 		qr = querier.NewQuerier(dbConn, mCache, labelsReader, nil, mt.ReadAuthorizer())
 
 		expectedResult = []prompb.TimeSeries{}
@@ -444,7 +444,7 @@ func TestMultiTenancyWithValidTenantsAndNonTenantOps(t *testing.T) {
 		mCache := &cache.MetricNameCache{Metrics: clockcache.WithMax(cache.DefaultMetricCacheSize)}
 		lCache := clockcache.WithMax(100)
 		dbConn := pgxconn.NewPgxConn(db)
-		labelsReader := lreader.NewLabelsReader(dbConn, lCache)
+		labelsReader := lreader.NewLabelsReader(dbConn, lCache, querier.NewLabelsQuerier(dbConn, mCache, mt.ReadAuthorizer()))
 		qr := querier.NewQuerier(dbConn, mCache, labelsReader, nil, mt.ReadAuthorizer())
 
 		// ----- query-test: querying a non-tenant -----
@@ -531,7 +531,7 @@ func TestMultiTenancyWithValidTenantsAndNonTenantOps(t *testing.T) {
 		mt, err = tenancy.NewAuthorizer(cfg)
 		require.NoError(t, err)
 
-		labelsReader = lreader.NewLabelsReader(dbConn, lCache)
+		labelsReader = lreader.NewLabelsReader(dbConn, lCache, querier.NewLabelsQuerier(dbConn, mCache, mt.ReadAuthorizer()))
 		qr = querier.NewQuerier(dbConn, mCache, labelsReader, nil, mt.ReadAuthorizer())
 
 		expectedResult = []prompb.TimeSeries{
@@ -637,7 +637,7 @@ func TestMultiTenancyWithValidTenantsAsLabels(t *testing.T) {
 		mCache := &cache.MetricNameCache{Metrics: clockcache.WithMax(cache.DefaultMetricCacheSize)}
 		lCache := clockcache.WithMax(100)
 		dbConn := pgxconn.NewPgxConn(db)
-		labelsReader := lreader.NewLabelsReader(dbConn, lCache)
+		labelsReader := lreader.NewLabelsReader(dbConn, lCache, querier.NewLabelsQuerier(dbConn, mCache, mt.ReadAuthorizer()))
 		qr := querier.NewQuerier(dbConn, mCache, labelsReader, nil, mt.ReadAuthorizer())
 
 		// ----- query-test: querying a single tenant (tenant-b) -----
@@ -127,7 +127,7 @@ func TestSQLStaleNaN(t *testing.T) {
 			mCache := &cache.MetricNameCache{Metrics: clockcache.WithMax(cache.DefaultMetricCacheSize)}
 			lCache := clockcache.WithMax(100)
 			dbConn := pgxconn.NewPgxConn(db)
-			labelsReader := lreader.NewLabelsReader(dbConn, lCache)
+			labelsReader := lreader.NewLabelsReader(dbConn, lCache, querier.NewLabelsQuerier(dbConn, mCache, nil))
 			r := querier.NewQuerier(dbConn, mCache, labelsReader, nil, nil)
 			resp, err := r.Query(c.query)
 			if err != nil {
@@ -7,6 +7,7 @@ package end_to_end_tests
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -54,6 +55,26 @@ func getLabelValuesRequest(apiUrl string, labelName string) (*http.Request, erro
 	)
 }
 
+func getMatchedLabelsRequest(apiUrl, path, matcher string, start, end int64) (*http.Request, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s", apiUrl, path))
+
+	if err != nil {
+		return nil, err
+	}
+
+	val := url.Values{}
+	val.Add("match[]", matcher)
+	val.Add("start", fmt.Sprintf("%d", start/1000))
+	val.Add("end", fmt.Sprintf("%d", end/1000))
+	u.RawQuery = val.Encode()
+
+	return http.NewRequest(
+		"GET",
+		u.String(),
+		nil,
+	)
+}
+
 func TestPromQLLabelEndpoint(t *testing.T) {
 	if testing.Short() || !*useDocker {
 		t.Skip("skipping integration test")
@@ -110,8 +131,8 @@ func TestPromQLLabelEndpoint(t *testing.T) {
 
 		lCache := clockcache.WithMax(100)
 		dbConn := pgxconn.NewPgxConn(readOnly)
-		labelsReader := lreader.NewLabelsReader(dbConn, lCache)
-		labelNames, err := labelsReader.LabelNames()
+		labelsReader := lreader.NewLabelsReader(dbConn, lCache, nil)
+		labelNames, err := labelsReader.LabelNames(math.MinInt64, math.MaxInt64)
 		if err != nil {
 			t.Fatalf("could not get label names from querier")
 		}
@@ -130,6 +151,29 @@ func TestPromQLLabelEndpoint(t *testing.T) {
 		}
 		testMethod = testRequestConcurrent(requestCases, client, labelsResultComparator, true)
 		tester.Run("test label endpoint", testMethod)
+
+		// Label names and values restricted by matchers and time range.
+		requestCases = requestCases[:0]
+		matchers := []string{`metric_1`, `{instance="1"}`, `metric_2{foo=~"ba.*"}`, `{__name__=~"metric_.*", aaa!=""}`, `non_existent_metric`}
+		timeRanges := [][2]int64{{startTime, endTime}, {startTime, startTime + 60*1000}, {endTime + 3600*1000, endTime + 7200*1000}}
+		for _, matcher := range matchers {
+			for _, tr := range timeRanges {
+				paths := []string{"labels", "label/__name__/values", "label/instance/values", "label/foo/values"}
+				for _, path := range paths {
+					tsReq, err = getMatchedLabelsRequest(tsURL, path, matcher, tr[0], tr[1])
+					if err != nil {
+						t.Fatalf("unable to create TS PromQL matched labels request: %v", err)
+					}
+					promReq, err = getMatchedLabelsRequest(promURL, path, matcher, tr[0], tr[1])
+					if err != nil {
+						t.Fatalf("unable to create Prometheus PromQL matched labels request: %v", err)
+					}
+					requestCases = append(requestCases, requestCase{tsReq, promReq, fmt.Sprintf("get %s for %s between %d and %d", path, matcher, tr[0], tr[1])})
+				}
+			}
+		}
+		testMethod = testRequestConcurrent(requestCases, client, labelsResultComparator, true)
+		tester.Run("test label endpoint with matchers", testMethod)
 	})
 }
 
@@ -62,3 +62,34 @@ func ParseEnv(p string, fs *flag.FlagSet) error {
 
 	return err
 }
+
+// SanitizeMetricName replaces the characters that are not valid in a Prometheus
+// metric name with underscores.
+func SanitizeMetricName(name string) string {
+	return sanitize(name, func(c byte) bool {
+		return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+	})
+}
+
+// SanitizeLabelName replaces the characters that are not valid in a Prometheus
+// label name with underscores.
+func SanitizeLabelName(name string) string {
+	return sanitize(name, func(c byte) bool {
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+	})
+}
+
+// sanitize replaces invalid characters with underscores and prefixes names that
+// start with a digit with an underscore.
+func sanitize(name string, valid func(c byte) bool) string {
+	b := []byte(name)
+	for i := range b {
+		if !valid(b[i]) {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 0 && b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
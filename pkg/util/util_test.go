@@ -125,3 +125,24 @@ func TestParseEnv(t *testing.T) {
 	}
 
 }
+
+func TestSanitizeNames(t *testing.T) {
+	testCases := []struct {
+		input  string
+		metric string
+		label  string
+	}{
+		{input: "http_requests_total", metric: "http_requests_total", label: "http_requests_total"},
+		{input: "http.server-duration", metric: "http_server_duration", label: "http_server_duration"},
+		{input: "job:requests:rate5m", metric: "job:requests:rate5m", label: "job_requests_rate5m"},
+		{input: "1xx", metric: "_1xx", label: "_1xx"},
+	}
+	for _, c := range testCases {
+		if got := SanitizeMetricName(c.input); got != c.metric {
+			t.Errorf("unexpected metric name for %s: got %s, wanted %s", c.input, got, c.metric)
+		}
+		if got := SanitizeLabelName(c.input); got != c.label {
+			t.Errorf("unexpected label name for %s: got %s, wanted %s", c.input, got, c.label)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package zipkin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+type jsonSpan struct {
+	TraceID        string            `json:"traceId"`
+	ParentID       string            `json:"parentId"`
+	ID             string            `json:"id"`
+	Kind           string            `json:"kind"`
+	Name           string            `json:"name"`
+	Timestamp      uint64            `json:"timestamp"`
+	Duration       uint64            `json:"duration"`
+	LocalEndpoint  *jsonEndpoint     `json:"localEndpoint"`
+	RemoteEndpoint *jsonEndpoint     `json:"remoteEndpoint"`
+	Annotations    []jsonAnnotation  `json:"annotations"`
+	Tags           map[string]string `json:"tags"`
+}
+
+type jsonEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int32  `json:"port"`
+}
+
+type jsonAnnotation struct {
+	Timestamp uint64 `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// UnmarshalJSON decodes a list of spans in the Zipkin v2 JSON format and translates
+// them to OpenTelemetry traces.
+func UnmarshalJSON(b []byte) (pdata.Traces, error) {
+	var jsonSpans []jsonSpan
+	if err := json.Unmarshal(b, &jsonSpans); err != nil {
+		return pdata.Traces{}, fmt.Errorf("error decoding Zipkin JSON spans: %w", err)
+	}
+
+	spans := make([]span, len(jsonSpans))
+	for i, js := range jsonSpans {
+		s := &spans[i]
+		var err error
+		if s.traceID, err = decodeHexID(js.TraceID); err != nil {
+			return pdata.Traces{}, fmt.Errorf("invalid trace ID %q: %w", js.TraceID, err)
+		}
+		if s.id, err = decodeHexID(js.ID); err != nil {
+			return pdata.Traces{}, fmt.Errorf("invalid span ID %q: %w", js.ID, err)
+		}
+		if js.ParentID != "" {
+			if s.parentID, err = decodeHexID(js.ParentID); err != nil {
+				return pdata.Traces{}, fmt.Errorf("invalid parent ID %q: %w", js.ParentID, err)
+			}
+		}
+		kind, ok := spanKinds[js.Kind]
+		if !ok {
+			return pdata.Traces{}, fmt.Errorf("invalid span kind %q", js.Kind)
+		}
+		s.kind = kind
+		s.name = js.Name
+		s.timestamp = js.Timestamp
+		s.duration = js.Duration
+		s.localEndpoint = js.LocalEndpoint.endpoint()
+		s.remoteEndpoint = js.RemoteEndpoint.endpoint()
+		s.tags = js.Tags
+		for _, a := range js.Annotations {
+			s.annotations = append(s.annotations, annotation{timestamp: a.Timestamp, value: a.Value})
+		}
+	}
+	return toTraces(spans)
+}
+
+func (e *jsonEndpoint) endpoint() endpoint {
+	if e == nil {
+		return endpoint{}
+	}
+	ip := e.IPv4
+	if ip == "" {
+		ip = e.IPv6
+	}
+	return endpoint{serviceName: e.ServiceName, ip: ip, port: e.Port}
+}
+
+// decodeHexID decodes a hex encoded ID. Zipkin allows the leading zeros of IDs
+// to be omitted.
+func decodeHexID(id string) ([]byte, error) {
+	switch {
+	case len(id) == 0:
+		return nil, fmt.Errorf("missing ID")
+	case len(id) <= 16:
+		id = fmt.Sprintf("%016s", id)
+	case len(id) <= 32:
+		id = fmt.Sprintf("%032s", id)
+	default:
+		return nil, fmt.Errorf("ID longer than 32 characters")
+	}
+	return hex.DecodeString(id)
+}
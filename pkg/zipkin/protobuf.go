@@ -0,0 +1,288 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package zipkin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoSpanKinds maps the Span.Kind enum of zipkin.proto to span kinds.
+var protoSpanKinds = map[uint64]pdata.SpanKind{
+	0: pdata.SpanKindInternal,
+	1: pdata.SpanKindClient,
+	2: pdata.SpanKindServer,
+	3: pdata.SpanKindProducer,
+	4: pdata.SpanKindConsumer,
+}
+
+// UnmarshalProtobuf decodes a ListOfSpans message of the Zipkin v2 protobuf format
+// (https://github.com/openzipkin/zipkin-api/blob/master/zipkin.proto) and translates
+// the spans to OpenTelemetry traces.
+func UnmarshalProtobuf(b []byte) (pdata.Traces, error) {
+	var spans []span
+	r := protoReader{b: b}
+	for !r.done() {
+		field, wireType, err := r.tag()
+		if err != nil {
+			return pdata.Traces{}, err
+		}
+		if field != 1 || wireType != wireBytes {
+			if err = r.skip(wireType); err != nil {
+				return pdata.Traces{}, err
+			}
+			continue
+		}
+		msg, err := r.bytes()
+		if err != nil {
+			return pdata.Traces{}, err
+		}
+		s, err := decodeProtoSpan(msg)
+		if err != nil {
+			return pdata.Traces{}, fmt.Errorf("error decoding Zipkin protobuf span: %w", err)
+		}
+		spans = append(spans, s)
+	}
+	return toTraces(spans)
+}
+
+func decodeProtoSpan(b []byte) (span, error) {
+	var s span
+	r := protoReader{b: b}
+	for !r.done() {
+		field, wireType, err := r.tag()
+		if err != nil {
+			return s, err
+		}
+		switch {
+		case field == 1 && wireType == wireBytes:
+			s.traceID, err = r.bytes()
+		case field == 2 && wireType == wireBytes:
+			s.parentID, err = r.bytes()
+		case field == 3 && wireType == wireBytes:
+			s.id, err = r.bytes()
+		case field == 4 && wireType == wireVarint:
+			var kind uint64
+			if kind, err = r.varint(); err == nil {
+				s.kind = protoSpanKinds[kind]
+			}
+		case field == 5 && wireType == wireBytes:
+			var name []byte
+			name, err = r.bytes()
+			s.name = string(name)
+		case field == 6 && wireType == wireFixed64:
+			s.timestamp, err = r.fixed64()
+		case field == 7 && wireType == wireVarint:
+			s.duration, err = r.varint()
+		case (field == 8 || field == 9) && wireType == wireBytes:
+			var msg []byte
+			if msg, err = r.bytes(); err != nil {
+				break
+			}
+			if field == 8 {
+				s.localEndpoint, err = decodeProtoEndpoint(msg)
+			} else {
+				s.remoteEndpoint, err = decodeProtoEndpoint(msg)
+			}
+		case field == 10 && wireType == wireBytes:
+			var msg []byte
+			if msg, err = r.bytes(); err != nil {
+				break
+			}
+			var a annotation
+			if a, err = decodeProtoAnnotation(msg); err == nil {
+				s.annotations = append(s.annotations, a)
+			}
+		case field == 11 && wireType == wireBytes:
+			var msg []byte
+			if msg, err = r.bytes(); err != nil {
+				break
+			}
+			if s.tags == nil {
+				s.tags = make(map[string]string)
+			}
+			err = decodeProtoTag(msg, s.tags)
+		default:
+			err = r.skip(wireType)
+		}
+		if err != nil {
+			return s, err
+		}
+	}
+	if s.kind == pdata.SpanKindUnspecified {
+		s.kind = pdata.SpanKindInternal
+	}
+	return s, nil
+}
+
+func decodeProtoEndpoint(b []byte) (endpoint, error) {
+	var (
+		e          endpoint
+		ipv4, ipv6 []byte
+	)
+	r := protoReader{b: b}
+	for !r.done() {
+		field, wireType, err := r.tag()
+		if err != nil {
+			return e, err
+		}
+		switch {
+		case field == 1 && wireType == wireBytes:
+			var name []byte
+			name, err = r.bytes()
+			e.serviceName = string(name)
+		case field == 2 && wireType == wireBytes:
+			ipv4, err = r.bytes()
+		case field == 3 && wireType == wireBytes:
+			ipv6, err = r.bytes()
+		case field == 4 && wireType == wireVarint:
+			var port uint64
+			port, err = r.varint()
+			e.port = int32(port)
+		default:
+			err = r.skip(wireType)
+		}
+		if err != nil {
+			return e, err
+		}
+	}
+	if len(ipv4) > 0 {
+		e.ip = net.IP(ipv4).String()
+	} else if len(ipv6) > 0 {
+		e.ip = net.IP(ipv6).String()
+	}
+	return e, nil
+}
+
+func decodeProtoAnnotation(b []byte) (annotation, error) {
+	var a annotation
+	r := protoReader{b: b}
+	for !r.done() {
+		field, wireType, err := r.tag()
+		if err != nil {
+			return a, err
+		}
+		switch {
+		case field == 1 && wireType == wireFixed64:
+			a.timestamp, err = r.fixed64()
+		case field == 2 && wireType == wireBytes:
+			var value []byte
+			value, err = r.bytes()
+			a.value = string(value)
+		default:
+			err = r.skip(wireType)
+		}
+		if err != nil {
+			return a, err
+		}
+	}
+	return a, nil
+}
+
+// decodeProtoTag decodes an entry of the tags map.
+func decodeProtoTag(b []byte, tags map[string]string) error {
+	var key, value []byte
+	r := protoReader{b: b}
+	for !r.done() {
+		field, wireType, err := r.tag()
+		if err != nil {
+			return err
+		}
+		switch {
+		case field == 1 && wireType == wireBytes:
+			key, err = r.bytes()
+		case field == 2 && wireType == wireBytes:
+			value, err = r.bytes()
+		default:
+			err = r.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	tags[string(key)] = string(value)
+	return nil
+}
+
+// protoReader reads the fields of a protobuf message.
+type protoReader struct {
+	b []byte
+}
+
+var errTruncated = fmt.Errorf("truncated protobuf message")
+
+func (r *protoReader) done() bool {
+	return len(r.b) == 0
+}
+
+func (r *protoReader) tag() (uint64, int, error) {
+	v, err := r.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return v >> 3, int(v & 7), nil
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.b = r.b[n:]
+	return v, nil
+}
+
+func (r *protoReader) fixed64() (uint64, error) {
+	if len(r.b) < 8 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v, nil
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	l, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(r.b)) < l {
+		return nil, errTruncated
+	}
+	v := r.b[:l]
+	r.b = r.b[l:]
+	return v, nil
+}
+
+func (r *protoReader) skip(wireType int) error {
+	var err error
+	switch wireType {
+	case wireVarint:
+		_, err = r.varint()
+	case wireFixed64:
+		_, err = r.fixed64()
+	case wireBytes:
+		_, err = r.bytes()
+	case wireFixed32:
+		if len(r.b) < 4 {
+			return errTruncated
+		}
+		r.b = r.b[4:]
+	default:
+		err = fmt.Errorf("unsupported protobuf wire type %d", wireType)
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package zipkin translates spans in the Zipkin v2 JSON and protobuf formats to
+// OpenTelemetry traces.
+package zipkin
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+const (
+	serviceNameKey     = "service.name"
+	hostIPKey          = "net.host.ip"
+	hostPortKey        = "net.host.port"
+	peerServiceKey     = "peer.service"
+	peerIPKey          = "net.peer.ip"
+	peerPortKey        = "net.peer.port"
+	errorTag           = "error"
+	statusCodeTag      = "otel.status_code"
+	statusMessageTag   = "otel.status_description"
+	libraryNameTag     = "otel.library.name"
+	libraryVersionTag  = "otel.library.version"
+	microsToNanosecond = 1000
+)
+
+// span is a Zipkin v2 span, independent of its encoding.
+type span struct {
+	traceID        []byte
+	parentID       []byte
+	id             []byte
+	kind           pdata.SpanKind
+	name           string
+	timestamp      uint64 // Microseconds since the epoch.
+	duration       uint64 // Microseconds.
+	localEndpoint  endpoint
+	remoteEndpoint endpoint
+	annotations    []annotation
+	tags           map[string]string
+}
+
+type endpoint struct {
+	serviceName string
+	ip          string
+	port        int32
+}
+
+type annotation struct {
+	timestamp uint64
+	value     string
+}
+
+var spanKinds = map[string]pdata.SpanKind{
+	"":         pdata.SpanKindInternal,
+	"CLIENT":   pdata.SpanKindClient,
+	"SERVER":   pdata.SpanKindServer,
+	"PRODUCER": pdata.SpanKindProducer,
+	"CONSUMER": pdata.SpanKindConsumer,
+}
+
+// resourceKey identifies the resource and instrumentation library of a span.
+type resourceKey struct {
+	endpoint       endpoint
+	libraryName    string
+	libraryVersion string
+}
+
+// toTraces translates Zipkin spans to OpenTelemetry traces. Spans are grouped into
+// resources by their local endpoint, whose service name, IP and port become the
+// service.name, net.host.ip and net.host.port resource attributes. The remote
+// endpoint becomes the peer.service, net.peer.ip and net.peer.port span attributes.
+func toTraces(spans []span) (pdata.Traces, error) {
+	traces := pdata.NewTraces()
+	resources := make(map[endpoint]pdata.ResourceSpans)
+	libraries := make(map[resourceKey]pdata.SpanSlice)
+	for i := range spans {
+		s := &spans[i]
+		key := resourceKey{
+			endpoint:       s.localEndpoint,
+			libraryName:    s.tags[libraryNameTag],
+			libraryVersion: s.tags[libraryVersionTag],
+		}
+		slice, ok := libraries[key]
+		if !ok {
+			rs, ok := resources[key.endpoint]
+			if !ok {
+				rs = traces.ResourceSpans().AppendEmpty()
+				setEndpointAttributes(rs.Resource().Attributes(), key.endpoint, serviceNameKey, hostIPKey, hostPortKey)
+				resources[key.endpoint] = rs
+			}
+			ils := rs.InstrumentationLibrarySpans().AppendEmpty()
+			ils.InstrumentationLibrary().SetName(key.libraryName)
+			ils.InstrumentationLibrary().SetVersion(key.libraryVersion)
+			slice = ils.Spans()
+			libraries[key] = slice
+		}
+		if err := translateSpan(s, slice.AppendEmpty()); err != nil {
+			return pdata.Traces{}, err
+		}
+	}
+	return traces, nil
+}
+
+func translateSpan(s *span, dest pdata.Span) error {
+	traceID, err := toTraceID(s.traceID)
+	if err != nil {
+		return err
+	}
+	spanID, err := toSpanID(s.id)
+	if err != nil {
+		return fmt.Errorf("invalid span ID of trace %s: %w", traceID.HexString(), err)
+	}
+	dest.SetTraceID(traceID)
+	dest.SetSpanID(spanID)
+	if len(s.parentID) > 0 {
+		parentID, err := toSpanID(s.parentID)
+		if err != nil {
+			return fmt.Errorf("invalid parent ID of span %s: %w", spanID.HexString(), err)
+		}
+		dest.SetParentSpanID(parentID)
+	}
+	dest.SetName(s.name)
+	dest.SetKind(s.kind)
+	dest.SetStartTimestamp(pdata.Timestamp(s.timestamp * microsToNanosecond))
+	dest.SetEndTimestamp(pdata.Timestamp((s.timestamp + s.duration) * microsToNanosecond))
+
+	attrs := dest.Attributes()
+	for k, v := range s.tags {
+		switch k {
+		case errorTag:
+			dest.Status().SetCode(pdata.StatusCodeError)
+			// Instrumentations set the error tag to an error message or to "true".
+			if v != "" && v != "true" && dest.Status().Message() == "" {
+				dest.Status().SetMessage(v)
+			}
+		case statusCodeTag:
+			switch strings.ToUpper(v) {
+			case "ERROR":
+				dest.Status().SetCode(pdata.StatusCodeError)
+			case "OK":
+				if dest.Status().Code() != pdata.StatusCodeError {
+					dest.Status().SetCode(pdata.StatusCodeOk)
+				}
+			}
+		case statusMessageTag:
+			dest.Status().SetMessage(v)
+		case libraryNameTag, libraryVersionTag:
+			// Stored as the instrumentation library of the span.
+		default:
+			attrs.InsertString(k, v)
+		}
+	}
+	setEndpointAttributes(attrs, s.remoteEndpoint, peerServiceKey, peerIPKey, peerPortKey)
+
+	for _, a := range s.annotations {
+		event := dest.Events().AppendEmpty()
+		event.SetName(a.value)
+		event.SetTimestamp(pdata.Timestamp(a.timestamp * microsToNanosecond))
+	}
+	return nil
+}
+
+func setEndpointAttributes(attrs pdata.AttributeMap, e endpoint, serviceKey, ipKey, portKey string) {
+	if e.serviceName != "" {
+		attrs.InsertString(serviceKey, e.serviceName)
+	}
+	if e.ip != "" {
+		attrs.InsertString(ipKey, e.ip)
+	}
+	if e.port != 0 {
+		attrs.InsertInt(portKey, int64(e.port))
+	}
+}
+
+// toTraceID converts a 64 or 128 bit Zipkin trace ID. 64 bit IDs are the lower
+// half of the 128 bit OpenTelemetry trace ID.
+func toTraceID(id []byte) (pdata.TraceID, error) {
+	var b [16]byte
+	switch len(id) {
+	case 8:
+		copy(b[8:], id)
+	case 16:
+		copy(b[:], id)
+	default:
+		return pdata.InvalidTraceID(), fmt.Errorf("invalid trace ID length %d", len(id))
+	}
+	return pdata.NewTraceID(b), nil
+}
+
+func toSpanID(id []byte) (pdata.SpanID, error) {
+	var b [8]byte
+	if len(id) != len(b) {
+		return pdata.InvalidSpanID(), fmt.Errorf("invalid ID length %d", len(id))
+	}
+	copy(b[:], id)
+	return pdata.NewSpanID(b), nil
+}
@@ -0,0 +1,189 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package zipkin
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+const testJSON = `[
+  {
+    "traceId": "5af7183fb1d4cf5f",
+    "parentId": "6b221d5bc9e6496c",
+    "id": "352bff9a74ca9ad2",
+    "kind": "CLIENT",
+    "name": "get /api",
+    "timestamp": 1556604172355737,
+    "duration": 1431,
+    "localEndpoint": {"serviceName": "frontend", "ipv4": "192.168.99.1", "port": 3306},
+    "remoteEndpoint": {"serviceName": "backend", "ipv6": "::1", "port": 9000},
+    "annotations": [{"timestamp": 1556604172355800, "value": "ws"}],
+    "tags": {"http.method": "GET", "error": "connection reset", "otel.library.name": "zipkin-js"}
+  },
+  {
+    "traceId": "5af7183fb1d4cf5f",
+    "id": "6b221d5bc9e6496c",
+    "name": "get",
+    "timestamp": 1556604172355000,
+    "duration": 2000,
+    "localEndpoint": {"serviceName": "frontend", "ipv4": "192.168.99.1", "port": 3306}
+  }
+]`
+
+func TestUnmarshalJSON(t *testing.T) {
+	traces, err := UnmarshalJSON([]byte(testJSON))
+	require.NoError(t, err)
+	requireTestTraces(t, traces)
+}
+
+// requireTestTraces checks the translation of the spans of testJSON.
+func requireTestTraces(t *testing.T, traces pdata.Traces) {
+	require.Equal(t, 2, traces.SpanCount())
+	require.Equal(t, 1, traces.ResourceSpans().Len())
+
+	rs := traces.ResourceSpans().At(0)
+	require.Equal(t, map[string]interface{}{
+		"service.name":  "frontend",
+		"net.host.ip":   "192.168.99.1",
+		"net.host.port": int64(3306),
+	}, rs.Resource().Attributes().AsRaw())
+	require.Equal(t, 2, rs.InstrumentationLibrarySpans().Len())
+
+	ils := rs.InstrumentationLibrarySpans().At(0)
+	require.Equal(t, "zipkin-js", ils.InstrumentationLibrary().Name())
+	client := ils.Spans().At(0)
+	require.Equal(t, "00000000000000005af7183fb1d4cf5f", client.TraceID().HexString())
+	require.Equal(t, "352bff9a74ca9ad2", client.SpanID().HexString())
+	require.Equal(t, "6b221d5bc9e6496c", client.ParentSpanID().HexString())
+	require.Equal(t, "get /api", client.Name())
+	require.Equal(t, pdata.SpanKindClient, client.Kind())
+	require.Equal(t, pdata.Timestamp(1556604172355737000), client.StartTimestamp())
+	require.Equal(t, pdata.Timestamp(1556604172357168000), client.EndTimestamp())
+	require.Equal(t, pdata.StatusCodeError, client.Status().Code())
+	require.Equal(t, "connection reset", client.Status().Message())
+	require.Equal(t, map[string]interface{}{
+		"http.method":   "GET",
+		"peer.service":  "backend",
+		"net.peer.ip":   "::1",
+		"net.peer.port": int64(9000),
+	}, client.Attributes().AsRaw())
+	require.Equal(t, 1, client.Events().Len())
+	require.Equal(t, "ws", client.Events().At(0).Name())
+	require.Equal(t, pdata.Timestamp(1556604172355800000), client.Events().At(0).Timestamp())
+
+	ils = rs.InstrumentationLibrarySpans().At(1)
+	require.Equal(t, "", ils.InstrumentationLibrary().Name())
+	local := ils.Spans().At(0)
+	require.True(t, local.ParentSpanID().IsEmpty())
+	require.Equal(t, pdata.SpanKindInternal, local.Kind())
+	require.Equal(t, pdata.StatusCodeUnset, local.Status().Code())
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	for _, input := range []string{
+		`{`,
+		`[{"traceId": "", "id": "352bff9a74ca9ad2"}]`,
+		`[{"traceId": "5af7183fb1d4cf5f", "id": "xyz"}]`,
+		`[{"traceId": "5af7183fb1d4cf5f", "id": "352bff9a74ca9ad2352bff9a74ca9ad2"}]`,
+		`[{"traceId": "5af7183fb1d4cf5f", "id": "352bff9a74ca9ad2", "kind": "BROKER"}]`,
+	} {
+		_, err := UnmarshalJSON([]byte(input))
+		require.Error(t, err, input)
+	}
+}
+
+func TestUnmarshalProtobuf(t *testing.T) {
+	endpoint := func(service string, ipv4, ipv6 net.IP, port uint64) []byte {
+		b := proto.NewBuffer(nil)
+		encodeString(b, 1, service)
+		if ipv4 != nil {
+			encodeBytes(b, 2, ipv4.To4())
+		}
+		if ipv6 != nil {
+			encodeBytes(b, 3, ipv6.To16())
+		}
+		encodeVarint(b, 4, port)
+		return b.Bytes()
+	}
+	mapEntry := func(k, v string) []byte {
+		b := proto.NewBuffer(nil)
+		encodeString(b, 1, k)
+		encodeString(b, 2, v)
+		return b.Bytes()
+	}
+	id := func(s string) []byte {
+		b, err := decodeHexID(s)
+		require.NoError(t, err)
+		return b
+	}
+	local := endpoint("frontend", net.ParseIP("192.168.99.1"), nil, 3306)
+
+	client := proto.NewBuffer(nil)
+	encodeBytes(client, 1, id("5af7183fb1d4cf5f"))
+	encodeBytes(client, 2, id("6b221d5bc9e6496c"))
+	encodeBytes(client, 3, id("352bff9a74ca9ad2"))
+	encodeVarint(client, 4, 1)
+	encodeString(client, 5, "get /api")
+	encodeFixed64(client, 6, 1556604172355737)
+	encodeVarint(client, 7, 1431)
+	encodeBytes(client, 8, local)
+	encodeBytes(client, 9, endpoint("backend", nil, net.ParseIP("::1"), 9000))
+	annotation := proto.NewBuffer(nil)
+	encodeFixed64(annotation, 1, 1556604172355800)
+	encodeString(annotation, 2, "ws")
+	encodeBytes(client, 10, annotation.Bytes())
+	encodeBytes(client, 11, mapEntry("http.method", "GET"))
+	encodeBytes(client, 11, mapEntry("error", "connection reset"))
+	encodeBytes(client, 11, mapEntry("otel.library.name", "zipkin-js"))
+	// Unknown fields are skipped.
+	encodeVarint(client, 12, 1)
+
+	parent := proto.NewBuffer(nil)
+	encodeBytes(parent, 1, id("5af7183fb1d4cf5f"))
+	encodeBytes(parent, 3, id("6b221d5bc9e6496c"))
+	encodeString(parent, 5, "get")
+	encodeFixed64(parent, 6, 1556604172355000)
+	encodeVarint(parent, 7, 2000)
+	encodeBytes(parent, 8, local)
+
+	list := proto.NewBuffer(nil)
+	encodeBytes(list, 1, client.Bytes())
+	encodeBytes(list, 1, parent.Bytes())
+
+	traces, err := UnmarshalProtobuf(list.Bytes())
+	require.NoError(t, err)
+	requireTestTraces(t, traces)
+
+	_, err = UnmarshalProtobuf(list.Bytes()[:len(list.Bytes())-1])
+	require.Error(t, err)
+}
+
+func encodeTag(b *proto.Buffer, field, wireType uint64) {
+	_ = b.EncodeVarint(field<<3 | wireType)
+}
+
+func encodeVarint(b *proto.Buffer, field, v uint64) {
+	encodeTag(b, field, wireVarint)
+	_ = b.EncodeVarint(v)
+}
+
+func encodeFixed64(b *proto.Buffer, field, v uint64) {
+	encodeTag(b, field, wireFixed64)
+	_ = b.EncodeFixed64(v)
+}
+
+func encodeBytes(b *proto.Buffer, field uint64, v []byte) {
+	encodeTag(b, field, wireBytes)
+	_ = b.EncodeRawBytes(v)
+}
+
+func encodeString(b *proto.Buffer, field uint64, v string) {
+	encodeBytes(b, field, []byte(v))
+}